@@ -13,5 +13,17 @@ func GetCommands() []*cobra.Command {
 		flushCmd,
 		serviceCmd,
 		pairCmd,
+		statusCmd,
+		pauseCmd,
+		resumeCmd,
+		queueCmd,
+		nextCmd,
+		restoreCmd,
+		snippetCmd,
+		transformCmd,
+		tuiCmd,
+		fileCmd,
+		groupCmd,
+		policyCmd,
 	}
 } 
\ No newline at end of file
@@ -0,0 +1,471 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/clipboard"
+	"github.com/berrythewa/clipman-daemon/internal/ipc"
+	"github.com/berrythewa/clipman-daemon/internal/storage"
+	"github.com/berrythewa/clipman-daemon/internal/sync"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"go.uber.org/zap"
+)
+
+// DaemonComponents groups the parts of a running daemon that control
+// commands operate on
+type DaemonComponents struct {
+	Monitor   *clipboard.Monitor
+	Storage   *storage.BoltStorage
+	Sync      *sync.Manager            // nil when sync is disabled
+	Publisher *clipboard.SyncPublisher // nil when sync is disabled
+}
+
+// daemonControl serves control requests against a running daemon
+type daemonControl struct {
+	components DaemonComponents
+	startedAt  time.Time
+}
+
+// StartControlServer starts the control socket used by CLI commands
+// to talk to this daemon
+func StartControlServer(components DaemonComponents) (*ipc.Server, error) {
+	control := &daemonControl{
+		components: components,
+		startedAt:  time.Now(),
+	}
+
+	server := ipc.NewServer(ipc.SocketPath(cfg.GetPaths().DataDir), zapLogger)
+	server.Handle(ipc.CommandStatus, control.handleStatus)
+	server.Handle(ipc.CommandPause, control.handlePause)
+	server.Handle(ipc.CommandResume, control.handleResume)
+	server.Handle(ipc.CommandQueue, control.handleQueue)
+	server.Handle(ipc.CommandNext, control.handleNext)
+	server.Handle(ipc.CommandRestore, control.handleRestore)
+	server.Handle(ipc.CommandSnippet, control.handleSnippet)
+	server.Handle(ipc.CommandTransform, control.handleTransform)
+	server.Handle(ipc.CommandHistory, control.handleHistory)
+	server.Handle(ipc.CommandPin, control.handlePin)
+	server.Handle(ipc.CommandDelete, control.handleDelete)
+	server.Handle(ipc.CommandFile, control.handleFile)
+	server.Handle(ipc.CommandGroup, control.handleGroup)
+	server.Handle(ipc.CommandPolicy, control.handlePolicy)
+	server.Handle(ipc.CommandRevoke, control.handleRevoke)
+
+	if err := server.Start(); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// handleStatus reports the daemon state
+func (d *daemonControl) handleStatus(args json.RawMessage) (interface{}, error) {
+	status := ipc.StatusResponse{
+		PID:         os.Getpid(),
+		StartedAt:   d.startedAt,
+		DeviceID:    cfg.DeviceID,
+		Backend:     cfg.Clipboard.Backend,
+		Pause:       d.components.Monitor.GetPauseState(),
+		Queue:       d.components.Monitor.GetQueueState(),
+		SyncEnabled: d.components.Sync != nil,
+	}
+
+	if d.components.Sync != nil {
+		status.SyncConnected = d.components.Sync.IsConnected()
+		status.PeerCount = len(d.components.Sync.GetConnectedPeers())
+	}
+	if d.components.Publisher != nil {
+		status.Outbox = d.components.Publisher.OutboxState()
+	}
+
+	return status, nil
+}
+
+// handlePause pauses clipboard capture
+func (d *daemonControl) handlePause(args json.RawMessage) (interface{}, error) {
+	var pauseArgs ipc.PauseArgs
+	if err := ipc.DecodeArgs(args, &pauseArgs); err != nil {
+		return nil, err
+	}
+
+	zapLogger.Info("Pause requested over control socket",
+		zap.Duration("duration", pauseArgs.Duration),
+		zap.Bool("allow_peer_copy", pauseArgs.AllowPeerCopy))
+
+	return d.components.Monitor.Pause(pauseArgs.Duration, pauseArgs.AllowPeerCopy)
+}
+
+// handleResume resumes clipboard capture
+func (d *daemonControl) handleResume(args json.RawMessage) (interface{}, error) {
+	zapLogger.Info("Resume requested over control socket")
+	return d.components.Monitor.Resume()
+}
+
+// handleQueue starts, stops or reports the paste queue
+func (d *daemonControl) handleQueue(args json.RawMessage) (interface{}, error) {
+	var queueArgs ipc.QueueArgs
+	if err := ipc.DecodeArgs(args, &queueArgs); err != nil {
+		return nil, err
+	}
+
+	switch queueArgs.Action {
+	case ipc.QueueActionStart:
+		order := queueArgs.Order
+		if order == "" {
+			order = clipboard.QueueFIFO
+		}
+		if _, err := clipboard.ParseQueueOrder(string(order)); err != nil {
+			return nil, err
+		}
+		return d.components.Monitor.StartQueue(order), nil
+	case ipc.QueueActionStop:
+		return d.components.Monitor.StopQueue(), nil
+	case ipc.QueueActionStatus, "":
+		return d.components.Monitor.GetQueueState(), nil
+	}
+	return nil, fmt.Errorf("unknown queue action: %s", queueArgs.Action)
+}
+
+// handleNext pastes the next item from the paste queue
+func (d *daemonControl) handleNext(args json.RawMessage) (interface{}, error) {
+	content, state, err := d.components.Monitor.PasteNext()
+	if err != nil {
+		return nil, err
+	}
+
+	return ipc.NextResponse{Type: content.Type, Preview: contentPreview(content), Queue: state}, nil
+}
+
+// handleRestore writes a history item back to the clipboard
+func (d *daemonControl) handleRestore(args json.RawMessage) (interface{}, error) {
+	var restoreArgs ipc.RestoreArgs
+	if err := ipc.DecodeArgs(args, &restoreArgs); err != nil {
+		return nil, err
+	}
+
+	content, err := selectForRestore(d.components.Storage, restoreArgs)
+	if err != nil {
+		return nil, err
+	}
+	restored, err := d.components.Monitor.Restore(content)
+	if err != nil {
+		return nil, err
+	}
+	return newRestoreResponse(restored), nil
+}
+
+// handleSnippet manages the snippet library and expands snippets to the clipboard
+func (d *daemonControl) handleSnippet(args json.RawMessage) (interface{}, error) {
+	var snippetArgs ipc.SnippetArgs
+	if err := ipc.DecodeArgs(args, &snippetArgs); err != nil {
+		return nil, err
+	}
+
+	monitor := d.components.Monitor
+	switch snippetArgs.Action {
+	case ipc.SnippetActionSave:
+		if snippetArgs.Snippet == nil {
+			return nil, fmt.Errorf("no snippet to save")
+		}
+		saved, err := monitor.SaveSnippet(snippetArgs.Snippet)
+		if err != nil {
+			return nil, err
+		}
+		return ipc.SnippetResponse{Snippets: []*types.Snippet{saved}}, nil
+	case ipc.SnippetActionDelete:
+		return ipc.SnippetResponse{}, monitor.DeleteSnippet(snippetArgs.Name)
+	case ipc.SnippetActionExpand:
+		content, err := monitor.ExpandSnippet(snippetArgs.Name, snippetArgs.Values)
+		if err != nil {
+			return nil, err
+		}
+		return ipc.SnippetResponse{Expanded: string(content.Data)}, nil
+	}
+	return readSnippets(d.components.Storage, snippetArgs)
+}
+
+// handleTransform transforms the clipboard or a history item and writes the result to the clipboard
+func (d *daemonControl) handleTransform(args json.RawMessage) (interface{}, error) {
+	var transformArgs ipc.TransformArgs
+	if err := ipc.DecodeArgs(args, &transformArgs); err != nil {
+		return nil, err
+	}
+
+	var source *types.ClipboardContent
+	if transformArgs.Item != nil {
+		var err error
+		if source, err = d.components.Storage.SelectContent(*transformArgs.Item); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := d.components.Monitor.TransformContent(source, transformArgs.Transforms)
+	if err != nil {
+		return nil, err
+	}
+	return newTransformResponse(result), nil
+}
+
+// handleHistory lists history items for interactive clients
+func (d *daemonControl) handleHistory(args json.RawMessage) (interface{}, error) {
+	var options ipc.HistoryArgs
+	if err := ipc.DecodeArgs(args, &options); err != nil {
+		return nil, err
+	}
+
+	contents, err := d.components.Storage.GetHistory(options)
+	if err != nil {
+		return nil, err
+	}
+	return newHistoryItems(contents), nil
+}
+
+// handlePin pins or unpins a history item
+func (d *daemonControl) handlePin(args json.RawMessage) (interface{}, error) {
+	var pinArgs ipc.PinArgs
+	if err := ipc.DecodeArgs(args, &pinArgs); err != nil {
+		return nil, err
+	}
+
+	content, err := d.components.Storage.SelectContent(storage.ItemSelector{ID: pinArgs.ID})
+	if err != nil {
+		return nil, err
+	}
+	return nil, d.components.Storage.SetPinned(content, pinArgs.Pinned)
+}
+
+// handleDelete deletes a history item
+func (d *daemonControl) handleDelete(args json.RawMessage) (interface{}, error) {
+	var deleteArgs ipc.DeleteArgs
+	if err := ipc.DecodeArgs(args, &deleteArgs); err != nil {
+		return nil, err
+	}
+
+	content, err := d.components.Storage.SelectContent(storage.ItemSelector{ID: deleteArgs.ID})
+	if err != nil {
+		return nil, err
+	}
+	return nil, d.components.Storage.DeleteContents([]*types.ClipboardContent{content})
+}
+
+// handleFile sends files to paired devices and manages transfers
+func (d *daemonControl) handleFile(args json.RawMessage) (interface{}, error) {
+	var fileArgs ipc.FileArgs
+	if err := ipc.DecodeArgs(args, &fileArgs); err != nil {
+		return nil, err
+	}
+
+	manager := d.components.Sync
+	if manager == nil {
+		return nil, fmt.Errorf("sync is disabled, file transfers need sync")
+	}
+
+	switch fileArgs.Action {
+	case ipc.FileActionSend:
+		peerID, err := resolvePairedPeer(manager, fileArgs.Peer)
+		if err != nil {
+			return nil, err
+		}
+		transfer, err := manager.SendFile(peerID, fileArgs.Path)
+		if err != nil {
+			return nil, err
+		}
+		return ipc.FileResponse{Transfers: []sync.TransferInfo{transfer}}, nil
+	case ipc.FileActionAccept, ipc.FileActionDecline:
+		return nil, manager.RespondToFileOffer(fileArgs.ID, fileArgs.Action == ipc.FileActionAccept)
+	case ipc.FileActionCancel:
+		return nil, manager.CancelTransfer(fileArgs.ID)
+	case ipc.FileActionList, "":
+		return ipc.FileResponse{Transfers: manager.GetTransfers()}, nil
+	}
+	return nil, fmt.Errorf("unknown file action: %s", fileArgs.Action)
+}
+
+// handleGroup creates, joins, leaves and lists groups
+func (d *daemonControl) handleGroup(args json.RawMessage) (interface{}, error) {
+	var groupArgs ipc.GroupArgs
+	if err := ipc.DecodeArgs(args, &groupArgs); err != nil {
+		return nil, err
+	}
+
+	manager := d.components.Sync
+	if manager == nil {
+		return nil, fmt.Errorf("sync is disabled, groups need sync")
+	}
+
+	switch groupArgs.Action {
+	case ipc.GroupActionCreate, ipc.GroupActionJoin:
+		zapLogger.Info("Group change requested over control socket",
+			zap.String("action", groupArgs.Action),
+			zap.String("group", groupArgs.Name))
+		join := manager.AddGroup
+		if groupArgs.Action == ipc.GroupActionCreate {
+			join = manager.CreateGroup
+		}
+		group, err := join(groupArgs.Name, groupArgs.Route)
+		if err != nil {
+			return nil, err
+		}
+		return ipc.GroupResponse{Groups: []sync.GroupMembership{group}}, nil
+	case ipc.GroupActionLeave:
+		zapLogger.Info("Group change requested over control socket",
+			zap.String("action", groupArgs.Action),
+			zap.String("group", groupArgs.Name))
+		return nil, manager.RemoveGroup(groupArgs.Name)
+	case ipc.GroupActionMembers:
+		members, err := manager.GroupMembers(groupArgs.Name)
+		if err != nil {
+			return nil, err
+		}
+		return ipc.GroupResponse{Members: members}, nil
+	case ipc.GroupActionAdd:
+		peerID, err := resolvePairedPeer(manager, groupArgs.Peer)
+		if err != nil {
+			return nil, err
+		}
+		zapLogger.Info("Group member change requested over control socket",
+			zap.String("action", groupArgs.Action),
+			zap.String("group", groupArgs.Name),
+			zap.String("peer_id", peerID))
+		delivered, err := manager.AddGroupMember(groupArgs.Name, peerID)
+		if err != nil {
+			return nil, err
+		}
+		response := ipc.GroupResponse{}
+		if delivered {
+			response.Delivered = []string{peerID}
+		}
+		return response, nil
+	case ipc.GroupActionRemove:
+		// A revoked or unpaired device can still be a member, so a name that
+		// isn't paired is taken as its peer ID
+		peerID, err := resolvePairedPeer(manager, groupArgs.Peer)
+		if err != nil {
+			peerID = groupArgs.Peer
+		}
+		zapLogger.Info("Group member change requested over control socket",
+			zap.String("action", groupArgs.Action),
+			zap.String("group", groupArgs.Name),
+			zap.String("peer_id", peerID))
+		delivered, err := manager.RemoveGroupMember(groupArgs.Name, peerID)
+		if err != nil {
+			return nil, err
+		}
+		return ipc.GroupResponse{Delivered: delivered}, nil
+	case ipc.GroupActionList, "":
+		return ipc.GroupResponse{Groups: manager.Groups()}, nil
+	}
+	return nil, fmt.Errorf("unknown group action: %s", groupArgs.Action)
+}
+
+// handlePolicy sets and lists the sync policies of paired devices and groups
+func (d *daemonControl) handlePolicy(args json.RawMessage) (interface{}, error) {
+	var policyArgs ipc.PolicyArgs
+	if err := ipc.DecodeArgs(args, &policyArgs); err != nil {
+		return nil, err
+	}
+
+	manager := d.components.Sync
+	if manager == nil {
+		return nil, fmt.Errorf("sync is disabled, sync policies need sync")
+	}
+
+	switch policyArgs.Action {
+	case ipc.PolicyActionSetPeer:
+		peerID, err := resolvePairedPeer(manager, policyArgs.Peer)
+		if err != nil {
+			return nil, err
+		}
+		zapLogger.Info("Sync policy change requested over control socket",
+			zap.String("peer_id", peerID),
+			zap.String("policy", policyArgs.Policy.Describe()))
+		if err := manager.SetPeerPolicy(peerID, policyArgs.Policy); err != nil {
+			return nil, err
+		}
+	case ipc.PolicyActionSetGroup:
+		zapLogger.Info("Sync policy change requested over control socket",
+			zap.String("group", policyArgs.Group),
+			zap.String("policy", policyArgs.Policy.Describe()))
+		if err := manager.SetGroupPolicy(policyArgs.Group, policyArgs.Policy); err != nil {
+			return nil, err
+		}
+	case ipc.PolicyActionList, "":
+	default:
+		return nil, fmt.Errorf("unknown policy action: %s", policyArgs.Action)
+	}
+
+	response := ipc.PolicyResponse{Policies: manager.Policies(), DeviceNames: make(map[string]string)}
+	for _, device := range manager.GetPairedDevices() {
+		if _, ok := response.Peers[device.PeerID]; ok {
+			response.DeviceNames[device.PeerID] = device.DeviceName
+		}
+	}
+	return response, nil
+}
+
+// handleRevoke revokes a paired device
+func (d *daemonControl) handleRevoke(args json.RawMessage) (interface{}, error) {
+	var revokeArgs ipc.RevokeArgs
+	if err := ipc.DecodeArgs(args, &revokeArgs); err != nil {
+		return nil, err
+	}
+
+	manager := d.components.Sync
+	if manager == nil {
+		return nil, fmt.Errorf("sync is disabled, revoking devices needs sync")
+	}
+	peerID, err := resolvePairedPeer(manager, revokeArgs.Peer)
+	if err != nil {
+		return nil, err
+	}
+
+	zapLogger.Info("Device revocation requested over control socket", zap.String("peer_id", peerID))
+	return manager.RevokeDevice(peerID)
+}
+
+// resolvePairedPeer finds a paired device by peer ID or device name
+func resolvePairedPeer(manager *sync.Manager, nameOrID string) (string, error) {
+	if manager.IsPaired(nameOrID) {
+		return nameOrID, nil
+	}
+
+	var matches []string
+	for _, device := range manager.GetPairedDevices() {
+		if strings.EqualFold(device.DeviceName, nameOrID) {
+			matches = append(matches, device.PeerID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no paired device %q, see 'pair --list'", nameOrID)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("several paired devices are named %q, use the peer ID", nameOrID)
+}
+
+// newControlClient returns a client for the local daemon's control socket
+func newControlClient() *ipc.Client {
+	return ipc.NewClient(ipc.SocketPath(GetConfig().GetPaths().DataDir))
+}
+
+// openStorage opens the history database directly, for commands run while
+// the daemon is not running
+func openStorage() (*storage.BoltStorage, error) {
+	cfg := GetConfig()
+	paths := cfg.GetPaths()
+
+	store, err := storage.NewBoltStorage(storage.StorageConfig{
+		DBPath:   paths.DBFile,
+		BlobDir:  paths.BlobDir,
+		DeviceID: cfg.DeviceID,
+		Logger:   GetZapLogger(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	return store, nil
+}
@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/ipc"
+	"github.com/berrythewa/clipman-daemon/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// File command flags
+	fileDetach bool
+)
+
+// fileProgressInterval is how often send reports the progress of a transfer
+const fileProgressInterval = 500 * time.Millisecond
+
+// fileCmd represents the file command
+var fileCmd = &cobra.Command{
+	Use:   "file",
+	Short: "Send files to paired devices and manage transfers",
+	Long: `Send files directly to a paired device. Files are sent in chunks and
+checked with SHA-256 once received. An interrupted transfer is resumed
+from what the other device already has.
+
+Received files are saved to the download folder (sync.default_download_folder).
+When sync.require_file_confirmation is set, incoming files wait until they
+are accepted, unless the sender is listed in sync.auto_accept_from_peers.
+Files larger than sync.max_file_size_mb are refused.
+
+The daemon must be running with sync enabled.
+
+Examples:
+  # Send a file to a paired device, by name or peer ID
+  clipmand file send laptop ~/report.pdf
+
+  # List transfers and incoming files waiting for confirmation
+  clipmand file list
+
+  # Accept or decline an incoming file
+  clipmand file accept 3f9c2a...
+  clipmand file decline 3f9c2a...`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return fileListCmd.RunE(cmd, args)
+	},
+}
+
+// fileSendCmd sends a file to a paired device
+var fileSendCmd = &cobra.Command{
+	Use:   "send <device> <path>",
+	Short: "Send a file to a paired device",
+	Long: `Send a file to a paired device, given by device name or peer ID.
+Progress is shown until the transfer ends, unless --detach is given.
+Stopping the command doesn't stop the transfer, use 'file cancel'.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := filepath.Abs(args[1])
+		if err != nil {
+			return err
+		}
+
+		response, err := callFile(ipc.FileArgs{Action: ipc.FileActionSend, Peer: args[0], Path: path})
+		if err != nil {
+			return err
+		}
+		transfer := response.Transfers[0]
+		fmt.Printf("Sending %s (%s) as transfer %s.\n", transfer.FileName, formatBytes(transfer.FileSize), transfer.ID)
+		if fileDetach {
+			return nil
+		}
+		return waitForTransfer(transfer.ID)
+	},
+}
+
+// fileListCmd lists transfers
+var fileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List running and recent transfers",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		response, err := callFile(ipc.FileArgs{Action: ipc.FileActionList})
+		if err != nil {
+			return err
+		}
+
+		if len(response.Transfers) == 0 {
+			fmt.Println("No file transfers.")
+			return nil
+		}
+		for _, transfer := range response.Transfers {
+			direction, peer := "to", transfer.Recipient
+			if transfer.IsIncoming {
+				direction, peer = "from", transfer.Sender
+			}
+			fmt.Printf("%s  %s %s %s  %s\n", transfer.ID, transfer.FileName, direction, shortPeerID(peer), describeTransfer(transfer))
+		}
+		return nil
+	},
+}
+
+// fileAcceptCmd accepts an incoming file
+var fileAcceptCmd = &cobra.Command{
+	Use:   "accept <id>",
+	Short: "Accept an incoming file waiting for confirmation",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := callFile(ipc.FileArgs{Action: ipc.FileActionAccept, ID: args[0]}); err != nil {
+			return err
+		}
+		fmt.Printf("Accepted transfer %s.\n", args[0])
+		return nil
+	},
+}
+
+// fileDeclineCmd declines an incoming file
+var fileDeclineCmd = &cobra.Command{
+	Use:     "decline <id>",
+	Aliases: []string{"reject"},
+	Short:   "Decline an incoming file waiting for confirmation",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := callFile(ipc.FileArgs{Action: ipc.FileActionDecline, ID: args[0]}); err != nil {
+			return err
+		}
+		fmt.Printf("Declined transfer %s.\n", args[0])
+		return nil
+	},
+}
+
+// fileCancelCmd cancels a transfer
+var fileCancelCmd = &cobra.Command{
+	Use:   "cancel <id>",
+	Short: "Stop a running transfer",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := callFile(ipc.FileArgs{Action: ipc.FileActionCancel, ID: args[0]}); err != nil {
+			return err
+		}
+		fmt.Printf("Cancelled transfer %s.\n", args[0])
+		return nil
+	},
+}
+
+// waitForTransfer shows the progress of a transfer until it ends
+func waitForTransfer(id string) error {
+	for {
+		response, err := callFile(ipc.FileArgs{Action: ipc.FileActionList})
+		if err != nil {
+			return err
+		}
+
+		var transfer *sync.TransferInfo
+		for i := range response.Transfers {
+			if response.Transfers[i].ID == id {
+				transfer = &response.Transfers[i]
+				break
+			}
+		}
+		if transfer == nil {
+			return fmt.Errorf("transfer %s is no longer known to the daemon", id)
+		}
+
+		fmt.Printf("\r\033[K%s", describeTransfer(*transfer))
+		if transfer.Finished() {
+			fmt.Println()
+			if transfer.Status != sync.TransferCompleted {
+				return fmt.Errorf("transfer %s", transfer.Status)
+			}
+			return nil
+		}
+		time.Sleep(fileProgressInterval)
+	}
+}
+
+// describeTransfer describes the state of a transfer
+func describeTransfer(transfer sync.TransferInfo) string {
+	switch transfer.Status {
+	case sync.TransferPending:
+		if transfer.IsIncoming {
+			return "waiting for confirmation"
+		}
+		return "waiting for the other device to accept"
+	case sync.TransferInProgress:
+		percent := 100
+		if transfer.FileSize > 0 {
+			percent = int(transfer.Transferred * 100 / transfer.FileSize)
+		}
+		description := fmt.Sprintf("%d%% (%s of %s)", percent, formatBytes(transfer.Transferred), formatBytes(transfer.FileSize))
+		if transfer.Error != "" {
+			description += ", resuming"
+		}
+		return description
+	case sync.TransferCompleted:
+		if transfer.IsIncoming && transfer.Path != "" {
+			return "completed, saved to " + transfer.Path
+		}
+		return fmt.Sprintf("completed (%s)", formatBytes(transfer.FileSize))
+	case sync.TransferFailed:
+		return "failed: " + transfer.Error
+	}
+	return transfer.Status
+}
+
+// shortPeerID shortens a peer ID for listings
+func shortPeerID(id string) string {
+	if len(id) <= 12 {
+		return id
+	}
+	return "…" + id[len(id)-8:]
+}
+
+// formatBytes formats a size in bytes for display
+func formatBytes(size int64) string {
+	switch {
+	case size < 1024:
+		return fmt.Sprintf("%d B", size)
+	case size < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	case size < 1024*1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(size)/1024/1024)
+	}
+	return fmt.Sprintf("%.1f GB", float64(size)/1024/1024/1024)
+}
+
+// callFile runs a file action in the daemon
+func callFile(args ipc.FileArgs) (ipc.FileResponse, error) {
+	var response ipc.FileResponse
+	err := newControlClient().Call(ipc.CommandFile, args, &response)
+	if errors.Is(err, ipc.ErrDaemonNotRunning) {
+		return response, errors.New("file transfers need the daemon to be running")
+	}
+	return response, err
+}
+
+func init() {
+	fileSendCmd.Flags().BoolVarP(&fileDetach, "detach", "d", false, "Return once the transfer has started")
+	fileCmd.AddCommand(fileSendCmd, fileListCmd, fileAcceptCmd, fileDeclineCmd, fileCancelCmd)
+}
@@ -24,6 +24,7 @@ operation, unless the --quiet flag is used.`,
 		// Initialize storage
 		storageConfig := storage.StorageConfig{
 			DBPath:   paths.DBFile,
+			BlobDir:  paths.BlobDir,
 			DeviceID: cfg.DeviceID,
 			Logger:   zapLogger,
 			MaxSize:  cfg.Storage.MaxSize,
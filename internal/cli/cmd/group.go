@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/berrythewa/clipman-daemon/internal/ipc"
+	"github.com/berrythewa/clipman-daemon/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Group command flags
+	groupTypes []string
+	groupMatch string
+)
+
+// groupCmd represents the group command
+var groupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Create, join and leave sync groups",
+	Long: `Manage the groups this device syncs through. Devices share content
+with the other devices in the same groups. Until groups are changed, a
+device is in the default group (clipman-default) only.
+
+Group membership is kept across restarts; the daemon rejoins every group
+when it starts. Groups can be changed while the daemon is stopped, the
+changes apply when it next starts.
+
+Creating a group makes a new group key, handed only to the group's
+members. Add a paired device to a group with 'group add', it receives the
+key now or when it next connects and can then join the group. Pairing
+adds a device to the default group only. Removing a device with 'group
+remove' rotates the key without it, so it can't read the group's content
+from then on.
+
+Each group has a route selecting the content copied on this device that
+is published to it: by clipboard type (--types) and by a regular
+expression the text must match (--match). Content goes to every group it
+matches; a group without rules gets all of it. Snippets go to every group.
+
+Examples:
+  # Create a group for work devices, sharing only text and links
+  clipmand group create work --types text,url
+
+  # Join a group created on another device
+  clipmand group join family
+
+  # Change the route of a group already joined
+  clipmand group join work --match 'JIRA-[0-9]+'
+
+  # Let a paired device join the work group, and later remove it
+  clipmand group add work laptop
+  clipmand group remove work laptop
+
+  # List groups and the peers online in one
+  clipmand group list
+  clipmand group members work
+
+  # Stop syncing through a group
+  clipmand group leave clipman-default`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return groupListCmd.RunE(cmd, args)
+	},
+}
+
+// groupCreateCmd creates a group
+var groupCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a group with a new key and join it",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		response, err := callGroup(ipc.GroupArgs{Action: ipc.GroupActionCreate, Name: args[0], Route: groupRoute()})
+		if err != nil {
+			return err
+		}
+		group := response.Groups[0]
+		fmt.Printf("Created group %s, publishing %s.\n", group.Name, group.Describe())
+		fmt.Printf("Add paired devices with 'group add %s <device>' so they can join it.\n", group.Name)
+		return nil
+	},
+}
+
+// groupJoinCmd joins a group
+var groupJoinCmd = &cobra.Command{
+	Use:   "join <name>",
+	Short: "Join a group created on another device",
+	Long: `Join a group created on another device. Its key must have been
+received from a member of the group, which adds this device with 'group
+add'. Joining a group already joined replaces its route.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		response, err := callGroup(ipc.GroupArgs{Action: ipc.GroupActionJoin, Name: args[0], Route: groupRoute()})
+		if err != nil {
+			return err
+		}
+		group := response.Groups[0]
+		fmt.Printf("Joined group %s, publishing %s.\n", group.Name, group.Describe())
+		return nil
+	},
+}
+
+// groupLeaveCmd leaves a group
+var groupLeaveCmd = &cobra.Command{
+	Use:   "leave <name>",
+	Short: "Leave a group",
+	Long: `Leave a group. The group key is kept, so the group can be joined
+again later.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := callGroup(ipc.GroupArgs{Action: ipc.GroupActionLeave, Name: args[0]}); err != nil {
+			return err
+		}
+		fmt.Printf("Left group %s.\n", args[0])
+		return nil
+	},
+}
+
+// groupAddCmd adds a paired device to a group
+var groupAddCmd = &cobra.Command{
+	Use:   "add <name> <device>",
+	Short: "Add a paired device to a group",
+	Long: `Add a paired device, by name or peer ID, to a group this device has
+the key of. The device receives the group key now if it is connected, or
+when it next connects, and can then join the group.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		response, err := callGroup(ipc.GroupArgs{Action: ipc.GroupActionAdd, Name: args[0], Peer: args[1]})
+		if err != nil {
+			return err
+		}
+		if len(response.Delivered) > 0 {
+			fmt.Printf("Added %s to group %s, it received the group key.\n", args[1], args[0])
+		} else {
+			fmt.Printf("Added %s to group %s, it receives the group key when it next connects.\n", args[1], args[0])
+		}
+		return nil
+	},
+}
+
+// groupRemoveCmd removes a device from a group
+var groupRemoveCmd = &cobra.Command{
+	Use:   "remove <name> <device>",
+	Short: "Remove a device from a group, rotating the group key",
+	Long: `Remove a device, by name or peer ID, from a group. The group key is
+rotated and the new key handed to the remaining members, so the removed
+device can't read the group's content from then on. Members that are
+offline receive the new key when they next connect.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		response, err := callGroup(ipc.GroupArgs{Action: ipc.GroupActionRemove, Name: args[0], Peer: args[1]})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %s from group %s and rotated its key.\n", args[1], args[0])
+		if len(response.Delivered) > 0 {
+			fmt.Printf("The new key was handed to %d connected device%s.\n", len(response.Delivered), plural(len(response.Delivered)))
+		}
+		return nil
+	},
+}
+
+// groupListCmd lists groups
+var groupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the groups this device is in",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		response, err := callGroup(ipc.GroupArgs{Action: ipc.GroupActionList})
+		if err != nil {
+			return err
+		}
+
+		if len(response.Groups) == 0 {
+			fmt.Println("Not in any group, copied content stays on this device.")
+			return nil
+		}
+		for _, group := range response.Groups {
+			fmt.Printf("%s  %s\n", group.Name, group.Describe())
+		}
+		return nil
+	},
+}
+
+// groupMembersCmd lists the peers online in a group
+var groupMembersCmd = &cobra.Command{
+	Use:   "members <name>",
+	Short: "List the peers online in a group",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		response, err := callGroup(ipc.GroupArgs{Action: ipc.GroupActionMembers, Name: args[0]})
+		if err != nil {
+			return err
+		}
+
+		if len(response.Members) == 0 {
+			fmt.Printf("No peers online in group %s.\n", args[0])
+			return nil
+		}
+		fmt.Printf("%d peer%s online in group %s:\n", len(response.Members), plural(len(response.Members)), args[0])
+		for _, member := range response.Members {
+			if member.Name != "" {
+				fmt.Printf("  %s  %s\n", member.ID, member.Name)
+			} else {
+				fmt.Printf("  %s\n", member.ID)
+			}
+		}
+		return nil
+	},
+}
+
+// groupRoute builds a group route from the command flags
+func groupRoute() sync.GroupRoute {
+	var types []string
+	for _, t := range groupTypes {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	return sync.GroupRoute{Types: types, Match: groupMatch}
+}
+
+// callGroup runs a group action in the daemon, or on the saved groups if
+// the daemon is not running
+func callGroup(args ipc.GroupArgs) (ipc.GroupResponse, error) {
+	var response ipc.GroupResponse
+	err := newControlClient().Call(ipc.CommandGroup, args, &response)
+	if errors.Is(err, ipc.ErrDaemonNotRunning) {
+		return groupOffline(args)
+	}
+	return response, err
+}
+
+// groupOffline runs a group action without a daemon. The daemon joins or
+// leaves the groups when it next starts.
+func groupOffline(args ipc.GroupArgs) (ipc.GroupResponse, error) {
+	syncCfg := sync.LoadSyncConfig(GetConfig())
+	groups := sync.NewGroupStore(syncCfg.GroupsPath, GetZapLogger())
+
+	var (
+		group sync.GroupMembership
+		err   error
+	)
+	switch args.Action {
+	case ipc.GroupActionCreate:
+		group, err = groups.Create(sync.NewGroupKeyring(syncCfg.GroupKeysPath, GetZapLogger()), args.Name, args.Route)
+	case ipc.GroupActionJoin:
+		group, err = groups.Join(sync.NewGroupKeyring(syncCfg.GroupKeysPath, GetZapLogger()), args.Name, args.Route)
+	case ipc.GroupActionLeave:
+		return ipc.GroupResponse{}, groups.Remove(args.Name)
+	case ipc.GroupActionMembers:
+		return ipc.GroupResponse{}, errors.New("listing group members needs the daemon to be running")
+	case ipc.GroupActionAdd, ipc.GroupActionRemove:
+		return ipc.GroupResponse{}, errors.New("changing group members needs the daemon to be running")
+	case ipc.GroupActionList, "":
+		return ipc.GroupResponse{Groups: groups.List()}, nil
+	default:
+		return ipc.GroupResponse{}, fmt.Errorf("unknown group action: %s", args.Action)
+	}
+	if err != nil {
+		return ipc.GroupResponse{}, err
+	}
+	return ipc.GroupResponse{Groups: []sync.GroupMembership{group}}, nil
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{groupCreateCmd, groupJoinCmd} {
+		cmd.Flags().StringSliceVar(&groupTypes, "types", nil, "Clipboard types to publish to the group: text, image, files, url (default all)")
+		cmd.Flags().StringVar(&groupMatch, "match", "", "Regular expression the text of content must match to be published to the group")
+	}
+	groupCmd.AddCommand(groupCreateCmd, groupJoinCmd, groupLeaveCmd, groupListCmd, groupMembersCmd, groupAddCmd, groupRemoveCmd)
+}
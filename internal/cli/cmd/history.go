@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -22,12 +23,18 @@ var (
 	since      		string
 	before     		string
 	itemType   		string
+	itemSubtype		string
+	itemLanguage	string
+	searchQuery		string
 	reverse    		bool
 	minSize    		int64
 	contentMaxSize  int64
 	jsonOutput 		bool
 	dumpAll    		bool
 	mostRecent      bool
+	launcherOutput  bool
+	previewWidth    int
+	launcherIcons   bool
 )
 
 // historyCmd represents the history command
@@ -47,13 +54,35 @@ Examples:
   # Show all text items in reverse order (newest first)
   clipmand history --type text --reverse
 
+  # Show copied JSON, or Go code
+  clipmand history --subtype json
+  clipmand history --subtype code --language go
+
+  # Search text, HTML and RTF items (rich text is searched as plain text)
+  clipmand history --search "meeting notes"
+
   # Show items from a specific time range
   clipmand history --since 2023-01-01T00:00:00Z --before 2023-01-31T23:59:59Z
 
   # Show items larger than a specific size
   clipmand history --min-size 1024
+
+  # Pick an item in a launcher and restore it
+  clipmand history --launcher | rofi -dmenu | clipmand restore --stdin
+  clipmand history --launcher | fzf --with-nth 2.. | clipmand restore --stdin
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		// Launcher output works through the daemon when it is running
+		if launcherOutput {
+			historyOptions, err := historyOptionsFromFlags()
+			if err != nil {
+				return err
+			}
+			// Most recent first, as launchers select the first line
+			historyOptions.Reverse = true
+			return displayHistoryLauncher(os.Stdout, historyOptions, previewWidth, launcherIcons)
+		}
+
 		// Get all system paths
 		paths := GetConfig().GetPaths()
 		
@@ -87,6 +116,7 @@ Examples:
 		// Initialize storage
 		storageConfig := storage.StorageConfig{
 			DBPath:   paths.DBFile,
+			BlobDir:  paths.BlobDir,
 			DeviceID: GetConfig().DeviceID,
 			Logger:   GetZapLogger(),
 		}
@@ -121,70 +151,23 @@ Examples:
 		}
 		defer store.Close()
 		
-		// If --most-recent flag is set, override other settings to show just the most recent item
-		if mostRecent {
-			limit = 1
-			reverse = true
-		}
-		
-		// Configure history options
-		historyOptions := config.HistoryOptions{
-			Limit:   limit,
-			Reverse: reverse,
-			MinSize: minSize,
-		}
-		
-		if contentMaxSize > 0 {
-			historyOptions.MaxSize = contentMaxSize
-		}
-		
 		// Check if --dump-all flag is set
 		if dumpAll {
 			zapLogger.Info("Dumping complete clipboard history")
 			return store.LogCompleteHistory(config.HistoryOptions{})
 		}
 		
-		// Parse time filters
-		if since != "" {
-			sinceTime, err := time.Parse(time.RFC3339, since)
-			if err != nil {
-				return fmt.Errorf("invalid time format for --since: %v", err)
-			}
-			historyOptions.Since = sinceTime
-		}
-		
-		if before != "" {
-			beforeTime, err := time.Parse(time.RFC3339, before)
-			if err != nil {
-				return fmt.Errorf("invalid time format for --before: %v", err)
-			}
-			historyOptions.Before = beforeTime
-		}
-		
-		// Parse content type filter
-		if itemType != "" {
-			switch itemType {
-			case "text":
-				historyOptions.ContentType = types.TypeText
-			case "string":
-				historyOptions.ContentType = types.TypeString
-			case "image":
-				historyOptions.ContentType = types.TypeImage
-			case "url":
-				historyOptions.ContentType = types.TypeURL
-			case "file":
-				historyOptions.ContentType = types.TypeFile
-			case "filepath":
-				historyOptions.ContentType = types.TypeFilePath
-			default:
-				return fmt.Errorf("invalid content type: %s", itemType)
-			}
+		historyOptions, err := historyOptionsFromFlags()
+		if err != nil {
+			return err
 		}
 		
 		// Log the filter options
 		zapLogger.Info("Retrieving clipboard history with filters",
 			zap.Int64("limit", historyOptions.Limit),
 			zap.String("type", string(historyOptions.ContentType)),
+			zap.String("subtype", string(historyOptions.Subtype)),
+			zap.String("search", historyOptions.Search),
 			zap.Bool("reverse", historyOptions.Reverse),
 			zap.Int64("min_size", historyOptions.MinSize))
 		
@@ -194,7 +177,7 @@ Examples:
 		}
 		
 		// Custom display handling for most recent item
-		if mostRecent {
+		if mostRecent && historyOptions.Search == "" {
 			content, err := store.GetLatestContent()
 			if err != nil {
 				return fmt.Errorf("failed to get most recent content: %v", err)
@@ -207,7 +190,14 @@ Examples:
 			
 			fmt.Println("\n=== MOST RECENT CLIPBOARD ITEM ===")
 			fmt.Printf("Timestamp: %s\n", content.Created.Format(time.RFC3339))
+			fmt.Printf("ID: %s\n", content.ID())
 			fmt.Printf("Type: %s\n", content.Type)
+			if content.Subtype != types.SubtypeNone {
+				fmt.Printf("Subtype: %s\n", content.Subtype)
+			}
+			for key, value := range content.Metadata {
+				fmt.Printf("%s: %s\n", key, value)
+			}
 			fmt.Printf("Size: %d bytes\n", len(content.Data))
 			
 			// Format content based on type
@@ -226,13 +216,96 @@ func init() {
 	historyCmd.Flags().Int64Var(&limit, "limit", 0, "Maximum number of history entries to retrieve (0 for all)")
 	historyCmd.Flags().StringVar(&since, "since", "", "Retrieve history since this time (RFC3339 format)")
 	historyCmd.Flags().StringVar(&before, "before", "", "Retrieve history before this time (RFC3339 format)")
-	historyCmd.Flags().StringVar(&itemType, "type", "", "Filter by content type (text, image, url, file, filepath)")
+	historyCmd.Flags().StringVar(&itemType, "type", "", "Filter by content type (text, image, url, file, filepath, html, rtf)")
+	historyCmd.Flags().StringVar(&itemSubtype, "subtype", "", "Filter by content subtype (code, json, yaml, xml, email, phone, color, uuid, shell, base64)")
+	historyCmd.Flags().StringVar(&itemLanguage, "language", "", "Filter code by guessed language (e.g. go, python, javascript)")
+	historyCmd.Flags().StringVar(&searchQuery, "search", "", "Only show items containing this text (case-insensitive)")
 	historyCmd.Flags().BoolVar(&reverse, "reverse", false, "Reverse history order (newest first)")
 	historyCmd.Flags().Int64Var(&minSize, "min-size", 0, "Minimum content size in bytes")
 	historyCmd.Flags().Int64Var(&contentMaxSize, "max-size", 0, "Maximum content size in bytes")
 	historyCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output in JSON format")
 	historyCmd.Flags().BoolVar(&dumpAll, "dump-all", false, "Dump complete history without filters")
 	historyCmd.Flags().BoolVar(&mostRecent, "most-recent", false, "Show only the most recent clipboard item with full details")
+	historyCmd.Flags().BoolVar(&launcherOutput, "launcher", false, "Output one line per item for dmenu, rofi, fzf or wofi, newest first")
+	historyCmd.Flags().IntVar(&previewWidth, "preview-width", 80, "Maximum preview length in launcher output (0 for no limit)")
+	historyCmd.Flags().BoolVar(&launcherIcons, "icons", false, "Show type icons in launcher output")
+}
+
+// historyOptionsFromFlags builds history options from the filtering flags
+func historyOptionsFromFlags() (config.HistoryOptions, error) {
+	// If --most-recent flag is set, override other settings to show just the most recent item
+	if mostRecent {
+		limit = 1
+		reverse = true
+	}
+	
+	// Configure history options
+	historyOptions := config.HistoryOptions{
+		Limit:   limit,
+		Reverse: reverse,
+		MinSize: minSize,
+	}
+	
+	if contentMaxSize > 0 {
+		historyOptions.MaxSize = contentMaxSize
+	}
+	
+	// Parse time filters
+	if since != "" {
+		sinceTime, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return historyOptions, fmt.Errorf("invalid time format for --since: %v", err)
+		}
+		historyOptions.Since = sinceTime
+	}
+	
+	if before != "" {
+		beforeTime, err := time.Parse(time.RFC3339, before)
+		if err != nil {
+			return historyOptions, fmt.Errorf("invalid time format for --before: %v", err)
+		}
+		historyOptions.Before = beforeTime
+	}
+	
+	// Parse content type filter
+	if itemType != "" {
+		switch itemType {
+		case "text":
+			historyOptions.ContentType = types.TypeText
+		case "string":
+			historyOptions.ContentType = types.TypeString
+		case "image":
+			historyOptions.ContentType = types.TypeImage
+		case "url":
+			historyOptions.ContentType = types.TypeURL
+		case "file":
+			historyOptions.ContentType = types.TypeFile
+		case "filepath":
+			historyOptions.ContentType = types.TypeFilePath
+		case "html":
+			historyOptions.ContentType = types.TypeHTML
+		case "rtf":
+			historyOptions.ContentType = types.TypeRTF
+		default:
+			return historyOptions, fmt.Errorf("invalid content type: %s", itemType)
+		}
+	}
+	
+	// Parse content subtype filter
+	if itemSubtype != "" {
+		switch subtype := types.ContentSubtype(itemSubtype); subtype {
+		case types.SubtypeCode, types.SubtypeJSON, types.SubtypeYAML, types.SubtypeXML,
+			types.SubtypeEmail, types.SubtypePhone, types.SubtypeColor, types.SubtypeUUID,
+			types.SubtypeShell, types.SubtypeBase64:
+			historyOptions.Subtype = subtype
+		default:
+			return historyOptions, fmt.Errorf("invalid content subtype: %s", itemSubtype)
+		}
+	}
+	historyOptions.Language = itemLanguage
+	historyOptions.Search = searchQuery
+	
+	return historyOptions, nil
 }
 
 // displayHistoryJSON outputs history in JSON format
@@ -244,10 +317,18 @@ func displayHistoryJSON(store *storage.BoltStorage, options config.HistoryOption
 	
 	// Convert to a simpler structure for JSON output
 	type historyItem struct {
+		ID        string            `json:"id"`
 		Type      types.ContentType `json:"type"`
+		Subtype   types.ContentSubtype `json:"subtype,omitempty"`
+		Metadata  map[string]string `json:"metadata,omitempty"`
 		Timestamp string            `json:"timestamp"`
 		Size      int64               `json:"size"`
 		Content   string            `json:"content"`
+		Markdown  string            `json:"markdown,omitempty"` // Markdown rendition of HTML and RTF
+		Width     int               `json:"width,omitempty"`
+		Height    int               `json:"height,omitempty"`
+		Thumbnail []byte            `json:"thumbnail,omitempty"` // Base64-encoded PNG
+		Pinned    bool              `json:"pinned,omitempty"`
 	}
 	
 	items := make([]historyItem, 0, len(contents))
@@ -257,20 +338,30 @@ func displayHistoryJSON(store *storage.BoltStorage, options config.HistoryOption
 			if content.Type == types.TypeImage {
 				preview = "[Binary image data]"
 			} else {
+				// Rich text is previewed as its plain-text rendition
+				text := content.Text()
 				maxPreview := 100
-				if len(content.Data) <= maxPreview {
-					preview = string(content.Data)
+				if len(text) <= maxPreview {
+					preview = text
 				} else {
-					preview = string(content.Data[:maxPreview]) + "..."
+					preview = text[:maxPreview] + "..."
 				}
 			}
 		}
 		
 		items = append(items, historyItem{
+			ID:        content.ID(),
 			Type:      content.Type,
+			Subtype:   content.Subtype,
+			Metadata:  content.Metadata,
 			Timestamp: content.Created.Format(time.RFC3339),
 			Size:      int64(len(content.Data)),
 			Content:   preview,
+			Markdown:  content.Markdown,
+			Width:     atoiOrZero(content.Metadata[types.MetaWidth]),
+			Height:    atoiOrZero(content.Metadata[types.MetaHeight]),
+			Thumbnail: content.Thumbnail,
+			Pinned:    content.Pinned,
 		})
 	}
 	
@@ -291,6 +382,9 @@ func displayFormattedContent(content *types.ClipboardContent) {
 	case types.TypeImage:
 		fmt.Println("[Binary image data]")
 		fmt.Printf("Size: %d bytes\n", len(content.Data))
+		if width, height := content.Metadata[types.MetaWidth], content.Metadata[types.MetaHeight]; width != "" {
+			fmt.Printf("Dimensions: %sx%s\n", width, height)
+		}
 	
 	case types.TypeFile:
 		// For file lists, try to parse JSON and display nicely
@@ -327,11 +421,17 @@ func displayFormattedContent(content *types.ClipboardContent) {
 			fmt.Printf("  - File does not exist or is inaccessible\n")
 		}
 	
-	default: // Text content
-		fmt.Println(formatMultilineContent(string(content.Data), 100))
+	default: // Text content, HTML and RTF as their plain-text rendition
+		fmt.Println(formatMultilineContent(content.Text(), 100))
 	}
 }
 
+// atoiOrZero parses a decimal metadata value, returning 0 if it is missing or invalid
+func atoiOrZero(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
 // formatMultilineContent formats text content for display, handling newlines and truncation
 func formatMultilineContent(text string, maxLineLength int) string {
 	lines := strings.Split(text, "\n")
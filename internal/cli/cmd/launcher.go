@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/berrythewa/clipman-daemon/internal/config"
+	"github.com/berrythewa/clipman-daemon/internal/ipc"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+// Launcher output writes one line per history item, "<id>\t<preview>", for
+// picking an item in dmenu, rofi, fzf or wofi. The selected line is passed
+// back to 'clipmand restore', which only looks at the ID.
+
+// launcherTypeIcons mark the type of each item when icons are enabled
+var launcherTypeIcons = map[types.ContentType]string{
+	types.TypeText:     "📝",
+	types.TypeString:   "📝",
+	types.TypeURL:      "🔗",
+	types.TypeImage:    "🖼️",
+	types.TypeFile:     "📁",
+	types.TypeFilePath: "📄",
+	types.TypeHTML:     "🌐",
+	types.TypeRTF:      "📃",
+}
+
+// launcherCodeIcon marks text classified as code or structured data
+const launcherCodeIcon = "💻"
+
+// launcherPinnedIcon marks pinned items when icons are enabled
+const launcherPinnedIcon = "📌"
+
+// launcherHistory returns history items from the running daemon, or from the
+// database when the daemon is not running
+func launcherHistory(options config.HistoryOptions) ([]*types.ClipboardContent, error) {
+	var items []ipc.HistoryItem
+	err := newControlClient().Call(ipc.CommandHistory, options, &items)
+	if errors.Is(err, ipc.ErrDaemonNotRunning) {
+		store, err := openStorage()
+		if err != nil {
+			return nil, err
+		}
+		defer store.Close()
+		return store.GetHistory(options)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history: %w", err)
+	}
+
+	contents := make([]*types.ClipboardContent, len(items))
+	for i := range items {
+		contents[i] = &items[i].ClipboardContent
+	}
+	return contents, nil
+}
+
+// displayHistoryLauncher prints history as launcher lines
+func displayHistoryLauncher(w io.Writer, options config.HistoryOptions, width int, icons bool) error {
+	contents, err := launcherHistory(options)
+	if err != nil {
+		return err
+	}
+	for _, content := range contents {
+		if _, err := fmt.Fprintln(w, formatLauncherLine(content, width, icons)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatLauncherLine formats an item as its ID, a tab and a one-line preview
+// of at most width characters
+func formatLauncherLine(content *types.ClipboardContent, width int, icons bool) string {
+	preview := truncateLauncherText(escapeLauncherText(launcherPreview(content)), width)
+	if icons {
+		icon := launcherTypeIcons[content.Type]
+		if icon == "" {
+			icon = "❔"
+		}
+		switch content.Subtype {
+		case types.SubtypeCode, types.SubtypeJSON, types.SubtypeYAML, types.SubtypeXML, types.SubtypeShell:
+			icon = launcherCodeIcon
+		}
+		if content.Pinned {
+			icon = launcherPinnedIcon + icon
+		}
+		preview = icon + " " + preview
+	}
+	return content.ID() + "\t" + preview
+}
+
+// launcherPreview describes an item in text, with a summary for images and file lists
+func launcherPreview(content *types.ClipboardContent) string {
+	switch content.Type {
+	case types.TypeImage:
+		description := "[image"
+		if width, height := content.Metadata[types.MetaWidth], content.Metadata[types.MetaHeight]; width != "" {
+			description += " " + width + "x" + height
+		}
+		if format := content.Metadata[types.MetaImageFormat]; format != "" {
+			description += " " + format
+		}
+		return description + "]"
+	case types.TypeFile:
+		var files []string
+		if err := json.Unmarshal(content.Data, &files); err != nil || len(files) == 0 {
+			break
+		}
+		names := make([]string, len(files))
+		for i, file := range files {
+			names[i] = filepath.Base(file)
+		}
+		if len(files) == 1 {
+			return "[1 file] " + names[0]
+		}
+		return fmt.Sprintf("[%d files] %s", len(files), strings.Join(names, ", "))
+	}
+	return strings.TrimSpace(content.Text())
+}
+
+// escapeLauncherText keeps text on one line, escaping backslashes, line
+// breaks, tabs and other control characters
+func escapeLauncherText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case unicode.IsControl(r):
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// truncateLauncherText shortens text to at most width characters, 0 meaning no limit
+func truncateLauncherText(text string, width int) string {
+	runes := []rune(text)
+	if width <= 0 || len(runes) <= width {
+		return text
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// parseLauncherLine returns the item ID at the start of a launcher line
+func parseLauncherLine(line string) (string, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", errors.New("no item selected")
+	}
+	return fields[0], nil
+}
+
+// readLauncherLine reads the selected line from a launcher's output
+func readLauncherLine(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read selection: %w", err)
+	}
+	return parseLauncherLine(line)
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+func TestEscapeLauncherText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain", "hello world", "hello world"},
+		{"line breaks", "one\ntwo\r\nthree", `one\ntwo\r\nthree`},
+		{"tab", "key\tvalue", `key\tvalue`},
+		{"backslash", `C:\Users`, `C:\\Users`},
+		{"escaped sequence stays distinct", `a\nb`, `a\\nb`},
+		{"terminal escape", "\x1b[31mred\x1b[0m", `\x1b[31mred\x1b[0m`},
+		{"bell and delete", "\a\x7f", `\x07\x7f`},
+		{"C1 control", "a\u009bb", `a\x9bb`},
+		{"unicode", "naïve 📋", "naïve 📋"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := escapeLauncherText(tt.text)
+			if got != tt.want {
+				t.Errorf("escapeLauncherText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+			if strings.ContainsAny(got, "\n\r\t") {
+				t.Errorf("escaped text %q isn't a single field", got)
+			}
+		})
+	}
+}
+
+func TestTruncateLauncherText(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  string
+	}{
+		{"short", 10, "short"},
+		{"exactly", 7, "exactly"},
+		{"truncated text", 6, "trunc…"},
+		{"no limit", 0, "no limit"},
+		{"ab", 1, "…"},
+		{"ünïcödé", 4, "ünï…"},
+	}
+	for _, tt := range tests {
+		if got := truncateLauncherText(tt.text, tt.width); got != tt.want {
+			t.Errorf("truncateLauncherText(%q, %d) = %q, want %q", tt.text, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestParseLauncherLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    string
+		wantErr bool
+	}{
+		{"17a2b3c4d5e6f708\tsome text\n", "17a2b3c4d5e6f708", false},
+		{"17a2b3c4d5e6f708", "17a2b3c4d5e6f708", false},
+		{"  17a2b3c4d5e6f708 \t📝 text", "17a2b3c4d5e6f708", false},
+		{"", "", true},
+		{" \n", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseLauncherLine(tt.line)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseLauncherLine(%q) = %q, %v; want %q", tt.line, got, err, tt.want)
+		}
+	}
+}
+
+func TestLauncherLineRoundTrip(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	contents := []*types.ClipboardContent{
+		{Type: types.TypeText, Data: []byte("multi\nline\ttext"), Created: created},
+		{Type: types.TypeText, Data: []byte("\tleading tab and \x1b[2J escape"), Created: created.Add(time.Second)},
+		{Type: types.TypeURL, Data: []byte("https://example.com"), Created: created.Add(2 * time.Second), Pinned: true},
+		{Type: types.TypeFile, Data: []byte(`["/tmp/a.txt","/tmp/b.txt"]`), Created: created.Add(3 * time.Second)},
+		{Type: types.TypeImage, Data: []byte{0x89, 'P', 'N', 'G'}, Created: created.Add(4 * time.Second),
+			Metadata: map[string]string{types.MetaWidth: "640", types.MetaHeight: "480", types.MetaImageFormat: "png"}},
+	}
+	for _, content := range contents {
+		for _, icons := range []bool{false, true} {
+			line := formatLauncherLine(content, 20, icons)
+			if strings.ContainsAny(line, "\n\r\x1b") || strings.Count(line, "\t") != 1 {
+				t.Errorf("line %q isn't a single ID and preview", line)
+			}
+			id, err := readLauncherLine(strings.NewReader(line + "\n"))
+			if err != nil || id != content.ID() {
+				t.Errorf("selected line %q gave ID %q (%v), want %q", line, id, err, content.ID())
+			}
+		}
+	}
+
+	if line := formatLauncherLine(contents[3], 0, false); !strings.HasSuffix(line, "\t[2 files] a.txt, b.txt") {
+		t.Errorf("file list line = %q", line)
+	}
+	if line := formatLauncherLine(contents[4], 0, false); !strings.HasSuffix(line, "\t[image 640x480 png]") {
+		t.Errorf("image line = %q", line)
+	}
+}
@@ -3,12 +3,14 @@ package cmd
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	// "strconv"
 	"strings"
 	"time"
 
+	"github.com/berrythewa/clipman-daemon/internal/ipc"
 	"github.com/berrythewa/clipman-daemon/internal/types"
 	"github.com/berrythewa/clipman-daemon/internal/sync"
 	"github.com/spf13/cobra"
@@ -37,7 +39,7 @@ This command allows you to:
 - Enable pairing mode to receive pairing requests
 - Initiate pairing with another device
 - List paired devices
-- Remove paired devices
+- Revoke paired devices
 
 Pairing is the most secure approach for device discovery, as it requires
 explicit user confirmation and verification codes to establish trust.
@@ -52,7 +54,9 @@ Examples:
   # List paired devices:
   clipman pair --list
 
-  # Remove a paired device:
+  # Revoke a paired device. It is removed from your other paired devices
+  # too, which report whether they acknowledged it, and can no longer
+  # connect; group keys are rotated so it can't read group traffic:
   clipman pair --remove "QmHashOfThePeer"`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if listPaired {
@@ -280,11 +284,22 @@ func listPairedDevices() error {
 
 	fmt.Printf("Found %d paired devices:\n\n", len(devices))
 	
+	names := make(map[string]string, len(devices))
+	for _, device := range devices {
+		names[device.PeerID] = device.DeviceName
+	}
 	for i, device := range devices {
 		fmt.Printf("%d. Device: %s (%s)\n", i+1, device.DeviceName, device.DeviceType)
 		fmt.Printf("   Peer ID: %s\n", device.PeerID)
 		fmt.Printf("   Paired: %s\n", formatRelativeTime(device.PairedAt))
 		fmt.Printf("   Last seen: %s\n", formatRelativeTime(device.LastSeen))
+		if len(device.RevocationRequestedBy) > 0 {
+			by := make([]string, 0, len(device.RevocationRequestedBy))
+			for _, id := range device.RevocationRequestedBy {
+				by = append(by, describePairedDevice(id, names))
+			}
+			fmt.Printf("   ⚠️ Revoked on %s, confirm with: clipman pair --remove %s\n", strings.Join(by, ", "), device.PeerID)
+		}
 		fmt.Println()
 	}
 	
@@ -301,49 +316,10 @@ func listPairedDevices() error {
 	return nil
 }
 
-// removePairedDevice removes a paired device by peer ID
-func removePairedDevice(peerID string) error {
-	// Get sync manager
-	syncManager, err := getSyncManager()
-	if err != nil {
-		return err
-	}
-
-	// Start the sync manager if needed
-	if err := syncManager.Start(); err != nil {
-		return fmt.Errorf("failed to start sync manager: %w", err)
-	}
-	defer syncManager.Stop()
-
-	// Check if device is paired
-	if !syncManager.IsPaired(peerID) {
-		// If the device isn't paired, provide list of available paired devices
-		devices := syncManager.GetPairedDevices()
-		if len(devices) == 0 {
-			return fmt.Errorf("no paired devices found - you don't have any devices to remove")
-		}
-		
-		fmt.Println("⚠️ The specified device ID is not paired")
-		fmt.Println("\nHere are your paired devices:")
-		for i, device := range devices {
-			fmt.Printf("%d. %s (%s)\n", i+1, device.DeviceName, device.PeerID)
-		}
-		
-		return fmt.Errorf("device with ID %s is not paired", peerID)
-	}
-
-	// Get device info for better feedback
-	var deviceName string
-	devices := syncManager.GetPairedDevices()
-	for _, device := range devices {
-		if device.PeerID == peerID {
-			deviceName = device.DeviceName
-			break
-		}
-	}
-
+// removePairedDevice revokes a paired device, through the daemon if it is running
+func removePairedDevice(nameOrID string) error {
 	// Confirm removal
-	fmt.Printf("Are you sure you want to remove the paired device '%s' (%s)? (y/n): ", deviceName, peerID)
+	fmt.Printf("Are you sure you want to revoke the paired device '%s'? It will be removed from your other paired devices too and group keys rotated. (y/n): ", nameOrID)
 	reader := bufio.NewReader(os.Stdin)
 	response, err := reader.ReadString('\n')
 	if err != nil {
@@ -356,24 +332,80 @@ func removePairedDevice(peerID string) error {
 		return nil
 	}
 
-	// Remove the device
-	if err := syncManager.RemovePairedDevice(peerID); err != nil {
-		return fmt.Errorf("failed to remove device: %w", err)
+	var result ipc.RevokeResponse
+	err = newControlClient().Call(ipc.CommandRevoke, ipc.RevokeArgs{Peer: nameOrID}, &result)
+	if errors.Is(err, ipc.ErrDaemonNotRunning) {
+		result, err = revokeOffline(nameOrID)
 	}
-
-	fmt.Printf("Successfully removed paired device '%s' (%s)\n", deviceName, peerID)
-	
-	// Verify the device was actually removed
-	if syncManager.IsPaired(peerID) {
-		fmt.Println("\n⚠️ Warning: The device still appears to be paired despite removal attempt")
-		fmt.Println("This could indicate a persistence issue in the sync system")
-	} else {
-		fmt.Println("Device removal confirmed")
+	if err != nil {
+		return fmt.Errorf("failed to revoke device: %w", err)
 	}
-	
+
+	printRevocation(result)
 	return nil
 }
 
+// revokeOffline revokes a paired device without a daemon. Paired devices
+// not reached now are told, and receive the new group keys, when the daemon
+// next connects to them.
+func revokeOffline(nameOrID string) (ipc.RevokeResponse, error) {
+	syncManager, err := getSyncManager()
+	if err != nil {
+		return ipc.RevokeResponse{}, err
+	}
+	manager, ok := syncManager.(*sync.Manager)
+	if !ok {
+		return ipc.RevokeResponse{}, fmt.Errorf("sync manager can't revoke devices")
+	}
+
+	// Start the sync manager if needed
+	if err := manager.Start(); err != nil {
+		return ipc.RevokeResponse{}, fmt.Errorf("failed to start sync manager: %w", err)
+	}
+	defer manager.Stop()
+
+	peerID, err := resolvePairedPeer(manager, nameOrID)
+	if err != nil {
+		return ipc.RevokeResponse{}, err
+	}
+	return manager.RevokeDevice(peerID)
+}
+
+// printRevocation reports a revocation and which paired devices acknowledged it
+func printRevocation(result ipc.RevokeResponse) {
+	fmt.Printf("Revoked paired device %s, it can no longer connect to this device.\n",
+		describePairedDevice(result.PeerID, map[string]string{result.PeerID: result.DeviceName}))
+
+	if len(result.RotatedGroups) > 0 {
+		fmt.Printf("Rotated the key of %d group%s (%s), delivered to %d device%s.\n",
+			len(result.RotatedGroups), plural(len(result.RotatedGroups)), strings.Join(result.RotatedGroups, ", "),
+			len(result.KeysDelivered), plural(len(result.KeysDelivered)))
+	}
+
+	if len(result.Acknowledged) == 0 && len(result.Confirming) == 0 && len(result.Pending) == 0 {
+		fmt.Println("No other paired devices to tell.")
+		return
+	}
+	if len(result.Acknowledged) > 0 {
+		fmt.Printf("Acknowledged by %d device%s:\n", len(result.Acknowledged), plural(len(result.Acknowledged)))
+		for _, id := range result.Acknowledged {
+			fmt.Printf("  %s\n", describePairedDevice(id, result.DeviceNames))
+		}
+	}
+	if len(result.Confirming) > 0 {
+		fmt.Printf("Paired with it themselves, left to confirm there with 'pair --remove' (%d device%s):\n", len(result.Confirming), plural(len(result.Confirming)))
+		for _, id := range result.Confirming {
+			fmt.Printf("  %s\n", describePairedDevice(id, result.DeviceNames))
+		}
+	}
+	if len(result.Pending) > 0 {
+		fmt.Printf("Not reached, told when they next connect (%d device%s):\n", len(result.Pending), plural(len(result.Pending)))
+		for _, id := range result.Pending {
+			fmt.Printf("  %s\n", describePairedDevice(id, result.DeviceNames))
+		}
+	}
+}
+
 // getSyncManager gets the sync manager from the current service
 func getSyncManager() (types.SyncManager, error) {
 	// Create a new sync manager instance directly
@@ -432,7 +464,7 @@ func init() {
 	// Define flags for the pair command
 	pairCmd.Flags().StringVar(&pairRequest, "request", "", "Request secure pairing with the device at the specified address")
 	pairCmd.Flags().BoolVar(&listPaired, "list", false, "List all securely paired devices")
-	pairCmd.Flags().StringVar(&removePair, "remove", "", "Remove a securely paired device by Peer ID")
+	pairCmd.Flags().StringVar(&removePair, "remove", "", "Revoke a paired device by peer ID or device name, on your other paired devices too")
 	pairCmd.Flags().BoolVar(&acceptAll, "auto-accept", false, "Automatically accept all pairing requests (use with caution)")
 	pairCmd.Flags().IntVar(&timeout, "timeout", 0, "Timeout in seconds for pairing mode (0 = no timeout)")
 } 
\ No newline at end of file
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/clipboard"
+	"github.com/berrythewa/clipman-daemon/internal/ipc"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Pause command flags
+	pauseAllowPeers bool
+)
+
+// pauseCmd represents the pause command
+var pauseCmd = &cobra.Command{
+	Use:   "pause [duration]",
+	Short: "Pause clipboard capture (incognito mode)",
+	Long: `Temporarily stop capturing clipboard changes, for example while
+handling passwords. While paused nothing is stored in history or synced
+to other devices.
+
+An optional duration resumes capture automatically once it has elapsed.
+Without a duration, capture stays paused until 'clipmand resume'.
+The pause survives daemon restarts.
+
+Examples:
+  # Pause until resumed
+  clipmand pause
+
+  # Pause for 5 minutes
+  clipmand pause 5m
+
+  # Pause, but keep copying content received from peers to the clipboard
+  clipmand pause 1h --allow-peers`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var duration time.Duration
+		if len(args) == 1 {
+			var err error
+			duration, err = time.ParseDuration(args[0])
+			if err != nil || duration <= 0 {
+				return fmt.Errorf("invalid duration %q, use e.g. 30s, 5m or 1h", args[0])
+			}
+		}
+
+		var state clipboard.PauseState
+		err := newControlClient().Call(ipc.CommandPause, ipc.PauseArgs{
+			Duration:      duration,
+			AllowPeerCopy: pauseAllowPeers,
+		}, &state)
+
+		if errors.Is(err, ipc.ErrDaemonNotRunning) {
+			// Persist it so the daemon starts paused
+			state = clipboard.NewPauseState(duration, pauseAllowPeers)
+			if err := clipboard.SavePauseState(pauseStatePath(), state); err != nil {
+				return err
+			}
+			fmt.Println("Daemon is not running; capture will start paused.")
+		} else if err != nil {
+			return fmt.Errorf("failed to pause: %w", err)
+		}
+
+		fmt.Println(describePauseState(state))
+		return nil
+	},
+}
+
+// resumeCmd represents the resume command
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume clipboard capture after a pause",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var state clipboard.PauseState
+		err := newControlClient().Call(ipc.CommandResume, nil, &state)
+
+		if errors.Is(err, ipc.ErrDaemonNotRunning) {
+			state = clipboard.PauseState{}
+			if err := clipboard.SavePauseState(pauseStatePath(), state); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return fmt.Errorf("failed to resume: %w", err)
+		}
+
+		fmt.Println(describePauseState(state))
+		return nil
+	},
+}
+
+// pauseStatePath returns where the daemon persists its pause state
+func pauseStatePath() string {
+	return clipboard.PauseStatePath(GetConfig().GetPaths().DataDir)
+}
+
+// describePauseState formats a pause state for display
+func describePauseState(state clipboard.PauseState) string {
+	if !state.Paused {
+		return "Clipboard capture is active."
+	}
+
+	description := "Clipboard capture is paused"
+	if state.Until.IsZero() {
+		description += " until resumed"
+	} else {
+		description += fmt.Sprintf(" for %s more (until %s)",
+			state.Remaining().Round(time.Second), state.Until.Format("15:04:05"))
+	}
+	if state.AllowPeerCopy {
+		description += "; content from peers is still copied"
+	}
+	return description + "."
+}
+
+func init() {
+	pauseCmd.Flags().BoolVar(&pauseAllowPeers, "allow-peers", false, "Keep copying content received from peers to the clipboard (if auto-copy is enabled)")
+}
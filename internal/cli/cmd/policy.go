@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/berrythewa/clipman-daemon/internal/ipc"
+	"github.com/berrythewa/clipman-daemon/internal/sync"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Policy command flags
+	policyTypes      []string
+	policyMaxSizeKB  int
+	policyDirection  string
+	policyQuietHours string
+	policyClear      bool
+)
+
+// policyFlags are the flags that set a policy
+var policyFlags = []string{"types", "max-size-kb", "direction", "quiet-hours", "clear"}
+
+// policyCmd represents the policy command
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Limit what is synced with paired devices and groups",
+	Long: `Set sync policies for paired devices and groups. A policy limits the
+content synced, on top of sync.clipboard_types and sync.max_clipboard_size_kb:
+
+  --types        Clipboard types synced: text, image, files, url (URLs count as text)
+  --max-size-kb  Largest content synced
+  --direction    send, receive or both
+  --quiet-hours  Local time range nothing is synced in, e.g. 22:00-07:00
+
+Policies apply both when content is published and when it is received.
+Content is synced with a device in a group only when the policies of both
+allow it. Setting a policy replaces the previous one, --clear removes it.
+Snippets are synced whatever the types.
+
+Examples:
+  # Receive only text and URLs from the home desktop
+  clipmand policy peer home-desktop --types text --direction receive
+
+  # No syncing through the work group at night
+  clipmand policy group work --quiet-hours 19:00-08:00
+
+  # List policies, and remove one
+  clipmand policy list
+  clipmand policy peer home-desktop --clear`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return policyListCmd.RunE(cmd, args)
+	},
+}
+
+// policyPeerCmd sets the policy of a paired device
+var policyPeerCmd = &cobra.Command{
+	Use:   "peer <device>",
+	Short: "Show or set the sync policy of a paired device",
+	Long: `Show or set the sync policy of a paired device, given by device name or
+peer ID. While the daemon is not running, use the peer ID.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		policyArgs := ipc.PolicyArgs{Action: ipc.PolicyActionList}
+		if policyFlagsChanged(cmd) {
+			policyArgs = ipc.PolicyArgs{Action: ipc.PolicyActionSetPeer, Peer: args[0], Policy: flagPolicy()}
+		}
+		response, err := callPolicy(policyArgs)
+		if err != nil {
+			return err
+		}
+
+		for id, policy := range response.Peers {
+			if id == args[0] || strings.EqualFold(response.DeviceNames[id], args[0]) {
+				fmt.Printf("%s: %s\n", describePairedDevice(id, response.DeviceNames), policy.Describe())
+				return nil
+			}
+		}
+		fmt.Printf("%s: no restrictions\n", args[0])
+		return nil
+	},
+}
+
+// policyGroupCmd sets the policy of a group
+var policyGroupCmd = &cobra.Command{
+	Use:   "group <name>",
+	Short: "Show or set the sync policy of a group",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		policyArgs := ipc.PolicyArgs{Action: ipc.PolicyActionList}
+		if policyFlagsChanged(cmd) {
+			policyArgs = ipc.PolicyArgs{Action: ipc.PolicyActionSetGroup, Group: args[0], Policy: flagPolicy()}
+		}
+		response, err := callPolicy(policyArgs)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Group %s: %s\n", args[0], response.Groups[args[0]].Describe())
+		return nil
+	},
+}
+
+// policyListCmd lists policies
+var policyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the sync policies of paired devices and groups",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		response, err := callPolicy(ipc.PolicyArgs{Action: ipc.PolicyActionList})
+		if err != nil {
+			return err
+		}
+
+		if len(response.Peers) == 0 && len(response.Groups) == 0 {
+			fmt.Println("No sync policies, everything allowed by the sync settings is synced.")
+			return nil
+		}
+		if len(response.Peers) > 0 {
+			fmt.Println("Paired devices:")
+			for _, id := range sortedKeys(response.Peers) {
+				fmt.Printf("  %s: %s\n", describePairedDevice(id, response.DeviceNames), response.Peers[id].Describe())
+			}
+		}
+		if len(response.Groups) > 0 {
+			fmt.Println("Groups:")
+			for _, name := range sortedKeys(response.Groups) {
+				fmt.Printf("  %s: %s\n", name, response.Groups[name].Describe())
+			}
+		}
+		return nil
+	},
+}
+
+// policyFlagsChanged reports whether any flag setting a policy was given
+func policyFlagsChanged(cmd *cobra.Command) bool {
+	for _, name := range policyFlags {
+		if cmd.Flags().Changed(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// flagPolicy builds a sync policy from the command flags
+func flagPolicy() sync.SyncPolicy {
+	if policyClear {
+		return sync.SyncPolicy{}
+	}
+	var types []string
+	for _, t := range policyTypes {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	return sync.SyncPolicy{
+		Types:      types,
+		MaxSizeKB:  policyMaxSizeKB,
+		Direction:  policyDirection,
+		QuietHours: policyQuietHours,
+	}
+}
+
+// describePairedDevice names a paired device in listings
+func describePairedDevice(id string, names map[string]string) string {
+	if name := names[id]; name != "" {
+		return fmt.Sprintf("%s (%s)", name, shortPeerID(id))
+	}
+	return id
+}
+
+// sortedKeys returns the keys of a policy map in order
+func sortedKeys(policies map[string]sync.SyncPolicy) []string {
+	keys := make([]string, 0, len(policies))
+	for key := range policies {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// callPolicy runs a policy action in the daemon, or on the saved policies
+// if the daemon is not running
+func callPolicy(args ipc.PolicyArgs) (ipc.PolicyResponse, error) {
+	var response ipc.PolicyResponse
+	err := newControlClient().Call(ipc.CommandPolicy, args, &response)
+	if errors.Is(err, ipc.ErrDaemonNotRunning) {
+		return policyOffline(args)
+	}
+	return response, err
+}
+
+// policyOffline runs a policy action without a daemon, which picks up the
+// changes when it next reads the policies
+func policyOffline(args ipc.PolicyArgs) (ipc.PolicyResponse, error) {
+	policies := sync.NewPolicyStore(sync.LoadSyncConfig(GetConfig()).PoliciesPath, GetZapLogger())
+
+	switch args.Action {
+	case ipc.PolicyActionSetPeer:
+		if _, err := peer.Decode(args.Peer); err != nil {
+			return ipc.PolicyResponse{}, fmt.Errorf("invalid peer ID %q: device names need the daemon to be running", args.Peer)
+		}
+		if err := policies.SetPeer(args.Peer, args.Policy); err != nil {
+			return ipc.PolicyResponse{}, err
+		}
+	case ipc.PolicyActionSetGroup:
+		if err := policies.SetGroup(args.Group, args.Policy); err != nil {
+			return ipc.PolicyResponse{}, err
+		}
+	case ipc.PolicyActionList, "":
+	default:
+		return ipc.PolicyResponse{}, fmt.Errorf("unknown policy action: %s", args.Action)
+	}
+	return ipc.PolicyResponse{Policies: policies.All()}, nil
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{policyPeerCmd, policyGroupCmd} {
+		cmd.Flags().StringSliceVar(&policyTypes, "types", nil, "Clipboard types synced: text, image, files, url (default all)")
+		cmd.Flags().IntVar(&policyMaxSizeKB, "max-size-kb", 0, "Largest content synced in KB (default no limit beyond sync.max_clipboard_size_kb)")
+		cmd.Flags().StringVar(&policyDirection, "direction", "", "Direction content is synced in: send, receive or both (default both)")
+		cmd.Flags().StringVar(&policyQuietHours, "quiet-hours", "", "Local time range nothing is synced in, e.g. 22:00-07:00")
+		cmd.Flags().BoolVar(&policyClear, "clear", false, "Remove the policy")
+	}
+	policyCmd.AddCommand(policyListCmd, policyPeerCmd, policyGroupCmd)
+}
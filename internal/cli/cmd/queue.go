@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/berrythewa/clipman-daemon/internal/clipboard"
+	"github.com/berrythewa/clipman-daemon/internal/ipc"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Queue command flags
+	queueLIFO bool
+)
+
+// queueCmd represents the queue command
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Collect copies in a paste queue and paste them one by one",
+	Long: `Queue mode collects everything you copy so you can paste the items in
+order somewhere else. Start the queue, copy the items, then run
+'clipmand next' before each paste to put the next item on the clipboard.
+
+Items are still stored in history as usual. The queue lives in the
+running daemon and is cleared when it stops.
+
+Examples:
+  # Start queueing copies, pasting them in the order they were copied
+  clipmand queue start
+
+  # Paste the most recent copy first
+  clipmand queue start --lifo
+
+  # Put the next queued item on the clipboard
+  clipmand next
+
+  # Show progress, or stop and drop what's left
+  clipmand queue
+  clipmand queue stop`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runQueueAction(ipc.QueueArgs{Action: ipc.QueueActionStatus})
+	},
+}
+
+// queueStartCmd starts queue mode
+var queueStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start queueing copies, clearing any previous queue",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		order := clipboard.QueueFIFO
+		if queueLIFO {
+			order = clipboard.QueueLIFO
+		}
+		return runQueueAction(ipc.QueueArgs{Action: ipc.QueueActionStart, Order: order})
+	},
+}
+
+// queueStopCmd stops queue mode
+var queueStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop queueing copies and drop the queued items",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runQueueAction(ipc.QueueArgs{Action: ipc.QueueActionStop})
+	},
+}
+
+// nextCmd represents the next command
+var nextCmd = &cobra.Command{
+	Use:   "next",
+	Short: "Put the next item from the paste queue on the clipboard",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var response ipc.NextResponse
+		err := newControlClient().Call(ipc.CommandNext, nil, &response)
+		if errors.Is(err, ipc.ErrDaemonNotRunning) {
+			return fmt.Errorf("the paste queue needs a running daemon: %w", err)
+		} else if err != nil {
+			return err
+		}
+
+		fmt.Printf("Pasted: %s\n", response.Preview)
+		fmt.Println(describeQueueState(response.Queue))
+		return nil
+	},
+}
+
+// runQueueAction sends a queue action to the daemon and prints the resulting state
+func runQueueAction(args ipc.QueueArgs) error {
+	var state clipboard.QueueState
+	err := newControlClient().Call(ipc.CommandQueue, args, &state)
+	if errors.Is(err, ipc.ErrDaemonNotRunning) {
+		return fmt.Errorf("the paste queue needs a running daemon: %w", err)
+	} else if err != nil {
+		return fmt.Errorf("failed to %s queue: %w", args.Action, err)
+	}
+
+	fmt.Println(describeQueueState(state))
+	return nil
+}
+
+// describeQueueState formats a paste queue state for display
+func describeQueueState(state clipboard.QueueState) string {
+	if !state.Active {
+		return "Paste queue is off."
+	}
+
+	order := "in copy order"
+	if state.Order == clipboard.QueueLIFO {
+		order = "newest first"
+	}
+	return fmt.Sprintf("Paste queue is on (%s): %d pasted, %d left.", order, state.Pasted, state.Queued)
+}
+
+// truncatePreview shortens text to one line of at most max characters
+func truncatePreview(text string, max int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > max {
+		return string(runes[:max]) + "..."
+	}
+	return text
+}
+
+func init() {
+	queueStartCmd.Flags().BoolVar(&queueLIFO, "lifo", false, "Paste the most recent copy first (stack order)")
+	queueCmd.AddCommand(queueStartCmd, queueStopCmd)
+}
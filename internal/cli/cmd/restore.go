@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/berrythewa/clipman-daemon/internal/clipboard"
+	"github.com/berrythewa/clipman-daemon/internal/ipc"
+	"github.com/berrythewa/clipman-daemon/internal/storage"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Restore command flags
+	restoreIndex  int
+	restoreSearch string
+	restorePlain  bool
+	restoreStdin  bool
+)
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore [id]",
+	Short: "Put a history item back on the clipboard",
+	Long: `Write an item from history back to the clipboard, keeping its original
+type, so HTML, RTF, images and file lists paste as they were copied.
+Files that have been moved or deleted since are recreated from their
+snapshots when file snapshots are enabled.
+
+Select the item by its ID (as shown by 'clipmand history', a unique
+prefix is enough), by its position in history, or by searching its text.
+A line of 'clipmand history --launcher' output can be given instead of the
+ID, or read from standard input with --stdin.
+The restored item is not added to history again.
+
+Examples:
+  # Restore an item by ID
+  clipmand restore 17d2c4f1
+
+  # Restore the second most recent item
+  clipmand restore --index 2
+
+  # Restore the most recent item containing some text
+  clipmand restore --search "invoice"
+
+  # Restore a formatted item as plain text
+  clipmand restore --index 1 --plain
+
+  # Restore the item picked in a launcher
+  clipmand history --launcher | dmenu -l 20 | clipmand restore --stdin`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		restoreArgs := ipc.RestoreArgs{
+			ItemSelector: storage.ItemSelector{Index: restoreIndex, Search: restoreSearch},
+			PlainText:    restorePlain,
+		}
+		switch {
+		case restoreStdin && len(args) == 1:
+			return fmt.Errorf("give either an ID or --stdin, not both")
+		case restoreStdin:
+			id, err := readLauncherLine(os.Stdin)
+			if err != nil {
+				return err
+			}
+			restoreArgs.ID = id
+		case len(args) == 1:
+			id, err := parseLauncherLine(args[0])
+			if err != nil {
+				return err
+			}
+			restoreArgs.ID = id
+		}
+		if err := restoreArgs.Validate(); err != nil {
+			return err
+		}
+
+		var response ipc.RestoreResponse
+		err := newControlClient().Call(ipc.CommandRestore, restoreArgs, &response)
+		if errors.Is(err, ipc.ErrDaemonNotRunning) {
+			response, err = restoreOffline(restoreArgs)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore item: %w", err)
+		}
+
+		fmt.Printf("Restored %s (%s, copied %s): %s\n",
+			response.ID, response.Type, response.Created.Format("2006-01-02 15:04:05"), response.Preview)
+		return nil
+	},
+}
+
+// restoreOffline restores an item without a running daemon, reading
+// history directly and writing to the clipboard from this process
+func restoreOffline(args ipc.RestoreArgs) (ipc.RestoreResponse, error) {
+	store, err := openStorage()
+	if err != nil {
+		return ipc.RestoreResponse{}, err
+	}
+	defer store.Close()
+	return restoreFromStore(store, args)
+}
+
+// restoreFromStore restores an item from an open database, writing to the
+// clipboard from this process
+func restoreFromStore(store *storage.BoltStorage, args ipc.RestoreArgs) (ipc.RestoreResponse, error) {
+	content, err := selectForRestore(store, args)
+	if err != nil {
+		return ipc.RestoreResponse{}, err
+	}
+	cfg := GetConfig()
+	content, err = clipboard.RestoreFiles(content, store.Blobs(), clipboard.RestoreDir(cfg))
+	if err != nil {
+		return ipc.RestoreResponse{}, err
+	}
+
+	clip, err := clipboard.NewClipboardFromConfig(cfg)
+	if err != nil {
+		return ipc.RestoreResponse{}, err
+	}
+	if err := clip.Write(content); err != nil {
+		return ipc.RestoreResponse{}, fmt.Errorf("failed to write item to clipboard: %w", err)
+	}
+	return newRestoreResponse(content), nil
+}
+
+// selectForRestore picks the item to restore, converted to plain text if requested
+func selectForRestore(store *storage.BoltStorage, args ipc.RestoreArgs) (*types.ClipboardContent, error) {
+	content, err := store.SelectContent(args.ItemSelector)
+	if err != nil || !args.PlainText {
+		return content, err
+	}
+
+	plain, err := clipboard.PlainTextContent(content)
+	if err != nil {
+		return nil, err
+	}
+	// Keep the identity of the item it came from
+	plain.Created = content.Created
+	return plain, nil
+}
+
+// newHistoryItems prepares history items for clients, leaving out image data
+func newHistoryItems(contents []*types.ClipboardContent) []ipc.HistoryItem {
+	items := make([]ipc.HistoryItem, 0, len(contents))
+	for _, content := range contents {
+		item := ipc.HistoryItem{ClipboardContent: *content, Size: len(content.Data)}
+		if content.Type == types.TypeImage {
+			item.Data = nil
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// newRestoreResponse describes a restored item
+func newRestoreResponse(content *types.ClipboardContent) ipc.RestoreResponse {
+	return ipc.RestoreResponse{
+		ID:      content.ID(),
+		Type:    content.Type,
+		Created: content.Created,
+		Preview: contentPreview(content),
+	}
+}
+
+// contentPreview returns a one-line preview of an item, or its type for images
+func contentPreview(content *types.ClipboardContent) string {
+	if content.Type == types.TypeImage {
+		return "[" + string(content.Type) + "]"
+	}
+	return truncatePreview(content.Text(), 60)
+}
+
+func init() {
+	restoreCmd.Flags().IntVarP(&restoreIndex, "index", "n", 0, "Restore the item at this position in history, 1 being the most recent")
+	restoreCmd.Flags().StringVarP(&restoreSearch, "search", "s", "", "Restore the most recent item containing this text")
+	restoreCmd.Flags().BoolVar(&restorePlain, "plain", false, "Restore as plain text, dropping formatting")
+	restoreCmd.Flags().BoolVar(&restoreStdin, "stdin", false, "Read the item ID, or a launcher line, from standard input")
+}
@@ -51,32 +51,33 @@ until interrupted.`,
 		
 		// Initialize content publisher
 		var contentPublisher clipboard.ContentPublisher
+		var syncManager *sync.Manager
+		var syncPublisher *clipboard.SyncPublisher
 		
 		if noSync || !cfg.Sync.Enabled {
 			zapLogger.Info("Using no-op publisher (sync functionality disabled)")
 			contentPublisher = clipboard.NewNoOpPublisher(zapLogger)
 		} else {
 			// Create sync manager with the global config
-			syncManager, err := sync.New(context.Background(), cfg, zapLogger)
+			manager, err := sync.New(context.Background(), cfg, zapLogger)
 			if err != nil {
 				zapLogger.Error("Failed to initialize sync manager, falling back to no-op publisher", zap.Error(err))
 				contentPublisher = clipboard.NewNoOpPublisher(zapLogger)
 			} else {
 				// Start the sync manager
-				if err := syncManager.Start(); err != nil {
+				if err := manager.Start(); err != nil {
 					zapLogger.Error("Failed to start sync manager, falling back to no-op publisher", zap.Error(err))
 					contentPublisher = clipboard.NewNoOpPublisher(zapLogger)
 				} else {
-					// Join the default group
-					defaultGroup := "clipman-default"
-					if err := syncManager.JoinGroup(defaultGroup); err != nil {
-						zapLogger.Error("Failed to join default group, falling back to no-op publisher", zap.Error(err))
-						contentPublisher = clipboard.NewNoOpPublisher(zapLogger)
-					} else {
-						zapLogger.Info("Using sync publisher",
-							zap.String("group", defaultGroup))
-						contentPublisher = clipboard.NewSyncPublisher(syncManager, defaultGroup, zapLogger)
+					// Rejoin the groups this device is in
+					if err := manager.RejoinGroups(); err != nil {
+						zapLogger.Warn("Failed to rejoin some groups", zap.Error(err))
 					}
+					zapLogger.Info("Using sync publisher",
+						zap.Int("groups", len(manager.Groups())))
+					syncPublisher = clipboard.NewSyncPublisher(manager, zapLogger)
+					contentPublisher = syncPublisher
+					syncManager = manager
 				}
 			}
 		}
@@ -84,6 +85,7 @@ until interrupted.`,
 		// Initialize storage
 		storageConfig := storage.StorageConfig{
 			DBPath:     paths.DBFile,
+			BlobDir:    paths.BlobDir,
 			MaxSize:    cfg.Storage.MaxSize,
 			DeviceID:   cfg.DeviceID,
 			Logger:     zapLogger,
@@ -101,6 +103,11 @@ until interrupted.`,
 		}
 		defer store.Close()
 		
+		// Queue content copied while no peer is online in storage
+		if syncPublisher != nil {
+			syncPublisher.SetOutbox(clipboard.NewOutbox(store, cfg, zapLogger))
+		}
+		
 		// Start the monitor
 		monitor := clipboard.NewMonitor(cfg, contentPublisher, zapLogger, store)
 		if err := monitor.Start(); err != nil {
@@ -110,6 +117,26 @@ until interrupted.`,
 		
 		zapLogger.Info("Monitor started")
 		
+		// Deliver content received from peers to the monitor, and reconcile
+		// history with them from storage
+		if syncManager != nil {
+			syncManager.SetContentHandler(monitor.HandleRemoteContent)
+			syncManager.SetHistory(store, monitor.HandleReconciledContent)
+		}
+		
+		// Start the control socket so CLI commands can reach the daemon
+		controlServer, err := StartControlServer(DaemonComponents{
+			Monitor:   monitor,
+			Storage:   store,
+			Sync:      syncManager,
+			Publisher: syncPublisher,
+		})
+		if err != nil {
+			zapLogger.Error("Failed to start control socket, CLI commands won't reach the daemon", zap.Error(err))
+		} else {
+			defer controlServer.Stop()
+		}
+		
 		if duration > 0 {
 			// Run for specified duration
 			zapLogger.Info("Running for test duration", zap.Duration("duration", duration))
@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/berrythewa/clipman-daemon/internal/clipboard"
+	"github.com/berrythewa/clipman-daemon/internal/ipc"
+	"github.com/berrythewa/clipman-daemon/internal/storage"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"github.com/berrythewa/clipman-daemon/pkg/snippet"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Snippet command flags
+	snippetTag         string
+	snippetTags        []string
+	snippetDescription string
+	snippetValues      []string
+)
+
+// snippetCmd represents the snippet command
+var snippetCmd = &cobra.Command{
+	Use:   "snippet",
+	Short: "Manage reusable snippets and expand them to the clipboard",
+	Long: `Snippets are named pieces of text kept apart from history, such as
+signatures, boilerplate or commands. They can contain template variables
+that are filled in when the snippet is expanded:
+
+  {{date}}, {{date:02/01/2006}}   current date, optionally in a Go time layout
+  {{time}}, {{time:15:04}}        current time, optionally in a Go time layout
+  {{uuid}}                        a new random UUID
+  {{env:NAME}}                    an environment variable of the daemon
+  {{clipboard}}                   the current clipboard text
+  {{prompt:Label}}                a value you are asked for
+
+Snippets are synced to paired devices when sync is enabled.
+
+Examples:
+  # Save a snippet, reading the text from stdin
+  echo 'Best regards, {{env:USER}}' | clipmand snippet add sig --tag email
+
+  # Save a snippet given on the command line
+  clipmand snippet add standup 'Standup {{date}}: {{prompt:Update}}'
+
+  # Put an expanded snippet on the clipboard
+  clipmand snippet expand standup --set Update="shipped the parser"
+
+  # List snippets, or those with a tag
+  clipmand snippet list --tag email`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return snippetListCmd.RunE(cmd, args)
+	},
+}
+
+// snippetListCmd lists snippets
+var snippetListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List snippets",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		response, err := callSnippet(ipc.SnippetArgs{Action: ipc.SnippetActionList, Tag: strings.ToLower(snippetTag)})
+		if err != nil {
+			return err
+		}
+
+		if len(response.Snippets) == 0 {
+			fmt.Println("No snippets.")
+			return nil
+		}
+		for _, s := range response.Snippets {
+			line := s.Name
+			if len(s.Tags) > 0 {
+				line += " [" + strings.Join(s.Tags, ", ") + "]"
+			}
+			if s.Description != "" {
+				line += " - " + s.Description
+			} else {
+				line += " - " + truncatePreview(s.Content, 50)
+			}
+			fmt.Println(line)
+		}
+		return nil
+	},
+}
+
+// snippetAddCmd saves a snippet
+var snippetAddCmd = &cobra.Command{
+	Use:   "add <name> [text]",
+	Short: "Save a snippet, replacing any snippet with the same name",
+	Long: `Save a snippet. The text is taken from the argument, or read from
+stdin when it is omitted.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var text string
+		if len(args) == 2 {
+			text = args[1]
+		} else {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("failed to read snippet text: %w", err)
+			}
+			text = strings.TrimSuffix(string(data), "\n")
+		}
+		if text == "" {
+			return errors.New("snippet text is empty")
+		}
+
+		_, err := callSnippet(ipc.SnippetArgs{
+			Action: ipc.SnippetActionSave,
+			Snippet: &types.Snippet{
+				Name:        args[0],
+				Content:     text,
+				Description: snippetDescription,
+				Tags:        snippetTags,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Saved snippet %s.\n", args[0])
+		return nil
+	},
+}
+
+// snippetShowCmd prints a snippet
+var snippetShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Print a snippet's template",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := getSnippet(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(s.Content)
+		return nil
+	},
+}
+
+// snippetRemoveCmd deletes a snippet
+var snippetRemoveCmd = &cobra.Command{
+	Use:     "rm <name>",
+	Aliases: []string{"remove", "delete"},
+	Short:   "Delete a snippet",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := callSnippet(ipc.SnippetArgs{Action: ipc.SnippetActionDelete, Name: args[0]}); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted snippet %s.\n", args[0])
+		return nil
+	},
+}
+
+// snippetExpandCmd expands a snippet to the clipboard
+var snippetExpandCmd = &cobra.Command{
+	Use:   "expand <name>",
+	Short: "Expand a snippet and put it on the clipboard",
+	Long: `Fill in a snippet's template variables and put the result on the
+clipboard. Prompts not given with --set are asked for on the terminal.
+The expanded text is not added to history.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := getSnippet(args[0])
+		if err != nil {
+			return err
+		}
+
+		values, err := promptValues(s, snippetValues)
+		if err != nil {
+			return err
+		}
+
+		response, err := callSnippet(ipc.SnippetArgs{Action: ipc.SnippetActionExpand, Name: args[0], Values: values})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Copied snippet %s: %s\n", args[0], truncatePreview(response.Expanded, 60))
+		return nil
+	},
+}
+
+// getSnippet fetches one snippet
+func getSnippet(name string) (*types.Snippet, error) {
+	response, err := callSnippet(ipc.SnippetArgs{Action: ipc.SnippetActionGet, Name: name})
+	if err != nil {
+		return nil, err
+	}
+	return response.Snippets[0], nil
+}
+
+// promptValues collects the prompt answers of a snippet, from --set
+// assignments first and then by asking on the terminal
+func promptValues(s *types.Snippet, assignments []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, assignment := range assignments {
+		label, value, ok := strings.Cut(assignment, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid --set %q, use Label=value", assignment)
+		}
+		values[label] = value
+	}
+
+	labels, err := snippet.Prompts(s.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for _, label := range labels {
+		if _, ok := values[label]; ok {
+			continue
+		}
+		fmt.Printf("%s: ", label)
+		answer, err := reader.ReadString('\n')
+		if err != nil && answer == "" {
+			return nil, fmt.Errorf("no value given for prompt %q", label)
+		}
+		values[label] = strings.TrimRight(answer, "\r\n")
+	}
+	return values, nil
+}
+
+// callSnippet runs a snippet action in the daemon, or directly on the
+// database when the daemon is not running
+func callSnippet(args ipc.SnippetArgs) (ipc.SnippetResponse, error) {
+	var response ipc.SnippetResponse
+	err := newControlClient().Call(ipc.CommandSnippet, args, &response)
+	if errors.Is(err, ipc.ErrDaemonNotRunning) {
+		return snippetOffline(args)
+	}
+	return response, err
+}
+
+// snippetOffline runs a snippet action without a daemon. Changes made this
+// way are not sent to paired devices.
+func snippetOffline(args ipc.SnippetArgs) (ipc.SnippetResponse, error) {
+	store, err := openStorage()
+	if err != nil {
+		return ipc.SnippetResponse{}, err
+	}
+	defer store.Close()
+
+	cfg := GetConfig()
+	switch args.Action {
+	case ipc.SnippetActionSave:
+		saved, err := clipboard.SaveSnippet(store, args.Snippet, cfg.DeviceID)
+		if err != nil {
+			return ipc.SnippetResponse{}, err
+		}
+		return ipc.SnippetResponse{Snippets: []*types.Snippet{saved}}, nil
+	case ipc.SnippetActionDelete:
+		_, err := clipboard.DeleteSnippet(store, args.Name, cfg.DeviceID)
+		return ipc.SnippetResponse{}, err
+	case ipc.SnippetActionExpand:
+		s, err := store.GetSnippet(args.Name)
+		if err != nil {
+			return ipc.SnippetResponse{}, err
+		}
+		clip, err := clipboard.NewClipboardFromConfig(cfg)
+		if err != nil {
+			return ipc.SnippetResponse{}, err
+		}
+		content, err := clipboard.ExpandSnippet(s, clip, args.Values, cfg.DeviceID)
+		if err != nil {
+			return ipc.SnippetResponse{}, err
+		}
+		if err := clip.Write(content); err != nil {
+			return ipc.SnippetResponse{}, fmt.Errorf("failed to write snippet to clipboard: %w", err)
+		}
+		return ipc.SnippetResponse{Expanded: string(content.Data)}, nil
+	}
+	return readSnippets(store, args)
+}
+
+// readSnippets answers the read-only snippet actions
+func readSnippets(store *storage.BoltStorage, args ipc.SnippetArgs) (ipc.SnippetResponse, error) {
+	switch args.Action {
+	case ipc.SnippetActionList, "":
+		snippets, err := store.ListSnippets(args.Tag)
+		return ipc.SnippetResponse{Snippets: snippets}, err
+	case ipc.SnippetActionGet:
+		s, err := store.GetSnippet(args.Name)
+		if err != nil {
+			return ipc.SnippetResponse{}, err
+		}
+		return ipc.SnippetResponse{Snippets: []*types.Snippet{s}}, nil
+	}
+	return ipc.SnippetResponse{}, fmt.Errorf("unknown snippet action: %s", args.Action)
+}
+
+func init() {
+	snippetListCmd.Flags().StringVarP(&snippetTag, "tag", "t", "", "Only list snippets with this tag")
+	snippetAddCmd.Flags().StringSliceVarP(&snippetTags, "tag", "t", nil, "Tag the snippet (repeatable or comma-separated)")
+	snippetAddCmd.Flags().StringVarP(&snippetDescription, "description", "d", "", "Short description shown in listings")
+	snippetExpandCmd.Flags().StringArrayVar(&snippetValues, "set", nil, "Answer a prompt, as Label=value (repeatable)")
+	snippetCmd.AddCommand(snippetListCmd, snippetAddCmd, snippetShowCmd, snippetRemoveCmd, snippetExpandCmd)
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/clipboard"
+	"github.com/berrythewa/clipman-daemon/internal/ipc"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Status command flags
+	statusJSON bool
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the state of the running daemon",
+	Long: `Show whether the daemon is running and what it is doing,
+including whether clipboard capture is paused, the sync connection state and
+content waiting in the outbox for a paired device to come online.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var status ipc.StatusResponse
+		err := newControlClient().Call(ipc.CommandStatus, nil, &status)
+
+		if errors.Is(err, ipc.ErrDaemonNotRunning) {
+			state, loadErr := clipboard.LoadPauseState(pauseStatePath())
+			if loadErr != nil {
+				return loadErr
+			}
+
+			if statusJSON {
+				return json.NewEncoder(os.Stdout).Encode(map[string]interface{}{
+					"running": false,
+					"pause":   state,
+				})
+			}
+
+			fmt.Println("Daemon:    not running")
+			if state.Paused {
+				fmt.Printf("Capture:   %s\n", describePauseState(state))
+			}
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to get daemon status: %w", err)
+		}
+
+		if statusJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(status)
+		}
+
+		fmt.Printf("Daemon:    running (PID %d, up %s)\n", status.PID, time.Since(status.StartedAt).Round(time.Second))
+		fmt.Printf("Device:    %s\n", status.DeviceID)
+		fmt.Printf("Backend:   %s\n", status.Backend)
+		fmt.Printf("Capture:   %s\n", describePauseState(status.Pause))
+		if status.Queue.Active {
+			fmt.Printf("Queue:     %s\n", describeQueueState(status.Queue))
+		}
+		if status.SyncEnabled {
+			connection := "not connected"
+			if status.SyncConnected {
+				connection = fmt.Sprintf("connected to %d peer%s", status.PeerCount, plural(status.PeerCount))
+			}
+			fmt.Printf("Sync:      %s\n", connection)
+			if status.Outbox != nil {
+				fmt.Printf("Outbox:    %s\n", describeOutboxState(*status.Outbox))
+			}
+		} else {
+			fmt.Println("Sync:      disabled")
+		}
+		return nil
+	},
+}
+
+// describeOutboxState describes content waiting for peers and the last delivery
+func describeOutboxState(state clipboard.OutboxState) string {
+	var description string
+	if state.Queued == 0 {
+		description = "empty"
+	} else {
+		description = fmt.Sprintf("%d item%s waiting for a peer (%s), oldest queued %s ago",
+			state.Queued, plural(state.Queued), formatBytes(state.Bytes), time.Since(state.Oldest).Round(time.Second))
+	}
+	if state.Delivered > 0 {
+		description += fmt.Sprintf("; %d delivered, last %s ago", state.Delivered, time.Since(state.LastDelivered).Round(time.Second))
+	}
+	if state.Dropped > 0 {
+		description += fmt.Sprintf("; %d dropped after waiting too long or for space", state.Dropped)
+	}
+	if state.Queued > 0 && state.LastError != "" {
+		description += "; " + state.LastError
+	}
+	return description
+}
+
+func init() {
+	statusCmd.Flags().BoolVar(&statusJSON, "json", false, "Output status in JSON format")
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/berrythewa/clipman-daemon/internal/clipboard"
+	"github.com/berrythewa/clipman-daemon/internal/ipc"
+	"github.com/berrythewa/clipman-daemon/internal/storage"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Transform command flags
+	transformID     string
+	transformIndex  int
+	transformSearch string
+)
+
+// transformCmd represents the transform command
+var transformCmd = &cobra.Command{
+	Use:   "transform",
+	Short: "Convert the clipboard content, e.g. base64 decode or JSON pretty-print",
+	Long: `Apply a chain of transforms to the current clipboard, or to a history
+item, and put the result on the clipboard. Transforms work on text; rich
+text is converted as its plain text, and the result is always plain text.
+The result is not added to history.
+
+Examples:
+  # See the available transforms
+  clipmand transform list
+
+  # Pretty-print the JSON on the clipboard
+  clipmand transform apply json-pretty
+
+  # Decode base64, then minify the JSON inside it
+  clipmand transform apply base64-decode json-minify
+
+  # Hash the third most recent history item
+  clipmand transform apply sha256 --index 3`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+// transformListCmd lists the available transforms
+var transformListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the available transforms",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, t := range clipboard.NamedTransforms() {
+			fmt.Printf("%-14s %s\n", t.Name, t.Description)
+		}
+		return nil
+	},
+}
+
+// transformApplyCmd applies a chain of transforms
+var transformApplyCmd = &cobra.Command{
+	Use:   "apply <transform>...",
+	Short: "Apply transforms in order and put the result on the clipboard",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, name := range args {
+			if _, ok := clipboard.LookupTransform(name); !ok {
+				return fmt.Errorf("unknown transform %q, see 'clipmand transform list'", name)
+			}
+		}
+
+		transformArgs := ipc.TransformArgs{Transforms: args}
+		sel := storage.ItemSelector{ID: transformID, Index: transformIndex, Search: transformSearch}
+		if sel != (storage.ItemSelector{}) {
+			if err := sel.Validate(); err != nil {
+				return err
+			}
+			transformArgs.Item = &sel
+		}
+
+		var response ipc.TransformResponse
+		err := newControlClient().Call(ipc.CommandTransform, transformArgs, &response)
+		if errors.Is(err, ipc.ErrDaemonNotRunning) {
+			response, err = transformOffline(transformArgs)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to transform: %w", err)
+		}
+
+		fmt.Printf("Copied %d bytes: %s\n", response.Size, response.Preview)
+		return nil
+	},
+}
+
+// transformOffline transforms without a running daemon, reading history
+// directly and using the clipboard from this process
+func transformOffline(args ipc.TransformArgs) (ipc.TransformResponse, error) {
+	clip, err := clipboard.NewClipboardFromConfig(GetConfig())
+	if err != nil {
+		return ipc.TransformResponse{}, err
+	}
+
+	var source *types.ClipboardContent
+	if args.Item != nil {
+		store, err := openStorage()
+		if err != nil {
+			return ipc.TransformResponse{}, err
+		}
+		source, err = store.SelectContent(*args.Item)
+		store.Close()
+		if err != nil {
+			return ipc.TransformResponse{}, err
+		}
+	} else if source, err = clip.Read(); err != nil {
+		return ipc.TransformResponse{}, fmt.Errorf("failed to read clipboard: %w", err)
+	}
+
+	result, err := clipboard.ApplyTransforms(source, args.Transforms)
+	if err != nil {
+		return ipc.TransformResponse{}, err
+	}
+	if err := clip.Write(result); err != nil {
+		return ipc.TransformResponse{}, fmt.Errorf("failed to write result to clipboard: %w", err)
+	}
+	return newTransformResponse(result), nil
+}
+
+// newTransformResponse describes a transform result
+func newTransformResponse(content *types.ClipboardContent) ipc.TransformResponse {
+	return ipc.TransformResponse{Preview: contentPreview(content), Size: len(content.Data)}
+}
+
+func init() {
+	transformApplyCmd.Flags().StringVar(&transformID, "id", "", "Transform the history item with this ID instead of the clipboard")
+	transformApplyCmd.Flags().IntVarP(&transformIndex, "index", "n", 0, "Transform the history item at this position, 1 being the most recent")
+	transformApplyCmd.Flags().StringVarP(&transformSearch, "search", "s", "", "Transform the most recent history item containing this text")
+	transformCmd.AddCommand(transformListCmd, transformApplyCmd)
+}
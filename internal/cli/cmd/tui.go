@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/berrythewa/clipman-daemon/internal/config"
+	"github.com/berrythewa/clipman-daemon/internal/ipc"
+	"github.com/berrythewa/clipman-daemon/internal/storage"
+	"github.com/berrythewa/clipman-daemon/internal/tui"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// TUI command flags
+	tuiLimit int64
+)
+
+// tuiCmd represents the tui command
+var tuiCmd = &cobra.Command{
+	Use:     "tui",
+	Aliases: []string{"browse"},
+	Short:   "Browse, search and restore history interactively",
+	Long: `Open an interactive browser for clipboard history in the terminal.
+The list shows the most recent items first, with a preview of the selected
+item next to it.
+
+Keys:
+  up/down, j/k, pgup/pgdn, g/G   move through the list
+  /                              search as you type, every word must match
+  esc                            clear the search, or quit
+  enter                          restore the item to the clipboard and quit
+  t                              restore the item as plain text and quit
+  p                              pin or unpin the item, pinned items are never flushed
+  d, delete                      delete the item
+  r                              reload history
+  q, ctrl-c                      quit
+
+Works with or without a running daemon.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		options := config.HistoryOptions{Reverse: true, Limit: tuiLimit}
+
+		var source tui.Source = daemonHistorySource{options: options}
+		if _, err := source.Items(); errors.Is(err, ipc.ErrDaemonNotRunning) {
+			store, err := openStorage()
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+			source = storeHistorySource{store: store, options: options}
+		} else if err != nil {
+			return err
+		}
+
+		message, err := tui.Run(source, os.Stdin, os.Stdout)
+		if err != nil {
+			return err
+		}
+		if message != "" {
+			fmt.Println(message)
+		}
+		return nil
+	},
+}
+
+// daemonHistorySource browses history through the running daemon
+type daemonHistorySource struct {
+	options config.HistoryOptions
+}
+
+func (s daemonHistorySource) Items() ([]tui.Item, error) {
+	var items []ipc.HistoryItem
+	if err := newControlClient().Call(ipc.CommandHistory, s.options, &items); err != nil {
+		return nil, err
+	}
+
+	result := make([]tui.Item, len(items))
+	for i := range items {
+		result[i] = tui.Item{Content: &items[i].ClipboardContent, Size: items[i].Size}
+	}
+	return result, nil
+}
+
+func (s daemonHistorySource) Restore(item tui.Item, plainText bool) error {
+	return newControlClient().Call(ipc.CommandRestore, ipc.RestoreArgs{
+		ItemSelector: storage.ItemSelector{ID: item.Content.ID()},
+		PlainText:    plainText,
+	}, nil)
+}
+
+func (s daemonHistorySource) SetPinned(item tui.Item, pinned bool) error {
+	return newControlClient().Call(ipc.CommandPin, ipc.PinArgs{ID: item.Content.ID(), Pinned: pinned}, nil)
+}
+
+func (s daemonHistorySource) Delete(item tui.Item) error {
+	return newControlClient().Call(ipc.CommandDelete, ipc.DeleteArgs{ID: item.Content.ID()}, nil)
+}
+
+// storeHistorySource browses history directly in the database, when the daemon is not running
+type storeHistorySource struct {
+	store   *storage.BoltStorage
+	options config.HistoryOptions
+}
+
+func (s storeHistorySource) Items() ([]tui.Item, error) {
+	contents, err := s.store.GetHistory(s.options)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]tui.Item, len(contents))
+	for i, content := range contents {
+		items[i] = tui.Item{Content: content, Size: len(content.Data)}
+	}
+	return items, nil
+}
+
+func (s storeHistorySource) Restore(item tui.Item, plainText bool) error {
+	_, err := restoreFromStore(s.store, ipc.RestoreArgs{
+		ItemSelector: storage.ItemSelector{ID: item.Content.ID()},
+		PlainText:    plainText,
+	})
+	return err
+}
+
+func (s storeHistorySource) SetPinned(item tui.Item, pinned bool) error {
+	return s.store.SetPinned(item.Content, pinned)
+}
+
+func (s storeHistorySource) Delete(item tui.Item) error {
+	return s.store.DeleteContents([]*types.ClipboardContent{item.Content})
+}
+
+func init() {
+	tuiCmd.Flags().Int64VarP(&tuiLimit, "limit", "l", 1000, "Maximum number of recent items to load")
+}
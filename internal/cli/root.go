@@ -10,6 +10,7 @@ import (
 	cmdpkg "github.com/berrythewa/clipman-daemon/internal/cli/cmd"
 	"github.com/berrythewa/clipman-daemon/internal/config"
 	"github.com/berrythewa/clipman-daemon/internal/clipboard"
+	"github.com/berrythewa/clipman-daemon/internal/ipc"
 	"github.com/berrythewa/clipman-daemon/internal/storage"
 	"github.com/berrythewa/clipman-daemon/internal/platform"
 	// TODO: check if needed "github.com/berrythewa/clipman-daemon/internal/types"
@@ -38,6 +39,9 @@ var (
 	// Logger instance
 	zapLogger *zap.Logger
 	
+	// Control socket of the running daemon
+	controlServer *ipc.Server
+	
 	// Version information - set by main
 	Version   = "dev"
 	BuildTime = "unknown"
@@ -229,32 +233,33 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 
 	// Initialize content publisher
 	var contentPublisher clipboard.ContentPublisher
+	var syncManager *sync.Manager
+	var syncPublisher *clipboard.SyncPublisher
 	
 	if noSync {
 		zapLogger.Info("Using no-op publisher (sync functionality disabled)")
 		contentPublisher = clipboard.NewNoOpPublisher(zapLogger)
 	} else {
 		// Create sync manager with the global config
-		syncManager, err := sync.New(context.Background(), cfg, zapLogger)
+		manager, err := sync.New(context.Background(), cfg, zapLogger)
 		if err != nil {
 			zapLogger.Error("Failed to initialize sync manager, falling back to no-op publisher", zap.Error(err))
 			contentPublisher = clipboard.NewNoOpPublisher(zapLogger)
 		} else {
 			// Start the sync manager
-			if err := syncManager.Start(); err != nil {
+			if err := manager.Start(); err != nil {
 				zapLogger.Error("Failed to start sync manager, falling back to no-op publisher", zap.Error(err))
 				contentPublisher = clipboard.NewNoOpPublisher(zapLogger)
 			} else {
-				// Join the default group
-				defaultGroup := "clipman-default"
-				if err := syncManager.JoinGroup(defaultGroup); err != nil {
-					zapLogger.Error("Failed to join default group, falling back to no-op publisher", zap.Error(err))
-					contentPublisher = clipboard.NewNoOpPublisher(zapLogger)
-				} else {
-					zapLogger.Info("Using sync publisher",
-						zap.String("group", defaultGroup))
-					contentPublisher = clipboard.NewSyncPublisher(syncManager, defaultGroup, zapLogger)
+				// Rejoin the groups this device is in
+				if err := manager.RejoinGroups(); err != nil {
+					zapLogger.Warn("Failed to rejoin some groups", zap.Error(err))
 				}
+				zapLogger.Info("Using sync publisher",
+					zap.Int("groups", len(manager.Groups())))
+				syncPublisher = clipboard.NewSyncPublisher(manager, zapLogger)
+				contentPublisher = syncPublisher
+				syncManager = manager
 			}
 		}
 	}
@@ -262,6 +267,7 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 	// Initialize storage
 	storageConfig := storage.StorageConfig{
 		DBPath:   paths.DBFile,
+		BlobDir:  paths.BlobDir,
 		MaxSize:  cfg.Storage.MaxSize,
 		DeviceID: cfg.DeviceID,
 		Logger:   zapLogger,
@@ -279,6 +285,11 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 	}
 	defer store.Close()
 	
+	// Queue content copied while no peer is online in storage
+	if syncPublisher != nil {
+		syncPublisher.SetOutbox(clipboard.NewOutbox(store, cfg, zapLogger))
+	}
+	
 	// Start the monitor
 	monitor := clipboard.NewMonitor(cfg, contentPublisher, zapLogger, store)
 	if err := monitor.Start(); err != nil {
@@ -287,6 +298,25 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 	}
 	
 	zapLogger.Info("Monitor started")
+	
+	// Deliver content received from peers to the monitor, and reconcile
+	// history with them from storage
+	if syncManager != nil {
+		syncManager.SetContentHandler(monitor.HandleRemoteContent)
+		syncManager.SetHistory(store, monitor.HandleReconciledContent)
+	}
+	
+	// Start the control socket so CLI commands can reach the daemon
+	controlServer, err = cmdpkg.StartControlServer(cmdpkg.DaemonComponents{
+		Monitor:   monitor,
+		Storage:   store,
+		Sync:      syncManager,
+		Publisher: syncPublisher,
+	})
+	if err != nil {
+		zapLogger.Error("Failed to start control socket, CLI commands won't reach the daemon", zap.Error(err))
+	}
+	
 	zapLogger.Info("Running until interrupted, press Ctrl+C to stop")
 	
 	// Run indefinitely - block until interrupted
@@ -308,6 +338,11 @@ func setupSignalHandlers() {
 
 // cleanup performs cleanup operations before exit
 func cleanup() {
+	if controlServer != nil {
+		controlServer.Stop()
+		controlServer = nil
+	}
+	
 	if zapLogger != nil {
 		zapLogger.Info("Shutting down Clipman")
 		zapLogger.Sync() // Flush any buffered log entries
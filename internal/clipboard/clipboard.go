@@ -1,6 +1,10 @@
 package clipboard
 
 import (
+	"path/filepath"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/config"
 	"github.com/berrythewa/clipman-daemon/internal/platform"
 	"github.com/berrythewa/clipman-daemon/internal/types"
 )
@@ -27,3 +31,14 @@ type Clipboard interface {
 func NewClipboard() Clipboard {
 	return platform.GetPlatformClipboard()
 }
+
+// NewClipboardFromConfig returns the clipboard backend selected in the configuration
+func NewClipboardFromConfig(cfg *config.Config) (Clipboard, error) {
+	filePath := cfg.Clipboard.FilePath
+	if cfg.Clipboard.Backend == platform.BackendFile && filePath == "" {
+		filePath = filepath.Join(cfg.GetPaths().DataDir, "clipboard")
+	}
+
+	pollInterval := time.Duration(cfg.PollingInterval) * time.Millisecond
+	return platform.GetClipboardForBackend(cfg.Clipboard.Backend, filePath, pollInterval)
+}
@@ -9,6 +9,7 @@ import (
 
 	atottoClip "github.com/atotto/clipboard"
 	"github.com/berrythewa/clipman-daemon/internal/types"
+	"github.com/berrythewa/clipman-daemon/pkg/classify"
 )
 
 // AtottoClipboard is a fallback clipboard implementation using the atotto/clipboard library
@@ -29,7 +30,7 @@ func (c *AtottoClipboard) Read() (*types.ClipboardContent, error) {
 		Data:    []byte(text),
 		Created: time.Now(),
 	}
-	content.Type = detectContentType(content.Data)
+	content.Type = classify.Type(content.Data)
 	return content, nil
 }
 
@@ -0,0 +1,270 @@
+package clipboard
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"  // Register GIF decoding
+	_ "image/jpeg" // Register JPEG decoding
+	"image/png"
+	"math/bits"
+	"strconv"
+
+	"github.com/berrythewa/clipman-daemon/internal/config"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// imageHashSize is the side of the grayscale grid the perceptual hash is computed on
+	imageHashSize = 8
+
+	// maxDecodedPixels bounds the images that are decoded. A small compressed
+	// file can describe a huge image, decoding takes 4 bytes per pixel.
+	maxDecodedPixels = 64 * 1024 * 1024
+)
+
+// processImageContent normalizes an image, enforces the maximum dimensions and
+// adds a thumbnail, dimensions and perceptual hash. Images that can't be decoded
+// are kept unchanged. Images too large to decode are dropped, since they could
+// be neither scaled down to the maximum dimensions nor thumbnailed.
+func (cp *ContentProcessor) processImageContent(content *types.ClipboardContent) *types.ClipboardContent {
+	header, format, err := image.DecodeConfig(bytes.NewReader(content.Data))
+	if err != nil {
+		if cp.logger != nil {
+			cp.logger.Debug("Failed to decode image, storing as is", zap.Error(err))
+		}
+		return content
+	}
+	if int64(header.Width)*int64(header.Height) > maxDecodedPixels {
+		if cp.logger != nil {
+			cp.logger.Warn("Dropping image too large to decode",
+				zap.Int("width", header.Width), zap.Int("height", header.Height))
+		}
+		return nil
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(content.Data))
+	if err != nil {
+		if cp.logger != nil {
+			cp.logger.Debug("Failed to decode image, storing as is", zap.Error(err))
+		}
+		return content
+	}
+
+	opts := cp.images
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	resized := false
+	if w, h := fitWithin(width, height, opts.MaxWidth, opts.MaxHeight); w != width || h != height {
+		if cp.logger != nil {
+			cp.logger.Info("Scaling down large image",
+				zap.Int("width", width), zap.Int("height", height),
+				zap.Int("new_width", w), zap.Int("new_height", h))
+		}
+		img = scaleImage(img, w, h)
+		width, height = w, h
+		resized = true
+	}
+
+	// Re-encode as PNG when normalizing other formats or after scaling
+	if resized || (opts.Normalize && format != "png") {
+		data, err := encodePNG(img)
+		if err != nil {
+			if cp.logger != nil {
+				cp.logger.Warn("Failed to normalize image", zap.Error(err))
+			}
+		} else {
+			content.Data = data
+		}
+	}
+
+	if content.Metadata == nil {
+		content.Metadata = make(map[string]string)
+	}
+	content.Metadata[types.MetaWidth] = strconv.Itoa(width)
+	content.Metadata[types.MetaHeight] = strconv.Itoa(height)
+	content.Metadata[types.MetaImageFormat] = format
+	content.Metadata[types.MetaImageHash] = formatImageHash(imageHash(img))
+
+	content.Thumbnail = nil
+	if opts.ThumbnailSize > 0 {
+		w, h := fitWithin(width, height, opts.ThumbnailSize, opts.ThumbnailSize)
+		if thumbnail, err := encodePNG(scaleImage(img, w, h)); err == nil {
+			content.Thumbnail = thumbnail
+		} else if cp.logger != nil {
+			cp.logger.Warn("Failed to create thumbnail", zap.Error(err))
+		}
+	}
+
+	return content
+}
+
+// SetImageConfig sets how images are normalized, scaled and thumbnailed
+func (cp *ContentProcessor) SetImageConfig(images config.ImageConfig) {
+	cp.images = images
+}
+
+// fitWithin scales width and height down to fit maxWidth x maxHeight, keeping
+// the aspect ratio. A zero limit means no limit in that direction.
+func fitWithin(width, height, maxWidth, maxHeight int) (int, int) {
+	scale := 1.0
+	if maxWidth > 0 && width > maxWidth {
+		scale = float64(maxWidth) / float64(width)
+	}
+	if maxHeight > 0 && height > maxHeight {
+		if s := float64(maxHeight) / float64(height); s < scale {
+			scale = s
+		}
+	}
+	if scale == 1.0 {
+		return width, height
+	}
+	return max(1, int(float64(width)*scale+0.5)), max(1, int(float64(height)*scale+0.5))
+}
+
+// scaleImage resizes img to width x height by averaging the source pixels
+// covered by each destination pixel
+func scaleImage(img image.Image, width, height int) *image.RGBA {
+	src := toRGBA(img)
+	sb := src.Bounds()
+	sw, sh := sb.Dx(), sb.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := y * sh / height
+		y1 := max((y+1)*sh/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := x * sw / width
+			x1 := max((x+1)*sw/width, x0+1)
+
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint32(p[0])
+					g += uint32(p[1])
+					b += uint32(p[2])
+					a += uint32(p[3])
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// toRGBA returns img as an RGBA image with bounds starting at the origin
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	return rgba
+}
+
+// imageHash computes a 64-bit difference hash: the image is reduced to a
+// 9x8 grayscale grid and each bit records whether a cell is brighter than
+// its right neighbour. Near-identical images have hashes a few bits apart.
+func imageHash(img image.Image) uint64 {
+	small := scaleImage(img, imageHashSize+1, imageHashSize)
+
+	var hash uint64
+	for y := 0; y < imageHashSize; y++ {
+		for x := 0; x < imageHashSize; x++ {
+			left := color.GrayModel.Convert(small.At(x, y)).(color.Gray).Y
+			right := color.GrayModel.Convert(small.At(x+1, y)).(color.Gray).Y
+			hash <<= 1
+			if left > right {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// formatImageHash formats a perceptual hash for metadata
+func formatImageHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// parseImageHash parses a perceptual hash from metadata
+func parseImageHash(s string) (uint64, bool) {
+	hash, err := strconv.ParseUint(s, 16, 64)
+	return hash, err == nil && len(s) == 16
+}
+
+// imageHashDistance returns the number of differing bits between two hashes
+func imageHashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// encodePNG encodes img as PNG
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// imageGroupLookback is how many recent images are compared when grouping
+const imageGroupLookback = 10
+
+// groupSimilarImage assigns content to the group of a recent near-identical
+// image, or starts a new group. It reports whether the image is similar to
+// the most recent stored image.
+func (m *Monitor) groupSimilarImage(content *types.ClipboardContent) bool {
+	hash, ok := parseImageHash(content.Metadata[types.MetaImageHash])
+	if !ok {
+		return false
+	}
+
+	group := content.Metadata[types.MetaImageHash]
+	defer func() { content.Metadata[types.MetaImageGroup] = group }()
+
+	threshold := m.config.Images.DedupThreshold
+	if threshold < 0 || m.storage == nil {
+		return false
+	}
+
+	recent, err := m.storage.GetHistory(config.HistoryOptions{
+		ContentType: types.TypeImage,
+		Limit:       imageGroupLookback,
+		Reverse:     true,
+	})
+	if err != nil {
+		m.logger.Warn("Failed to load recent images for grouping", zap.Error(err))
+		return false
+	}
+
+	for i, item := range recent {
+		other, ok := parseImageHash(item.Metadata[types.MetaImageHash])
+		if !ok || imageHashDistance(hash, other) > threshold {
+			continue
+		}
+
+		if itemGroup := item.Metadata[types.MetaImageGroup]; itemGroup != "" {
+			group = itemGroup
+		} else {
+			group = item.Metadata[types.MetaImageHash]
+		}
+		m.logger.Debug("Grouped similar image",
+			zap.String("group", group),
+			zap.Int("distance", imageHashDistance(hash, other)))
+		return i == 0
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package clipboard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"testing"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/config"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+// gradientImage draws a diagonal gradient, shifted by offset to make variants
+func gradientImage(width, height, offset int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := uint8((x*255/width + y*128/height + offset) % 256)
+			img.Set(x, y, color.RGBA{v, 255 - v, v / 2, 255})
+		}
+	}
+	return img
+}
+
+func imageContent(t *testing.T, img image.Image) *types.ClipboardContent {
+	t.Helper()
+	data, err := encodePNG(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &types.ClipboardContent{Type: types.TypeImage, Data: data, Created: time.Now()}
+}
+
+func TestProcessImageContent(t *testing.T) {
+	cp := NewContentProcessor()
+	cp.SetImageConfig(config.ImageConfig{
+		Normalize:     true,
+		MaxWidth:      200,
+		MaxHeight:     200,
+		ThumbnailSize: 32,
+	})
+
+	// A JPEG larger than the limit is scaled down and converted to PNG
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, gradientImage(400, 100, 0), nil); err != nil {
+		t.Fatal(err)
+	}
+	content := cp.Process(&types.ClipboardContent{Type: types.TypeImage, Data: buf.Bytes(), Created: time.Now()})
+
+	img, format, err := image.Decode(bytes.NewReader(content.Data))
+	if err != nil {
+		t.Fatalf("processed image does not decode: %v", err)
+	}
+	if format != "png" || img.Bounds().Dx() != 200 || img.Bounds().Dy() != 50 {
+		t.Errorf("expected a 200x50 png, got %dx%d %s", img.Bounds().Dx(), img.Bounds().Dy(), format)
+	}
+	if content.Metadata[types.MetaWidth] != "200" || content.Metadata[types.MetaHeight] != "50" {
+		t.Errorf("unexpected dimensions metadata %v", content.Metadata)
+	}
+	if content.Metadata[types.MetaImageFormat] != "jpeg" {
+		t.Errorf("expected original format jpeg, got %q", content.Metadata[types.MetaImageFormat])
+	}
+
+	thumbnail, _, err := image.Decode(bytes.NewReader(content.Thumbnail))
+	if err != nil {
+		t.Fatalf("thumbnail does not decode: %v", err)
+	}
+	if thumbnail.Bounds().Dx() != 32 || thumbnail.Bounds().Dy() != 8 {
+		t.Errorf("expected a 32x8 thumbnail, got %v", thumbnail.Bounds())
+	}
+}
+
+func TestProcessImageContentDropsHugeImages(t *testing.T) {
+	cp := NewContentProcessor()
+	cp.SetImageConfig(config.DefaultImageConfig())
+
+	// A tiny GIF whose header claims a 65535x65535 screen
+	frame := image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black})
+	var buf bytes.Buffer
+	err := gif.EncodeAll(&buf, &gif.GIF{
+		Image:  []*image.Paletted{frame},
+		Delay:  []int{0},
+		Config: image.Config{ColorModel: frame.Palette, Width: 65535, Height: 65535},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if content := cp.Process(&types.ClipboardContent{Type: types.TypeImage, Data: buf.Bytes(), Created: time.Now()}); content != nil {
+		t.Errorf("huge image was kept: %d bytes, metadata %v", len(content.Data), content.Metadata)
+	}
+
+	// A PNG header declaring 20000x20000 pixels, past the limit however
+	// little data follows it
+	header := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR\x00\x00\x4e\x20\x00\x00\x4e\x20\x08\x06\x00\x00\x00")
+	header = append(header, crc32Bytes(header[12:])...)
+	if config, _, err := image.DecodeConfig(bytes.NewReader(header)); err != nil || config.Width != 20000 {
+		t.Fatalf("test header decodes as %+v, %v", config, err)
+	}
+	if content := cp.Process(&types.ClipboardContent{Type: types.TypeImage, Data: header, Created: time.Now()}); content != nil {
+		t.Error("image with a huge declared size was kept")
+	}
+}
+
+// crc32Bytes returns the big-endian CRC-32 that ends a PNG chunk
+func crc32Bytes(chunk []byte) []byte {
+	return binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(chunk))
+}
+
+func TestImageHashDistance(t *testing.T) {
+	original := imageHash(gradientImage(300, 200, 0))
+	similar := imageHash(gradientImage(300, 200, 2))
+	different := imageHash(gradientImage(300, 200, 128))
+
+	if d := imageHashDistance(original, similar); d > 6 {
+		t.Errorf("near-identical images are %d bits apart", d)
+	}
+	if d := imageHashDistance(original, different); d <= 6 {
+		t.Errorf("different images are only %d bits apart", d)
+	}
+}
+
+func TestMonitorGroupsSimilarImages(t *testing.T) {
+	monitor, store, _, cfg := newTestMonitor(t, "memory")
+	cfg.Images.SkipDuplicates = true
+
+	first := imageContent(t, gradientImage(300, 200, 0))
+	monitor.processNewContent(first)
+
+	second := imageContent(t, gradientImage(300, 200, 2))
+	second.Created = first.Created.Add(time.Second)
+	monitor.processNewContent(second)
+
+	third := imageContent(t, gradientImage(300, 200, 128))
+	third.Created = first.Created.Add(2 * time.Second)
+	monitor.processNewContent(third)
+
+	images, err := store.GetHistory(config.HistoryOptions{ContentType: types.TypeImage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 2 {
+		t.Fatalf("expected the near-identical image to be skipped, got %d images", len(images))
+	}
+	if images[0].Metadata[types.MetaImageGroup] == images[1].Metadata[types.MetaImageGroup] {
+		t.Error("different images share a group")
+	}
+
+	// Without skipping, the similar image is stored in the first image's group
+	cfg.Images.SkipDuplicates = false
+	fourth := imageContent(t, gradientImage(300, 200, 1))
+	fourth.Created = first.Created.Add(3 * time.Second)
+	monitor.processNewContent(fourth)
+
+	if got, want := fourth.Metadata[types.MetaImageGroup], images[0].Metadata[types.MetaImageGroup]; got != want {
+		t.Errorf("expected similar image in group %s, got %s", want, got)
+	}
+}
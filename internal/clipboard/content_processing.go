@@ -6,7 +6,9 @@ import (
 	"os"
 	"regexp"
 
+	"github.com/berrythewa/clipman-daemon/internal/config"
 	"github.com/berrythewa/clipman-daemon/internal/types"
+	"github.com/berrythewa/clipman-daemon/pkg/classify"
 	"go.uber.org/zap"
 )
 
@@ -18,6 +20,7 @@ type ContentProcessor struct {
 	filters      []ContentFilter
 	transformers []ContentTransformer
 	logger       *zap.Logger
+	images       config.ImageConfig
 	MaxSizeBytes int64 // 100MB default
 }
 
@@ -26,6 +29,7 @@ func NewContentProcessor() *ContentProcessor {
 	return &ContentProcessor{
 		filters:      []ContentFilter{},
 		transformers: []ContentTransformer{},
+		images:       config.DefaultImageConfig(),
 		MaxSizeBytes: 100 * 1024 * 1024, // 100MB default
 	}
 }
@@ -76,7 +80,12 @@ func (c *ContentProcessor) Process(content *types.ClipboardContent) *types.Clipb
 	case types.TypeFile:
 		content = c.processFileListContent(content)
 	case types.TypeImage:
-		// Images are already binary, leave as is
+		content = c.processImageContent(content)
+	case types.TypeHTML, types.TypeRTF:
+		content = c.processRichContent(content)
+	}
+	if content == nil {
+		return nil
 	}
 
 	// Apply all transformers
@@ -87,6 +96,10 @@ func (c *ContentProcessor) Process(content *types.ClipboardContent) *types.Clipb
 		}
 	}
 
+	// Classify the final data once, filters and everything after the
+	// processor use the type and subtype it sets
+	classify.Content(content)
+
 	// Apply all filters
 	for _, filter := range c.filters {
 		if !filter(content) {
@@ -245,6 +258,18 @@ func TrimTransformer() ContentTransformer {
 	}
 }
 
+// SubtypeFilter creates a filter that only allows specific content subtypes
+func SubtypeFilter(allowedSubtypes ...types.ContentSubtype) ContentFilter {
+	return func(content *types.ClipboardContent) bool {
+		for _, allowedSubtype := range allowedSubtypes {
+			if content.Subtype == allowedSubtype {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 // LowercaseTransformer creates a transformer that converts text to lowercase
 func LowercaseTransformer() ContentTransformer {
 	return func(content *types.ClipboardContent) *types.ClipboardContent {
@@ -0,0 +1,26 @@
+package clipboard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+func TestProcessClassifiesAfterTransformers(t *testing.T) {
+	cp := NewContentProcessor()
+	cp.AddTransformer(func(content *types.ClipboardContent) *types.ClipboardContent {
+		content.Data = []byte(`{"copied": "` + string(content.Data) + `"}`)
+		return content
+	})
+	cp.AddFilter(SubtypeFilter(types.SubtypeJSON))
+
+	// The transformer turned a file path into JSON, which the filter sees
+	content := cp.Process(&types.ClipboardContent{Type: types.TypeFilePath, Data: []byte("/etc/hosts"), Created: time.Now()})
+	if content == nil {
+		t.Fatal("transformed content was filtered out")
+	}
+	if content.Type != types.TypeText || content.Subtype != types.SubtypeJSON {
+		t.Errorf("content classified as %s/%s, want text/json", content.Type, content.Subtype)
+	}
+}
@@ -0,0 +1,44 @@
+package clipboard
+
+import (
+	"fmt"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"github.com/berrythewa/clipman-daemon/pkg/richtext"
+	"go.uber.org/zap"
+)
+
+// processRichContent adds plain-text and Markdown renditions to HTML and RTF
+// content. The original markup is kept so it can be pasted back as is.
+func (cp *ContentProcessor) processRichContent(content *types.ClipboardContent) *types.ClipboardContent {
+	renditions, err := renderRichText(content)
+	if err != nil {
+		if cp.logger != nil {
+			cp.logger.Debug("Failed to convert rich text, storing without renditions",
+				zap.String("content_type", string(content.Type)),
+				zap.Error(err))
+		}
+		content.PlainText, content.Markdown = "", ""
+		return content
+	}
+
+	content.PlainText = renditions.PlainText
+	content.Markdown = renditions.Markdown
+	return content
+}
+
+// renderRichText converts HTML or RTF content to plain text and Markdown
+func renderRichText(content *types.ClipboardContent) (richtext.Renditions, error) {
+	switch content.Type {
+	case types.TypeHTML:
+		return richtext.FromHTML(content.Data)
+	case types.TypeRTF:
+		return richtext.FromRTF(content.Data)
+	}
+	return richtext.Renditions{}, fmt.Errorf("content type %s is not rich text", content.Type)
+}
+
+// isRichText reports whether a content type has plain-text and Markdown renditions
+func isRichText(contentType types.ContentType) bool {
+	return contentType == types.TypeHTML || contentType == types.TypeRTF
+}
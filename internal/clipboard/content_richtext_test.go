@@ -0,0 +1,45 @@
+package clipboard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/config"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+func TestMonitorStoresRichTextRenditions(t *testing.T) {
+	monitor, store, _, _ := newTestMonitor(t, "memory")
+
+	html := `<p>Quarterly <b>report</b></p><script>track()</script>`
+	monitor.processNewContent(&types.ClipboardContent{
+		Type:    types.TypeHTML,
+		Data:    []byte(html),
+		Created: time.Now(),
+	})
+
+	items, err := store.GetHistory(config.HistoryOptions{Search: "quarterly REPORT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("expected the HTML item to match a search of its text, got %d items", len(items))
+	}
+
+	item := items[0]
+	if item.Type != types.TypeHTML || string(item.Data) != html {
+		t.Errorf("expected the original HTML to be kept, got %s %q", item.Type, item.Data)
+	}
+	if item.PlainText != "Quarterly report" || item.Markdown != "Quarterly **report**" {
+		t.Errorf("unexpected renditions %q, %q", item.PlainText, item.Markdown)
+	}
+
+	// Markup is not searchable
+	items, err = store.GetHistory(config.HistoryOptions{Search: "<b>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 0 {
+		t.Errorf("expected no match for markup, got %d items", len(items))
+	}
+}
@@ -0,0 +1,148 @@
+package clipboard
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"github.com/berrythewa/clipman-daemon/internal/config"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+// maxRedirectUnwraps limits how many nested redirect wrappers are removed
+const maxRedirectUnwraps = 5
+
+// URLCleanTransformer creates a transformer that unwraps redirect links and
+// removes tracking parameters from copied URLs. The URL as copied is kept
+// in the original_url metadata when it changes.
+func URLCleanTransformer(opts config.URLCleaningConfig) ContentTransformer {
+	return func(content *types.ClipboardContent) *types.ClipboardContent {
+		// URLs are usually reported as text, they are only typed later
+		switch content.Type {
+		case types.TypeURL, types.TypeText, types.TypeString:
+		default:
+			return content
+		}
+
+		original := string(bytes.TrimSpace(content.Data))
+		if strings.ContainsAny(original, " \t\r\n") {
+			return content
+		}
+
+		cleaned, ok := cleanURL(original, opts)
+		if !ok || cleaned == original {
+			return content
+		}
+
+		content.Data = []byte(cleaned)
+		if content.Metadata == nil {
+			content.Metadata = make(map[string]string)
+		}
+		content.Metadata[types.MetaOriginalURL] = original
+		return content
+	}
+}
+
+// cleanURL canonicalizes a web URL. It reports false if raw is not one.
+func cleanURL(raw string, opts config.URLCleaningConfig) (string, bool) {
+	u, ok := parseWebURL(raw)
+	if !ok {
+		return "", false
+	}
+
+	if opts.UnwrapRedirects {
+		for i := 0; i < maxRedirectUnwraps; i++ {
+			target, ok := unwrapRedirect(u, opts.RedirectRules)
+			if !ok {
+				break
+			}
+			u = target
+		}
+	}
+
+	u.Host = strings.ToLower(u.Host)
+	if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
+		u.Host = u.Hostname()
+	}
+	u.RawQuery = stripParams(u.RawQuery, opts.StripParams)
+	u.ForceQuery = false
+	return u.String(), true
+}
+
+// parseWebURL parses an absolute http or https URL
+func parseWebURL(raw string) (*url.URL, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, false
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, false
+	}
+	return u, true
+}
+
+// unwrapRedirect returns the target of a redirect wrapper matching one of the rules
+func unwrapRedirect(u *url.URL, rules []config.RedirectRule) (*url.URL, bool) {
+	host := strings.ToLower(u.Hostname())
+	for _, rule := range rules {
+		if !matchHost(host, strings.ToLower(rule.Host)) {
+			continue
+		}
+		if rule.Path != "" && u.Path != rule.Path {
+			continue
+		}
+		// Only unwrap to another web URL, never to javascript: or similar
+		if target, ok := parseWebURL(u.Query().Get(rule.Param)); ok {
+			return target, true
+		}
+	}
+	return nil, false
+}
+
+// matchHost reports whether host matches pattern, where "*.example.com"
+// matches example.com and its subdomains
+func matchHost(host, pattern string) bool {
+	if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+		return host == domain || strings.HasSuffix(host, "."+domain)
+	}
+	return host == pattern
+}
+
+// stripParams removes matching parameters from a raw query, keeping the
+// order and encoding of the others
+func stripParams(rawQuery string, patterns []string) string {
+	if rawQuery == "" || len(patterns) == 0 {
+		return rawQuery
+	}
+
+	parts := strings.Split(rawQuery, "&")
+	kept := parts[:0]
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(part, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+		if !matchParam(strings.ToLower(key), patterns) {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+// matchParam reports whether a parameter name matches one of the patterns
+func matchParam(key string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == pattern {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package clipboard
+
+import (
+	"testing"
+
+	"github.com/berrythewa/clipman-daemon/internal/config"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+func TestURLCleanTransformer(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"tracking parameters", "https://example.com/post?id=7&utm_source=x&UTM_Medium=y&fbclid=abc#top", "https://example.com/post?id=7#top"},
+		{"only tracking parameters", "https://Example.com:443/a?gclid=1", "https://example.com/a"},
+		{"google redirect", "https://www.google.com/url?sa=t&url=https%3A%2F%2Fexample.com%2Fdoc%3Fmc_cid%3D1%26page%3D2&usg=x", "https://example.com/doc?page=2"},
+		{"outlook safelink", "https://eur01.safelinks.protection.outlook.com/?url=https%3A%2F%2Fexample.org%2F&data=05", "https://example.org/"},
+		{"unsafe redirect target", "https://www.google.com/url?q=javascript:alert(1)", "https://www.google.com/url?q=javascript:alert(1)"},
+		{"clean url", "https://example.com/a?b=c", "https://example.com/a?b=c"},
+		{"not a url", "see https://example.com?utm_source=x", "see https://example.com?utm_source=x"},
+	}
+
+	transform := URLCleanTransformer(config.DefaultURLCleaningConfig())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := transform(&types.ClipboardContent{Type: types.TypeText, Data: []byte(tt.in)})
+			if got := string(content.Data); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+
+			original, ok := content.Metadata[types.MetaOriginalURL]
+			if changed := tt.in != tt.want; ok != changed || (changed && original != tt.in) {
+				t.Errorf("unexpected original_url %q", original)
+			}
+		})
+	}
+}
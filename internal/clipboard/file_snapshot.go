@@ -0,0 +1,222 @@
+package clipboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/berrythewa/clipman-daemon/internal/config"
+	"github.com/berrythewa/clipman-daemon/internal/storage"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"go.uber.org/zap"
+)
+
+// snapshotFiles copies the files referenced by a file item into the blob
+// area, so the item stays usable if the files are moved or deleted. Files
+// over the size limits are skipped and only their paths are kept.
+func (m *Monitor) snapshotFiles(content *types.ClipboardContent) {
+	opts := m.config.FileSnapshots
+	if !opts.Enabled || m.storage == nil || m.storage.Blobs() == nil {
+		return
+	}
+
+	paths := contentFilePaths(content)
+	if len(paths) == 0 {
+		return
+	}
+
+	blobs := m.storage.Blobs()
+	maxFileSize := int64(m.config.Sync.MaxFileSizeMB) * 1024 * 1024
+	maxTotalSize := int64(opts.MaxTotalMB) * 1024 * 1024
+
+	var total int64
+	content.Files = nil
+	for _, path := range paths {
+		if opts.MaxFiles > 0 && len(content.Files) >= opts.MaxFiles {
+			m.logger.Info("File snapshot limit reached, keeping remaining files as paths only",
+				zap.Int("max_files", opts.MaxFiles))
+			break
+		}
+
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			m.logger.Debug("Not snapshotting path, it is not a readable regular file", zap.String("path", path))
+			continue
+		}
+		if maxFileSize > 0 && info.Size() > maxFileSize {
+			m.logger.Info("File too large to snapshot",
+				zap.String("path", path),
+				zap.Int64("size", info.Size()),
+				zap.Int("max_file_size_mb", m.config.Sync.MaxFileSizeMB))
+			continue
+		}
+		if maxTotalSize > 0 && total+info.Size() > maxTotalSize {
+			m.logger.Info("File snapshot size limit reached, skipping file",
+				zap.String("path", path),
+				zap.Int("max_total_mb", opts.MaxTotalMB))
+			continue
+		}
+
+		hash, size, err := blobs.Put(path, maxFileSize)
+		if err != nil {
+			m.logger.Warn("Failed to snapshot file", zap.String("path", path), zap.Error(err))
+			continue
+		}
+
+		total += size
+		content.Files = append(content.Files, types.FileSnapshot{
+			Path:    path,
+			Size:    size,
+			Mode:    info.Mode(),
+			ModTime: info.ModTime(),
+			Hash:    hash,
+		})
+	}
+
+	if len(content.Files) > 0 {
+		m.logger.Debug("Snapshotted copied files",
+			zap.Int("files", len(content.Files)),
+			zap.Int64("bytes", total))
+	}
+}
+
+// restoreDir returns where snapshotted files are recreated
+func (m *Monitor) restoreDir() string {
+	return RestoreDir(m.config)
+}
+
+// RestoreDir returns where snapshotted files are recreated for a configuration
+func RestoreDir(cfg *config.Config) string {
+	if dir := cfg.FileSnapshots.RestoreDir; dir != "" {
+		return dir
+	}
+	return filepath.Join(cfg.GetSystemPaths().TempDir, "restored")
+}
+
+// RestoreFiles recreates the snapshotted files of a file item that are no
+// longer at their original paths under dir, and returns a copy of content
+// that points at them. Files that are unchanged, or whose snapshot is not
+// available, keep their original paths.
+func RestoreFiles(content *types.ClipboardContent, blobs *storage.BlobStore, dir string) (*types.ClipboardContent, error) {
+	if len(content.Files) == 0 || blobs == nil {
+		return content, nil
+	}
+
+	// Each item gets its own directory so restores of different items don't collide
+	target := filepath.Join(dir, content.Created.UTC().Format("20060102-150405.000000000"))
+	restored := make(map[string]string)
+	used := make(map[string]bool)
+
+	for _, file := range content.Files {
+		if fileUnchanged(file) || !blobs.Has(file.Hash) {
+			continue
+		}
+
+		path := uniquePath(target, filepath.Base(file.Path), used)
+		if err := restoreFile(blobs, file, path); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", file.Path, err)
+		}
+		restored[file.Path] = path
+	}
+
+	if len(restored) == 0 {
+		return content, nil
+	}
+
+	result := *content
+	switch content.Type {
+	case types.TypeFilePath:
+		if path, ok := restored[strings.TrimSpace(string(content.Data))]; ok {
+			result.Data = []byte(path)
+		}
+	case types.TypeFile:
+		var paths []string
+		if err := json.Unmarshal(content.Data, &paths); err != nil {
+			return nil, fmt.Errorf("invalid file list data: %w", err)
+		}
+		for i, path := range paths {
+			if restoredPath, ok := restored[path]; ok {
+				paths[i] = restoredPath
+			}
+		}
+		data, err := json.Marshal(paths)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode file list: %w", err)
+		}
+		result.Data = data
+	}
+	return &result, nil
+}
+
+// contentFilePaths returns the paths referenced by a file item
+func contentFilePaths(content *types.ClipboardContent) []string {
+	switch content.Type {
+	case types.TypeFilePath:
+		if path := strings.TrimSpace(string(content.Data)); path != "" {
+			return []string{path}
+		}
+	case types.TypeFile:
+		var paths []string
+		if err := json.Unmarshal(content.Data, &paths); err == nil {
+			return paths
+		}
+	}
+	return nil
+}
+
+// fileUnchanged reports whether a snapshotted file is still at its path unmodified
+func fileUnchanged(file types.FileSnapshot) bool {
+	info, err := os.Stat(file.Path)
+	return err == nil && info.Mode().IsRegular() &&
+		info.Size() == file.Size && info.ModTime().Equal(file.ModTime)
+}
+
+// uniquePath returns a path for name in dir that hasn't been used in this restore
+func uniquePath(dir, name string, used map[string]bool) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 2; used[candidate]; i++ {
+		candidate = base + " (" + strconv.Itoa(i) + ")" + ext
+	}
+	used[candidate] = true
+	return filepath.Join(dir, candidate)
+}
+
+// restoreFile writes a snapshot to path with its original mode and modification time
+func restoreFile(blobs *storage.BlobStore, file types.FileSnapshot, path string) error {
+	src, err := blobs.Open(file.Hash)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), file.Mode.Perm()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return os.Chtimes(path, file.ModTime, file.ModTime)
+}
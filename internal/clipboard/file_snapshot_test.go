@@ -0,0 +1,79 @@
+package clipboard
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+func TestFileSnapshotAndRestore(t *testing.T) {
+	monitor, store, _, cfg := newTestMonitor(t, "memory")
+	cfg.FileSnapshots.Enabled = true
+	cfg.FileSnapshots.MaxFiles = 2
+
+	// Same base name in two directories, plus one file over the count limit
+	src := t.TempDir()
+	paths := []string{
+		filepath.Join(src, "one", "notes.txt"),
+		filepath.Join(src, "two", "notes.txt"),
+		filepath.Join(src, "extra.txt"),
+	}
+	for i, path := range paths {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte{byte('a' + i)}, 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, _ := json.Marshal(paths)
+	monitor.processNewContent(&types.ClipboardContent{Type: types.TypeFile, Data: data, Created: time.Now()})
+
+	item, err := store.GetLatestContent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Type != types.TypeFile {
+		t.Fatalf("expected a file item, got %s", item.Type)
+	}
+	if len(item.Files) != 2 {
+		t.Fatalf("expected 2 snapshots with max_files 2, got %d", len(item.Files))
+	}
+
+	// Unchanged files are not restored
+	if restored, err := RestoreFiles(item, store.Blobs(), t.TempDir()); err != nil || restored != item {
+		t.Fatalf("expected unchanged files to keep their paths, got %v", err)
+	}
+
+	for _, path := range paths {
+		os.Remove(path)
+	}
+
+	restoreDir := t.TempDir()
+	restored, err := RestoreFiles(item, store.Blobs(), restoreDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var restoredPaths []string
+	if err := json.Unmarshal(restored.Data, &restoredPaths); err != nil {
+		t.Fatal(err)
+	}
+	if restoredPaths[0] == restoredPaths[1] || restoredPaths[2] != paths[2] {
+		t.Fatalf("unexpected restored paths %v", restoredPaths)
+	}
+	for i, path := range restoredPaths[:2] {
+		got, err := os.ReadFile(path)
+		if err != nil || string(got) != string(rune('a'+i)) {
+			t.Errorf("restored %s has %q, %v", path, got, err)
+		}
+		if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0640 {
+			t.Errorf("restored %s with mode %v", path, info.Mode().Perm())
+		}
+	}
+}
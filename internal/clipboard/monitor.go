@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/berrythewa/clipman-daemon/internal/config"
+	"github.com/berrythewa/clipman-daemon/internal/hooks"
 	"github.com/berrythewa/clipman-daemon/internal/storage"
 	"github.com/berrythewa/clipman-daemon/internal/types"
 	"go.uber.org/zap"
@@ -31,15 +32,37 @@ type Monitor struct {
 	ctx              context.Context
 	cancel           context.CancelFunc
 	contentProcessor *ContentProcessor
+	hooks            *hooks.Runner
+	
+	// Pause state
+	pause            PauseState
+	pauseMu          sync.Mutex
+	pauseTimer       *time.Timer
+	
+	// Paste queue
+	queue            pasteQueue
+	queueMu          sync.Mutex
 }
 
 func NewMonitor(cfg *config.Config, contentPublisher ContentPublisher, logger *zap.Logger, storage *storage.BoltStorage) *Monitor {
 	ctx, cancel := context.WithCancel(context.Background())
+	
+	// Pick the clipboard backend from config, falling back to the OS clipboard
+	clip, err := NewClipboardFromConfig(cfg)
+	if err != nil {
+		logger.Error("Failed to create configured clipboard backend, using native clipboard",
+			zap.String("backend", cfg.Clipboard.Backend),
+			zap.Error(err))
+		clip = NewClipboard()
+	} else if cfg.Clipboard.Backend != "" && cfg.Clipboard.Backend != "native" {
+		logger.Info("Using headless clipboard backend", zap.String("backend", cfg.Clipboard.Backend))
+	}
+	
 	m := &Monitor{
 		config:           cfg,
 		contentPublisher: contentPublisher,
 		logger:           logger,
-		clipboard:        NewClipboard(),
+		clipboard:        clip,
 		storage:          storage,
 		history:          NewClipboardHistory(100), // Keep last 100 items
 		ctx:              ctx,
@@ -55,9 +78,33 @@ func NewMonitor(cfg *config.Config, contentPublisher ContentPublisher, logger *z
 		m.contentProcessor.SetMaxSize(cfg.Storage.MaxSize)
 	}
 	
+	// Normalize, scale and thumbnail images
+	m.contentProcessor.SetImageConfig(cfg.Images)
+	
 	// Add type-specific transformers for trimming text
 	m.contentProcessor.AddTransformer(TrimTransformer())
 	
+	// Unwrap redirect links and strip tracking parameters from URLs
+	if cfg.URLCleaning.Enabled {
+		if err := cfg.ValidateURLCleaningConfig(); err != nil {
+			logger.Error("Invalid URL cleaning configuration, URLs are kept as copied", zap.Error(err))
+		} else {
+			m.contentProcessor.AddTransformer(URLCleanTransformer(cfg.URLCleaning))
+		}
+	}
+	
+	// Set up user hooks; transform hooks run as part of content processing
+	runner, err := hooks.NewRunner(cfg, logger)
+	if err != nil {
+		logger.Error("Failed to set up hooks, hooks are disabled", zap.Error(err))
+	} else if runner.HasHooks() {
+		m.hooks = runner
+		m.contentProcessor.AddTransformer(runner.Transformer())
+		if storage != nil {
+			storage.SetEvictHandler(m.handleEvicted)
+		}
+	}
+	
 	// Configure the clipboard with the stealth mode settings
 	if clipLinux, ok := m.clipboard.(interface {
 		SetStealthMode(bool)
@@ -90,6 +137,9 @@ func (m *Monitor) Start() error {
 	} else if lastContent != nil {
 		m.lastContent = lastContent
 	}
+	
+	// Stay paused if we were paused before a restart
+	m.restorePauseState()
 
 	go m.monitorClipboard()
 	return nil
@@ -97,7 +147,15 @@ func (m *Monitor) Start() error {
 
 func (m *Monitor) Stop() error {
 	m.logger.Info("Stopping clipboard monitor")
+	m.pauseMu.Lock()
+	if m.pauseTimer != nil {
+		m.pauseTimer.Stop()
+	}
+	m.pauseMu.Unlock()
 	m.cancel()
+	
+	// Let running hooks finish
+	m.hooks.Wait()
 	return nil
 }
 
@@ -128,14 +186,21 @@ func (m *Monitor) monitorClipboard() {
 				zap.Int("size", len(content.Data)),
 				zap.String("data_preview", string(content.Data[:min(len(content.Data), 50)])))
 			
+			// Drop changes entirely while paused, nothing is stored or synced
+			if m.IsPaused() {
+				m.logger.Debug("Clipboard capture paused, ignoring change")
+				continue
+			}
+			
 			m.mu.Lock()
-			if !m.isContentEqual(content, m.lastContent) {
+			changed := !m.isContentEqual(content, m.lastContent)
+			m.mu.Unlock()
+			if changed {
 				m.logger.Debug("Processing new content")
 				m.processNewContent(content)
 			} else {
 				m.logger.Debug("Content is equal to previous, skipping")
 			}
-			m.mu.Unlock()
 		}
 	}
 }
@@ -169,9 +234,27 @@ func (m *Monitor) processNewContent(content *types.ClipboardContent) {
 	}
 
 	content = m.prepareContent(content)
+	
+	// Keep copies of copied files so the item outlives them. Copying can
+	// take a while, so it runs before taking the lock peer content needs.
+	m.snapshotFiles(content)
+	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	// Group near-identical images, optionally dropping repeats
+	if content.Type == types.TypeImage && m.groupSimilarImage(content) && m.config.Images.SkipDuplicates {
+		m.logger.Info("Image is nearly identical to the previous one, skipping")
+		m.lastContent = content
+		return
+	}
+	
+	m.hooks.Fire(config.HookEventCopy, content, nil)
 
 	if err := m.saveContent(content); err != nil {
 		m.logger.Error("Failed to save content", zap.Error(err))
+	} else {
+		m.hooks.Fire(config.HookEventStore, content, nil)
 	}
 
 	if err := m.publishContent(content); err != nil {
@@ -180,6 +263,7 @@ func (m *Monitor) processNewContent(content *types.ClipboardContent) {
 
 	m.history.Add(content)
 	m.lastContent = content
+	m.enqueue(content)
 
 	m.logger.Info("--- Current Clipboard History ---")
 	history := m.history.GetLast(5)
@@ -211,7 +295,13 @@ func (m *Monitor) processNewContent(content *types.ClipboardContent) {
 }
 
 func (m *Monitor) prepareContent(content *types.ClipboardContent) *types.ClipboardContent {
-	content.Type = detectContentType(content.Data)
+	// Rich text keeps the type reported by the clipboard, its markup
+	// would otherwise be detected as plain text
+	if isRichText(content.Type) {
+		// Transformers may have changed the markup, so render again
+		return m.contentProcessor.processRichContent(content)
+	}
+	// The content processor classified the rest after its transformers
 	// compressedContent, err := compression.CompressContent(content)
 	// if err != nil {
 	// 	m.logger.Error("Failed to compress content", "error", err)
@@ -232,12 +322,8 @@ func (m *Monitor) publishContent(content *types.ClipboardContent) error {
 		return nil
 	}
 	
-	// Check if the publisher is connected
-	if !m.contentPublisher.IsConnected() {
-		m.logger.Debug("Content publisher not connected, skipping content publish")
-		return nil
-	}
-	
+	// The publisher decides what to do while not connected, the sync
+	// publisher queues content in its outbox
 	return m.contentPublisher.PublishContent(content)
 }
 
@@ -272,6 +358,18 @@ func (m *Monitor) isContentEqual(content1, content2 *types.ClipboardContent) boo
 	return true
 }
 
+// handleEvicted runs evict hooks for content removed from storage
+func (m *Monitor) handleEvicted(contents []*types.ClipboardContent) {
+	for _, content := range contents {
+		m.hooks.Fire(config.HookEventEvict, content, nil)
+	}
+}
+
+// GetClipboard returns the clipboard backend the monitor reads from
+func (m *Monitor) GetClipboard() Clipboard {
+	return m.clipboard
+}
+
 // New method to get clipboard history
 func (m *Monitor) GetHistory(n int) []*HistoryItem {
 	return m.history.GetLast(n)
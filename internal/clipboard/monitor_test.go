@@ -0,0 +1,133 @@
+package clipboard
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/config"
+	"github.com/berrythewa/clipman-daemon/internal/platform"
+	"github.com/berrythewa/clipman-daemon/internal/storage"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"go.uber.org/zap"
+)
+
+// recordingPublisher collects everything the monitor publishes
+type recordingPublisher struct {
+	mu        sync.Mutex
+	published []*types.ClipboardContent
+}
+
+func (p *recordingPublisher) PublishContent(content *types.ClipboardContent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.published = append(p.published, content)
+	return nil
+}
+
+func (p *recordingPublisher) IsConnected() bool {
+	return true
+}
+
+func (p *recordingPublisher) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.published)
+}
+
+// newTestMonitor builds a monitor on a headless backend with real storage
+func newTestMonitor(t *testing.T, backend string) (*Monitor, *storage.BoltStorage, *recordingPublisher, *config.Config) {
+	t.Helper()
+
+	dir := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.SystemPaths.DataDir = dir
+	cfg.Clipboard.Backend = backend
+	cfg.PollingInterval = 20
+
+	store, err := storage.NewBoltStorage(storage.StorageConfig{
+		DBPath:   filepath.Join(dir, "clipman.db"),
+		BlobDir:  filepath.Join(dir, "blobs"),
+		DeviceID: "test-device",
+		Logger:   zap.NewNop(),
+	})
+	if err != nil {
+		t.Fatalf("failed to open storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	publisher := &recordingPublisher{}
+	monitor := NewMonitor(cfg, publisher, zap.NewNop(), store)
+	t.Cleanup(func() { monitor.Stop() })
+
+	return monitor, store, publisher, cfg
+}
+
+// waitFor polls cond until it holds or the test times out
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestMonitorMemoryBackendEndToEnd(t *testing.T) {
+	monitor, store, publisher, _ := newTestMonitor(t, platform.BackendMemory)
+
+	clip, ok := monitor.GetClipboard().(*platform.HeadlessClipboard)
+	if !ok {
+		t.Fatalf("expected headless clipboard, got %T", monitor.GetClipboard())
+	}
+	if err := monitor.Start(); err != nil {
+		t.Fatalf("failed to start monitor: %v", err)
+	}
+
+	clip.SetContent(&types.ClipboardContent{Type: types.TypeText, Data: []byte("  hello headless  "), Created: time.Now()})
+
+	waitFor(t, "content to be published", func() bool { return publisher.count() == 1 })
+
+	latest, err := store.GetLatestContent()
+	if err != nil {
+		t.Fatalf("failed to read latest content: %v", err)
+	}
+	if latest == nil || string(latest.Data) != "hello headless" {
+		t.Fatalf("unexpected stored content: %+v", latest)
+	}
+
+	// Content written by the daemon itself must not be captured again
+	clip.Write(&types.ClipboardContent{Type: types.TypeText, Data: []byte("from a peer"), Created: time.Now()})
+	time.Sleep(100 * time.Millisecond)
+	if publisher.count() != 1 {
+		t.Fatalf("write was captured as a new copy, published %d items", publisher.count())
+	}
+}
+
+func TestMonitorFileBackendEndToEnd(t *testing.T) {
+	monitor, store, publisher, cfg := newTestMonitor(t, platform.BackendFile)
+
+	if err := monitor.Start(); err != nil {
+		t.Fatalf("failed to start monitor: %v", err)
+	}
+
+	path := filepath.Join(cfg.SystemPaths.DataDir, "clipboard")
+	if err := os.WriteFile(path, []byte("copied from another process"), 0600); err != nil {
+		t.Fatalf("failed to write clipboard file: %v", err)
+	}
+
+	waitFor(t, "content to be published", func() bool { return publisher.count() == 1 })
+
+	latest, err := store.GetLatestContent()
+	if err != nil {
+		t.Fatalf("failed to read latest content: %v", err)
+	}
+	if latest == nil || string(latest.Data) != "copied from another process" {
+		t.Fatalf("unexpected stored content: %+v", latest)
+	}
+}
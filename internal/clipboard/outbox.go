@@ -0,0 +1,158 @@
+package clipboard
+
+import (
+	"sync"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/config"
+	"github.com/berrythewa/clipman-daemon/internal/storage"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"go.uber.org/zap"
+)
+
+// OutboxState describes content waiting to be delivered to peers
+type OutboxState struct {
+	Queued        int       `json:"queued"`         // Items waiting for a peer
+	Bytes         int64     `json:"bytes"`          // Space the waiting items take
+	Oldest        time.Time `json:"oldest"`         // When the longest waiting item was queued
+	Delivered     int       `json:"delivered"`      // Items delivered from the outbox since the daemon started
+	Dropped       int       `json:"dropped"`        // Items dropped for age or space since the daemon started
+	LastDelivered time.Time `json:"last_delivered"` // When an item was last delivered from the outbox
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+// Outbox keeps content published while no peer is in the group and delivers
+// it once one joins. It is kept in storage, so it survives restarts, and is
+// bounded by age and size.
+type Outbox struct {
+	store   *storage.BoltStorage
+	maxAge  time.Duration
+	maxSize int64
+	logger  *zap.Logger
+
+	mu       sync.Mutex
+	state    OutboxState // Counters, the waiting items are read from storage
+	draining map[string]bool
+}
+
+// NewOutbox creates an outbox in storage, limited by the sync configuration
+func NewOutbox(store *storage.BoltStorage, cfg *config.Config, logger *zap.Logger) *Outbox {
+	return &Outbox{
+		store:    store,
+		maxAge:   time.Duration(cfg.Sync.OutboxTTLMinutes) * time.Minute,
+		maxSize:  int64(cfg.Sync.OutboxMaxSizeKB) * 1024,
+		logger:   logger,
+		draining: make(map[string]bool),
+	}
+}
+
+// Queue keeps content for a group until it can be delivered. The reason is
+// reported in the status until the content is delivered.
+func (o *Outbox) Queue(content *types.ClipboardContent, group string, reason string) error {
+	entry := &storage.OutboxEntry{
+		Group:     group,
+		Content:   content,
+		Queued:    time.Now(),
+		LastError: reason,
+	}
+	if err := o.store.QueueOutgoing(entry); err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	o.state.LastError = reason
+	o.mu.Unlock()
+	o.prune()
+	return nil
+}
+
+// prune drops content that waited too long or doesn't fit
+func (o *Outbox) prune() {
+	dropped, err := o.store.PruneOutbox(o.maxAge, o.maxSize)
+	if err != nil {
+		o.logger.Warn("Failed to prune outbox", zap.Error(err))
+		return
+	}
+	if len(dropped) == 0 {
+		return
+	}
+
+	o.mu.Lock()
+	o.state.Dropped += len(dropped)
+	o.mu.Unlock()
+	o.logger.Info("Dropped content no peer came online for",
+		zap.Int("items", len(dropped)),
+		zap.Time("oldest_queued", dropped[0].Queued))
+}
+
+// Drain delivers the content queued for a group with send, oldest first, and
+// returns how many items were delivered. It stops at the first failure,
+// leaving the rest queued. Only one drain of a group runs at a time.
+func (o *Outbox) Drain(group string, send func(*types.ClipboardContent) error) int {
+	o.mu.Lock()
+	if o.draining[group] {
+		o.mu.Unlock()
+		return 0
+	}
+	o.draining[group] = true
+	o.mu.Unlock()
+	defer func() {
+		o.mu.Lock()
+		delete(o.draining, group)
+		o.mu.Unlock()
+	}()
+
+	o.prune()
+	entries, err := o.store.OutgoingEntries(group)
+	if err != nil {
+		o.logger.Warn("Failed to read outbox", zap.Error(err))
+		return 0
+	}
+
+	delivered := 0
+	for _, entry := range entries {
+		if err := send(entry.Content); err != nil {
+			entry.Attempts++
+			entry.LastError = err.Error()
+			if err := o.store.QueueOutgoing(entry); err != nil {
+				o.logger.Warn("Failed to update outbox", zap.Error(err))
+			}
+			o.mu.Lock()
+			o.state.LastError = entry.LastError
+			o.mu.Unlock()
+			break
+		}
+		if err := o.store.RemoveOutgoing(entry); err != nil {
+			o.logger.Warn("Failed to remove delivered content from outbox", zap.Error(err))
+		}
+
+		delivered++
+		o.mu.Lock()
+		o.state.Delivered++
+		o.state.LastDelivered = time.Now()
+		o.state.LastError = ""
+		o.mu.Unlock()
+	}
+	return delivered
+}
+
+// State reports the content waiting and what was delivered
+func (o *Outbox) State() OutboxState {
+	o.mu.Lock()
+	state := o.state
+	o.mu.Unlock()
+
+	entries, err := o.store.OutgoingEntries("")
+	if err != nil {
+		o.logger.Warn("Failed to read outbox", zap.Error(err))
+		return state
+	}
+	state.Queued = len(entries)
+	for _, entry := range entries {
+		state.Bytes += int64(entry.Size)
+		if state.Oldest.IsZero() || entry.Queued.Before(state.Oldest) {
+			state.Oldest = entry.Queued
+		}
+	}
+	return state
+}
@@ -0,0 +1,105 @@
+package clipboard
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/config"
+	"github.com/berrythewa/clipman-daemon/internal/storage"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"go.uber.org/zap"
+)
+
+func newTestOutbox(t *testing.T, ttlMinutes, maxSizeKB int) (*Outbox, *storage.BoltStorage) {
+	t.Helper()
+
+	dir := t.TempDir()
+	store, err := storage.NewBoltStorage(storage.StorageConfig{
+		DBPath: filepath.Join(dir, "clipman.db"),
+		Logger: zap.NewNop(),
+	})
+	if err != nil {
+		t.Fatalf("failed to open storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	cfg := config.DefaultConfig()
+	cfg.Sync.OutboxTTLMinutes = ttlMinutes
+	cfg.Sync.OutboxMaxSizeKB = maxSizeKB
+	return NewOutbox(store, cfg, zap.NewNop()), store
+}
+
+func newOutboxContent(text string, created time.Time) *types.ClipboardContent {
+	return &types.ClipboardContent{Type: types.TypeText, Data: []byte(text), Created: created}
+}
+
+func TestOutboxDrain(t *testing.T) {
+	outbox, _ := newTestOutbox(t, 60, 1024)
+	base := time.Now()
+	for i, text := range []string{"first", "second", "third"} {
+		if err := outbox.Queue(newOutboxContent(text, base.Add(time.Duration(i)*time.Second)), "group", "offline"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	outbox.Queue(newOutboxContent("other", base), "other-group", "offline")
+
+	if state := outbox.State(); state.Queued != 4 || state.Bytes == 0 || state.LastError != "offline" {
+		t.Errorf("state after queueing = %+v", state)
+	}
+
+	// Delivery stops at the first failure, keeping the rest in order
+	var sent []string
+	failed := errors.New("publish failed")
+	delivered := outbox.Drain("group", func(content *types.ClipboardContent) error {
+		if string(content.Data) == "second" {
+			return failed
+		}
+		sent = append(sent, string(content.Data))
+		return nil
+	})
+	if delivered != 1 || len(sent) != 1 || sent[0] != "first" {
+		t.Fatalf("delivered %d: %v", delivered, sent)
+	}
+	if state := outbox.State(); state.Queued != 3 || state.LastError != failed.Error() {
+		t.Errorf("state after failure = %+v", state)
+	}
+
+	sent = nil
+	outbox.Drain("group", func(content *types.ClipboardContent) error {
+		sent = append(sent, string(content.Data))
+		return nil
+	})
+	if len(sent) != 2 || sent[0] != "second" || sent[1] != "third" {
+		t.Errorf("second drain sent %v", sent)
+	}
+	state := outbox.State()
+	if state.Queued != 1 || state.Delivered != 3 || state.LastError != "" || state.LastDelivered.IsZero() {
+		t.Errorf("state after delivery = %+v", state)
+	}
+}
+
+func TestOutboxLimits(t *testing.T) {
+	outbox, store := newTestOutbox(t, 60, 1)
+
+	// Content that waited longer than the TTL is dropped
+	old := &storage.OutboxEntry{Group: "group", Content: newOutboxContent("old", time.Now()), Queued: time.Now().Add(-2 * time.Hour)}
+	if err := store.QueueOutgoing(old); err != nil {
+		t.Fatal(err)
+	}
+	outbox.Queue(newOutboxContent("new", time.Now().Add(time.Second)), "group", "offline")
+	if state := outbox.State(); state.Queued != 1 || state.Dropped != 1 {
+		t.Errorf("state after expiry = %+v", state)
+	}
+
+	// Over the size limit, the longest waiting content is dropped first
+	outbox.Queue(newOutboxContent(string(make([]byte, 600)), time.Now().Add(2*time.Second)), "group", "offline")
+	entries, err := store.OutgoingEntries("group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || len(entries[0].Content.Data) != 600 {
+		t.Errorf("outbox holds %d entries after exceeding its size", len(entries))
+	}
+}
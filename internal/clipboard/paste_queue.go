@@ -0,0 +1,142 @@
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"go.uber.org/zap"
+)
+
+// QueueOrder selects which queued item is pasted next
+type QueueOrder string
+
+const (
+	QueueFIFO QueueOrder = "fifo" // Paste in the order items were copied
+	QueueLIFO QueueOrder = "lifo" // Paste the most recent copy first
+)
+
+// maxQueueLength bounds the paste queue, the oldest items are dropped beyond it
+const maxQueueLength = 100
+
+// ErrQueueInactive is returned when pasting from the queue while queue mode is off
+var ErrQueueInactive = errors.New("paste queue is not active")
+
+// ErrQueueEmpty is returned when pasting from an empty queue
+var ErrQueueEmpty = errors.New("paste queue is empty")
+
+// QueueState describes the paste queue
+type QueueState struct {
+	Active bool       `json:"active"`
+	Order  QueueOrder `json:"order,omitempty"`
+	Queued int        `json:"queued"` // Items waiting to be pasted
+	Pasted int        `json:"pasted"` // Items written to the clipboard so far
+}
+
+// pasteQueue holds copies captured while queue mode is active
+type pasteQueue struct {
+	state QueueState
+	items []*types.ClipboardContent
+}
+
+// ParseQueueOrder parses "fifo" or "lifo"
+func ParseQueueOrder(s string) (QueueOrder, error) {
+	switch order := QueueOrder(s); order {
+	case QueueFIFO, QueueLIFO:
+		return order, nil
+	}
+	return "", fmt.Errorf("invalid queue order %q, use fifo or lifo", s)
+}
+
+// StartQueue turns on queue mode. Each copy from now on is queued until it
+// is pasted with PasteNext. Starting again clears the queue.
+func (m *Monitor) StartQueue(order QueueOrder) QueueState {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+
+	m.queue = pasteQueue{state: QueueState{Active: true, Order: order}}
+	m.logger.Info("Paste queue started", zap.String("order", string(order)))
+	return m.queue.state
+}
+
+// StopQueue turns off queue mode and drops any queued items
+func (m *Monitor) StopQueue() QueueState {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+
+	if m.queue.state.Active {
+		m.logger.Info("Paste queue stopped", zap.Int("unpasted", len(m.queue.items)))
+	}
+	m.queue = pasteQueue{}
+	return m.queue.state
+}
+
+// GetQueueState returns the current paste queue state
+func (m *Monitor) GetQueueState() QueueState {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+	return m.queue.state
+}
+
+// PasteNext writes the next queued item to the clipboard and removes it from the queue
+func (m *Monitor) PasteNext() (*types.ClipboardContent, QueueState, error) {
+	// Take the item off the queue first; the queue lock is not held while
+	// writing, as capture takes the monitor lock and then the queue lock
+	m.queueMu.Lock()
+	if !m.queue.state.Active {
+		defer m.queueMu.Unlock()
+		return nil, m.queue.state, ErrQueueInactive
+	}
+	if len(m.queue.items) == 0 {
+		defer m.queueMu.Unlock()
+		return nil, m.queue.state, ErrQueueEmpty
+	}
+
+	index := 0
+	if m.queue.state.Order == QueueLIFO {
+		index = len(m.queue.items) - 1
+	}
+	content := m.queue.items[index]
+	m.queue.items = append(m.queue.items[:index], m.queue.items[index+1:]...)
+	m.queueMu.Unlock()
+
+	// Don't capture, and queue, the item again when the clipboard reports the change
+	err := m.writeOwnContent(content)
+
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+
+	if err != nil {
+		// Put it back so it can be retried, unless the queue was stopped meanwhile
+		if m.queue.state.Active {
+			index = min(index, len(m.queue.items))
+			m.queue.items = append(m.queue.items[:index], append([]*types.ClipboardContent{content}, m.queue.items[index:]...)...)
+		}
+		return nil, m.queue.state, fmt.Errorf("failed to write queued item to clipboard: %w", err)
+	}
+
+	m.queue.state.Queued = len(m.queue.items)
+	m.queue.state.Pasted++
+
+	m.logger.Info("Pasted next queued item",
+		zap.String("type", string(content.Type)),
+		zap.Int("queued", m.queue.state.Queued))
+	return content, m.queue.state, nil
+}
+
+// enqueue adds captured content to the paste queue if queue mode is active
+func (m *Monitor) enqueue(content *types.ClipboardContent) {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+
+	if !m.queue.state.Active {
+		return
+	}
+
+	m.queue.items = append(m.queue.items, content)
+	if len(m.queue.items) > maxQueueLength {
+		m.logger.Warn("Paste queue is full, dropping the oldest item", zap.Int("max", maxQueueLength))
+		m.queue.items = m.queue.items[1:]
+	}
+	m.queue.state.Queued = len(m.queue.items)
+}
@@ -0,0 +1,68 @@
+package clipboard
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/platform"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+func TestPasteQueue(t *testing.T) {
+	monitor, _, _, _ := newTestMonitor(t, platform.BackendMemory)
+	clip := monitor.GetClipboard().(*platform.HeadlessClipboard)
+
+	if _, _, err := monitor.PasteNext(); !errors.Is(err, ErrQueueInactive) {
+		t.Fatalf("expected ErrQueueInactive, got %v", err)
+	}
+
+	copyAll := func(items ...string) {
+		for i, item := range items {
+			monitor.processNewContent(&types.ClipboardContent{
+				Type:    types.TypeText,
+				Data:    []byte(item),
+				Created: time.Now().Add(time.Duration(i) * time.Millisecond),
+			})
+		}
+	}
+
+	pasteAll := func() []string {
+		var pasted []string
+		for {
+			_, _, err := monitor.PasteNext()
+			if errors.Is(err, ErrQueueEmpty) {
+				return pasted
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			current, _ := clip.Read()
+			pasted = append(pasted, string(current.Data))
+		}
+	}
+
+	copyAll("before queue")
+	monitor.StartQueue(QueueFIFO)
+	copyAll("name", "email", "phone")
+
+	if state := monitor.GetQueueState(); state.Queued != 3 || state.Pasted != 0 {
+		t.Fatalf("unexpected state %+v", state)
+	}
+	if got := pasteAll(); len(got) != 3 || got[0] != "name" || got[2] != "phone" {
+		t.Errorf("expected FIFO order, got %v", got)
+	}
+	if state := monitor.GetQueueState(); state.Queued != 0 || state.Pasted != 3 {
+		t.Errorf("unexpected state %+v", state)
+	}
+
+	monitor.StartQueue(QueueLIFO)
+	copyAll("first", "second")
+	if got := pasteAll(); len(got) != 2 || got[0] != "second" || got[1] != "first" {
+		t.Errorf("expected LIFO order, got %v", got)
+	}
+
+	if state := monitor.StopQueue(); state.Active {
+		t.Error("queue still active after stop")
+	}
+}
@@ -0,0 +1,191 @@
+package clipboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// PauseStateFile is the file name of the persisted pause state inside the data directory
+const PauseStateFile = "pause.json"
+
+// PauseState describes whether clipboard capture is paused
+type PauseState struct {
+	Paused        bool      `json:"paused"`
+	Since         time.Time `json:"since,omitempty"`
+	Until         time.Time `json:"until,omitempty"`           // Zero means until resumed
+	AllowPeerCopy bool      `json:"allow_peer_copy,omitempty"` // Keep applying AutoCopyFromPeers while paused
+}
+
+// Remaining returns how long until auto-resume, or zero for an open-ended pause
+func (s PauseState) Remaining() time.Duration {
+	if !s.Paused || s.Until.IsZero() {
+		return 0
+	}
+	if remaining := time.Until(s.Until); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Expired reports whether a timed pause has run out
+func (s PauseState) Expired() bool {
+	return s.Paused && !s.Until.IsZero() && !time.Now().Before(s.Until)
+}
+
+// PauseStatePath returns the path of the persisted pause state for a data directory
+func PauseStatePath(dataDir string) string {
+	return filepath.Join(dataDir, PauseStateFile)
+}
+
+// LoadPauseState reads the persisted pause state, returning an unpaused state if none exists
+func LoadPauseState(path string) (PauseState, error) {
+	var state PauseState
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to read pause state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return PauseState{}, fmt.Errorf("failed to decode pause state: %w", err)
+	}
+
+	if state.Expired() {
+		return PauseState{}, nil
+	}
+	return state, nil
+}
+
+// SavePauseState persists the pause state, removing the file when not paused
+func SavePauseState(path string, state PauseState) error {
+	if !state.Paused {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove pause state: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode pause state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write pause state: %w", err)
+	}
+	return nil
+}
+
+// NewPauseState builds a pause state starting now. A zero duration pauses until resumed.
+func NewPauseState(duration time.Duration, allowPeerCopy bool) PauseState {
+	now := time.Now()
+	state := PauseState{
+		Paused:        true,
+		Since:         now,
+		AllowPeerCopy: allowPeerCopy,
+	}
+	if duration > 0 {
+		state.Until = now.Add(duration)
+	}
+	return state
+}
+
+// Pause stops capturing clipboard changes. A zero duration pauses until Resume is called.
+func (m *Monitor) Pause(duration time.Duration, allowPeerCopy bool) (PauseState, error) {
+	return m.setPauseState(NewPauseState(duration, allowPeerCopy))
+}
+
+// Resume restarts capturing clipboard changes
+func (m *Monitor) Resume() (PauseState, error) {
+	return m.setPauseState(PauseState{})
+}
+
+// GetPauseState returns the current pause state
+func (m *Monitor) GetPauseState() PauseState {
+	m.pauseMu.Lock()
+	defer m.pauseMu.Unlock()
+	return m.pause
+}
+
+// IsPaused reports whether clipboard capture is paused
+func (m *Monitor) IsPaused() bool {
+	return m.GetPauseState().Paused
+}
+
+// setPauseState applies, schedules and persists a pause state
+func (m *Monitor) setPauseState(state PauseState) (PauseState, error) {
+	m.pauseMu.Lock()
+	if m.pauseTimer != nil {
+		m.pauseTimer.Stop()
+		m.pauseTimer = nil
+	}
+	m.pause = state
+	if state.Paused && !state.Until.IsZero() {
+		until := state.Until
+		m.pauseTimer = time.AfterFunc(time.Until(until), func() {
+			m.autoResume(until)
+		})
+	}
+	m.pauseMu.Unlock()
+
+	if state.Paused {
+		m.logger.Info("Clipboard capture paused",
+			zap.Time("until", state.Until),
+			zap.Bool("allow_peer_copy", state.AllowPeerCopy))
+	} else {
+		m.logger.Info("Clipboard capture resumed")
+	}
+
+	if err := SavePauseState(m.pauseStatePath(), state); err != nil {
+		m.logger.Error("Failed to persist pause state", zap.Error(err))
+		return state, err
+	}
+	return state, nil
+}
+
+// autoResume resumes capture when a timed pause expires
+func (m *Monitor) autoResume(until time.Time) {
+	m.pauseMu.Lock()
+	stale := !m.pause.Paused || !m.pause.Until.Equal(until)
+	m.pauseMu.Unlock()
+
+	// The pause was changed after this timer was scheduled
+	if stale {
+		return
+	}
+
+	m.logger.Info("Pause duration elapsed, resuming automatically")
+	m.Resume()
+}
+
+// restorePauseState reloads a pause that was active before the daemon restarted
+func (m *Monitor) restorePauseState() {
+	state, err := LoadPauseState(m.pauseStatePath())
+	if err != nil {
+		m.logger.Error("Failed to load pause state", zap.Error(err))
+		return
+	}
+
+	if state.Paused {
+		m.setPauseState(state)
+	} else {
+		// Clean up an expired state file
+		SavePauseState(m.pauseStatePath(), state)
+	}
+}
+
+// pauseStatePath returns where the pause state is persisted
+func (m *Monitor) pauseStatePath() string {
+	return PauseStatePath(m.config.GetPaths().DataDir)
+}
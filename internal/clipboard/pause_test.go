@@ -0,0 +1,94 @@
+package clipboard
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/platform"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"go.uber.org/zap"
+)
+
+func TestPauseTimedResume(t *testing.T) {
+	monitor, _, publisher, cfg := newTestMonitor(t, platform.BackendMemory)
+	if err := monitor.Start(); err != nil {
+		t.Fatalf("failed to start monitor: %v", err)
+	}
+	clip := monitor.GetClipboard().(*platform.HeadlessClipboard)
+
+	if _, err := monitor.Pause(200*time.Millisecond, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(PauseStatePath(cfg.SystemPaths.DataDir)); err != nil {
+		t.Errorf("pause state not persisted: %v", err)
+	}
+
+	// Copies made while paused are not captured
+	clip.SetContent(&types.ClipboardContent{Type: types.TypeText, Data: []byte("secret"), Created: time.Now()})
+	time.Sleep(50 * time.Millisecond)
+	if publisher.count() != 0 {
+		t.Fatalf("captured %d items while paused", publisher.count())
+	}
+
+	waitFor(t, "the pause to run out", func() bool { return !monitor.IsPaused() })
+	if _, err := os.Stat(PauseStatePath(cfg.SystemPaths.DataDir)); !os.IsNotExist(err) {
+		t.Errorf("pause state left behind after resuming: %v", err)
+	}
+	clip.SetContent(&types.ClipboardContent{Type: types.TypeText, Data: []byte("after the pause"), Created: time.Now()})
+	waitFor(t, "content to be captured after resuming", func() bool { return publisher.count() == 1 })
+}
+
+func TestPauseRestoredOnRestart(t *testing.T) {
+	monitor, store, publisher, cfg := newTestMonitor(t, platform.BackendMemory)
+	if _, err := monitor.Pause(0, true); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewMonitor(cfg, publisher, zap.NewNop(), store)
+	t.Cleanup(func() { restarted.Stop() })
+	if err := restarted.Start(); err != nil {
+		t.Fatalf("failed to start monitor: %v", err)
+	}
+	if state := restarted.GetPauseState(); !state.Paused || !state.Until.IsZero() || !state.AllowPeerCopy {
+		t.Errorf("restored pause state = %+v", state)
+	}
+
+	// A timed pause that ran out while the daemon was stopped is dropped
+	expired := NewPauseState(time.Minute, false)
+	expired.Until = time.Now().Add(-time.Second)
+	if err := SavePauseState(PauseStatePath(cfg.SystemPaths.DataDir), expired); err != nil {
+		t.Fatal(err)
+	}
+	if state, err := LoadPauseState(PauseStatePath(cfg.SystemPaths.DataDir)); err != nil || state.Paused {
+		t.Errorf("expired pause loaded as %+v, %v", state, err)
+	}
+}
+
+func TestPauseAllowPeerCopy(t *testing.T) {
+	monitor, store, _, cfg := newTestMonitor(t, platform.BackendMemory)
+	cfg.Sync.AutoCopyFromPeers = true
+	clip := monitor.GetClipboard()
+	peer := types.PeerInfo{ID: "peer-a"}
+
+	// Peer content reaches the clipboard but not the history
+	if _, err := monitor.Pause(0, true); err != nil {
+		t.Fatal(err)
+	}
+	monitor.HandleRemoteContent(&types.ClipboardContent{Type: types.TypeText, Data: []byte("from peer"), Created: time.Now()}, peer)
+	if content, err := clip.Read(); err != nil || string(content.Data) != "from peer" {
+		t.Errorf("clipboard = %+v, %v; want the peer content", content, err)
+	}
+	if latest, _ := store.GetLatestContent(); latest != nil {
+		t.Errorf("stored peer content while paused: %q", latest.Data)
+	}
+
+	// Without AllowPeerCopy, peer content is ignored
+	if _, err := monitor.Pause(0, false); err != nil {
+		t.Fatal(err)
+	}
+	monitor.HandleRemoteContent(&types.ClipboardContent{Type: types.TypeText, Data: []byte("ignored"), Created: time.Now()}, peer)
+	if content, err := clip.Read(); err != nil || string(content.Data) != "from peer" {
+		t.Errorf("clipboard = %+v, %v; want it unchanged", content, err)
+	}
+}
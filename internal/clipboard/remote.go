@@ -0,0 +1,89 @@
+package clipboard
+
+import (
+	"github.com/berrythewa/clipman-daemon/internal/config"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"go.uber.org/zap"
+)
+
+// HandleRemoteContent handles clipboard content received from a peer.
+// It is stored in history and, if AutoCopyFromPeers is enabled, placed on the
+// local clipboard. While paused nothing is stored, and the clipboard is only
+// updated if the pause allows peer copies. Snippets from peers are merged
+// into the snippet library instead.
+func (m *Monitor) HandleRemoteContent(content *types.ClipboardContent, peer types.PeerInfo) {
+	if content == nil {
+		return
+	}
+	if content.Type == types.TypeSnippet {
+		m.handleRemoteSnippet(content, peer)
+		return
+	}
+
+	autoCopy := m.config.Sync.AutoCopyFromPeers
+	state := m.GetPauseState()
+
+	if state.Paused {
+		if autoCopy && state.AllowPeerCopy {
+			m.writeRemoteContent(content, peer)
+		} else {
+			m.logger.Debug("Clipboard capture paused, ignoring content from peer",
+				zap.String("peer", peer.ID))
+		}
+		return
+	}
+
+	m.hooks.Fire(config.HookEventSyncReceive, content, &peer)
+
+	m.mu.Lock()
+	if err := m.saveContent(content); err != nil {
+		m.logger.Error("Failed to save content from peer", zap.Error(err))
+	} else {
+		m.hooks.Fire(config.HookEventStore, content, nil)
+	}
+	m.history.Add(content)
+	// Don't capture it again when the platform reports the change
+	m.lastContent = content
+	m.mu.Unlock()
+
+	if autoCopy {
+		m.writeRemoteContent(content, peer)
+	}
+}
+
+// HandleReconciledContent stores history a peer had that this device missed,
+// such as items copied there while this device was offline. It is only
+// stored: the clipboard and the recent history are left as they are and no
+// hooks fire. While paused nothing is stored.
+func (m *Monitor) HandleReconciledContent(content *types.ClipboardContent, peer types.PeerInfo) {
+	if content == nil || content.Type == types.TypeSnippet {
+		return
+	}
+	if m.GetPauseState().Paused {
+		return
+	}
+
+	// Pinning is a choice made on each device
+	content.Pinned = false
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.saveContent(content); err != nil {
+		m.logger.Error("Failed to save history from peer",
+			zap.String("peer", peer.ID),
+			zap.Error(err))
+	}
+}
+
+// writeRemoteContent places peer content on the local clipboard
+func (m *Monitor) writeRemoteContent(content *types.ClipboardContent, peer types.PeerInfo) {
+	if err := m.clipboard.Write(content); err != nil {
+		m.logger.Error("Failed to copy content from peer to clipboard",
+			zap.String("peer", peer.ID),
+			zap.Error(err))
+		return
+	}
+
+	m.logger.Info("Copied content from peer to clipboard",
+		zap.String("peer", peer.ID),
+		zap.String("type", string(content.Type)))
+}
@@ -0,0 +1,47 @@
+package clipboard
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"go.uber.org/zap"
+)
+
+// Restore writes a history item back to the clipboard with its original
+// type. Snapshotted files that are gone are recreated first. The write is
+// not captured as a new copy, so history gets no duplicate entry.
+func (m *Monitor) Restore(content *types.ClipboardContent) (*types.ClipboardContent, error) {
+	if m.storage != nil {
+		restored, err := RestoreFiles(content, m.storage.Blobs(), m.restoreDir())
+		if err != nil {
+			return nil, err
+		}
+		content = restored
+	}
+
+	if err := m.writeOwnContent(content); err != nil {
+		return nil, fmt.Errorf("failed to write item to clipboard: %w", err)
+	}
+
+	m.logger.Info("Restored history item to clipboard",
+		zap.String("id", content.ID()),
+		zap.String("type", string(content.Type)))
+	return content, nil
+}
+
+// writeOwnContent writes content to the clipboard and remembers it, so the
+// resulting clipboard change is not captured as a new copy
+func (m *Monitor) writeOwnContent(content *types.ClipboardContent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.clipboard.Write(content); err != nil {
+		return err
+	}
+
+	written := *content
+	written.Created = time.Now()
+	m.lastContent = &written
+	return nil
+}
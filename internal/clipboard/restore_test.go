@@ -0,0 +1,70 @@
+package clipboard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/platform"
+	"github.com/berrythewa/clipman-daemon/internal/storage"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+func TestRestoreHistoryItem(t *testing.T) {
+	monitor, store, publisher, _ := newTestMonitor(t, platform.BackendMemory)
+	clip := monitor.GetClipboard().(*platform.HeadlessClipboard)
+	if err := monitor.Start(); err != nil {
+		t.Fatalf("failed to start monitor: %v", err)
+	}
+
+	created := time.Now().Add(-time.Hour)
+	monitor.processNewContent(&types.ClipboardContent{
+		Type:    types.TypeHTML,
+		Data:    []byte("<p>Quarterly <b>report</b></p>"),
+		Created: created,
+	})
+	monitor.processNewContent(&types.ClipboardContent{
+		Type:    types.TypeText,
+		Data:    []byte("something newer"),
+		Created: created.Add(time.Minute),
+	})
+
+	for _, sel := range []storage.ItemSelector{
+		{Index: 2},
+		{Search: "quarterly report"},
+		{ID: (&types.ClipboardContent{Created: created}).ID()[:10]},
+	} {
+		content, err := store.SelectContent(sel)
+		if err != nil {
+			t.Fatalf("select %+v: %v", sel, err)
+		}
+		if content.Type != types.TypeHTML {
+			t.Fatalf("select %+v picked a %s item", sel, content.Type)
+		}
+	}
+
+	content, err := store.SelectContent(storage.ItemSelector{Index: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := monitor.Restore(content); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+
+	current, _ := clip.Read()
+	if current.Type != types.TypeHTML || string(current.Data) != string(content.Data) {
+		t.Errorf("clipboard holds %s %q", current.Type, current.Data)
+	}
+
+	// The restored item must not be recorded again
+	time.Sleep(100 * time.Millisecond)
+	if publisher.count() != 2 {
+		t.Errorf("restore was captured as a new copy, published %d items", publisher.count())
+	}
+	if contents, _ := store.GetAllContents(); len(contents) != 2 {
+		t.Errorf("expected 2 history items, got %d", len(contents))
+	}
+
+	if _, err := store.SelectContent(storage.ItemSelector{Index: 3}); err == nil {
+		t.Error("expected an error selecting past the end of history")
+	}
+}
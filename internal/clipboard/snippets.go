@@ -0,0 +1,209 @@
+package clipboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/storage"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"github.com/berrythewa/clipman-daemon/pkg/snippet"
+	"go.uber.org/zap"
+)
+
+// snippetNamePattern restricts snippet names to what is easy to type on a command line
+var snippetNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// SaveSnippet validates a snippet and stores it as a new version made on
+// deviceID, keeping the creation time of the snippet it replaces
+func SaveSnippet(store *storage.BoltStorage, s *types.Snippet, deviceID string) (*types.Snippet, error) {
+	if !snippetNamePattern.MatchString(s.Name) {
+		return nil, fmt.Errorf("invalid snippet name %q, use letters, digits, '.', '_' and '-'", s.Name)
+	}
+	if err := snippet.Validate(s.Content); err != nil {
+		return nil, fmt.Errorf("invalid snippet template: %w", err)
+	}
+
+	saved := *s
+	saved.Tags = normalizeTags(s.Tags)
+	saved.Updated = time.Now()
+	saved.Origin = deviceID
+	saved.Deleted = false
+	saved.Created = saved.Updated
+	if existing, err := store.GetSnippet(s.Name); err == nil {
+		saved.Created = existing.Created
+	}
+
+	if err := store.SaveSnippet(&saved); err != nil {
+		return nil, fmt.Errorf("failed to save snippet: %w", err)
+	}
+	return &saved, nil
+}
+
+// DeleteSnippet deletes a snippet, leaving a tombstone so the deletion
+// reaches paired devices, and returns the tombstone
+func DeleteSnippet(store *storage.BoltStorage, name, deviceID string) (*types.Snippet, error) {
+	existing, err := store.GetSnippet(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tombstone := &types.Snippet{
+		Name:    existing.Name,
+		Created: existing.Created,
+		Updated: time.Now(),
+		Origin:  deviceID,
+		Deleted: true,
+	}
+	if err := store.SaveSnippet(tombstone); err != nil {
+		return nil, fmt.Errorf("failed to delete snippet: %w", err)
+	}
+	return tombstone, nil
+}
+
+// ExpandSnippet expands the template variables of a snippet into text
+// content. Prompt values are taken from values, {{clipboard}} from clip.
+// {{env:NAME}} only reads the environment in snippets last changed on
+// deviceID, so a snippet from a paired device can't copy out secrets; in
+// others it expands to nothing.
+func ExpandSnippet(s *types.Snippet, clip Clipboard, values map[string]string, deviceID string) (*types.ClipboardContent, error) {
+	env := os.Getenv
+	if s.Origin != deviceID {
+		env = func(string) string { return "" }
+	}
+	text, err := snippet.Expand(s.Content, snippet.Context{
+		Env:       env,
+		Clipboard: func() (string, error) { return clipboardText(clip), nil },
+		Values:    values,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand snippet %s: %w", s.Name, err)
+	}
+
+	return &types.ClipboardContent{
+		Type:    types.TypeText,
+		Data:    []byte(text),
+		Created: time.Now(),
+	}, nil
+}
+
+// SaveSnippet stores a snippet and sends it to paired devices
+func (m *Monitor) SaveSnippet(s *types.Snippet) (*types.Snippet, error) {
+	saved, err := SaveSnippet(m.storage, s, m.config.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	m.logger.Info("Saved snippet", zap.String("name", saved.Name))
+	m.publishSnippet(saved)
+	return saved, nil
+}
+
+// DeleteSnippet deletes a snippet here and on paired devices
+func (m *Monitor) DeleteSnippet(name string) error {
+	tombstone, err := DeleteSnippet(m.storage, name, m.config.DeviceID)
+	if err != nil {
+		return err
+	}
+
+	m.logger.Info("Deleted snippet", zap.String("name", name))
+	m.publishSnippet(tombstone)
+	return nil
+}
+
+// ExpandSnippet expands a snippet and places the result on the clipboard.
+// The expansion is not recorded in history.
+func (m *Monitor) ExpandSnippet(name string, values map[string]string) (*types.ClipboardContent, error) {
+	s, err := m.storage.GetSnippet(name)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ExpandSnippet(s, m.clipboard, values, m.config.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.writeOwnContent(content); err != nil {
+		return nil, fmt.Errorf("failed to write snippet to clipboard: %w", err)
+	}
+
+	m.logger.Info("Expanded snippet to clipboard", zap.String("name", name))
+	return content, nil
+}
+
+// publishSnippet sends a snippet version to paired devices
+func (m *Monitor) publishSnippet(s *types.Snippet) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		m.logger.Error("Failed to encode snippet for sync", zap.Error(err))
+		return
+	}
+
+	content := &types.ClipboardContent{Type: types.TypeSnippet, Data: data, Created: s.Updated}
+	if err := m.publishContent(content); err != nil {
+		m.logger.Warn("Failed to sync snippet", zap.String("name", s.Name), zap.Error(err))
+	}
+}
+
+// handleRemoteSnippet merges a snippet received from a peer. Snippets sync
+// even while capture is paused, since they are not clipboard data.
+func (m *Monitor) handleRemoteSnippet(content *types.ClipboardContent, peer types.PeerInfo) {
+	if m.storage == nil {
+		return
+	}
+
+	var s types.Snippet
+	if err := json.Unmarshal(content.Data, &s); err != nil || !snippetNamePattern.MatchString(s.Name) {
+		m.logger.Warn("Ignoring invalid snippet from peer", zap.String("peer", peer.ID))
+		return
+	}
+	// Versions made here are trusted to read the environment, so a peer
+	// can't pass its own off as one
+	if s.Origin == m.config.DeviceID {
+		m.logger.Warn("Ignoring snippet from peer claiming to be made on this device",
+			zap.String("name", s.Name),
+			zap.String("peer", peer.ID))
+		return
+	}
+
+	merged, err := m.storage.MergeSnippet(&s)
+	if err != nil {
+		m.logger.Error("Failed to store snippet from peer", zap.String("peer", peer.ID), zap.Error(err))
+		return
+	}
+	if merged {
+		m.logger.Info("Updated snippet from peer",
+			zap.String("name", s.Name),
+			zap.Bool("deleted", s.Deleted),
+			zap.String("peer", peer.ID))
+	}
+}
+
+// clipboardText returns the text on the clipboard, empty for images. Backends
+// report an empty clipboard as a read error, so that is treated as no text.
+func clipboardText(clip Clipboard) string {
+	content, err := clip.Read()
+	if err != nil || content == nil || content.Type == types.TypeImage {
+		return ""
+	}
+	return content.Text()
+}
+
+// normalizeTags lowercases tags and removes blanks and duplicates
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
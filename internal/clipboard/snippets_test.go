@@ -0,0 +1,110 @@
+package clipboard
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/platform"
+	"github.com/berrythewa/clipman-daemon/internal/storage"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+func TestSnippetExpandAndSync(t *testing.T) {
+	monitor, store, publisher, _ := newTestMonitor(t, platform.BackendMemory)
+	clip := monitor.GetClipboard().(*platform.HeadlessClipboard)
+
+	if _, err := monitor.SaveSnippet(&types.Snippet{Name: "bad name", Content: "x"}); err == nil {
+		t.Error("expected an error for a name with a space")
+	}
+	if _, err := monitor.SaveSnippet(&types.Snippet{Name: "bad", Content: "{{nope}}"}); err == nil {
+		t.Error("expected an error for an unknown variable")
+	}
+
+	saved, err := monitor.SaveSnippet(&types.Snippet{
+		Name:    "reply",
+		Content: "Hi {{prompt:Name}}, see {{clipboard}}",
+		Tags:    []string{"Email", "email", " work "},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Tags) != 2 || saved.Tags[0] != "email" || saved.Tags[1] != "work" {
+		t.Errorf("tags not normalized: %v", saved.Tags)
+	}
+	if publisher.count() != 1 || publisher.published[0].Type != types.TypeSnippet {
+		t.Fatalf("snippet was not published for sync")
+	}
+
+	clip.Write(&types.ClipboardContent{Type: types.TypeText, Data: []byte("ticket 42"), Created: time.Now()})
+	if _, err := monitor.ExpandSnippet("reply", nil); err == nil {
+		t.Error("expected an error for a missing prompt value")
+	}
+	if _, err := monitor.ExpandSnippet("reply", map[string]string{"Name": "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	current, _ := clip.Read()
+	if string(current.Data) != "Hi Ada, see ticket 42" {
+		t.Errorf("clipboard holds %q", current.Data)
+	}
+
+	// A stale version from a peer is ignored, a newer one replaces ours
+	remote := func(s types.Snippet) {
+		data, _ := json.Marshal(s)
+		monitor.HandleRemoteContent(&types.ClipboardContent{Type: types.TypeSnippet, Data: data}, types.PeerInfo{ID: "peer"})
+	}
+	remote(types.Snippet{Name: "reply", Content: "stale", Updated: saved.Updated.Add(-time.Minute), Origin: "peer"})
+	if s, _ := store.GetSnippet("reply"); s.Content != saved.Content {
+		t.Errorf("stale snippet replaced the local one: %q", s.Content)
+	}
+	remote(types.Snippet{Name: "reply", Content: "newer", Updated: saved.Updated.Add(time.Minute), Origin: "peer"})
+	if s, _ := store.GetSnippet("reply"); s.Content != "newer" {
+		t.Errorf("newer snippet was not merged: %q", s.Content)
+	}
+	remote(types.Snippet{Name: "reply", Content: "forged", Updated: saved.Updated.Add(time.Hour), Origin: saved.Origin})
+	if s, _ := store.GetSnippet("reply"); s.Content != "newer" {
+		t.Errorf("snippet from peer claiming this device's origin was merged: %q", s.Content)
+	}
+	if contents, _ := store.GetAllContents(); len(contents) != 0 {
+		t.Errorf("snippets leaked into history: %d items", len(contents))
+	}
+
+	// {{env:NAME}} only reads the environment in snippets made on this device
+	t.Setenv("CLIPMAN_SNIPPET_SECRET", "hunter2")
+	remote(types.Snippet{Name: "leak", Content: "key={{env:CLIPMAN_SNIPPET_SECRET}}", Updated: time.Now(), Origin: "peer"})
+	if _, err := monitor.ExpandSnippet("leak", nil); err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := clip.Read(); string(current.Data) != "key=" {
+		t.Errorf("snippet from peer expanded to %q", current.Data)
+	}
+	if _, err := monitor.SaveSnippet(&types.Snippet{Name: "leak", Content: "key={{env:CLIPMAN_SNIPPET_SECRET}}"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := monitor.ExpandSnippet("leak", nil); err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := clip.Read(); string(current.Data) != "key=hunter2" {
+		t.Errorf("local snippet expanded to %q", current.Data)
+	}
+	if err := monitor.DeleteSnippet("leak"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting syncs a tombstone
+	if err := monitor.DeleteSnippet("reply"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetSnippet("reply"); !errors.Is(err, storage.ErrSnippetNotFound) {
+		t.Errorf("expected ErrSnippetNotFound after delete, got %v", err)
+	}
+	var tombstone types.Snippet
+	json.Unmarshal(publisher.published[publisher.count()-1].Data, &tombstone)
+	if !tombstone.Deleted || tombstone.Name != "reply" {
+		t.Errorf("deletion was not published: %+v", tombstone)
+	}
+	if snippets, _ := store.ListSnippets(""); len(snippets) != 0 {
+		t.Errorf("deleted snippet still listed")
+	}
+}
@@ -1,27 +1,54 @@
 package clipboard
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/berrythewa/clipman-daemon/internal/sync"
 	"github.com/berrythewa/clipman-daemon/internal/types"
 	"go.uber.org/zap"
 )
 
-// SyncPublisher implements ContentPublisher interface using the sync package
+// outboxDrainDelay lets a peer that joined the group receive the group keys
+// before queued content is sent to it
+const outboxDrainDelay = time.Second
+
+// errNoGroupPeers is why content is queued while no peer is in the group
+var errNoGroupPeers = errors.New("no peer is online in the group")
+
+// SyncPublisher implements ContentPublisher interface using the sync package.
+// Content goes to every group this device is in whose route it matches.
 type SyncPublisher struct {
 	syncManager *sync.Manager
-	groupName   string
 	logger      *zap.Logger
+	outbox      *Outbox
 }
 
 // NewSyncPublisher creates a new SyncPublisher that uses the sync manager
-func NewSyncPublisher(syncManager *sync.Manager, groupName string, logger *zap.Logger) *SyncPublisher {
+func NewSyncPublisher(syncManager *sync.Manager, logger *zap.Logger) *SyncPublisher {
 	return &SyncPublisher{
 		syncManager: syncManager,
-		groupName:   groupName,
 		logger:      logger,
 	}
 }
 
+// SetOutbox makes the publisher queue content while no peer is in a group
+// or publishing fails, and deliver it when a peer joins. Content left from
+// an earlier run is delivered if a peer is already there.
+func (p *SyncPublisher) SetOutbox(outbox *Outbox) {
+	p.outbox = outbox
+	if p.syncManager == nil {
+		return
+	}
+	p.syncManager.SetGroupJoinHandler(func(group string, peer types.PeerInfo) {
+		go p.drain(group)
+	})
+	for _, group := range p.syncManager.Groups() {
+		go p.drain(group.Name)
+	}
+}
+
 // PublishContent implements ContentPublisher.PublishContent
 // It publishes the content to other devices using the sync manager
 func (p *SyncPublisher) PublishContent(content *types.ClipboardContent) error {
@@ -32,6 +59,35 @@ func (p *SyncPublisher) PublishContent(content *types.ClipboardContent) error {
 		return nil
 	}
 
+	groups := p.syncManager.RouteContent(content)
+	if len(groups) == 0 {
+		if p.logger != nil {
+			p.logger.Debug("SyncPublisher: content matches no group route, skipping content publishing",
+				zap.String("content_type", string(content.Type)))
+		}
+		return nil
+	}
+
+	var errs []error
+	for _, group := range groups {
+		if err := p.publishToGroup(content, group); err != nil {
+			errs = append(errs, fmt.Errorf("group %s: %w", group, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// publishToGroup publishes content to one group, queueing it if that fails
+func (p *SyncPublisher) publishToGroup(content *types.ClipboardContent, group string) error {
+	if p.outbox != nil && p.syncManager.GroupPeerCount(group) == 0 {
+		if p.logger != nil {
+			p.logger.Debug("SyncPublisher: no peers in the group, queueing content",
+				zap.String("content_type", string(content.Type)),
+				zap.String("group", group))
+		}
+		return p.outbox.Queue(content, group, errNoGroupPeers.Error())
+	}
+
 	if !p.IsConnected() {
 		if p.logger != nil {
 			p.logger.Debug("SyncPublisher: not connected to any peers, skipping content publishing")
@@ -43,10 +99,35 @@ func (p *SyncPublisher) PublishContent(content *types.ClipboardContent) error {
 		p.logger.Debug("SyncPublisher: publishing content",
 			zap.String("content_type", string(content.Type)),
 			zap.Int("content_size", len(content.Data)),
-			zap.String("group", p.groupName))
+			zap.String("group", group))
+	}
+
+	err := p.syncManager.SendContent(content, group)
+	if err != nil && p.outbox != nil {
+		if p.logger != nil {
+			p.logger.Warn("SyncPublisher: publishing failed, queueing content",
+				zap.String("group", group),
+				zap.Error(err))
+		}
+		return p.outbox.Queue(content, group, err.Error())
 	}
+	return err
+}
 
-	return p.syncManager.SendContent(content, p.groupName)
+// drain delivers the content queued for a group while a peer is in it
+func (p *SyncPublisher) drain(group string) {
+	time.Sleep(outboxDrainDelay)
+	delivered := p.outbox.Drain(group, func(content *types.ClipboardContent) error {
+		if p.syncManager.GroupPeerCount(group) == 0 {
+			return errNoGroupPeers
+		}
+		return p.syncManager.SendContent(content, group)
+	})
+	if delivered > 0 && p.logger != nil {
+		p.logger.Info("Delivered content queued while no peer was online",
+			zap.Int("items", delivered),
+			zap.String("group", group))
+	}
 }
 
 // IsConnected implements ContentPublisher.IsConnected
@@ -56,4 +137,13 @@ func (p *SyncPublisher) IsConnected() bool {
 		return false
 	}
 	return p.syncManager.IsConnected()
-} 
\ No newline at end of file
+}
+
+// OutboxState reports the content waiting for peers, nil without an outbox
+func (p *SyncPublisher) OutboxState() *OutboxState {
+	if p.outbox == nil {
+		return nil
+	}
+	state := p.outbox.State()
+	return &state
+}
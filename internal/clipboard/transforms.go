@@ -0,0 +1,314 @@
+package clipboard
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"go.uber.org/zap"
+)
+
+// NamedTransform is a transform that can be applied to clipboard content on request
+type NamedTransform struct {
+	Name        string
+	Description string
+	Transformer ContentTransformer // Returns nil if the content is not valid input
+	invalid     string             // Why Transformer returned nil
+}
+
+// namedTransforms are the transforms available to the transform command, in listing order
+var namedTransforms = []NamedTransform{
+	{Name: "base64-encode", Description: "Encode as standard base64", Transformer: textTransformer(func(s string) (string, bool) {
+		return base64.StdEncoding.EncodeToString([]byte(s)), true
+	})},
+	{Name: "base64-decode", Description: "Decode standard or URL-safe base64", Transformer: textTransformer(decodeBase64), invalid: "not valid base64 text"},
+	{Name: "url-encode", Description: "Percent-encode for use in a URL query", Transformer: textTransformer(func(s string) (string, bool) {
+		return url.QueryEscape(s), true
+	})},
+	{Name: "url-decode", Description: "Decode percent-encoding", Transformer: textTransformer(func(s string) (string, bool) {
+		decoded, err := url.QueryUnescape(s)
+		return decoded, err == nil
+	}), invalid: "not valid percent-encoding"},
+	{Name: "json-pretty", Description: "Indent JSON", Transformer: textTransformer(func(s string) (string, bool) {
+		return formatJSON(s, func(b *bytes.Buffer, data []byte) error { return json.Indent(b, data, "", "  ") })
+	}), invalid: "not valid JSON"},
+	{Name: "json-minify", Description: "Remove whitespace from JSON", Transformer: textTransformer(func(s string) (string, bool) {
+		return formatJSON(s, json.Compact)
+	}), invalid: "not valid JSON"},
+	{Name: "upper", Description: "Convert to UPPER CASE", Transformer: textTransformer(func(s string) (string, bool) {
+		return strings.ToUpper(s), true
+	})},
+	{Name: "lower", Description: "Convert to lower case", Transformer: textTransformer(func(s string) (string, bool) {
+		return strings.ToLower(s), true
+	})},
+	{Name: "title", Description: "Capitalize Each Word", Transformer: textTransformer(func(s string) (string, bool) {
+		return titleCase(s), true
+	})},
+	{Name: "snake", Description: "Convert words to snake_case", Transformer: textTransformer(func(s string) (string, bool) {
+		return strings.Join(lowerWords(s), "_"), true
+	})},
+	{Name: "kebab", Description: "Convert words to kebab-case", Transformer: textTransformer(func(s string) (string, bool) {
+		return strings.Join(lowerWords(s), "-"), true
+	})},
+	{Name: "camel", Description: "Convert words to camelCase", Transformer: textTransformer(func(s string) (string, bool) {
+		return camelCase(s), true
+	})},
+	{Name: "sort-lines", Description: "Sort lines alphabetically", Transformer: lineTransformer(func(lines []string) []string {
+		sort.Strings(lines)
+		return lines
+	})},
+	{Name: "unique-lines", Description: "Remove duplicate lines, keeping the first", Transformer: lineTransformer(func(lines []string) []string {
+		seen := make(map[string]bool)
+		unique := lines[:0]
+		for _, line := range lines {
+			if !seen[line] {
+				seen[line] = true
+				unique = append(unique, line)
+			}
+		}
+		return unique
+	})},
+	{Name: "trim", Description: "Remove surrounding whitespace and trailing whitespace on each line", Transformer: textTransformer(func(s string) (string, bool) {
+		lines := strings.Split(s, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+		}
+		return strings.TrimSpace(strings.Join(lines, "\n")), true
+	})},
+	{Name: "sha256", Description: "Replace with the hex SHA-256 hash", Transformer: hashTransformer(sha256.New)},
+	{Name: "sha512", Description: "Replace with the hex SHA-512 hash", Transformer: hashTransformer(sha512.New)},
+	{Name: "sha1", Description: "Replace with the hex SHA-1 hash", Transformer: hashTransformer(sha1.New)},
+	{Name: "md5", Description: "Replace with the hex MD5 hash", Transformer: hashTransformer(md5.New)},
+}
+
+// ErrNotText is returned when converting content that has no text, such as an image
+var ErrNotText = errors.New("content is not text")
+
+// NamedTransforms returns the transforms available to the transform command
+func NamedTransforms() []NamedTransform {
+	return namedTransforms
+}
+
+// LookupTransform returns the named transform, accepting "hash" for sha256
+func LookupTransform(name string) (NamedTransform, bool) {
+	name = strings.ToLower(name)
+	if name == "hash" {
+		name = "sha256"
+	}
+	for _, t := range namedTransforms {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return NamedTransform{}, false
+}
+
+// ApplyTransforms applies a chain of named transforms to a copy of content.
+// Rich text and file items are transformed as their text, and the result is
+// always plain text.
+func ApplyTransforms(content *types.ClipboardContent, names []string) (*types.ClipboardContent, error) {
+	if len(names) == 0 {
+		return nil, errors.New("no transforms given")
+	}
+
+	chain := make([]NamedTransform, 0, len(names))
+	for _, name := range names {
+		t, ok := LookupTransform(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown transform %q, see 'clipmand transform list'", name)
+		}
+		chain = append(chain, t)
+	}
+
+	result, err := PlainTextContent(content)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range chain {
+		if result = t.Transformer(result); result == nil {
+			return nil, fmt.Errorf("%s failed: the content is %s", t.Name, t.invalid)
+		}
+	}
+	return result, nil
+}
+
+// PlainTextContent returns content as new plain text content, using the
+// plain-text rendition of rich text
+func PlainTextContent(content *types.ClipboardContent) (*types.ClipboardContent, error) {
+	if content == nil || content.Type == types.TypeImage {
+		return nil, ErrNotText
+	}
+
+	text := content.Text()
+	if isRichText(content.Type) && content.PlainText == "" {
+		// Straight from the clipboard, rich text has no rendition yet
+		if renditions, err := renderRichText(content); err == nil {
+			text = renditions.PlainText
+		}
+	}
+
+	return &types.ClipboardContent{
+		Type:    types.TypeText,
+		Data:    []byte(text),
+		Created: time.Now(),
+	}, nil
+}
+
+// TransformContent applies a chain of transforms to content, or to the
+// current clipboard if content is nil, and writes the result to the
+// clipboard without recording it in history
+func (m *Monitor) TransformContent(content *types.ClipboardContent, names []string) (*types.ClipboardContent, error) {
+	if content == nil {
+		current, err := m.clipboard.Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read clipboard: %w", err)
+		}
+		content = current
+	}
+
+	result, err := ApplyTransforms(content, names)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.writeOwnContent(result); err != nil {
+		return nil, fmt.Errorf("failed to write result to clipboard: %w", err)
+	}
+
+	m.logger.Info("Transformed clipboard content", zap.Strings("transforms", names))
+	return result, nil
+}
+
+// textTransformer adapts a text conversion to a ContentTransformer that
+// returns nil when the conversion reports the text as invalid
+func textTransformer(convert func(string) (string, bool)) ContentTransformer {
+	return func(content *types.ClipboardContent) *types.ClipboardContent {
+		text, ok := convert(string(content.Data))
+		if !ok {
+			return nil
+		}
+		content.Data = []byte(text)
+		return content
+	}
+}
+
+// lineTransformer adapts a conversion of lines to a ContentTransformer,
+// keeping a final newline if there was one
+func lineTransformer(convert func([]string) []string) ContentTransformer {
+	return textTransformer(func(s string) (string, bool) {
+		trailing := strings.HasSuffix(s, "\n")
+		lines := convert(strings.Split(strings.TrimSuffix(s, "\n"), "\n"))
+		text := strings.Join(lines, "\n")
+		if trailing {
+			text += "\n"
+		}
+		return text, true
+	})
+}
+
+// hashTransformer creates a ContentTransformer that replaces text with its hex digest
+func hashTransformer(newHash func() hash.Hash) ContentTransformer {
+	return textTransformer(func(s string) (string, bool) {
+		h := newHash()
+		h.Write([]byte(s))
+		return hex.EncodeToString(h.Sum(nil)), true
+	})
+}
+
+// formatJSON reformats JSON text with json.Indent or json.Compact
+func formatJSON(s string, format func(*bytes.Buffer, []byte) error) (string, bool) {
+	var b bytes.Buffer
+	if err := format(&b, []byte(strings.TrimSpace(s))); err != nil {
+		return "", false
+	}
+	return b.String(), true
+}
+
+// decodeBase64 decodes standard or URL-safe base64, padded or not. Decoded
+// data that is not text is rejected, since the result is placed on the
+// clipboard as text.
+func decodeBase64(s string) (string, bool) {
+	s = strings.Join(strings.Fields(s), "")
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if decoded, err := enc.DecodeString(s); err == nil {
+			return string(decoded), utf8.Valid(decoded) && !bytes.ContainsRune(decoded, 0)
+		}
+	}
+	return "", false
+}
+
+// titleCase capitalizes the first letter of each word and lowercases the rest
+func titleCase(s string) string {
+	runes := []rune(s)
+	start := true
+	for i, r := range runes {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start {
+				runes[i] = unicode.ToUpper(r)
+			} else {
+				runes[i] = unicode.ToLower(r)
+			}
+			start = false
+		} else {
+			start = r != '\''
+		}
+	}
+	return string(runes)
+}
+
+// lowerWords splits text into lower-case words at spaces, punctuation and
+// camelCase boundaries
+func lowerWords(s string) []string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		// Split "fooBar" before B, and "HTTPServer" before S
+		if unicode.IsUpper(r) && len(word) > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+	return words
+}
+
+// camelCase joins the words of text as camelCase
+func camelCase(s string) string {
+	words := lowerWords(s)
+	for i := 1; i < len(words); i++ {
+		runes := []rune(words[i])
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+	return strings.Join(words, "")
+}
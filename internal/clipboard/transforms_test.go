@@ -0,0 +1,77 @@
+package clipboard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/platform"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+func TestApplyTransforms(t *testing.T) {
+	tests := []struct {
+		input      string
+		transforms []string
+		want       string
+	}{
+		{"hello world", []string{"base64-encode"}, "aGVsbG8gd29ybGQ="},
+		{"aGVsbG8gd29ybGQ", []string{"base64-decode"}, "hello world"},
+		{"a b&c=d/é", []string{"url-encode"}, "a+b%26c%3Dd%2F%C3%A9"},
+		{"a+b%26c", []string{"url-decode"}, "a b&c"},
+		{` {"a": [1, 2]} `, []string{"json-minify"}, `{"a":[1,2]}`},
+		{`{"a":1}`, []string{"json-pretty"}, "{\n  \"a\": 1\n}"},
+		{"Hello World", []string{"upper"}, "HELLO WORLD"},
+		{"hELLO wORLD it's", []string{"title"}, "Hello World It's"},
+		{"parseHTTPServer config-file", []string{"snake"}, "parse_http_server_config_file"},
+		{"Parse HTTP server", []string{"kebab"}, "parse-http-server"},
+		{"user_id field", []string{"camel"}, "userIdField"},
+		{"pear\napple\npear\nfig\n", []string{"unique-lines", "sort-lines"}, "apple\nfig\npear\n"},
+		{"  a  \n b\t\n\n", []string{"trim"}, "a\n b"},
+		{"abc", []string{"hash"}, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"abc", []string{"md5"}, "900150983cd24fb0d6963f7d28e17f72"},
+		{"x", []string{"base64-encode", "base64-decode", "upper"}, "X"},
+	}
+
+	for _, test := range tests {
+		content := &types.ClipboardContent{Type: types.TypeText, Data: []byte(test.input)}
+		result, err := ApplyTransforms(content, test.transforms)
+		if err != nil {
+			t.Errorf("%v on %q: %v", test.transforms, test.input, err)
+			continue
+		}
+		if string(result.Data) != test.want {
+			t.Errorf("%v on %q: got %q, want %q", test.transforms, test.input, result.Data, test.want)
+		}
+		if string(content.Data) != test.input {
+			t.Errorf("%v modified the input content", test.transforms)
+		}
+	}
+
+	text := &types.ClipboardContent{Type: types.TypeText, Data: []byte("not json")}
+	for _, names := range [][]string{{"json-pretty"}, {"nope"}, {}, {"base64-decode"}} {
+		if _, err := ApplyTransforms(text, names); err == nil {
+			t.Errorf("expected an error for %v", names)
+		}
+	}
+	if _, err := ApplyTransforms(&types.ClipboardContent{Type: types.TypeImage, Data: []byte{0x89}}, []string{"upper"}); err != ErrNotText {
+		t.Errorf("expected ErrNotText for an image, got %v", err)
+	}
+}
+
+func TestTransformContent(t *testing.T) {
+	monitor, store, _, _ := newTestMonitor(t, platform.BackendMemory)
+	clip := monitor.GetClipboard().(*platform.HeadlessClipboard)
+
+	clip.Write(&types.ClipboardContent{Type: types.TypeHTML, Data: []byte("<b>Hi</b> there"), Created: time.Now()})
+	if _, err := monitor.TransformContent(nil, []string{"upper"}); err != nil {
+		t.Fatal(err)
+	}
+
+	current, _ := clip.Read()
+	if current.Type != types.TypeText || string(current.Data) != "HI THERE" {
+		t.Errorf("clipboard holds %s %q", current.Type, current.Data)
+	}
+	if contents, _ := store.GetAllContents(); len(contents) != 0 {
+		t.Errorf("transform result was recorded in history")
+	}
+}
@@ -22,6 +22,7 @@ type SystemPaths struct {
 	DBFile     string `json:"db_file"`
 	TempDir    string `json:"temp_dir"`
 	LogDir     string `json:"log_dir"`
+	BlobDir    string `json:"blob_dir"`
 }
 
 // LogConfig holds logging-related configuration
@@ -45,6 +46,9 @@ type HistoryOptions struct {
 	Since       time.Time `json:"since"`
 	Before      time.Time `json:"before"`
 	ContentType types.ContentType `json:"content_type"`
+	Subtype     types.ContentSubtype `json:"subtype"`
+	Language    string    `json:"language"`
+	Search      string    `json:"search"`
 	Reverse     bool      `json:"reverse"`
 	MinSize     int64     `json:"min_size"`
 	MaxSize     int64     `json:"max_size"`
@@ -58,6 +62,30 @@ type ServerConfig struct {
 	Username string `json:"username"`
 }
 
+// ClipboardConfig selects where clipboard content comes from
+type ClipboardConfig struct {
+	Backend  string `json:"backend"`   // "native", "memory" or "file"
+	FilePath string `json:"file_path"` // File or FIFO for the "file" backend
+}
+
+// ImageConfig controls how captured images are processed
+type ImageConfig struct {
+	Normalize      bool `json:"normalize"`       // Re-encode images as PNG
+	MaxWidth       int  `json:"max_width"`       // Larger images are scaled down, 0 for no limit
+	MaxHeight      int  `json:"max_height"`      // Larger images are scaled down, 0 for no limit
+	ThumbnailSize  int  `json:"thumbnail_size"`  // Longest thumbnail edge in pixels, 0 disables thumbnails
+	DedupThreshold int  `json:"dedup_threshold"` // Max perceptual hash distance (0-64) for similar images, -1 disables grouping
+	SkipDuplicates bool `json:"skip_duplicates"` // Don't store an image similar to the previous one
+}
+
+// FileSnapshotConfig controls copying the files of file items into the blob area
+type FileSnapshotConfig struct {
+	Enabled    bool   `json:"enabled"`      // Snapshot files when they are copied
+	MaxFiles   int    `json:"max_files"`    // Most files snapshotted per item, 0 for no limit
+	MaxTotalMB int    `json:"max_total_mb"` // Most data snapshotted per item, 0 for no limit
+	RestoreDir string `json:"restore_dir"`  // Where missing files are recreated, defaults to {temp_dir}/restored
+}
+
 // Config holds all application configuration
 // TODO: move config types to types/relevant_file.go
 type Config struct {
@@ -84,6 +112,21 @@ type Config struct {
 	// Synchronization configuration
     Sync types.SyncConfig `json:"sync"`
 	
+	// Clipboard backend configuration
+	Clipboard ClipboardConfig `json:"clipboard"`
+	
+	// User hook scripts
+	Hooks HooksConfig `json:"hooks"`
+	
+	// Image processing
+	Images ImageConfig `json:"images"`
+	
+	// File snapshots
+	FileSnapshots FileSnapshotConfig `json:"file_snapshots"`
+	
+	// URL cleaning
+	URLCleaning URLCleaningConfig `json:"url_cleaning"`
+	
 	// Clipboard monitoring options
 	StealthMode     bool  `json:"stealth_mode"`     // Minimize clipboard access notifications
 	PollingInterval int64 `json:"polling_interval"` // Base polling interval in milliseconds
@@ -109,12 +152,16 @@ func DefaultSystemPaths() SystemPaths {
 	// Temp directory is in $HOME/.clipman/temp
 	tempDir := filepath.Join(dataDir, "temp")
 	
+	// File snapshots are in $HOME/.clipman/blobs
+	blobDir := filepath.Join(dataDir, "blobs")
+	
 	return SystemPaths{
 		ConfigFile: configFile,
 		DataDir:    dataDir,
 		DBFile:     dbFile,
 		LogDir:     logDir,
 		TempDir:    tempDir,
+		BlobDir:    blobDir,
 	}
 }
 
@@ -148,6 +195,36 @@ func DefaultHistoryOptions() HistoryOptions {
 	}
 }
 
+// DefaultClipboardConfig returns default clipboard backend configuration
+func DefaultClipboardConfig() ClipboardConfig {
+	return ClipboardConfig{
+		Backend:  "native", // Use the OS clipboard
+		FilePath: "",       // Defaults to {data_dir}/clipboard for the file backend
+	}
+}
+
+// DefaultImageConfig returns default image processing configuration
+func DefaultImageConfig() ImageConfig {
+	return ImageConfig{
+		Normalize:      true,
+		MaxWidth:       7680, // 8K
+		MaxHeight:      4320,
+		ThumbnailSize:  128,
+		DedupThreshold: 6,     // Out of 64 hash bits
+		SkipDuplicates: false, // Group similar images, but keep them all
+	}
+}
+
+// DefaultFileSnapshotConfig returns default file snapshot configuration
+func DefaultFileSnapshotConfig() FileSnapshotConfig {
+	return FileSnapshotConfig{
+		Enabled:    false, // Only keep paths unless asked to
+		MaxFiles:   20,
+		MaxTotalMB: 200,
+		RestoreDir: "", // Computed from SystemPaths
+	}
+}
+
 // DefaultConfig returns a configuration with sensible defaults
 func DefaultConfig() *Config {
 	config := &Config{
@@ -158,6 +235,11 @@ func DefaultConfig() *Config {
 		Log:           DefaultLogConfig(),
 		History:       DefaultHistoryOptions(),
 		Storage:       DefaultStorageConfig(),
+		Clipboard:     DefaultClipboardConfig(),
+		Hooks:         DefaultHooksConfig(),
+		Images:        DefaultImageConfig(),
+		FileSnapshots: DefaultFileSnapshotConfig(),
+		URLCleaning:   DefaultURLCleaningConfig(),
 		StealthMode:   true,            // Enabled by default
 		PollingInterval: 10000,         // 10 seconds by default for less frequent clipboard checks
 		Sync:          DefaultSyncConfig(),
@@ -192,12 +274,16 @@ func (c *Config) GetSystemPaths() SystemPaths {
 	// Set up temp directory
 	tempDir := filepath.Join(dataDir, "temp")
 	
+	// Set up blob directory for file snapshots
+	blobDir := filepath.Join(dataDir, "blobs")
+	
 	return SystemPaths{
 		ConfigFile: configPath,
 		DataDir:    dataDir,
 		DBFile:     dbPath,
 		LogDir:     logDir,
 		TempDir:    tempDir,
+		BlobDir:    blobDir,
 	}
 }
 
@@ -318,6 +404,29 @@ func overrideFromEnv(config *Config) {
 		config.Sync.DiscoveryMethod = val
 	}
 	
+	// Clipboard backend
+	if val := os.Getenv("CLIPMAN_CLIPBOARD_BACKEND"); val != "" {
+		config.Clipboard.Backend = val
+	}
+	if val := os.Getenv("CLIPMAN_CLIPBOARD_FILE"); val != "" {
+		config.Clipboard.FilePath = val
+	}
+	
+	// Hooks
+	if val := os.Getenv("CLIPMAN_HOOKS_ENABLED"); val != "" {
+		config.Hooks.Enabled = val == "true"
+	}
+	
+	// File snapshots
+	if val := os.Getenv("CLIPMAN_FILE_SNAPSHOTS"); val != "" {
+		config.FileSnapshots.Enabled = val == "true"
+	}
+	
+	// URL cleaning
+	if val := os.Getenv("CLIPMAN_URL_CLEANING"); val != "" {
+		config.URLCleaning.Enabled = val == "true"
+	}
+	
 	// Clipboard monitoring options
 	if val := os.Getenv("CLIPMAN_STEALTH_MODE"); val != "" {
 		config.StealthMode = val == "true"
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Hook events
+const (
+	HookEventCopy        = "copy"         // New content captured from the local clipboard
+	HookEventStore       = "store"        // Content persisted to storage
+	HookEventSyncReceive = "sync_receive" // Content received from a peer
+	HookEventEvict       = "evict"        // Content removed from storage to stay under limits
+)
+
+// HooksConfig holds configuration for user hook scripts
+type HooksConfig struct {
+	Enabled        bool         `json:"enabled"`
+	MaxConcurrent  int          `json:"max_concurrent"`  // Hooks running at the same time
+	DefaultTimeout int          `json:"default_timeout"` // Seconds, used when a hook sets none
+	Hooks          []HookConfig `json:"hooks"`
+}
+
+// HookConfig describes one executable run on clipboard events
+type HookConfig struct {
+	Name      string    `json:"name"`
+	Command   string    `json:"command"`
+	Args      []string  `json:"args"`
+	Events    []string  `json:"events"`    // copy, store, sync_receive, evict
+	Timeout   int       `json:"timeout"`   // Seconds, 0 uses the default timeout
+	Transform bool      `json:"transform"` // Replace copied content with the hook's stdout
+	Match     HookMatch `json:"match"`
+}
+
+// HookMatch restricts which content a hook runs for. Empty fields match anything.
+type HookMatch struct {
+	Types     []string `json:"types"`     // Content types, e.g. "text", "url", "image"
+	Subtypes  []string `json:"subtypes"`  // Content subtypes, e.g. "json", "code", "email"
+	Languages []string `json:"languages"` // Languages guessed for code, e.g. "go", "python"
+	Pattern   string   `json:"pattern"`   // Regular expression matched against the content
+	MinSize   int64    `json:"min_size"`  // Minimum content size in bytes
+	MaxSize   int64    `json:"max_size"`  // Maximum content size in bytes, 0 for no limit
+}
+
+// DefaultHooksConfig returns default hooks configuration
+func DefaultHooksConfig() HooksConfig {
+	return HooksConfig{
+		Enabled:        true,
+		MaxConcurrent:  4,  // Run at most 4 hooks at once
+		DefaultTimeout: 10, // Kill hooks after 10 seconds
+		Hooks:          []HookConfig{},
+	}
+}
+
+// ValidateHooksConfig validates the configured hooks
+func (c *Config) ValidateHooksConfig() error {
+	for i, hook := range c.Hooks.Hooks {
+		name := hook.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+
+		if hook.Command == "" {
+			return fmt.Errorf("hook %s: command is required", name)
+		}
+		if len(hook.Events) == 0 {
+			return fmt.Errorf("hook %s: at least one event is required", name)
+		}
+		for _, event := range hook.Events {
+			switch event {
+			case HookEventCopy, HookEventStore, HookEventSyncReceive, HookEventEvict:
+				// Valid events
+			default:
+				return fmt.Errorf("hook %s: invalid event: %s", name, event)
+			}
+		}
+		if hook.Transform && (len(hook.Events) != 1 || hook.Events[0] != HookEventCopy) {
+			return fmt.Errorf("hook %s: transform hooks only support the %s event", name, HookEventCopy)
+		}
+		if hook.Timeout < 0 {
+			return fmt.Errorf("hook %s: timeout must not be negative", name)
+		}
+		if hook.Match.Pattern != "" {
+			if _, err := regexp.Compile(hook.Match.Pattern); err != nil {
+				return fmt.Errorf("hook %s: invalid match pattern: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -23,6 +23,8 @@ func DefaultSyncConfig() types.SyncConfig {
         MaxClipboardSizeKB: 512,
         ClipboardHistorySize: 50,
         ClipboardBlacklistApps: []string{},
+        OutboxTTLMinutes:  24 * 60,
+        OutboxMaxSizeKB:   10 * 1024,
         
         // File Transfer Options
         EnableFileSharing: true,
@@ -69,6 +71,14 @@ func (c *Config) ValidateSyncConfig() error {
         return fmt.Errorf("max_clipboard_size_kb cannot be negative")
     }
     
+    if c.Sync.OutboxTTLMinutes < 0 {
+        return fmt.Errorf("outbox_ttl_minutes cannot be negative")
+    }
+    
+    if c.Sync.OutboxMaxSizeKB < 0 {
+        return fmt.Errorf("outbox_max_size_kb cannot be negative")
+    }
+    
     if c.Sync.MaxFileSizeMB < 0 {
         return fmt.Errorf("max_file_size_mb cannot be negative")
     }
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+)
+
+// URLCleaningConfig controls how captured URLs are canonicalized
+type URLCleaningConfig struct {
+	Enabled         bool           `json:"enabled"`
+	StripParams     []string       `json:"strip_params"`     // Query parameters to remove, a trailing * matches a prefix
+	UnwrapRedirects bool           `json:"unwrap_redirects"` // Replace redirect links with their target
+	RedirectRules   []RedirectRule `json:"redirect_rules"`
+}
+
+// RedirectRule describes a redirect wrapper that carries its target URL in a query parameter
+type RedirectRule struct {
+	Host  string `json:"host"`  // Host of the wrapper, a leading "*." also matches subdomains
+	Path  string `json:"path"`  // Path of the wrapper, empty for any path
+	Param string `json:"param"` // Query parameter holding the target URL
+}
+
+// DefaultURLCleaningConfig returns default URL cleaning configuration
+func DefaultURLCleaningConfig() URLCleaningConfig {
+	return URLCleaningConfig{
+		Enabled: true,
+		StripParams: []string{
+			"utm_*", "fbclid", "gclid", "dclid", "gbraid", "wbraid", "msclkid",
+			"yclid", "twclid", "igshid", "mc_cid", "mc_eid", "_hsenc", "_hsmi",
+			"mkt_tok", "oly_anon_id", "oly_enc_id", "vero_id", "ref_src",
+		},
+		UnwrapRedirects: true,
+		RedirectRules: []RedirectRule{
+			{Host: "www.google.com", Path: "/url", Param: "q"},
+			{Host: "www.google.com", Path: "/url", Param: "url"},
+			{Host: "google.com", Path: "/url", Param: "q"},
+			{Host: "*.safelinks.protection.outlook.com", Param: "url"},
+			{Host: "l.facebook.com", Path: "/l.php", Param: "u"},
+			{Host: "lm.facebook.com", Path: "/l.php", Param: "u"},
+			{Host: "www.youtube.com", Path: "/redirect", Param: "q"},
+			{Host: "steamcommunity.com", Path: "/linkfilter/", Param: "url"},
+			{Host: "slack-redir.net", Path: "/link", Param: "url"},
+		},
+	}
+}
+
+// ValidateURLCleaningConfig validates the redirect rules
+func (c *Config) ValidateURLCleaningConfig() error {
+	for i, rule := range c.URLCleaning.RedirectRules {
+		if rule.Host == "" || rule.Param == "" {
+			return fmt.Errorf("redirect rule #%d: host and param are required", i+1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,366 @@
+// Package hooks runs user-configured executables on clipboard events.
+//
+// Hooks receive item metadata in CLIPMAN_HOOK_* environment variables and
+// the content on stdin. Transform hooks run synchronously on copy and their
+// stdout replaces the copied content.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/config"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"github.com/berrythewa/clipman-daemon/pkg/classify"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultTimeout       = 10 * time.Second
+	defaultMaxConcurrent = 4
+	defaultMaxOutput     = 100 * 1024 * 1024 // 100MB, matches the default storage limit
+	maxStderr            = 4096              // Bytes of stderr kept for error logs
+	waitDelay            = time.Second       // Grace period for pipes after a hook is killed
+)
+
+// errOutputTooLarge is returned when a transform hook writes more than allowed
+var errOutputTooLarge = errors.New("hook output exceeds maximum size")
+
+// hook is a configured hook with its match pattern compiled
+type hook struct {
+	config.HookConfig
+	pattern *regexp.Regexp
+	timeout time.Duration
+}
+
+// Runner dispatches clipboard events to configured hooks
+type Runner struct {
+	hooks     []*hook
+	sem       chan struct{}
+	deviceID  string
+	maxOutput int64
+	logger    *zap.Logger
+	wg        sync.WaitGroup
+}
+
+// NewRunner creates a hook runner from the configuration. It returns
+// a runner without hooks when hooks are disabled.
+func NewRunner(cfg *config.Config, logger *zap.Logger) (*Runner, error) {
+	maxConcurrent := cfg.Hooks.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultMaxConcurrent
+	}
+
+	maxOutput := cfg.Storage.MaxSize
+	if maxOutput <= 0 {
+		maxOutput = defaultMaxOutput
+	}
+
+	r := &Runner{
+		sem:       make(chan struct{}, maxConcurrent),
+		deviceID:  cfg.DeviceID,
+		maxOutput: maxOutput,
+		logger:    logger,
+	}
+
+	if !cfg.Hooks.Enabled {
+		return r, nil
+	}
+
+	if err := cfg.ValidateHooksConfig(); err != nil {
+		return nil, fmt.Errorf("invalid hooks configuration: %w", err)
+	}
+
+	fallback := time.Duration(cfg.Hooks.DefaultTimeout) * time.Second
+	if fallback <= 0 {
+		fallback = defaultTimeout
+	}
+
+	for _, hc := range cfg.Hooks.Hooks {
+		h := &hook{HookConfig: hc, timeout: fallback}
+		if h.Name == "" {
+			h.Name = h.Command
+		}
+		if hc.Timeout > 0 {
+			h.timeout = time.Duration(hc.Timeout) * time.Second
+		}
+		if hc.Match.Pattern != "" {
+			h.pattern = regexp.MustCompile(hc.Match.Pattern) // Validated above
+		}
+		r.hooks = append(r.hooks, h)
+	}
+
+	if len(r.hooks) > 0 {
+		logger.Info("Loaded clipboard hooks",
+			zap.Int("count", len(r.hooks)),
+			zap.Int("max_concurrent", maxConcurrent))
+	}
+	return r, nil
+}
+
+// HasHooks reports whether any hooks are configured
+func (r *Runner) HasHooks() bool {
+	return r != nil && len(r.hooks) > 0
+}
+
+// Fire runs the non-transform hooks registered for an event in the background.
+// Hooks are skipped when the concurrency limit is reached. peer is only set
+// for sync_receive events.
+func (r *Runner) Fire(event string, content *types.ClipboardContent, peer *types.PeerInfo) {
+	if !r.HasHooks() || content == nil {
+		return
+	}
+
+	// Snapshot the content so later changes don't race with running hooks
+	snapshot := *content
+	snapshot.Metadata = maps.Clone(content.Metadata)
+
+	for _, h := range r.hooks {
+		if h.Transform || !h.handles(event) || !h.matches(&snapshot) {
+			continue
+		}
+
+		select {
+		case r.sem <- struct{}{}:
+		default:
+			r.logger.Warn("Too many hooks running, skipping hook",
+				zap.String("hook", h.Name),
+				zap.String("event", event))
+			continue
+		}
+
+		r.wg.Add(1)
+		go func(h *hook) {
+			defer r.wg.Done()
+			defer func() { <-r.sem }()
+
+			if _, err := r.run(h, event, &snapshot, peer, false); err != nil {
+				r.logger.Warn("Hook failed",
+					zap.String("hook", h.Name),
+					zap.String("event", event),
+					zap.Error(err))
+			}
+		}(h)
+	}
+}
+
+// Transform runs the transform hooks for copied content in order. Each hook
+// receives the output of the previous one; a hook that fails, times out or
+// writes nothing leaves the content unchanged.
+func (r *Runner) Transform(content *types.ClipboardContent) *types.ClipboardContent {
+	if !r.HasHooks() || content == nil {
+		return content
+	}
+
+	classified := false
+	for _, h := range r.hooks {
+		if !h.Transform {
+			continue
+		}
+		// Copied content is classified after the transformers, so it is
+		// only classified here for hooks matching on its subtype
+		if !classified && h.matchesClassification() {
+			classify.Content(content)
+			classified = true
+		}
+		if !h.matches(content) {
+			continue
+		}
+
+		// Wait for a free slot, but no longer than the hook itself may run
+		select {
+		case r.sem <- struct{}{}:
+		case <-time.After(h.timeout):
+			r.logger.Warn("Too many hooks running, skipping transform hook",
+				zap.String("hook", h.Name))
+			continue
+		}
+
+		output, err := r.run(h, config.HookEventCopy, content, nil, true)
+		<-r.sem
+
+		if err != nil {
+			r.logger.Warn("Transform hook failed, keeping content unchanged",
+				zap.String("hook", h.Name),
+				zap.Error(err))
+			continue
+		}
+		if len(output) == 0 {
+			continue
+		}
+
+		r.logger.Debug("Transform hook replaced content",
+			zap.String("hook", h.Name),
+			zap.Int("old_size", len(content.Data)),
+			zap.Int("new_size", len(output)))
+		content.Data = output
+		classified = false
+	}
+
+	return content
+}
+
+// Transformer returns Transform as a content transformer for the ContentProcessor chain
+func (r *Runner) Transformer() func(*types.ClipboardContent) *types.ClipboardContent {
+	return r.Transform
+}
+
+// Wait blocks until all background hooks have finished
+func (r *Runner) Wait() {
+	if r != nil {
+		r.wg.Wait()
+	}
+}
+
+// run executes a hook and returns its stdout if captureOutput is set
+func (r *Runner) run(h *hook, event string, content *types.ClipboardContent, peer *types.PeerInfo, captureOutput bool) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.Command, h.Args...)
+	cmd.Env = append(os.Environ(), r.environment(h, event, content, peer)...)
+	cmd.Stdin = bytes.NewReader(content.Data)
+	cmd.WaitDelay = waitDelay
+
+	stdout := &limitedBuffer{limit: r.maxOutput}
+	if captureOutput {
+		cmd.Stdout = stdout
+	}
+	stderr := &limitedBuffer{limit: maxStderr, truncate: true}
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	err := cmd.Run()
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return nil, fmt.Errorf("timed out after %s", h.timeout)
+	case stdout.exceeded:
+		return nil, errOutputTooLarge
+	case err != nil:
+		if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+
+	r.logger.Debug("Hook completed",
+		zap.String("hook", h.Name),
+		zap.String("event", event),
+		zap.Duration("duration", time.Since(start)))
+
+	return stdout.Bytes(), nil
+}
+
+// environment returns the CLIPMAN_HOOK_* variables describing an event
+func (r *Runner) environment(h *hook, event string, content *types.ClipboardContent, peer *types.PeerInfo) []string {
+	env := []string{
+		"CLIPMAN_HOOK_NAME=" + h.Name,
+		"CLIPMAN_HOOK_EVENT=" + event,
+		"CLIPMAN_HOOK_DEVICE_ID=" + r.deviceID,
+		"CLIPMAN_HOOK_CONTENT_TYPE=" + string(content.Type),
+		"CLIPMAN_HOOK_CONTENT_SIZE=" + strconv.Itoa(len(content.Data)),
+	}
+	if content.Subtype != types.SubtypeNone {
+		env = append(env, "CLIPMAN_HOOK_CONTENT_SUBTYPE="+string(content.Subtype))
+	}
+	for key, value := range content.Metadata {
+		env = append(env, "CLIPMAN_HOOK_META_"+strings.ToUpper(key)+"="+value)
+	}
+	if !content.Created.IsZero() {
+		env = append(env, "CLIPMAN_HOOK_CREATED="+content.Created.Format(time.RFC3339Nano))
+	}
+	if peer != nil {
+		env = append(env,
+			"CLIPMAN_HOOK_PEER_ID="+peer.ID,
+			"CLIPMAN_HOOK_PEER_NAME="+peer.Name)
+	}
+	return env
+}
+
+// handles reports whether the hook is registered for an event
+func (h *hook) handles(event string) bool {
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesClassification reports whether the hook matches on the subtype or
+// language found by classification
+func (h *hook) matchesClassification() bool {
+	return len(h.Match.Subtypes) > 0 || len(h.Match.Languages) > 0
+}
+
+// matches reports whether content satisfies the hook's match conditions
+func (h *hook) matches(content *types.ClipboardContent) bool {
+	size := int64(len(content.Data))
+	if size < h.Match.MinSize {
+		return false
+	}
+	if h.Match.MaxSize > 0 && size > h.Match.MaxSize {
+		return false
+	}
+
+	if len(h.Match.Types) > 0 && !containsString(h.Match.Types, string(content.Type)) {
+		return false
+	}
+	if len(h.Match.Subtypes) > 0 && !containsString(h.Match.Subtypes, string(content.Subtype)) {
+		return false
+	}
+	if len(h.Match.Languages) > 0 && !containsString(h.Match.Languages, content.Metadata[types.MetaLanguage]) {
+		return false
+	}
+
+	if h.pattern != nil && !h.pattern.Match(content.Data) {
+		return false
+	}
+	return true
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedBuffer collects output up to a limit. Past the limit it either
+// drops the rest (truncate) or fails the write so the hook is stopped.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit    int64
+	truncate bool
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	remaining := b.limit - int64(b.Len())
+	if int64(len(p)) <= remaining {
+		return b.Buffer.Write(p)
+	}
+
+	b.exceeded = true
+	if !b.truncate {
+		return 0, errOutputTooLarge
+	}
+	if remaining > 0 {
+		b.Buffer.Write(p[:remaining])
+	}
+	return len(p), nil
+}
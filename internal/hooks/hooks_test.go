@@ -0,0 +1,151 @@
+package hooks
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/config"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"go.uber.org/zap"
+)
+
+func newTestRunner(t *testing.T, hooks ...config.HookConfig) *Runner {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	cfg.DeviceID = "test-device"
+	cfg.Hooks.Hooks = hooks
+
+	runner, err := NewRunner(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewRunner: %v", err)
+	}
+	return runner
+}
+
+func textContent(data string) *types.ClipboardContent {
+	return &types.ClipboardContent{Type: types.TypeText, Data: []byte(data), Created: time.Now()}
+}
+
+func TestTransformReplacesMatchingContent(t *testing.T) {
+	runner := newTestRunner(t, config.HookConfig{
+		Name:      "upper",
+		Command:   "tr",
+		Args:      []string{"a-z", "A-Z"},
+		Events:    []string{config.HookEventCopy},
+		Transform: true,
+		Match:     config.HookMatch{Pattern: `^hello`},
+	})
+
+	got := runner.Transform(textContent("hello world"))
+	if string(got.Data) != "HELLO WORLD" {
+		t.Errorf("expected transformed content, got %q", got.Data)
+	}
+
+	got = runner.Transform(textContent("goodbye"))
+	if string(got.Data) != "goodbye" {
+		t.Errorf("expected non-matching content to be unchanged, got %q", got.Data)
+	}
+}
+
+func TestTransformClassifiesForSubtypeMatches(t *testing.T) {
+	runner := newTestRunner(t, config.HookConfig{
+		Name:      "compact-json",
+		Command:   "tr",
+		Args:      []string{"-d", " "},
+		Events:    []string{config.HookEventCopy},
+		Transform: true,
+		Match:     config.HookMatch{Subtypes: []string{string(types.SubtypeJSON)}},
+	})
+
+	// Copied content reaches transformers unclassified
+	got := runner.Transform(textContent(`{"a": 1, "b": [2, 3]}`))
+	if string(got.Data) != `{"a":1,"b":[2,3]}` {
+		t.Errorf("expected the JSON hook to run, got %q", got.Data)
+	}
+
+	got = runner.Transform(textContent("not json at all"))
+	if string(got.Data) != "not json at all" {
+		t.Errorf("expected text to be unchanged, got %q", got.Data)
+	}
+}
+
+func TestTransformKeepsContentOnFailure(t *testing.T) {
+	runner := newTestRunner(t,
+		config.HookConfig{
+			Name:      "fails",
+			Command:   "sh",
+			Args:      []string{"-c", "echo replaced; exit 1"},
+			Events:    []string{config.HookEventCopy},
+			Transform: true,
+		},
+		config.HookConfig{
+			Name:      "slow",
+			Command:   "sleep",
+			Args:      []string{"5"},
+			Events:    []string{config.HookEventCopy},
+			Timeout:   1,
+			Transform: true,
+		})
+
+	start := time.Now()
+	got := runner.Transform(textContent("original"))
+	if string(got.Data) != "original" {
+		t.Errorf("expected content unchanged, got %q", got.Data)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("timeout was not enforced, took %s", elapsed)
+	}
+}
+
+func TestFirePassesMetadataAndContent(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	runner := newTestRunner(t, config.HookConfig{
+		Name:    "record",
+		Command: "sh",
+		Args: []string{"-c",
+			`printf '%s %s %s %s\n' "$CLIPMAN_HOOK_EVENT" "$CLIPMAN_HOOK_CONTENT_TYPE" "$CLIPMAN_HOOK_DEVICE_ID" "$CLIPMAN_HOOK_PEER_ID" > "$0"; cat >> "$0"`,
+			out},
+		Events: []string{config.HookEventSyncReceive},
+		Match:  config.HookMatch{Types: []string{"text"}, MinSize: 3},
+	})
+
+	// Neither the wrong event nor too small content runs the hook
+	runner.Fire(config.HookEventCopy, textContent("ignored"), nil)
+	runner.Fire(config.HookEventSyncReceive, textContent("no"), nil)
+	runner.Wait()
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Fatalf("hook ran for a non-matching event or content")
+	}
+
+	runner.Fire(config.HookEventSyncReceive, textContent("from peer"), &types.PeerInfo{ID: "peer-1"})
+	runner.Wait()
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("hook did not run: %v", err)
+	}
+	lines := strings.SplitN(string(data), "\n", 2)
+	if lines[0] != "sync_receive text test-device peer-1" {
+		t.Errorf("unexpected metadata %q", lines[0])
+	}
+	if len(lines) < 2 || lines[1] != "from peer" {
+		t.Errorf("unexpected stdin content %q", data)
+	}
+}
+
+func TestNewRunnerRejectsInvalidHooks(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Hooks.Hooks = []config.HookConfig{{
+		Command:   "cat",
+		Events:    []string{config.HookEventStore},
+		Transform: true,
+	}}
+
+	if _, err := NewRunner(cfg, zap.NewNop()); err == nil {
+		t.Error("expected a transform hook on the store event to be rejected")
+	}
+}
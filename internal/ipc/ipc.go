@@ -0,0 +1,271 @@
+// Package ipc provides the local control channel between CLI commands and a
+// running daemon. The daemon holds an exclusive lock on the database, so
+// anything that needs live state goes through this socket instead.
+//
+// The protocol is one JSON request and one JSON response per connection over
+// a Unix domain socket in the data directory.
+package ipc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SocketName is the file name of the control socket inside the data directory
+const SocketName = "clipman.sock"
+
+// defaultTimeout bounds a single request/response exchange
+const defaultTimeout = 10 * time.Second
+
+// ErrDaemonNotRunning is returned by clients when nothing listens on the socket
+var ErrDaemonNotRunning = errors.New("clipman daemon is not running")
+
+// Request is a command sent to the daemon
+type Request struct {
+	Command string          `json:"command"`
+	Args    json.RawMessage `json:"args,omitempty"`
+}
+
+// Response is the daemon's answer to a request
+type Response struct {
+	OK    bool            `json:"ok"`
+	Error string          `json:"error,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// HandlerFunc handles a command. The returned value is sent back as JSON.
+type HandlerFunc func(args json.RawMessage) (interface{}, error)
+
+// SocketPath returns the control socket path for a data directory
+func SocketPath(dataDir string) string {
+	return filepath.Join(dataDir, SocketName)
+}
+
+// Server serves control requests from CLI commands
+type Server struct {
+	path     string
+	logger   *zap.Logger
+	listener net.Listener
+	handlers map[string]HandlerFunc
+	mutex    sync.RWMutex
+	wg       sync.WaitGroup
+}
+
+// NewServer creates a new control server listening on path
+func NewServer(path string, logger *zap.Logger) *Server {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Server{
+		path:     path,
+		logger:   logger.With(zap.String("component", "ipc")),
+		handlers: make(map[string]HandlerFunc),
+	}
+}
+
+// Handle registers a handler for a command
+func (s *Server) Handle(command string, handler HandlerFunc) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.handlers[command] = handler
+}
+
+// Start starts listening for requests
+func (s *Server) Start() error {
+	// A socket left behind by a crashed daemon would make Listen fail
+	if _, err := os.Stat(s.path); err == nil {
+		if conn, err := net.DialTimeout("unix", s.path, time.Second); err == nil {
+			conn.Close()
+			return fmt.Errorf("another daemon is already listening on %s", s.path)
+		}
+		os.Remove(s.path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+
+	listener, err := net.Listen("unix", s.path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+
+	// Only the owner may control the daemon
+	if err := os.Chmod(s.path, 0600); err != nil {
+		s.logger.Warn("Failed to restrict control socket permissions", zap.Error(err))
+	}
+
+	s.listener = listener
+	s.wg.Add(1)
+	go s.acceptLoop()
+
+	s.logger.Info("Control socket listening", zap.String("path", s.path))
+	return nil
+}
+
+// Stop stops the server and removes the socket
+func (s *Server) Stop() error {
+	if s.listener == nil {
+		return nil
+	}
+
+	err := s.listener.Close()
+	s.wg.Wait()
+	os.Remove(s.path)
+	s.listener = nil
+	return err
+}
+
+// acceptLoop accepts connections until the listener is closed
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.logger.Warn("Failed to accept control connection", zap.Error(err))
+			continue
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serve(conn)
+		}()
+	}
+}
+
+// serve handles a single request on a connection
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(defaultTimeout))
+
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		s.logger.Debug("Failed to decode control request", zap.Error(err))
+		return
+	}
+
+	s.mutex.RLock()
+	handler, ok := s.handlers[req.Command]
+	s.mutex.RUnlock()
+
+	var resp Response
+	if !ok {
+		resp.Error = fmt.Sprintf("unknown command: %s", req.Command)
+	} else if result, err := handler(req.Args); err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.OK = true
+		if result != nil {
+			data, err := json.Marshal(result)
+			if err != nil {
+				resp.OK = false
+				resp.Error = fmt.Sprintf("failed to encode response: %v", err)
+			} else {
+				resp.Data = data
+			}
+		}
+	}
+
+	s.logger.Debug("Handled control request",
+		zap.String("command", req.Command),
+		zap.Bool("ok", resp.OK))
+
+	// Handlers may take a while (e.g. waiting for peers), so the write
+	// deadline starts after the handler returns
+	conn.SetWriteDeadline(time.Now().Add(defaultTimeout))
+	if err := json.NewEncoder(conn).Encode(&resp); err != nil {
+		s.logger.Debug("Failed to write control response", zap.Error(err))
+	}
+}
+
+// Client sends control requests to a running daemon
+type Client struct {
+	path    string
+	timeout time.Duration
+}
+
+// NewClient creates a client for the socket at path
+func NewClient(path string) *Client {
+	return &Client{
+		path:    path,
+		timeout: defaultTimeout,
+	}
+}
+
+// SetTimeout sets how long to wait for a response
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// IsDaemonRunning reports whether a daemon is listening on the socket
+func (c *Client) IsDaemonRunning() bool {
+	conn, err := net.DialTimeout("unix", c.path, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// Call sends a command with optional args and decodes the result into result,
+// which may be nil
+func (c *Client) Call(command string, args interface{}, result interface{}) error {
+	conn, err := net.DialTimeout("unix", c.path, time.Second)
+	if err != nil {
+		return ErrDaemonNotRunning
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.timeout))
+
+	req := Request{Command: command}
+	if args != nil {
+		data, err := json.Marshal(args)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		req.Args = data
+	}
+
+	if err := json.NewEncoder(conn).Encode(&req); err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if !resp.OK {
+		return errors.New(resp.Error)
+	}
+
+	if result != nil && len(resp.Data) > 0 {
+		if err := json.Unmarshal(resp.Data, result); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// DecodeArgs unmarshals request args into v, treating missing args as empty
+func DecodeArgs(args json.RawMessage, v interface{}) error {
+	if len(args) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(args, v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
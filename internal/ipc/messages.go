@@ -0,0 +1,220 @@
+package ipc
+
+import (
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/clipboard"
+	"github.com/berrythewa/clipman-daemon/internal/config"
+	"github.com/berrythewa/clipman-daemon/internal/storage"
+	"github.com/berrythewa/clipman-daemon/internal/sync"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+// Control commands understood by the daemon
+const (
+	CommandStatus    = "status"
+	CommandPause     = "pause"
+	CommandResume    = "resume"
+	CommandQueue     = "queue"
+	CommandNext      = "next"
+	CommandRestore   = "restore"
+	CommandSnippet   = "snippet"
+	CommandTransform = "transform"
+	CommandHistory   = "history"
+	CommandPin       = "pin"
+	CommandDelete    = "delete"
+	CommandFile      = "file"
+	CommandGroup     = "group"
+	CommandPolicy    = "policy"
+	CommandRevoke    = "revoke"
+)
+
+// PauseArgs are the arguments of the pause command
+type PauseArgs struct {
+	Duration      time.Duration `json:"duration"` // Zero pauses until resumed
+	AllowPeerCopy bool          `json:"allow_peer_copy"`
+}
+
+// RestoreArgs are the arguments of the restore command
+type RestoreArgs struct {
+	storage.ItemSelector
+	PlainText bool `json:"plain_text,omitempty"` // Restore as plain text, dropping formatting
+}
+
+// HistoryArgs are the arguments of the history command
+type HistoryArgs = config.HistoryOptions
+
+// HistoryItem is a history item sent to clients. Image data is left out,
+// Size gives its length.
+type HistoryItem struct {
+	types.ClipboardContent
+	Size int `json:"size"`
+}
+
+// PinArgs are the arguments of the pin command
+type PinArgs struct {
+	ID     string `json:"id"`
+	Pinned bool   `json:"pinned"`
+}
+
+// DeleteArgs are the arguments of the delete command
+type DeleteArgs struct {
+	ID string `json:"id"`
+}
+
+// Queue actions
+const (
+	QueueActionStart  = "start"
+	QueueActionStop   = "stop"
+	QueueActionStatus = "status"
+)
+
+// QueueArgs are the arguments of the queue command
+type QueueArgs struct {
+	Action string               `json:"action"`          // start, stop or status
+	Order  clipboard.QueueOrder `json:"order,omitempty"` // For start, fifo by default
+}
+
+// NextResponse describes the item pasted by the next command
+type NextResponse struct {
+	Type    types.ContentType    `json:"type"`
+	Preview string               `json:"preview"`
+	Queue   clipboard.QueueState `json:"queue"`
+}
+
+// RestoreResponse describes the item restored by the restore command
+type RestoreResponse struct {
+	ID      string            `json:"id"`
+	Type    types.ContentType `json:"type"`
+	Created time.Time         `json:"created"`
+	Preview string            `json:"preview"`
+}
+
+// Snippet actions
+const (
+	SnippetActionSave   = "save"
+	SnippetActionList   = "list"
+	SnippetActionGet    = "get"
+	SnippetActionDelete = "delete"
+	SnippetActionExpand = "expand"
+)
+
+// SnippetArgs are the arguments of the snippet command
+type SnippetArgs struct {
+	Action  string            `json:"action"`
+	Name    string            `json:"name,omitempty"`
+	Tag     string            `json:"tag,omitempty"`     // For list, only snippets with this tag
+	Snippet *types.Snippet    `json:"snippet,omitempty"` // For save
+	Values  map[string]string `json:"values,omitempty"`  // For expand, answers to prompts by label
+}
+
+// SnippetResponse is the result of a snippet action
+type SnippetResponse struct {
+	Snippets []*types.Snippet `json:"snippets,omitempty"` // Listed, fetched or saved snippets
+	Expanded string           `json:"expanded,omitempty"` // Text placed on the clipboard by expand
+}
+
+// TransformArgs are the arguments of the transform command
+type TransformArgs struct {
+	Transforms []string              `json:"transforms"`     // Applied in order
+	Item       *storage.ItemSelector `json:"item,omitempty"` // History item to transform, the current clipboard if nil
+}
+
+// TransformResponse describes the text written by the transform command
+type TransformResponse struct {
+	Preview string `json:"preview"`
+	Size    int    `json:"size"`
+}
+
+// File transfer actions
+const (
+	FileActionSend    = "send"
+	FileActionList    = "list"
+	FileActionAccept  = "accept"
+	FileActionDecline = "decline"
+	FileActionCancel  = "cancel"
+)
+
+// FileArgs are the arguments of the file command
+type FileArgs struct {
+	Action string `json:"action"`
+	Peer   string `json:"peer,omitempty"` // For send, a paired device's peer ID or name
+	Path   string `json:"path,omitempty"` // For send, absolute path of the file
+	ID     string `json:"id,omitempty"`   // Transfer to accept, decline or cancel
+}
+
+// FileResponse lists transfers, the started one for send
+type FileResponse struct {
+	Transfers []sync.TransferInfo `json:"transfers"`
+}
+
+// Group actions
+const (
+	GroupActionCreate  = "create"
+	GroupActionJoin    = "join"
+	GroupActionLeave   = "leave"
+	GroupActionList    = "list"
+	GroupActionMembers = "members"
+	GroupActionAdd     = "add"
+	GroupActionRemove  = "remove"
+)
+
+// GroupArgs are the arguments of the group command
+type GroupArgs struct {
+	Action string          `json:"action"`
+	Name   string          `json:"name,omitempty"`
+	Route  sync.GroupRoute `json:"route,omitempty"` // For create and join, the content published to the group
+	Peer   string          `json:"peer,omitempty"`  // For add and remove, the device's name or peer ID
+}
+
+// GroupResponse lists groups, the created or joined one for create and
+// join, and for members the peers online in the group. For add and remove,
+// Delivered lists the devices that received the group key right away.
+type GroupResponse struct {
+	Groups    []sync.GroupMembership `json:"groups,omitempty"`
+	Members   []types.PeerInfo       `json:"members,omitempty"`
+	Delivered []string               `json:"delivered,omitempty"`
+}
+
+// Sync policy actions
+const (
+	PolicyActionList     = "list"
+	PolicyActionSetPeer  = "set-peer"
+	PolicyActionSetGroup = "set-group"
+)
+
+// PolicyArgs are the arguments of the policy command
+type PolicyArgs struct {
+	Action string          `json:"action"`
+	Peer   string          `json:"peer,omitempty"`  // For set-peer, a paired device's peer ID or name
+	Group  string          `json:"group,omitempty"` // For set-group, the group name
+	Policy sync.SyncPolicy `json:"policy"`          // The zero policy removes the device's or group's policy
+}
+
+// PolicyResponse lists sync policies
+type PolicyResponse struct {
+	sync.Policies
+	DeviceNames map[string]string `json:"device_names,omitempty"` // Names of paired devices with a policy, by peer ID
+}
+
+// RevokeArgs are the arguments of the revoke command
+type RevokeArgs struct {
+	Peer string `json:"peer"` // A paired device's peer ID or name
+}
+
+// RevokeResponse reports a revocation
+type RevokeResponse = sync.RevocationResult
+
+// StatusResponse describes the running daemon
+type StatusResponse struct {
+	PID           int                    `json:"pid"`
+	StartedAt     time.Time              `json:"started_at"`
+	DeviceID      string                 `json:"device_id"`
+	Backend       string                 `json:"backend"`
+	Pause         clipboard.PauseState   `json:"pause"`
+	Queue         clipboard.QueueState   `json:"queue"`
+	SyncEnabled   bool                   `json:"sync_enabled"`
+	SyncConnected bool                   `json:"sync_connected"`
+	PeerCount     int                    `json:"peer_count"`
+	Outbox        *clipboard.OutboxState `json:"outbox,omitempty"` // Content waiting for peers, nil when sync is disabled
+}
@@ -0,0 +1,376 @@
+package platform
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+// Clipboard backends that don't need a display server
+const (
+	BackendNative = "native" // OS clipboard (default)
+	BackendMemory = "memory" // In-process clipboard state only
+	BackendFile   = "file"   // Regular file or FIFO on disk
+)
+
+// HeadlessClipboard is a clipboard that lives in memory or in a file on disk.
+// It is meant for servers, containers and tests where no display server exists.
+//
+// In memory mode, SetContent simulates another application copying something.
+// In file mode, writes to the file by other processes are reported as changes;
+// if the path is a FIFO, every writer session (open, write, close) is one copy.
+//
+// Like the OS clipboards, content written through Write is not reported back
+// by MonitorChanges, so content received from peers isn't captured again.
+type HeadlessClipboard struct {
+	mu           sync.Mutex
+	content      *types.ClipboardContent
+	path         string
+	isFIFO       bool
+	pollInterval time.Duration
+
+	// State used to detect external changes
+	lastData    []byte
+	lastModTime time.Time
+	lastSize    int64
+
+	notify    chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+	isRunning bool
+	fifoOpen  bool // the FIFO reader is blocked waiting for a writer
+}
+
+// NewMemoryClipboard creates a clipboard that only exists in memory
+func NewMemoryClipboard() *HeadlessClipboard {
+	return &HeadlessClipboard{
+		notify: make(chan struct{}, 1),
+		closed: make(chan struct{}),
+	}
+}
+
+// NewFileClipboard creates a clipboard backed by a regular file or FIFO.
+// A missing path is created as an empty regular file.
+func NewFileClipboard(path string, pollInterval time.Duration) (*HeadlessClipboard, error) {
+	if path == "" {
+		return nil, fmt.Errorf("file clipboard requires a path")
+	}
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			return nil, fmt.Errorf("failed to create clipboard file: %w", err)
+		}
+		info, err = os.Stat(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat clipboard file: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("clipboard file %s is a directory", path)
+	}
+
+	c := &HeadlessClipboard{
+		path:         path,
+		isFIFO:       info.Mode()&os.ModeNamedPipe != 0,
+		pollInterval: pollInterval,
+		notify:       make(chan struct{}, 1),
+		closed:       make(chan struct{}),
+	}
+
+	// Whatever is in the file at startup counts as the current content,
+	// not as a new copy
+	if !c.isFIFO {
+		c.lastModTime = info.ModTime()
+		c.lastSize = info.Size()
+		if data, err := os.ReadFile(path); err == nil && len(data) > 0 {
+			c.lastData = data
+			c.content = newHeadlessContent(data)
+		}
+	}
+
+	return c, nil
+}
+
+// newHeadlessContent wraps raw bytes, telling images apart from text
+func newHeadlessContent(data []byte) *types.ClipboardContent {
+	contentType := types.TypeText
+	if strings.HasPrefix(http.DetectContentType(data), "image/") {
+		contentType = types.TypeImage
+	}
+	return &types.ClipboardContent{
+		Type:    contentType,
+		Data:    data,
+		Created: time.Now(),
+	}
+}
+
+// copyContent returns a deep copy so callers can't mutate our state
+func copyContent(content *types.ClipboardContent) *types.ClipboardContent {
+	data := make([]byte, len(content.Data))
+	copy(data, content.Data)
+	return &types.ClipboardContent{
+		Type:       content.Type,
+		Data:       data,
+		Created:    content.Created,
+		Compressed: content.Compressed,
+	}
+}
+
+// Read gets the current clipboard content
+func (c *HeadlessClipboard) Read() (*types.ClipboardContent, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.content == nil {
+		return nil, fmt.Errorf("clipboard is empty")
+	}
+	return copyContent(c.content), nil
+}
+
+// Write sets the clipboard content without reporting it as a change
+func (c *HeadlessClipboard) Write(content *types.ClipboardContent) error {
+	if content == nil || len(content.Data) == 0 {
+		return fmt.Errorf("empty content")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.content = copyContent(content)
+	c.lastData = c.content.Data
+
+	if c.path != "" && !c.isFIFO {
+		if err := os.WriteFile(c.path, content.Data, 0600); err != nil {
+			return fmt.Errorf("failed to write clipboard file: %w", err)
+		}
+		if info, err := os.Stat(c.path); err == nil {
+			c.lastModTime = info.ModTime()
+			c.lastSize = info.Size()
+		}
+	}
+
+	return nil
+}
+
+// SetContent replaces the clipboard content as if another application had
+// copied it, so it is reported by MonitorChanges
+func (c *HeadlessClipboard) SetContent(content *types.ClipboardContent) error {
+	if content == nil || len(content.Data) == 0 {
+		return fmt.Errorf("empty content")
+	}
+
+	c.mu.Lock()
+	c.content = copyContent(content)
+	c.mu.Unlock()
+
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// MonitorChanges monitors for clipboard changes and sends updates to the channel
+func (c *HeadlessClipboard) MonitorChanges(contentCh chan<- *types.ClipboardContent, stopCh <-chan struct{}) {
+	c.mu.Lock()
+	if c.isRunning {
+		c.mu.Unlock()
+		return
+	}
+	c.isRunning = true
+	c.mu.Unlock()
+
+	// In-process changes are reported in every mode
+	var watchers sync.WaitGroup
+	watch := func(watcher func(chan<- *types.ClipboardContent, <-chan struct{})) {
+		watchers.Add(1)
+		go func() {
+			defer watchers.Done()
+			watcher(contentCh, stopCh)
+		}()
+	}
+	watch(c.watchNotifications)
+
+	switch {
+	case c.path == "":
+	case c.isFIFO:
+		watch(c.watchFIFO)
+		// A FIFO reader blocked in open only notices the stop once woken
+		go func() {
+			select {
+			case <-stopCh:
+				c.wakeFIFO()
+			case <-c.closed:
+			}
+		}()
+	default:
+		watch(c.watchFile)
+	}
+
+	// Monitoring can be started again once every watcher stopped
+	go func() {
+		watchers.Wait()
+		c.mu.Lock()
+		c.isRunning = false
+		c.mu.Unlock()
+	}()
+}
+
+// watchNotifications reports content set through SetContent
+func (c *HeadlessClipboard) watchNotifications(contentCh chan<- *types.ClipboardContent, stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-c.closed:
+			return
+		case <-c.notify:
+			content, err := c.Read()
+			if err != nil {
+				continue
+			}
+			c.send(content, contentCh, stopCh)
+		}
+	}
+}
+
+// watchFile polls a regular file for modifications
+func (c *HeadlessClipboard) watchFile(contentCh chan<- *types.ClipboardContent, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-c.closed:
+			return
+		case <-ticker.C:
+			info, err := os.Stat(c.path)
+			if err != nil {
+				continue
+			}
+
+			c.mu.Lock()
+			unchanged := info.ModTime().Equal(c.lastModTime) && info.Size() == c.lastSize
+			c.mu.Unlock()
+			if unchanged {
+				continue
+			}
+
+			data, err := os.ReadFile(c.path)
+			if err != nil {
+				continue
+			}
+			if content := c.update(data, info); content != nil {
+				c.send(content, contentCh, stopCh)
+			}
+		}
+	}
+}
+
+// watchFIFO treats every writer session on a FIFO as one copy
+func (c *HeadlessClipboard) watchFIFO(contentCh chan<- *types.ClipboardContent, stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-c.closed:
+			return
+		default:
+		}
+
+		// Opening blocks until a writer shows up
+		c.mu.Lock()
+		c.fifoOpen = true
+		c.mu.Unlock()
+
+		f, err := os.Open(c.path)
+
+		c.mu.Lock()
+		c.fifoOpen = false
+		c.mu.Unlock()
+
+		if err != nil {
+			select {
+			case <-stopCh:
+				return
+			case <-c.closed:
+				return
+			case <-time.After(c.pollInterval):
+				continue
+			}
+		}
+
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil || len(data) == 0 {
+			continue
+		}
+
+		if content := c.update(data, nil); content != nil {
+			c.send(content, contentCh, stopCh)
+		}
+	}
+}
+
+// update records data read from disk, returning new content if it changed
+func (c *HeadlessClipboard) update(data []byte, info os.FileInfo) *types.ClipboardContent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if info != nil {
+		c.lastModTime = info.ModTime()
+		c.lastSize = info.Size()
+	}
+
+	if len(data) == 0 || bytes.Equal(data, c.lastData) {
+		return nil
+	}
+
+	c.lastData = data
+	c.content = newHeadlessContent(data)
+	return copyContent(c.content)
+}
+
+// send delivers content unless monitoring was stopped
+func (c *HeadlessClipboard) send(content *types.ClipboardContent, contentCh chan<- *types.ClipboardContent, stopCh <-chan struct{}) {
+	select {
+	case contentCh <- content:
+	case <-stopCh:
+	case <-c.closed:
+	}
+}
+
+// Close stops monitoring and releases resources
+func (c *HeadlessClipboard) Close() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.wakeFIFO()
+	})
+}
+
+// wakeFIFO wakes a FIFO reader that is blocked in open
+func (c *HeadlessClipboard) wakeFIFO() {
+	c.mu.Lock()
+	waiting := c.fifoOpen
+	c.mu.Unlock()
+	if waiting {
+		go func() {
+			if f, err := os.OpenFile(c.path, os.O_WRONLY, 0); err == nil {
+				f.Close()
+			}
+		}()
+	}
+}
@@ -0,0 +1,60 @@
+package platform
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+// waitStopped waits until a clipboard's monitoring has stopped
+func waitStopped(t *testing.T, c *HeadlessClipboard) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		c.mu.Lock()
+		running := c.isRunning
+		c.mu.Unlock()
+		if !running {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("monitoring still running after it was stopped")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHeadlessMonitorRestarts(t *testing.T) {
+	file, err := NewFileClipboard(filepath.Join(t.TempDir(), "clipboard"), 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, c := range map[string]*HeadlessClipboard{"memory": NewMemoryClipboard(), "file": file} {
+		defer c.Close()
+
+		// Monitoring stopped through stopCh can be started again
+		for round := 0; round < 2; round++ {
+			waitStopped(t, c)
+			contentCh := make(chan *types.ClipboardContent, 1)
+			stopCh := make(chan struct{})
+			c.MonitorChanges(contentCh, stopCh)
+
+			data := fmt.Sprintf("%s round %d", name, round)
+			if err := c.SetContent(&types.ClipboardContent{Type: types.TypeText, Data: []byte(data)}); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case content := <-contentCh:
+				if string(content.Data) != data {
+					t.Errorf("%s: got %q, want %q", name, content.Data, data)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s: no change reported in round %d", name, round)
+			}
+			close(stopCh)
+		}
+	}
+}
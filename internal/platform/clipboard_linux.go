@@ -36,6 +36,8 @@ import (
 
 	cliplib "github.com/atotto/clipboard"
 	"github.com/berrythewa/clipman-daemon/internal/types"
+	"github.com/berrythewa/clipman-daemon/pkg/classify"
+	"github.com/berrythewa/clipman-daemon/pkg/richtext"
 	"bufio"
 )
 
@@ -45,6 +47,7 @@ const (
 	monitorModeXFixes  = "xfixes"  // X11 XFixes extension
 	monitorModeWayland = "wayland" // Wayland compositor
 	monitorModeMir     = "mir"     // Mir display server
+	monitorModeDataControl = "data-control" // Native Wayland data-control protocol
 )
 
 // Available clipboard tools
@@ -137,6 +140,9 @@ type LinuxClipboard struct {
 	// Custom MIME type support
 	customTypes    map[string]CustomMimeTypeHandler
 	customTypesMu  sync.RWMutex
+	
+	// Native Wayland data-control connection, nil when not in use
+	dataControl    *dataControlClient
 }
 
 // NewClipboard creates a new platform-specific clipboard implementation
@@ -305,18 +311,6 @@ func (c *LinuxClipboard) detectContentType(data []byte, formats []string) types.
 		}
 	}
 
-	// Try to detect URL
-	if isURL(string(data)) {
-		c.logger.Printf("Detected URL in clipboard content")
-		return types.TypeURL
-	}
-
-	// Check if it looks like a file path
-	if isFilePath(string(data)) {
-		c.logger.Printf("Detected file path in clipboard content")
-		return types.TypeFilePath
-	}
-
 	// Check if it's a JSON array of file paths
 	if isFileList(data) {
 		c.logger.Printf("Detected file list in clipboard content")
@@ -335,8 +329,8 @@ func (c *LinuxClipboard) detectContentType(data []byte, formats []string) types.
 		return types.TypeRTF
 	}
 
-	// If we can't determine anything else, assume it's text
-	return types.TypeText
+	// Tell URLs and file paths from text the same way the monitor does
+	return classify.Type(data)
 }
 
 // isHTML checks if content is likely HTML
@@ -363,40 +357,6 @@ func hasFileURI(data []byte) bool {
 	return bytes.Contains(data, []byte("file://"))
 }
 
-// isURL checks if a string is a URL
-func isURL(text string) bool {
-	text = strings.TrimSpace(text)
-	if text == "" {
-		return false
-	}
-
-	// Use a more permissive URL pattern than Windows
-	urlPattern := regexp.MustCompile(`^(https?|ftp)://\S+$`)
-	if urlPattern.MatchString(text) {
-		// Double check with Go's URL parser
-		_, err := url.Parse(text)
-		return err == nil
-	}
-	return false
-}
-
-// isFilePath checks if text looks like a file path
-func isFilePath(text string) bool {
-	text = strings.TrimSpace(text)
-	if text == "" {
-		return false
-	}
-
-	// Check if it starts with / (Unix path) or has a drive letter (Windows path)
-	if strings.HasPrefix(text, "/") {
-		// Check if the file exists
-		_, err := os.Stat(text)
-		return err == nil
-	}
-
-	return false
-}
-
 // isFileList checks if data is a JSON array of file paths
 func isFileList(data []byte) bool {
 	var filePaths []string
@@ -459,6 +419,28 @@ func (c *LinuxClipboard) Read() (*types.ClipboardContent, error) {
 	// Get available formats
 	formats, _ := c.getAvailableFormats()
 	
+	// Read straight from the compositor when data-control is connected
+	if c.dataControl != nil && c.dataControl.alive() {
+		content, err := c.readWithDataControl(formats)
+		if err != nil {
+			return nil, err
+		}
+		
+		// Create content hash and update cache
+		c.updateCache(content, hashContent(content.Data), formats)
+		
+		// Check if content has changed
+		if bytes.Equal(content.Data, c.lastContent) {
+			return nil, fmt.Errorf("content unchanged")
+		}
+		
+		// Update the last content
+		c.lastContent = make([]byte, len(content.Data))
+		copy(c.lastContent, content.Data)
+		
+		return content, nil
+	}
+	
 	// Try to detect custom content first
 	if content, err := c.readCustomFormat(formats); err == nil {
 		// Create content hash
@@ -624,6 +606,11 @@ func (c *LinuxClipboard) getAvailableFormats() ([]string, error) {
 		return formats, nil
 	}
 	
+	// The data-control connection already knows the offered types
+	if c.dataControl != nil && c.dataControl.alive() {
+		return c.dataControl.MimeTypes(), nil
+	}
+	
 	// Try X11 environment first with xclip
 	if isX11Session() && hasCommand("xclip") {
 		cmd := exec.Command("xclip", "-selection", "clipboard", "-t", "TARGETS", "-o")
@@ -1051,6 +1038,13 @@ func (c *LinuxClipboard) detectMonitoringMode() string {
 	// Log the environment detection
 	c.logger.Printf("Detecting best clipboard monitoring method for this environment")
 
+	// Prefer the native data-control protocol on Wayland, it needs no
+	// external tools and reports every selection change
+	if isWaylandSession() && c.connectDataControl() {
+		c.logger.Printf("Wayland compositor supports %s, using native data-control monitoring", c.dataControl.protocol)
+		return monitorModeDataControl
+	}
+
 	// Check if both X11 and Wayland are available (common in many distributions)
 	if isX11Session() && isWaylandSession() {
 		c.logger.Printf("Both X11 and Wayland detected, preferring X11 monitoring")
@@ -1149,6 +1143,8 @@ func (c *LinuxClipboard) MonitorChanges(contentCh chan<- *types.ClipboardContent
 		go c.monitorWithWayland(contentCh, stopCh)
 	case monitorModeMir:
 		go c.monitorWithMir(contentCh, stopCh)
+	case monitorModeDataControl:
+		go c.monitorWithDataControl(contentCh, stopCh)
 	default:
 		go c.monitorWithAdaptivePolling(contentCh, stopCh)
 	}
@@ -1813,6 +1809,18 @@ func (c *LinuxClipboard) Write(content *types.ClipboardContent) error {
 	
 	var err error
 	
+	// Prefer the native data-control protocol, falling back to external tools
+	if c.dataControl != nil && c.dataControl.alive() {
+		if err := c.writeWithDataControl(content); err == nil {
+			c.lastContent = make([]byte, len(content.Data))
+			copy(c.lastContent, content.Data)
+			c.clearCache()
+			return nil
+		} else {
+			c.logger.Printf("Data-control write failed, falling back to external tools: %v", err)
+		}
+	}
+	
 	// Check if we have a custom handler for this content type
 	c.customTypesMu.RLock()
 	for _, handler := range c.customTypes {
@@ -1828,9 +1836,9 @@ func (c *LinuxClipboard) Write(content *types.ClipboardContent) error {
 	case types.TypeText:
 		err = c.writeTextContent(content.Data)
 	case types.TypeHTML:
-		err = c.writeHtmlContent(content.Data)
+		err = c.writeHtmlContent(content.Data, plainTextRendition(content))
 	case types.TypeRTF:
-		err = c.writeRtfContent(content.Data)
+		err = c.writeRtfContent(content.Data, plainTextRendition(content))
 	case types.TypeImage:
 		err = c.writeImageContent(content.Data)
 	case types.TypeFilePath:
@@ -2131,16 +2139,18 @@ func (c *LinuxClipboard) writeFileListContent(data []byte) error {
 	return fmt.Errorf("file list clipboard writing not supported in current environment")
 }
 
-// writeHtmlContent writes HTML data to the clipboard
-func (c *LinuxClipboard) writeHtmlContent(data []byte) error {
+// writeHtmlContent writes HTML data to the clipboard, along with its plain-text rendition
+func (c *LinuxClipboard) writeHtmlContent(data, plaintext []byte) error {
 	// Try X11 environment first
 	if isX11Session() && hasCommand("xclip") {
 		cmd := exec.Command("xclip", "-selection", "clipboard", "-t", mimeHTML)
 		cmd.Stdin = bytes.NewReader(data)
 		
 		// Also copy as plaintext
-		if err := c.writeTextContent(data); err != nil {
-			c.logger.Printf("Failed to write HTML as text: %v", err)
+		if len(plaintext) > 0 {
+			if err := c.writeTextContent(plaintext); err != nil {
+				c.logger.Printf("Failed to write HTML as text: %v", err)
+			}
 		}
 		
 		c.logger.Printf("Writing HTML to clipboard: %d bytes", len(data))
@@ -2153,8 +2163,10 @@ func (c *LinuxClipboard) writeHtmlContent(data []byte) error {
 		cmd.Stdin = bytes.NewReader(data)
 		
 		// Also copy as plaintext
-		if err := c.writeTextContent(data); err != nil {
-			c.logger.Printf("Failed to write HTML as text: %v", err)
+		if len(plaintext) > 0 {
+			if err := c.writeTextContent(plaintext); err != nil {
+				c.logger.Printf("Failed to write HTML as text: %v", err)
+			}
 		}
 		
 		c.logger.Printf("Writing HTML to Wayland clipboard: %d bytes", len(data))
@@ -2164,16 +2176,15 @@ func (c *LinuxClipboard) writeHtmlContent(data []byte) error {
 	return fmt.Errorf("HTML clipboard writing not available")
 }
 
-// writeRtfContent writes RTF data to the clipboard
-func (c *LinuxClipboard) writeRtfContent(data []byte) error {
+// writeRtfContent writes RTF data to the clipboard, along with its plain-text rendition
+func (c *LinuxClipboard) writeRtfContent(data, plaintext []byte) error {
 	// Try X11 environment first
 	if isX11Session() && hasCommand("xclip") {
 		cmd := exec.Command("xclip", "-selection", "clipboard", "-t", mimeRTF)
 		cmd.Stdin = bytes.NewReader(data)
 		
-		// Also try to extract and copy plaintext for compatibility
-		plaintext := extractPlainTextFromRTF(data)
-		if plaintext != nil && len(plaintext) > 0 {
+		// Also copy plaintext for compatibility
+		if len(plaintext) > 0 {
 			if err := c.writeTextContent(plaintext); err != nil {
 				c.logger.Printf("Failed to write RTF as text: %v", err)
 			}
@@ -2188,9 +2199,8 @@ func (c *LinuxClipboard) writeRtfContent(data []byte) error {
 		cmd := exec.Command("wl-copy", "--type", mimeRTF)
 		cmd.Stdin = bytes.NewReader(data)
 		
-		// Also try to extract and copy plaintext for compatibility
-		plaintext := extractPlainTextFromRTF(data)
-		if plaintext != nil && len(plaintext) > 0 {
+		// Also copy plaintext for compatibility
+		if len(plaintext) > 0 {
 			if err := c.writeTextContent(plaintext); err != nil {
 				c.logger.Printf("Failed to write RTF as text: %v", err)
 			}
@@ -2203,60 +2213,26 @@ func (c *LinuxClipboard) writeRtfContent(data []byte) error {
 	return fmt.Errorf("RTF clipboard writing not available")
 }
 
-// extractPlainTextFromRTF extracts plain text from RTF content
-// This is a simplified implementation and may not work for all RTF content
-func extractPlainTextFromRTF(rtfData []byte) []byte {
-	if len(rtfData) == 0 {
-		return nil
+// plainTextRendition returns the plain-text rendition of HTML or RTF content,
+// so applications pasting as plain text get the text rather than the markup
+func plainTextRendition(content *types.ClipboardContent) []byte {
+	if content.PlainText != "" {
+		return []byte(content.PlainText)
 	}
 	
-	// Convert to string for easier handling
-	rtfStr := string(rtfData)
-	
-	// Create a scanner to process the RTF content line by line
-	scanner := bufio.NewScanner(strings.NewReader(rtfStr))
-	var plaintext strings.Builder
-	inControlSequence := false
-	
-	for scanner.Scan() {
-		line := scanner.Text()
-		
-		// Process the line character by character
-		for i := 0; i < len(line); i++ {
-			char := line[i]
-			
-			switch {
-			case char == '{' || char == '}':
-				// Ignore group markers
-				continue
-			case char == '\\':
-				// Start of control word, skip until space or non-alphabetic character
-				inControlSequence = true
-				j := i + 1
-				for j < len(line) && ((line[j] >= 'a' && line[j] <= 'z') || (line[j] >= '0' && line[j] <= '9') || line[j] == '-') {
-					j++
-				}
-				
-				// Skip the control word and its parameter if any
-				if j < len(line) && line[j] == ' ' {
-					j++ // Skip the space after control word
-				}
-				i = j - 1
-				inControlSequence = false
-			case inControlSequence:
-				// Inside a control sequence, skip
-				continue
-			default:
-				// Regular character, add to plaintext
-				plaintext.WriteByte(char)
-			}
-		}
-		
-		// Add a newline after each line
-		plaintext.WriteByte('\n')
+	// Content captured before renditions existed is converted on the fly
+	var renditions richtext.Renditions
+	var err error
+	switch content.Type {
+	case types.TypeHTML:
+		renditions, err = richtext.FromHTML(content.Data)
+	case types.TypeRTF:
+		renditions, err = richtext.FromRTF(content.Data)
 	}
-	
-	return []byte(plaintext.String())
+	if err != nil || renditions.PlainText == "" {
+		return nil
+	}
+	return []byte(renditions.PlainText)
 }
 
 // Close cleans up resources used by the clipboard implementation
@@ -2277,6 +2253,12 @@ func (c *LinuxClipboard) Close() {
 		c.mirProc = nil
 	}
 	
+	// Disconnect from the compositor
+	if c.dataControl != nil {
+		c.dataControl.Close()
+		c.dataControl = nil
+	}
+	
 	// Clear cache
 	c.clearCache()
 	
@@ -2596,6 +2578,7 @@ This clipboard implementation for Linux has been significantly enhanced with the
 
 4. Monitoring Methods
    - Efficient event-based monitoring with XFixes (X11)
+   - Native Wayland monitoring via the ext/wlr data-control protocol
    - Wayland-specific monitoring with wl-paste
    - Adaptive polling with sleep optimization for lower CPU usage
 
@@ -1,6 +1,9 @@
 package platform
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/berrythewa/clipman-daemon/internal/types"
 )
 
@@ -37,6 +40,21 @@ func GetPlatformClipboard() Clipboard {
 	return NewClipboard()
 }
 
+// GetClipboardForBackend returns the clipboard for a configured backend name.
+// An empty name selects the native platform clipboard.
+func GetClipboardForBackend(backend string, filePath string, pollInterval time.Duration) (Clipboard, error) {
+	switch backend {
+	case "", BackendNative:
+		return GetPlatformClipboard(), nil
+	case BackendMemory:
+		return NewMemoryClipboard(), nil
+	case BackendFile:
+		return NewFileClipboard(filePath, pollInterval)
+	default:
+		return nil, fmt.Errorf("unknown clipboard backend: %s", backend)
+	}
+}
+
 // GetPlatformDaemonizer returns the appropriate daemonizer for the current platform
 // The actual implementation is selected at compile time through build tags
 func GetPlatformDaemonizer() Daemonizer {
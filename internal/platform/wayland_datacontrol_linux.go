@@ -0,0 +1,781 @@
+//go:build linux
+// +build linux
+
+package platform
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+// Data-control protocol globals, in order of preference
+const (
+	extDataControlManager = "ext_data_control_manager_v1"
+	wlrDataControlManager = "zwlr_data_control_manager_v1"
+	wlSeatInterface       = "wl_seat"
+)
+
+// Core protocol opcodes
+const (
+	wlDisplayID = 1
+
+	wlDisplaySync        = 0
+	wlDisplayGetRegistry = 1
+
+	wlDisplayEventError    = 0
+	wlDisplayEventDeleteID = 1
+
+	wlRegistryBind = 0
+
+	wlRegistryEventGlobal       = 0
+	wlRegistryEventGlobalRemove = 1
+
+	wlCallbackEventDone = 0
+)
+
+// Data-control opcodes. The ext and wlr variants share the same layout.
+const (
+	dataControlManagerCreateSource = 0
+	dataControlManagerGetDevice    = 1
+
+	dataControlDeviceSetSelection = 0
+
+	dataControlDeviceEventDataOffer        = 0
+	dataControlDeviceEventSelection        = 1
+	dataControlDeviceEventFinished         = 2
+	dataControlDeviceEventPrimarySelection = 3
+
+	dataControlSourceOffer   = 0
+	dataControlSourceDestroy = 1
+
+	dataControlSourceEventSend      = 0
+	dataControlSourceEventCancelled = 1
+
+	dataControlOfferReceive = 0
+	dataControlOfferDestroy = 1
+
+	dataControlOfferEventOffer = 0
+)
+
+// dataControlReadTimeout bounds how long we wait for the selection owner to send data
+const dataControlReadTimeout = 5 * time.Second
+
+// Text MIME types offered and accepted for plain text, most specific first
+var dataControlTextMimes = []string{mimeUTF8Text, "UTF8_STRING", mimeText, "STRING", "TEXT"}
+
+// wlObjectKind identifies what a tracked object ID refers to
+type wlObjectKind int
+
+const (
+	wlObjectRegistry wlObjectKind = iota
+	wlObjectCallback
+	wlObjectSeat
+	wlObjectManager
+	wlObjectDevice
+	wlObjectSource
+	wlObjectOffer
+)
+
+// wlGlobal is a global advertised by the compositor registry
+type wlGlobal struct {
+	name    uint32
+	iface   string
+	version uint32
+}
+
+// dataControlClient talks the wlr/ext data-control protocol directly,
+// without shelling out to wl-paste or wl-copy
+type dataControlClient struct {
+	conn     *wlConn
+	logger   ClipboardLogger
+	protocol string
+
+	registryID uint32
+	seatID     uint32
+	managerID  uint32
+	deviceID   uint32
+
+	mu        sync.Mutex
+	objects   map[uint32]wlObjectKind
+	globals   map[uint32]wlGlobal
+	callbacks map[uint32]chan struct{}
+	offers    map[uint32][]string
+	sources   map[uint32]map[string][]byte
+	selection uint32
+
+	// changes is signalled whenever the selection changes
+	changes chan struct{}
+	done    chan struct{}
+	err     error
+}
+
+// newDataControlClient connects to the compositor and binds a data-control device
+// for the first seat. It fails if the compositor does not expose the protocol.
+func newDataControlClient(logger ClipboardLogger) (*dataControlClient, error) {
+	conn, err := dialWayland()
+	if err != nil {
+		return nil, err
+	}
+	return newDataControlClientOn(conn, logger)
+}
+
+// newDataControlClientOn sets up a data-control client on an open connection
+func newDataControlClientOn(conn *wlConn, logger ClipboardLogger) (*dataControlClient, error) {
+	d := &dataControlClient{
+		conn:      conn,
+		logger:    logger,
+		objects:   make(map[uint32]wlObjectKind),
+		globals:   make(map[uint32]wlGlobal),
+		callbacks: make(map[uint32]chan struct{}),
+		offers:    make(map[uint32][]string),
+		sources:   make(map[uint32]map[string][]byte),
+		changes:   make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+
+	go d.readLoop()
+
+	if err := d.setup(); err != nil {
+		d.Close()
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// setup enumerates globals and creates the data-control device
+func (d *dataControlClient) setup() error {
+	d.registryID = d.track(wlObjectRegistry)
+	if err := d.conn.send(wlDisplayID, wlDisplayGetRegistry, new(wlEncoder).putUint(d.registryID), -1); err != nil {
+		return err
+	}
+	if err := d.roundtrip(); err != nil {
+		return err
+	}
+
+	var manager, seat *wlGlobal
+	d.mu.Lock()
+	for _, iface := range []string{extDataControlManager, wlrDataControlManager} {
+		for _, g := range d.globals {
+			if g.iface == iface {
+				g := g
+				manager = &g
+				break
+			}
+		}
+		if manager != nil {
+			break
+		}
+	}
+	for _, g := range d.globals {
+		if g.iface == wlSeatInterface && (seat == nil || g.name < seat.name) {
+			g := g
+			seat = &g
+		}
+	}
+	d.mu.Unlock()
+
+	if manager == nil {
+		return fmt.Errorf("compositor does not support the data-control protocol")
+	}
+	if seat == nil {
+		return fmt.Errorf("compositor did not advertise a seat")
+	}
+	d.protocol = manager.iface
+
+	d.seatID = d.track(wlObjectSeat)
+	if err := d.bind(*seat, d.seatID); err != nil {
+		return err
+	}
+	d.managerID = d.track(wlObjectManager)
+	if err := d.bind(*manager, d.managerID); err != nil {
+		return err
+	}
+
+	d.deviceID = d.track(wlObjectDevice)
+	args := new(wlEncoder).putUint(d.deviceID).putUint(d.seatID)
+	if err := d.conn.send(d.managerID, dataControlManagerGetDevice, args, -1); err != nil {
+		return err
+	}
+
+	// Wait for the initial selection event
+	return d.roundtrip()
+}
+
+// bind binds a registry global at version 1, which is all we need
+func (d *dataControlClient) bind(g wlGlobal, id uint32) error {
+	args := new(wlEncoder).putUint(g.name).putString(g.iface).putUint(1).putUint(id)
+	return d.conn.send(d.registryID, wlRegistryBind, args, -1)
+}
+
+// track allocates a new object ID of the given kind
+func (d *dataControlClient) track(kind wlObjectKind) uint32 {
+	id := d.conn.newID()
+	d.mu.Lock()
+	d.objects[id] = kind
+	d.mu.Unlock()
+	return id
+}
+
+// roundtrip waits until the compositor has processed all previous requests
+func (d *dataControlClient) roundtrip() error {
+	id := d.track(wlObjectCallback)
+	doneCh := make(chan struct{})
+	d.mu.Lock()
+	d.callbacks[id] = doneCh
+	d.mu.Unlock()
+
+	if err := d.conn.send(wlDisplayID, wlDisplaySync, new(wlEncoder).putUint(id), -1); err != nil {
+		return err
+	}
+
+	select {
+	case <-doneCh:
+		return nil
+	case <-d.done:
+		return d.closeErr()
+	case <-time.After(dataControlReadTimeout):
+		return fmt.Errorf("timed out waiting for wayland compositor")
+	}
+}
+
+// readLoop dispatches events until the connection fails or is closed
+func (d *dataControlClient) readLoop() {
+	var err error
+	for {
+		var msg *wlMessage
+		msg, err = d.conn.readMessage()
+		if err != nil {
+			break
+		}
+		if err = d.dispatch(msg); err != nil {
+			break
+		}
+	}
+
+	d.mu.Lock()
+	if d.err == nil {
+		d.err = err
+	}
+	d.mu.Unlock()
+	close(d.done)
+}
+
+// dispatch handles a single event
+func (d *dataControlClient) dispatch(msg *wlMessage) error {
+	args := &wlDecoder{data: msg.args}
+
+	if msg.sender == wlDisplayID {
+		switch msg.opcode {
+		case wlDisplayEventError:
+			object, code, message := args.uint(), args.uint(), args.string()
+			return fmt.Errorf("wayland protocol error on object %d (code %d): %s", object, code, message)
+		case wlDisplayEventDeleteID:
+			id := args.uint()
+			d.mu.Lock()
+			delete(d.objects, id)
+			d.mu.Unlock()
+		}
+		return args.err
+	}
+
+	d.mu.Lock()
+	kind, ok := d.objects[msg.sender]
+	d.mu.Unlock()
+	if !ok {
+		// Events for objects we already destroyed
+		return nil
+	}
+
+	switch kind {
+	case wlObjectRegistry:
+		d.handleRegistryEvent(msg.opcode, args)
+	case wlObjectCallback:
+		if msg.opcode == wlCallbackEventDone {
+			d.mu.Lock()
+			if ch, ok := d.callbacks[msg.sender]; ok {
+				close(ch)
+				delete(d.callbacks, msg.sender)
+			}
+			d.mu.Unlock()
+		}
+	case wlObjectDevice:
+		return d.handleDeviceEvent(msg.opcode, args)
+	case wlObjectOffer:
+		if msg.opcode == dataControlOfferEventOffer {
+			mime := args.string()
+			d.mu.Lock()
+			d.offers[msg.sender] = append(d.offers[msg.sender], mime)
+			d.mu.Unlock()
+		}
+	case wlObjectSource:
+		return d.handleSourceEvent(msg.sender, msg.opcode, args)
+	}
+
+	return args.err
+}
+
+// handleRegistryEvent records advertised globals
+func (d *dataControlClient) handleRegistryEvent(opcode uint16, args *wlDecoder) {
+	switch opcode {
+	case wlRegistryEventGlobal:
+		g := wlGlobal{name: args.uint(), iface: args.string(), version: args.uint()}
+		if args.err == nil {
+			d.mu.Lock()
+			d.globals[g.name] = g
+			d.mu.Unlock()
+		}
+	case wlRegistryEventGlobalRemove:
+		name := args.uint()
+		d.mu.Lock()
+		delete(d.globals, name)
+		d.mu.Unlock()
+	}
+}
+
+// handleDeviceEvent tracks new offers and selection changes
+func (d *dataControlClient) handleDeviceEvent(opcode uint16, args *wlDecoder) error {
+	switch opcode {
+	case dataControlDeviceEventDataOffer:
+		id := args.uint()
+		d.mu.Lock()
+		d.objects[id] = wlObjectOffer
+		d.offers[id] = nil
+		d.mu.Unlock()
+
+	case dataControlDeviceEventSelection:
+		id := args.uint()
+		d.mu.Lock()
+		previous := d.selection
+		d.selection = id
+		d.mu.Unlock()
+
+		if previous != 0 && previous != id {
+			d.destroyOffer(previous)
+		}
+
+		select {
+		case d.changes <- struct{}{}:
+		default:
+		}
+
+	case dataControlDeviceEventPrimarySelection:
+		// We only track the regular clipboard
+		id := args.uint()
+		d.mu.Lock()
+		isSelection := id == d.selection
+		d.mu.Unlock()
+		if id != 0 && !isSelection {
+			d.destroyOffer(id)
+		}
+
+	case dataControlDeviceEventFinished:
+		return fmt.Errorf("data-control device was invalidated by the compositor")
+	}
+
+	return args.err
+}
+
+// handleSourceEvent serves data for selections we own
+func (d *dataControlClient) handleSourceEvent(id uint32, opcode uint16, args *wlDecoder) error {
+	switch opcode {
+	case dataControlSourceEventSend:
+		mime := args.string()
+		if args.err != nil {
+			return args.err
+		}
+		fd, err := d.conn.takeFD()
+		if err != nil {
+			return err
+		}
+
+		d.mu.Lock()
+		data, ok := d.sources[id][mime]
+		d.mu.Unlock()
+
+		go func() {
+			f := os.NewFile(uintptr(fd), "wayland-source")
+			defer f.Close()
+			if ok {
+				if _, err := f.Write(data); err != nil {
+					d.logger.Printf("Failed to send clipboard data for %s: %v", mime, err)
+				}
+			}
+		}()
+
+	case dataControlSourceEventCancelled:
+		d.mu.Lock()
+		delete(d.sources, id)
+		d.mu.Unlock()
+		d.conn.send(id, dataControlSourceDestroy, nil, -1)
+	}
+
+	return nil
+}
+
+// destroyOffer releases an offer we no longer need
+func (d *dataControlClient) destroyOffer(id uint32) {
+	d.mu.Lock()
+	delete(d.offers, id)
+	d.mu.Unlock()
+	d.conn.send(id, dataControlOfferDestroy, nil, -1)
+}
+
+// Changes returns a channel that is signalled on every selection change
+func (d *dataControlClient) Changes() <-chan struct{} {
+	return d.changes
+}
+
+// Done returns a channel that is closed when the connection is lost
+func (d *dataControlClient) Done() <-chan struct{} {
+	return d.done
+}
+
+// alive reports whether the connection is still usable
+func (d *dataControlClient) alive() bool {
+	select {
+	case <-d.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// closeErr returns the reason the connection stopped
+func (d *dataControlClient) closeErr() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return d.err
+	}
+	return fmt.Errorf("wayland connection closed")
+}
+
+// MimeTypes returns the MIME types offered by the current selection
+func (d *dataControlClient) MimeTypes() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.selection == 0 {
+		return nil
+	}
+	mimes := make([]string, len(d.offers[d.selection]))
+	copy(mimes, d.offers[d.selection])
+	return mimes
+}
+
+// Receive reads the current selection in the given MIME type
+func (d *dataControlClient) Receive(mime string) ([]byte, error) {
+	d.mu.Lock()
+	offer := d.selection
+	d.mu.Unlock()
+	if offer == 0 {
+		return nil, fmt.Errorf("clipboard is empty")
+	}
+
+	var fds [2]int
+	if err := syscall.Pipe2(fds[:], syscall.O_CLOEXEC); err != nil {
+		return nil, fmt.Errorf("failed to create pipe: %w", err)
+	}
+	// Only our end is non-blocking so the read can use a deadline
+	if err := syscall.SetNonblock(fds[0], true); err != nil {
+		syscall.Close(fds[0])
+		syscall.Close(fds[1])
+		return nil, fmt.Errorf("failed to configure pipe: %w", err)
+	}
+	r := os.NewFile(uintptr(fds[0]), "wayland-offer")
+	defer r.Close()
+
+	err := d.conn.send(offer, dataControlOfferReceive, new(wlEncoder).putString(mime), fds[1])
+	// The compositor has its own copy of the write end now
+	syscall.Close(fds[1])
+	if err != nil {
+		return nil, err
+	}
+
+	r.SetReadDeadline(time.Now().Add(dataControlReadTimeout))
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from clipboard: %w", mime, err)
+	}
+	return data, nil
+}
+
+// SetSelection takes ownership of the clipboard, offering data in each
+// MIME type of order
+func (d *dataControlClient) SetSelection(order []string, data map[string][]byte) error {
+	if !d.alive() {
+		return d.closeErr()
+	}
+
+	id := d.track(wlObjectSource)
+	d.mu.Lock()
+	d.sources[id] = data
+	d.mu.Unlock()
+
+	if err := d.conn.send(d.managerID, dataControlManagerCreateSource, new(wlEncoder).putUint(id), -1); err != nil {
+		return err
+	}
+	for _, mime := range order {
+		if err := d.conn.send(id, dataControlSourceOffer, new(wlEncoder).putString(mime), -1); err != nil {
+			return err
+		}
+	}
+	return d.conn.send(d.deviceID, dataControlDeviceSetSelection, new(wlEncoder).putUint(id), -1)
+}
+
+// Close disconnects from the compositor
+func (d *dataControlClient) Close() {
+	d.conn.Close()
+	<-d.done
+}
+
+// connectDataControl tries to set up the native data-control backend
+func (c *LinuxClipboard) connectDataControl() bool {
+	if c.dataControl != nil && c.dataControl.alive() {
+		return true
+	}
+
+	client, err := newDataControlClient(c.logger)
+	if err != nil {
+		c.logger.Printf("Native Wayland data-control unavailable: %v", err)
+		return false
+	}
+
+	c.dataControl = client
+	return true
+}
+
+// monitorWithDataControl waits for selection events from the compositor
+func (c *LinuxClipboard) monitorWithDataControl(contentCh chan<- *types.ClipboardContent, stopCh <-chan struct{}) {
+	client := c.dataControl
+	c.logger.Printf("Starting native Wayland clipboard monitoring via %s", client.protocol)
+
+	for {
+		select {
+		case <-stopCh:
+			return
+
+		case <-client.Done():
+			c.logger.Printf("Wayland data-control connection lost: %v", client.closeErr())
+			// Both fallbacks start their own monitoring goroutine
+			if hasCommand("wl-paste") {
+				c.monitorMode = monitorModeWayland
+				c.monitorWithWayland(contentCh, stopCh)
+			} else {
+				c.monitorMode = monitorModePolling
+				c.monitorWithAdaptivePolling(contentCh, stopCh)
+			}
+			return
+
+		case <-client.Changes():
+			// The cache may still hold the previous selection
+			c.clearCache()
+			content, err := c.Read()
+			if err != nil {
+				if err.Error() != "content unchanged" {
+					c.logger.Printf("Error reading clipboard after selection change: %v", err)
+				}
+				continue
+			}
+
+			select {
+			case contentCh <- content:
+				c.logger.Printf("Data-control notification: New clipboard content detected and sent (size: %d bytes)", len(content.Data))
+			case <-stopCh:
+				return
+			}
+		}
+	}
+}
+
+// readWithDataControl reads the current selection, picking the richest offered format
+func (c *LinuxClipboard) readWithDataControl(formats []string) (*types.ClipboardContent, error) {
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("clipboard is empty")
+	}
+
+	// Custom formats take precedence, same as the external tool path
+	c.customTypesMu.RLock()
+	for _, handler := range c.customTypes {
+		if contains(formats, handler.MimeType) {
+			c.customTypesMu.RUnlock()
+			data, err := c.dataControl.Receive(handler.MimeType)
+			if err != nil {
+				return nil, err
+			}
+			return &types.ClipboardContent{Type: handler.TypeID, Data: data, Created: time.Now()}, nil
+		}
+	}
+	c.customTypesMu.RUnlock()
+
+	if contains(formats, mimeHTML) {
+		data, err := c.dataControl.Receive(mimeHTML)
+		if err == nil {
+			return &types.ClipboardContent{Type: types.TypeHTML, Data: data, Created: time.Now()}, nil
+		}
+		c.logger.Printf("Error reading HTML format: %v", err)
+	}
+
+	if contains(formats, mimeRTF) {
+		data, err := c.dataControl.Receive(mimeRTF)
+		if err == nil {
+			return &types.ClipboardContent{Type: types.TypeRTF, Data: data, Created: time.Now()}, nil
+		}
+		c.logger.Printf("Error reading RTF format: %v", err)
+	}
+
+	if imageMime := pickImageMime(formats); imageMime != "" {
+		data, err := c.dataControl.Receive(imageMime)
+		if err == nil {
+			return &types.ClipboardContent{Type: types.TypeImage, Data: data, Created: time.Now()}, nil
+		}
+		c.logger.Printf("Error reading image format: %v", err)
+	}
+
+	if contains(formats, mimeFilenames) || contains(formats, mimeURI) {
+		format := mimeFilenames
+		if !contains(formats, mimeFilenames) {
+			format = mimeURI
+		}
+		data, err := c.dataControl.Receive(format)
+		if err == nil {
+			if content, err := c.parseURIData(data); err == nil {
+				return content, nil
+			}
+		} else {
+			c.logger.Printf("Error reading file format: %v", err)
+		}
+	}
+
+	for _, mime := range dataControlTextMimes {
+		if !contains(formats, mime) {
+			continue
+		}
+		data, err := c.dataControl.Receive(mime)
+		if err != nil {
+			return nil, err
+		}
+		return &types.ClipboardContent{
+			Type:    c.detectContentType(data, formats),
+			Data:    data,
+			Created: time.Now(),
+		}, nil
+	}
+
+	// Anything else textual is still worth keeping as text
+	for _, mime := range formats {
+		if strings.HasPrefix(mime, "text/") {
+			data, err := c.dataControl.Receive(mime)
+			if err != nil {
+				return nil, err
+			}
+			return &types.ClipboardContent{
+				Type:    c.detectContentType(data, formats),
+				Data:    data,
+				Created: time.Now(),
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no supported format offered: %s", strings.Join(formats, ", "))
+}
+
+// pickImageMime returns the preferred image MIME type from the offer
+func pickImageMime(formats []string) string {
+	for _, mime := range []string{mimeImage, mimeJPEG, mimeGIF, mimeBMP} {
+		if contains(formats, mime) {
+			return mime
+		}
+	}
+	for _, mime := range formats {
+		if strings.HasPrefix(mime, "image/") {
+			return mime
+		}
+	}
+	return ""
+}
+
+// writeWithDataControl takes the selection and offers every MIME type
+// that makes sense for the content type
+func (c *LinuxClipboard) writeWithDataControl(content *types.ClipboardContent) error {
+	data := make(map[string][]byte)
+	var order []string
+	offer := func(mime string, payload []byte) {
+		if _, exists := data[mime]; !exists {
+			order = append(order, mime)
+		}
+		data[mime] = payload
+	}
+	offerText := func(payload []byte) {
+		for _, mime := range dataControlTextMimes {
+			offer(mime, payload)
+		}
+	}
+
+	// Custom handlers win, same as the external tool path
+	c.customTypesMu.RLock()
+	for _, handler := range c.customTypes {
+		if handler.TypeID == content.Type {
+			offer(handler.MimeType, content.Data)
+		}
+	}
+	c.customTypesMu.RUnlock()
+
+	if len(order) == 0 {
+		switch content.Type {
+		case types.TypeText, types.TypeString, types.TypeURL:
+			offerText(content.Data)
+		case types.TypeHTML:
+			offer(mimeHTML, content.Data)
+			if plaintext := plainTextRendition(content); len(plaintext) > 0 {
+				offerText(plaintext)
+			}
+		case types.TypeRTF:
+			offer(mimeRTF, content.Data)
+			if plaintext := plainTextRendition(content); len(plaintext) > 0 {
+				offerText(plaintext)
+			}
+		case types.TypeImage:
+			imageData := content.Data
+			if format, err := detectImageFormat(imageData); err == nil && format != "png" {
+				if converted, err := convertImageToPNG(imageData, format); err == nil {
+					imageData = converted
+				} else {
+					c.logger.Printf("Failed to convert image to PNG: %v, using original format", err)
+				}
+			}
+			offer(mimeImage, imageData)
+		case types.TypeFilePath:
+			path := string(content.Data)
+			offer(mimeFilenames, []byte("copy\n"+path))
+			offer(mimeURI, []byte("file://"+path))
+			offerText(content.Data)
+		case types.TypeFile:
+			var paths []string
+			if err := json.Unmarshal(content.Data, &paths); err != nil {
+				return fmt.Errorf("invalid file list data: %v", err)
+			}
+			var uris []string
+			for _, path := range paths {
+				uris = append(uris, "file://"+path)
+			}
+			offer(mimeFilenames, []byte("copy\n"+strings.Join(paths, "\n")))
+			offer(mimeURI, []byte(strings.Join(uris, "\n")))
+			offerText([]byte(strings.Join(paths, "\n")))
+		default:
+			return fmt.Errorf("unsupported content type: %s", content.Type)
+		}
+	}
+
+	c.logger.Printf("Writing %s to clipboard via data-control: %d bytes, %d formats",
+		content.Type, len(content.Data), len(order))
+	return c.dataControl.SetSelection(order, data)
+}
@@ -0,0 +1,245 @@
+//go:build linux
+// +build linux
+
+package platform
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// Wayland wire protocol limits
+const (
+	wlHeaderSize     = 8
+	wlMaxMessageSize = 4096
+	wlMaxFDs         = 28
+)
+
+// wlConn is a minimal Wayland wire protocol connection.
+// It only knows how to frame messages and pass file descriptors;
+// object semantics live in the protocol clients built on top of it.
+type wlConn struct {
+	conn    *net.UnixConn
+	writeMu sync.Mutex
+	idMu    sync.Mutex
+	nextID  uint32
+
+	// Incoming data not yet consumed (only touched by the reader)
+	buf []byte
+	fds []int
+}
+
+// wlMessage is a decoded Wayland message header with its raw arguments
+type wlMessage struct {
+	sender uint32
+	opcode uint16
+	args   []byte
+}
+
+// dialWayland connects to the compositor socket named by WAYLAND_DISPLAY
+func dialWayland() (*wlConn, error) {
+	name := os.Getenv("WAYLAND_DISPLAY")
+	if name == "" {
+		name = "wayland-0"
+	}
+
+	path := name
+	if !filepath.IsAbs(name) {
+		runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+		if runtimeDir == "" {
+			return nil, fmt.Errorf("XDG_RUNTIME_DIR is not set")
+		}
+		path = filepath.Join(runtimeDir, name)
+	}
+
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to wayland socket %s: %w", path, err)
+	}
+
+	// Client object IDs start at 2, 1 is always wl_display
+	return &wlConn{conn: conn, nextID: 2}, nil
+}
+
+// newID allocates a new client-side object ID
+func (c *wlConn) newID() uint32 {
+	c.idMu.Lock()
+	defer c.idMu.Unlock()
+	id := c.nextID
+	c.nextID++
+	return id
+}
+
+// send writes a request for the given object, passing fd along if it is >= 0
+func (c *wlConn) send(object uint32, opcode uint16, args *wlEncoder, fd int) error {
+	var payload []byte
+	if args != nil {
+		payload = args.data
+	}
+
+	size := wlHeaderSize + len(payload)
+	if size > wlMaxMessageSize {
+		return fmt.Errorf("wayland message too large: %d bytes", size)
+	}
+
+	msg := make([]byte, size)
+	binary.NativeEndian.PutUint32(msg[0:4], object)
+	binary.NativeEndian.PutUint32(msg[4:8], uint32(size)<<16|uint32(opcode))
+	copy(msg[wlHeaderSize:], payload)
+
+	var oob []byte
+	if fd >= 0 {
+		oob = syscall.UnixRights(fd)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if _, _, err := c.conn.WriteMsgUnix(msg, oob, nil); err != nil {
+		return fmt.Errorf("failed to write wayland message: %w", err)
+	}
+	return nil
+}
+
+// readMessage blocks until a complete message has been received
+func (c *wlConn) readMessage() (*wlMessage, error) {
+	for {
+		if len(c.buf) >= wlHeaderSize {
+			word := binary.NativeEndian.Uint32(c.buf[4:8])
+			size := int(word >> 16)
+			if size < wlHeaderSize {
+				return nil, fmt.Errorf("invalid wayland message size %d", size)
+			}
+
+			if len(c.buf) >= size {
+				msg := &wlMessage{
+					sender: binary.NativeEndian.Uint32(c.buf[0:4]),
+					opcode: uint16(word & 0xffff),
+					args:   append([]byte(nil), c.buf[wlHeaderSize:size]...),
+				}
+				c.buf = c.buf[size:]
+				return msg, nil
+			}
+		}
+
+		data := make([]byte, wlMaxMessageSize)
+		oob := make([]byte, syscall.CmsgSpace(wlMaxFDs*4))
+		n, oobn, _, _, err := c.conn.ReadMsgUnix(data, oob)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 && oobn == 0 {
+			return nil, io.EOF
+		}
+
+		if oobn > 0 {
+			if err := c.collectFDs(oob[:oobn]); err != nil {
+				return nil, err
+			}
+		}
+		c.buf = append(c.buf, data[:n]...)
+	}
+}
+
+// collectFDs queues file descriptors received as ancillary data
+func (c *wlConn) collectFDs(oob []byte) error {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return fmt.Errorf("failed to parse control message: %w", err)
+	}
+
+	for _, msg := range msgs {
+		fds, err := syscall.ParseUnixRights(&msg)
+		if err != nil {
+			continue
+		}
+		c.fds = append(c.fds, fds...)
+	}
+	return nil
+}
+
+// takeFD pops the next received file descriptor
+func (c *wlConn) takeFD() (int, error) {
+	if len(c.fds) == 0 {
+		return -1, fmt.Errorf("expected a file descriptor but none was received")
+	}
+	fd := c.fds[0]
+	c.fds = c.fds[1:]
+	return fd, nil
+}
+
+// Close closes the connection and any file descriptors still queued
+func (c *wlConn) Close() error {
+	for _, fd := range c.fds {
+		syscall.Close(fd)
+	}
+	c.fds = nil
+	return c.conn.Close()
+}
+
+// wlEncoder builds the argument payload of a request
+type wlEncoder struct {
+	data []byte
+}
+
+// putUint appends a uint, int, object or new_id argument
+func (e *wlEncoder) putUint(v uint32) *wlEncoder {
+	e.data = binary.NativeEndian.AppendUint32(e.data, v)
+	return e
+}
+
+// putString appends a NUL-terminated, 32-bit aligned string argument
+func (e *wlEncoder) putString(s string) *wlEncoder {
+	e.putUint(uint32(len(s) + 1))
+	e.data = append(e.data, s...)
+	e.data = append(e.data, 0)
+	for len(e.data)%4 != 0 {
+		e.data = append(e.data, 0)
+	}
+	return e
+}
+
+// wlDecoder reads the arguments of an event
+type wlDecoder struct {
+	data []byte
+	err  error
+}
+
+// uint reads a uint, int, object or new_id argument
+func (d *wlDecoder) uint() uint32 {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.data) < 4 {
+		d.err = fmt.Errorf("truncated wayland message")
+		return 0
+	}
+	v := binary.NativeEndian.Uint32(d.data[:4])
+	d.data = d.data[4:]
+	return v
+}
+
+// string reads a string argument
+func (d *wlDecoder) string() string {
+	length := int(d.uint())
+	if d.err != nil || length == 0 {
+		return ""
+	}
+
+	padded := (length + 3) &^ 3
+	if len(d.data) < padded {
+		d.err = fmt.Errorf("truncated wayland string")
+		return ""
+	}
+
+	// Drop the trailing NUL
+	s := string(d.data[:length-1])
+	d.data = d.data[padded:]
+	return s
+}
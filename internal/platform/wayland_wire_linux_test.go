@@ -0,0 +1,232 @@
+//go:build linux
+// +build linux
+
+package platform
+
+import (
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testLogger discards clipboard log output
+type testLogger struct{}
+
+func (testLogger) Printf(format string, v ...interface{}) {}
+
+// newTestWlConns connects two wire connections over a socketpair, the
+// second one playing the compositor
+func newTestWlConns(t *testing.T) (*wlConn, *wlConn) {
+	t.Helper()
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unixConn := func(fd int) *net.UnixConn {
+		f := os.NewFile(uintptr(fd), "wayland-test")
+		defer f.Close()
+		conn, err := net.FileConn(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn.(*net.UnixConn)
+	}
+	client := &wlConn{conn: unixConn(fds[0]), nextID: 2}
+	server := &wlConn{conn: unixConn(fds[1]), nextID: 0xff000000}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server
+}
+
+func TestWlCodec(t *testing.T) {
+	client, server := newTestWlConns(t)
+
+	// Strings are NUL-terminated and padded to 32 bits
+	args := new(wlEncoder).putUint(7).putString("text/plain").putString("").putUint(0xdeadbeef)
+	if len(args.data)%4 != 0 {
+		t.Fatalf("encoded arguments are %d bytes, not 32-bit aligned", len(args.data))
+	}
+	if err := client.send(3, 9, args, -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.send(4, 1, nil, -1); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := server.readMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.sender != 3 || msg.opcode != 9 {
+		t.Errorf("message header = %d/%d, want 3/9", msg.sender, msg.opcode)
+	}
+	decoder := &wlDecoder{data: msg.args}
+	if v, s, empty, last := decoder.uint(), decoder.string(), decoder.string(), decoder.uint(); v != 7 || s != "text/plain" || empty != "" || last != 0xdeadbeef || decoder.err != nil {
+		t.Errorf("decoded %d %q %q %#x (%v)", v, s, empty, last, decoder.err)
+	}
+
+	// Messages are framed by their size, so the second follows on its own
+	msg, err = server.readMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.sender != 4 || msg.opcode != 1 || len(msg.args) != 0 {
+		t.Errorf("second message = %+v", msg)
+	}
+
+	// Truncated arguments are reported, not read past
+	truncated := &wlDecoder{data: new(wlEncoder).putString("text/plain").data[:8]}
+	if truncated.string(); truncated.err == nil {
+		t.Error("decoded a truncated string")
+	}
+	if err := client.send(1, 0, &wlEncoder{data: make([]byte, wlMaxMessageSize)}, -1); err == nil {
+		t.Error("sent a message over the size limit")
+	}
+}
+
+func TestWlPassesFileDescriptors(t *testing.T) {
+	client, server := newTestWlConns(t)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if err := client.send(5, 0, new(wlEncoder).putString("text/plain"), int(w.Fd())); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	if _, err := server.readMessage(); err != nil {
+		t.Fatal(err)
+	}
+	fd, err := server.takeFD()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := os.NewFile(uintptr(fd), "received")
+	f.Write([]byte("hello"))
+	f.Close()
+
+	data := make([]byte, 5)
+	if _, err := r.Read(data); err != nil || string(data) != "hello" {
+		t.Errorf("read %q through the passed descriptor: %v", data, err)
+	}
+	if _, err := server.takeFD(); err == nil {
+		t.Error("took a descriptor that was never sent")
+	}
+}
+
+// fakeCompositor answers a data-control client with one seat, the wlr
+// manager and a selection offering fixed data
+type fakeCompositor struct {
+	conn  *wlConn
+	mimes []string
+	data  map[string][]byte
+
+	registry, manager, device, offer uint32
+}
+
+// serve handles client requests until the connection closes
+func (f *fakeCompositor) serve() {
+	for {
+		msg, err := f.conn.readMessage()
+		if err != nil {
+			return
+		}
+		args := &wlDecoder{data: msg.args}
+
+		switch {
+		case msg.sender == wlDisplayID && msg.opcode == wlDisplayGetRegistry:
+			f.registry = args.uint()
+			f.conn.send(f.registry, wlRegistryEventGlobal, new(wlEncoder).putUint(1).putString(wlSeatInterface).putUint(7), -1)
+			f.conn.send(f.registry, wlRegistryEventGlobal, new(wlEncoder).putUint(2).putString(wlrDataControlManager).putUint(2), -1)
+
+		case msg.sender == wlDisplayID && msg.opcode == wlDisplaySync:
+			f.conn.send(args.uint(), wlCallbackEventDone, new(wlEncoder).putUint(0), -1)
+
+		case msg.sender == f.registry && msg.opcode == wlRegistryBind:
+			args.uint()
+			iface := args.string()
+			args.uint()
+			if id := args.uint(); iface == wlrDataControlManager {
+				f.manager = id
+			}
+
+		case msg.sender == f.manager && msg.opcode == dataControlManagerGetDevice:
+			f.device = args.uint()
+			f.offerSelection()
+
+		case msg.sender == f.offer && msg.opcode == dataControlOfferReceive:
+			mime := args.string()
+			fd, err := f.conn.takeFD()
+			if err != nil {
+				return
+			}
+			w := os.NewFile(uintptr(fd), "offer")
+			w.Write(f.data[mime])
+			w.Close()
+		}
+	}
+}
+
+// offerSelection offers a new selection to the client's device
+func (f *fakeCompositor) offerSelection() {
+	f.offer = f.conn.newID()
+	f.conn.send(f.device, dataControlDeviceEventDataOffer, new(wlEncoder).putUint(f.offer), -1)
+	for _, mime := range f.mimes {
+		f.conn.send(f.offer, dataControlOfferEventOffer, new(wlEncoder).putString(mime), -1)
+	}
+	f.conn.send(f.device, dataControlDeviceEventSelection, new(wlEncoder).putUint(f.offer), -1)
+}
+
+func TestDataControlClient(t *testing.T) {
+	conn, server := newTestWlConns(t)
+	compositor := &fakeCompositor{
+		conn:  server,
+		mimes: []string{mimeUTF8Text, mimeText},
+		data:  map[string][]byte{mimeUTF8Text: []byte("copied text")},
+	}
+	go compositor.serve()
+
+	client, err := newDataControlClientOn(conn, testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.protocol != wlrDataControlManager {
+		t.Errorf("bound %q, want the wlr manager", client.protocol)
+	}
+
+	// The initial selection is tracked and signalled
+	select {
+	case <-client.Changes():
+	case <-time.After(time.Second):
+		t.Fatal("no change signalled for the initial selection")
+	}
+	if mimes := client.MimeTypes(); len(mimes) != 2 || mimes[0] != mimeUTF8Text {
+		t.Errorf("MimeTypes = %v", mimes)
+	}
+	data, err := client.Receive(mimeUTF8Text)
+	if err != nil || string(data) != "copied text" {
+		t.Errorf("Receive = %q, %v", data, err)
+	}
+
+	// A protocol error ends the connection with the compositor's message
+	server.send(wlDisplayID, wlDisplayEventError, new(wlEncoder).putUint(client.deviceID).putUint(1).putString("invalid selection"), -1)
+	select {
+	case <-client.Done():
+	case <-time.After(time.Second):
+		t.Fatal("connection still alive after a protocol error")
+	}
+	if err := client.closeErr(); err == nil || !strings.Contains(err.Error(), "invalid selection") {
+		t.Errorf("closeErr = %v", err)
+	}
+	if err := client.SetSelection([]string{mimeText}, map[string][]byte{mimeText: []byte("x")}); err == nil {
+		t.Error("set the selection on a closed connection")
+	}
+}
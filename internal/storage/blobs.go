@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// blobGracePeriod protects newly stored blobs from removal, as the item
+// referencing them may not be saved yet
+const blobGracePeriod = time.Minute
+
+// ErrBlobTooLarge is returned when a file grows past the size limit while it is stored
+var ErrBlobTooLarge = errors.New("file exceeds the snapshot size limit")
+
+// BlobStore keeps file snapshots in a directory, named by the SHA-256 of
+// their contents so identical files are stored once
+type BlobStore struct {
+	dir    string
+	logger *zap.Logger
+	mu     sync.Mutex
+}
+
+// NewBlobStore creates a blob store in dir
+func NewBlobStore(dir string, logger *zap.Logger) (*BlobStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &BlobStore{dir: dir, logger: logger}, nil
+}
+
+// Put copies the file at path into the store and returns its hash and size.
+// A maxSize above zero limits how much is read.
+func (bs *BlobStore) Put(path string, maxSize int64) (string, int64, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(bs.dir, ".incoming-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create blob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var reader io.Reader = src
+	if maxSize > 0 {
+		reader = io.LimitReader(src, maxSize+1)
+	}
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), reader)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to copy file: %w", err)
+	}
+	if maxSize > 0 && size > maxSize {
+		return "", 0, ErrBlobTooLarge
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, fmt.Errorf("failed to write blob: %w", err)
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	target := bs.path(hash)
+
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if _, err := os.Stat(target); err == nil {
+		// Already stored, refresh it so it isn't removed before it is referenced
+		now := time.Now()
+		os.Chtimes(target, now, now)
+		return hash, size, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return "", 0, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return "", 0, fmt.Errorf("failed to store blob: %w", err)
+	}
+	return hash, size, nil
+}
+
+// Open opens a stored blob for reading
+func (bs *BlobStore) Open(hash string) (*os.File, error) {
+	if !validBlobHash(hash) {
+		return nil, fmt.Errorf("invalid blob hash %q", hash)
+	}
+	return os.Open(bs.path(hash))
+}
+
+// Has reports whether a blob is stored
+func (bs *BlobStore) Has(hash string) bool {
+	if !validBlobHash(hash) {
+		return false
+	}
+	_, err := os.Stat(bs.path(hash))
+	return err == nil
+}
+
+// Remove deletes a blob, unless it was stored within the grace period
+func (bs *BlobStore) Remove(hash string) error {
+	if !validBlobHash(hash) {
+		return fmt.Errorf("invalid blob hash %q", hash)
+	}
+
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	path := bs.path(hash)
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to stat blob: %w", err)
+	}
+	if time.Since(info.ModTime()) < blobGracePeriod {
+		return nil
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove blob: %w", err)
+	}
+	// Remove the fan-out directory once it is empty
+	os.Remove(filepath.Dir(path))
+	return nil
+}
+
+// List returns the hashes of all stored blobs
+func (bs *BlobStore) List() ([]string, error) {
+	var hashes []string
+	err := filepath.WalkDir(bs.dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && validBlobHash(d.Name()) {
+			hashes = append(hashes, d.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blobs: %w", err)
+	}
+	return hashes, nil
+}
+
+// path returns where a blob is stored, fanned out by the first two hash digits
+func (bs *BlobStore) path(hash string) string {
+	return filepath.Join(bs.dir, hash[:2], hash)
+}
+
+// validBlobHash reports whether hash is a hex SHA-256, so it is safe to use in paths
+func validBlobHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
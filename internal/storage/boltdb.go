@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -46,6 +47,13 @@ type BoltStorage struct {
 	logger    *zap.Logger
 	deviceID  string
 	keepItems int
+	onEvict   func([]*types.ClipboardContent)
+	evicted   []*types.ClipboardContent // Flushed in the running write transaction
+	
+	// File snapshots, and blobs of deleted items to remove once unreferenced
+	blobs         *BlobStore
+	releaseMu     sync.Mutex
+	releasedBlobs []string
 }
 
 // StorageConfig holds configuration for BoltStorage initialization
@@ -55,6 +63,7 @@ type StorageConfig struct {
 	DeviceID  string
 	Logger    *zap.Logger
 	KeepItems int
+	BlobDir   string // Directory for file snapshots, none if empty
 }
 
 // NewBoltStorage creates a new BoltStorage instance
@@ -76,11 +85,12 @@ func NewBoltStorage(config StorageConfig) (*BoltStorage, error) {
 		return nil, fmt.Errorf("failed to open bolt database: %w", err)
 	}
 
-	// Create bucket if it doesn't exist
+	// Create buckets if they don't exist
 	err = db.Update(func(tx *bbolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(clipboardBucket))
-		if err != nil {
-			return fmt.Errorf("failed to create bucket: %w", err)
+		for _, bucket := range []string{clipboardBucket, snippetsBucket, outboxBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return fmt.Errorf("failed to create bucket %s: %w", bucket, err)
+			}
 		}
 		return nil
 	})
@@ -112,6 +122,20 @@ func NewBoltStorage(config StorageConfig) (*BoltStorage, error) {
 		deviceID:  config.DeviceID,
 		keepItems: keepItemsValue,
 	}
+	
+	if config.BlobDir != "" {
+		blobs, err := NewBlobStore(config.BlobDir, config.Logger)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		storage.blobs = blobs
+		
+		// Clean up blobs left behind by items deleted in an earlier run
+		if err := storage.PruneBlobs(); err != nil && config.Logger != nil {
+			config.Logger.Warn("Failed to prune file snapshots", zap.Error(err))
+		}
+	}
 
 	if config.Logger != nil {
 		config.Logger.Debug("BoltStorage initialized", 
@@ -125,7 +149,7 @@ func NewBoltStorage(config StorageConfig) (*BoltStorage, error) {
 
 // SaveContent saves a clipboard content item to the database
 func (s *BoltStorage) SaveContent(content *types.ClipboardContent) error {
-	return s.db.Update(func(tx *bbolt.Tx) error {
+	return s.update(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(clipboardBucket))
 
 		encoded, err := json.Marshal(content)
@@ -251,6 +275,10 @@ func (s *BoltStorage) collectItemsToFlush(tx *bbolt.Tx) ([]*types.ClipboardConte
 				s.logger.Error("Failed to unmarshal content", zap.Error(err))
 				continue
 			}
+			// Pinned items are only removed when deleted explicitly
+			if content.Pinned {
+				continue
+			}
 			itemsToFlush = append(itemsToFlush, &content)
 		}
 	}
@@ -260,11 +288,36 @@ func (s *BoltStorage) collectItemsToFlush(tx *bbolt.Tx) ([]*types.ClipboardConte
 
 // DeleteContents removes specified content items from storage
 func (s *BoltStorage) DeleteContents(contents []*types.ClipboardContent) error {
-	return s.db.Update(func(tx *bbolt.Tx) error {
+	return s.update(func(tx *bbolt.Tx) error {
 		return s.deleteItemsFromBucket(tx, contents)
 	})
 }
 
+// SetPinned pins or unpins a stored item. Pinned items are kept when history is flushed.
+func (s *BoltStorage) SetPinned(content *types.ClipboardContent, pinned bool) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(clipboardBucket))
+		key := []byte(content.Created.Format(time.RFC3339Nano))
+		
+		v := b.Get(key)
+		if v == nil {
+			return fmt.Errorf("%w: no item with ID %s", ErrItemNotFound, content.ID())
+		}
+		
+		var stored types.ClipboardContent
+		if err := json.Unmarshal(v, &stored); err != nil {
+			return fmt.Errorf("failed to decode content: %w", err)
+		}
+		stored.Pinned = pinned
+		
+		encoded, err := json.Marshal(&stored)
+		if err != nil {
+			return fmt.Errorf("failed to encode content: %w", err)
+		}
+		return b.Put(key, encoded)
+	})
+}
+
 // deleteItemsFromBucket removes the specified items from the database
 func (s *BoltStorage) deleteItemsFromBucket(tx *bbolt.Tx, itemsToDelete []*types.ClipboardContent) error {
 	if len(itemsToDelete) == 0 {
@@ -281,6 +334,14 @@ func (s *BoltStorage) deleteItemsFromBucket(tx *bbolt.Tx, itemsToDelete []*types
 			return err
 		}
 		totalFreed += int64(len(content.Data))
+		
+		if s.blobs != nil {
+			s.releaseMu.Lock()
+			for _, file := range content.Files {
+				s.releasedBlobs = append(s.releasedBlobs, file.Hash)
+			}
+			s.releaseMu.Unlock()
+		}
 	}
 	
 	// Update cache size
@@ -292,6 +353,96 @@ func (s *BoltStorage) deleteItemsFromBucket(tx *bbolt.Tx, itemsToDelete []*types
 	return nil
 }
 
+// SetEvictHandler sets a function called with content flushed from storage
+// to stay under its limits, once the transaction removing it has committed.
+// Content deleted on request doesn't call it.
+func (s *BoltStorage) SetEvictHandler(handler func([]*types.ClipboardContent)) {
+	s.onEvict = handler
+}
+
+// Blobs returns the store for file snapshots, or nil if there is none
+func (s *BoltStorage) Blobs() *BlobStore {
+	return s.blobs
+}
+
+// update runs a write transaction, then removes the file snapshots of
+// deleted items that no remaining item references and, if it committed,
+// reports the flushed items to the evict handler
+func (s *BoltStorage) update(fn func(tx *bbolt.Tx) error) error {
+	// Only one write transaction runs at a time, so it owns s.evicted
+	var evicted []*types.ClipboardContent
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		s.evicted = nil
+		err := fn(tx)
+		evicted, s.evicted = s.evicted, nil
+		return err
+	})
+	
+	s.releaseMu.Lock()
+	released := s.releasedBlobs
+	s.releasedBlobs = nil
+	s.releaseMu.Unlock()
+	
+	if len(released) > 0 {
+		if err := s.removeUnreferencedBlobs(released); err != nil {
+			s.logger.Warn("Failed to remove file snapshots", zap.Error(err))
+		}
+	}
+	if err == nil && len(evicted) > 0 && s.onEvict != nil {
+		s.onEvict(evicted)
+	}
+	return err
+}
+
+// PruneBlobs removes all file snapshots that no item references
+func (s *BoltStorage) PruneBlobs() error {
+	if s.blobs == nil {
+		return nil
+	}
+	hashes, err := s.blobs.List()
+	if err != nil {
+		return err
+	}
+	return s.removeUnreferencedBlobs(hashes)
+}
+
+// removeUnreferencedBlobs removes those of the given blobs that no item references
+func (s *BoltStorage) removeUnreferencedBlobs(hashes []string) error {
+	referenced := make(map[string]bool)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(clipboardBucket)).ForEach(func(k, v []byte) error {
+			// Only the snapshot list is needed, not the data
+			var item struct{ Files []types.FileSnapshot }
+			if err := json.Unmarshal(v, &item); err != nil {
+				return nil
+			}
+			for _, file := range item.Files {
+				referenced[file.Hash] = true
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to collect referenced snapshots: %w", err)
+	}
+	
+	removed := 0
+	for _, hash := range hashes {
+		if referenced[hash] {
+			continue
+		}
+		if err := s.blobs.Remove(hash); err != nil {
+			return err
+		}
+		referenced[hash] = true // Don't remove a hash listed twice again
+		removed++
+	}
+	if removed > 0 {
+		s.logger.Debug("Removed unreferenced file snapshots", zap.Int("count", removed))
+	}
+	return nil
+}
+
 // flushOldestContent flushes the oldest content from the cache
 func (s *BoltStorage) flushOldestContent(tx *bbolt.Tx) error {
 	itemsToFlush, err := s.collectItemsToFlush(tx)
@@ -299,12 +450,16 @@ func (s *BoltStorage) flushOldestContent(tx *bbolt.Tx) error {
 		return err
 	}
 	
-	return s.deleteItemsFromBucket(tx, itemsToFlush)
+	if err := s.deleteItemsFromBucket(tx, itemsToFlush); err != nil {
+		return err
+	}
+	s.evicted = append(s.evicted, itemsToFlush...)
+	return nil
 }
 
 // FlushCache flushes the oldest content from the cache to stay under size limits
 func (s *BoltStorage) FlushCache() error {
-	return s.db.Update(func(tx *bbolt.Tx) error {
+	return s.update(func(tx *bbolt.Tx) error {
 		return s.flushOldestContent(tx)
 	})
 }
@@ -397,6 +552,15 @@ func (s *BoltStorage) GetHistory(options config.HistoryOptions) ([]*types.Clipbo
 				continue
 			}
 			
+			// Apply subtype and language filters
+			if options.Subtype != "" && content.Subtype != options.Subtype {
+				continue
+			}
+			
+			if options.Language != "" && content.Metadata[types.MetaLanguage] != options.Language {
+				continue
+			}
+			
 			// Apply size filters
 			contentSize := int64(len(content.Data))
 			if options.MinSize > 0 && contentSize < options.MinSize {
@@ -408,6 +572,7 @@ func (s *BoltStorage) GetHistory(options config.HistoryOptions) ([]*types.Clipbo
 			}
 			
 			// Handle decompression if needed
+			item := &content
 			if content.Compressed {
 				decompressed, err := compression.DecompressContent(&content)
 				if err != nil {
@@ -416,11 +581,15 @@ func (s *BoltStorage) GetHistory(options config.HistoryOptions) ([]*types.Clipbo
 						zap.Error(err))
 					continue
 				}
-				contents = append(contents, decompressed)
-			} else {
-				contents = append(contents, &content)
+				item = decompressed
 			}
 			
+			// Apply the text search on the decompressed content
+			if options.Search != "" && !matchesSearch(item, options.Search) {
+				continue
+			}
+			contents = append(contents, item)
+			
 			// Check if we've reached the limit
 			count++
 			if options.Limit > 0 && count >= options.Limit {
@@ -460,6 +629,18 @@ func (s *BoltStorage) LogCompleteHistory(options config.HistoryOptions) error {
 	fmt.Printf("\n=== CLIPBOARD HISTORY %s===\n", reverseInfo)
 	fmt.Printf("Filters: limit=%s, type=%s\n", limitInfo, typeInfo)
 	
+	if options.Subtype != "" {
+		fmt.Printf("         subtype=%s\n", options.Subtype)
+	}
+	
+	if options.Language != "" {
+		fmt.Printf("         language=%s\n", options.Language)
+	}
+	
+	if options.Search != "" {
+		fmt.Printf("         search=%q\n", options.Search)
+	}
+	
 	if options.MinSize > 0 {
 		fmt.Printf("         min_size=%d bytes\n", options.MinSize)
 	}
@@ -514,8 +695,12 @@ func (s *BoltStorage) LogCompleteHistory(options config.HistoryOptions) error {
 		}
 		
 		fmt.Printf("\n%s\n", itemHeader)
+		fmt.Printf("  ID: %s\n", content.ID())
+		if content.Pinned {
+			fmt.Println("  Pinned: yes")
+		}
 		fmt.Printf("  Timestamp: %s\n", timestampStr)
-		fmt.Printf("  Type: %s\n", content.Type)
+		fmt.Printf("  Type: %s\n", describeContentType(content))
 		fmt.Printf("  Size: %d bytes\n", len(content.Data))
 		
 		// Format preview based on content type
@@ -523,7 +708,11 @@ func (s *BoltStorage) LogCompleteHistory(options config.HistoryOptions) error {
 		
 		switch content.Type {
 		case types.TypeImage:
-			fmt.Println("    [Binary image data]")
+			if width, height := content.Metadata[types.MetaWidth], content.Metadata[types.MetaHeight]; width != "" {
+				fmt.Printf("    [Image: %sx%s]\n", width, height)
+			} else {
+				fmt.Println("    [Binary image data]")
+			}
 		
 		case types.TypeFile:
 			// For file lists, try to parse JSON and display in a nicer format
@@ -555,6 +744,9 @@ func (s *BoltStorage) LogCompleteHistory(options config.HistoryOptions) error {
 		case types.TypeURL:
 			url := strings.TrimSpace(string(content.Data))
 			fmt.Printf("    %s\n", url)
+			if original := content.Metadata[types.MetaOriginalURL]; original != "" {
+				fmt.Printf("    (copied as %s)\n", original)
+			}
 		
 		case types.TypeFilePath:
 			path := strings.TrimSpace(string(content.Data))
@@ -568,8 +760,13 @@ func (s *BoltStorage) LogCompleteHistory(options config.HistoryOptions) error {
 			}
 		
 		default:
-			// For text content, show a nicely formatted preview
-			fmt.Println(formatTextPreviewWithIndent(string(content.Data), 120, 4))
+			// For text content, show a nicely formatted preview.
+			// HTML and RTF are shown as their plain-text rendition.
+			fmt.Println(formatTextPreviewWithIndent(content.Text(), 120, 4))
+		}
+		
+		if len(content.Files) > 0 {
+			fmt.Printf("    (%d file(s) snapshotted)\n", len(content.Files))
 		}
 	}
 	
@@ -578,6 +775,26 @@ func (s *BoltStorage) LogCompleteHistory(options config.HistoryOptions) error {
 	return nil
 }
 
+// matchesSearch reports whether the text of content contains query, ignoring
+// case. Rich text is searched by its plain-text rendition, not its markup.
+func matchesSearch(content *types.ClipboardContent, query string) bool {
+	if content.Type == types.TypeImage {
+		return false
+	}
+	return strings.Contains(strings.ToLower(content.Text()), strings.ToLower(query))
+}
+
+// describeContentType formats the type of content with its subtype, e.g. "text (code, go)"
+func describeContentType(content *types.ClipboardContent) string {
+	if content.Subtype == types.SubtypeNone {
+		return string(content.Type)
+	}
+	if language := content.Metadata[types.MetaLanguage]; language != "" {
+		return fmt.Sprintf("%s (%s, %s)", content.Type, content.Subtype, language)
+	}
+	return fmt.Sprintf("%s (%s)", content.Type, content.Subtype)
+}
+
 // formatTextPreview formats a text string for display, handling newlines and length limits
 func formatTextPreview(text string, maxLength int, indentSpaces int) string {
 	// Replace tabs with spaces
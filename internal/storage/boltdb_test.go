@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+func TestEvictHandler(t *testing.T) {
+	store, err := NewBoltStorage(StorageConfig{
+		DBPath:    filepath.Join(t.TempDir(), "clipman.db"),
+		KeepItems: 1,
+		Logger:    zap.NewNop(),
+	})
+	if err != nil {
+		t.Fatalf("failed to open storage: %v", err)
+	}
+	defer store.Close()
+
+	var evicted []string
+	store.SetEvictHandler(func(contents []*types.ClipboardContent) {
+		for _, content := range contents {
+			evicted = append(evicted, string(content.Data))
+		}
+	})
+
+	created := time.Now()
+	save := func(data string) {
+		t.Helper()
+		created = created.Add(time.Second)
+		if err := store.SaveContent(&types.ClipboardContent{Type: types.TypeText, Data: []byte(data), Created: created}); err != nil {
+			t.Fatalf("failed to save %q: %v", data, err)
+		}
+	}
+	save("first")
+	save("second")
+
+	// Nothing is reported if the flushing transaction rolls back
+	err = store.update(func(tx *bbolt.Tx) error {
+		if err := store.flushOldestContent(tx); err != nil {
+			return err
+		}
+		return errors.New("rolled back")
+	})
+	if err == nil || len(evicted) != 0 {
+		t.Fatalf("rolled back flush reported %v (err %v)", evicted, err)
+	}
+	if contents, _ := store.GetAllContents(); len(contents) != 2 {
+		t.Fatalf("rolled back flush left %d items", len(contents))
+	}
+
+	if err := store.FlushCache(); err != nil {
+		t.Fatal(err)
+	}
+	if len(evicted) != 1 || evicted[0] != "first" {
+		t.Fatalf("flush reported %v", evicted)
+	}
+
+	// Deleting on request isn't an eviction
+	contents, err := store.GetAllContents()
+	if err != nil || len(contents) != 1 {
+		t.Fatalf("expected one remaining item, got %d (%v)", len(contents), err)
+	}
+	if err := store.DeleteContents(contents); err != nil {
+		t.Fatal(err)
+	}
+	if len(evicted) != 1 {
+		t.Errorf("deletion reported as eviction: %v", evicted)
+	}
+}
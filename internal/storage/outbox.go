@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"go.etcd.io/bbolt"
+)
+
+const outboxBucket = "outbox"
+
+// OutboxEntry is content waiting to be published to a group
+type OutboxEntry struct {
+	Group     string                  `json:"group"`
+	Content   *types.ClipboardContent `json:"content"`
+	Queued    time.Time               `json:"queued"`
+	Attempts  int                     `json:"attempts,omitempty"`   // Failed attempts to publish it
+	LastError string                  `json:"last_error,omitempty"` // Why it was queued or last failed
+	Size      int                     `json:"-"`                    // Stored size in bytes
+}
+
+// key orders entries by group, then by creation of their content
+func (e *OutboxEntry) key() []byte {
+	return []byte(e.Group + "/" + e.Content.ID())
+}
+
+// QueueOutgoing stores an entry in the outbox, replacing the entry for the
+// same content and group
+func (s *BoltStorage) QueueOutgoing(entry *OutboxEntry) error {
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox entry: %w", err)
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(outboxBucket)).Put(entry.key(), encoded)
+	})
+}
+
+// OutgoingEntries returns the outbox entries of a group, or of every group
+// if group is empty, oldest content first
+func (s *BoltStorage) OutgoingEntries(group string) ([]*OutboxEntry, error) {
+	var entries []*OutboxEntry
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket([]byte(outboxBucket)).Cursor()
+		prefix := []byte(nil)
+		if group != "" {
+			prefix = []byte(group + "/")
+		}
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var entry OutboxEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return fmt.Errorf("failed to decode outbox entry %s: %w", k, err)
+			}
+			if entry.Content == nil {
+				continue
+			}
+			entry.Size = len(v)
+			entries = append(entries, &entry)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// RemoveOutgoing removes an entry from the outbox
+func (s *BoltStorage) RemoveOutgoing(entry *OutboxEntry) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(outboxBucket)).Delete(entry.key())
+	})
+}
+
+// PruneOutbox drops entries queued longer than maxAge, then the longest
+// queued ones until the outbox fits in maxSize bytes. A zero limit is not
+// applied. It returns the dropped entries.
+func (s *BoltStorage) PruneOutbox(maxAge time.Duration, maxSize int64) ([]*OutboxEntry, error) {
+	entries, err := s.OutgoingEntries("")
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Queued.Before(entries[j].Queued) })
+
+	var total int64
+	for _, entry := range entries {
+		total += int64(entry.Size)
+	}
+
+	var dropped []*OutboxEntry
+	for _, entry := range entries {
+		expired := maxAge > 0 && time.Since(entry.Queued) > maxAge
+		if !expired && (maxSize <= 0 || total <= maxSize) {
+			break
+		}
+		dropped = append(dropped, entry)
+		total -= int64(entry.Size)
+	}
+	if len(dropped) == 0 {
+		return nil, nil
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(outboxBucket))
+		for _, entry := range dropped {
+			if err := b.Delete(entry.key()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to prune outbox: %w", err)
+	}
+	return dropped, nil
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/berrythewa/clipman-daemon/internal/config"
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+// minIDPrefix is the shortest item ID prefix accepted by SelectContent
+const minIDPrefix = 4
+
+// ErrItemNotFound is returned when no history item matches a selector
+var ErrItemNotFound = errors.New("no matching history item")
+
+// ItemSelector picks one history item. Exactly one field should be set.
+type ItemSelector struct {
+	ID     string `json:"id,omitempty"`     // Item ID, or a unique prefix of it
+	Index  int    `json:"index,omitempty"`  // Position in history, 1 is the most recent item
+	Search string `json:"search,omitempty"` // The most recent item containing this text
+}
+
+// Validate checks that exactly one way of selecting an item is given
+func (sel ItemSelector) Validate() error {
+	set := 0
+	if sel.ID != "" {
+		set++
+		if len(sel.ID) < minIDPrefix {
+			return fmt.Errorf("item ID %q is too short, use at least %d characters", sel.ID, minIDPrefix)
+		}
+	}
+	if sel.Index != 0 {
+		set++
+		if sel.Index < 0 {
+			return fmt.Errorf("invalid index %d, 1 is the most recent item", sel.Index)
+		}
+	}
+	if sel.Search != "" {
+		set++
+	}
+	if set != 1 {
+		return errors.New("select an item by exactly one of ID, index or search")
+	}
+	return nil
+}
+
+// SelectContent returns the history item picked by a selector
+func (s *BoltStorage) SelectContent(sel ItemSelector) (*types.ClipboardContent, error) {
+	if err := sel.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case sel.Index > 0:
+		contents, err := s.GetHistory(config.HistoryOptions{Reverse: true, Limit: int64(sel.Index)})
+		if err != nil {
+			return nil, err
+		}
+		if len(contents) < sel.Index {
+			return nil, fmt.Errorf("%w: history has %d items", ErrItemNotFound, len(contents))
+		}
+		return contents[sel.Index-1], nil
+
+	case sel.Search != "":
+		contents, err := s.GetHistory(config.HistoryOptions{Reverse: true, Limit: 1, Search: sel.Search})
+		if err != nil {
+			return nil, err
+		}
+		if len(contents) == 0 {
+			return nil, fmt.Errorf("%w: nothing contains %q", ErrItemNotFound, sel.Search)
+		}
+		return contents[0], nil
+	}
+
+	contents, err := s.GetAllContents()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	id := strings.ToLower(sel.ID)
+	var match *types.ClipboardContent
+	for _, content := range contents {
+		if !strings.HasPrefix(content.ID(), id) {
+			continue
+		}
+		if match != nil {
+			return nil, fmt.Errorf("item ID %q is ambiguous, use more characters", sel.ID)
+		}
+		match = content
+	}
+	if match == nil {
+		return nil, fmt.Errorf("%w: no item with ID %s", ErrItemNotFound, sel.ID)
+	}
+	return match, nil
+}
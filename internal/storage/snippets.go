@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"go.etcd.io/bbolt"
+)
+
+const snippetsBucket = "snippets"
+
+// ErrSnippetNotFound is returned when no snippet has the requested name
+var ErrSnippetNotFound = errors.New("snippet not found")
+
+// SaveSnippet stores a snippet, replacing any snippet with the same name
+func (s *BoltStorage) SaveSnippet(snippet *types.Snippet) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return putSnippet(tx, snippet)
+	})
+}
+
+// GetSnippet returns the snippet with the given name
+func (s *BoltStorage) GetSnippet(name string) (*types.Snippet, error) {
+	var snippet *types.Snippet
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		snippet, err = getSnippet(tx, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if snippet == nil || snippet.Deleted {
+		return nil, fmt.Errorf("%w: %s", ErrSnippetNotFound, name)
+	}
+	return snippet, nil
+}
+
+// ListSnippets returns the snippets sorted by name, only those tagged with
+// tag if it is not empty
+func (s *BoltStorage) ListSnippets(tag string) ([]*types.Snippet, error) {
+	var snippets []*types.Snippet
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(snippetsBucket)).ForEach(func(k, v []byte) error {
+			var snippet types.Snippet
+			if err := json.Unmarshal(v, &snippet); err != nil {
+				return fmt.Errorf("failed to decode snippet %s: %w", k, err)
+			}
+			if !snippet.Deleted && (tag == "" || snippet.HasTag(tag)) {
+				snippets = append(snippets, &snippet)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(snippets, func(i, j int) bool { return snippets[i].Name < snippets[j].Name })
+	return snippets, nil
+}
+
+// MergeSnippet stores a snippet received from another device if it is newer
+// than the local version, and reports whether it was stored. Deletions
+// arrive as tombstones and are merged the same way.
+func (s *BoltStorage) MergeSnippet(snippet *types.Snippet) (bool, error) {
+	merged := false
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		local, err := getSnippet(tx, snippet.Name)
+		if err != nil {
+			return err
+		}
+		if local != nil && !snippet.Supersedes(local) {
+			return nil
+		}
+		merged = true
+		return putSnippet(tx, snippet)
+	})
+	return merged, err
+}
+
+// getSnippet reads a snippet, including tombstones, or returns nil if there is none
+func getSnippet(tx *bbolt.Tx, name string) (*types.Snippet, error) {
+	v := tx.Bucket([]byte(snippetsBucket)).Get([]byte(name))
+	if v == nil {
+		return nil, nil
+	}
+
+	var snippet types.Snippet
+	if err := json.Unmarshal(v, &snippet); err != nil {
+		return nil, fmt.Errorf("failed to decode snippet %s: %w", name, err)
+	}
+	return &snippet, nil
+}
+
+// putSnippet writes a snippet under its name
+func putSnippet(tx *bbolt.Tx, snippet *types.Snippet) error {
+	encoded, err := json.Marshal(snippet)
+	if err != nil {
+		return fmt.Errorf("failed to encode snippet: %w", err)
+	}
+	return tx.Bucket([]byte(snippetsBucket)).Put([]byte(snippet.Name), encoded)
+}
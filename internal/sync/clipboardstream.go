@@ -0,0 +1,310 @@
+// Package sync provides clipboard synchronization using libp2p
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"go.uber.org/zap"
+)
+
+const (
+	// inlineContentLimit is the largest encoded content sent inline on a
+	// group topic, larger content is announced and fetched over a stream
+	inlineContentLimit = 64 * 1024
+
+	// offeredContentTTL is how long announced content can be fetched
+	offeredContentTTL = 10 * time.Minute
+
+	// maxOfferedBytes bounds the announced content kept for fetching
+	maxOfferedBytes = 64 * 1024 * 1024
+
+	// contentFetchTimeout bounds fetching one item
+	contentFetchTimeout = 2 * time.Minute
+
+	// maxContentRequestSize bounds a fetch request
+	maxContentRequestSize = 1024
+)
+
+// ErrContentNotOffered is returned when fetched content is no longer offered
+var ErrContentNotOffered = errors.New("content is not offered")
+
+// ContentAnnouncement is published on a group topic in place of content too
+// large to send inline. It carries metadata and the hash to fetch it by.
+type ContentAnnouncement struct {
+	Hash        string    `json:"hash"`         // Hex SHA-256 of the encoded content
+	Size        int64     `json:"size"`         // Size of the encoded content to fetch in bytes
+	ContentSize int64     `json:"content_size"` // Size of the clipboard data in bytes
+	Type        string    `json:"type"`
+	Subtype     string    `json:"subtype,omitempty"`
+	Created     time.Time `json:"created"`
+}
+
+// contentRequest asks a peer for announced content
+type contentRequest struct {
+	Hash string `json:"hash"`
+}
+
+// contentResponse precedes the content data
+type contentResponse struct {
+	Found bool   `json:"found"`
+	Size  int64  `json:"size"`
+	Error string `json:"error,omitempty"`
+}
+
+// offeredContent is announced content waiting to be fetched
+type offeredContent struct {
+	data     []byte
+	expires  time.Time
+	excluded map[peer.ID]bool // Peers the content isn't served to
+}
+
+// ClipboardStream serves announced clipboard content to paired devices and
+// fetches content they announce, over direct streams
+type ClipboardStream struct {
+	host    host.Host
+	ctx     context.Context
+	logger  *zap.Logger
+	pairing *PairingManager
+
+	mutex        sync.Mutex
+	offers       map[string]*offeredContent
+	offeredOrder []string // Hashes in the order they were offered, to evict the oldest
+	offeredBytes int
+	fetches      map[peer.ID]*contentFetch // Running fetch per peer
+}
+
+// contentFetch is a running fetch, cancelled when the peer announces newer content
+type contentFetch struct {
+	cancel context.CancelFunc
+}
+
+// NewClipboardStream creates the clipboard stream protocol and registers its handler
+func NewClipboardStream(ctx context.Context, host host.Host, pm *ProtocolManager, pairing *PairingManager, logger *zap.Logger) *ClipboardStream {
+	cs := &ClipboardStream{
+		host:    host,
+		ctx:     ctx,
+		logger:  logger.With(zap.String("component", "clipboard-stream")),
+		pairing: pairing,
+		offers:  make(map[string]*offeredContent),
+		fetches: make(map[peer.ID]*contentFetch),
+	}
+	pm.AddHandler(cs)
+	return cs
+}
+
+// ID returns the protocol ID
+func (cs *ClipboardStream) ID() protocol.ID {
+	return protocol.ID(ClipboardProtocolPath)
+}
+
+// Start starts the protocol handler
+func (cs *ClipboardStream) Start() error {
+	return nil
+}
+
+// Stop cancels running fetches and drops offered content
+func (cs *ClipboardStream) Stop() error {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	for id, fetch := range cs.fetches {
+		fetch.cancel()
+		delete(cs.fetches, id)
+	}
+	cs.offers = make(map[string]*offeredContent)
+	cs.offeredOrder = nil
+	cs.offeredBytes = 0
+	return nil
+}
+
+// Offer keeps encoded content available for fetching and returns its hash
+func (cs *ClipboardStream) Offer(data []byte) string {
+	return cs.OfferExcluding(data, nil)
+}
+
+// OfferExcluding is Offer for content that isn't served to some peers, as
+// their sync policies don't allow it
+func (cs *ClipboardStream) OfferExcluding(data []byte, exclude []peer.ID) string {
+	excluded := make(map[peer.ID]bool, len(exclude))
+	for _, id := range exclude {
+		excluded[id] = true
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	cs.pruneOffers()
+	if offer, ok := cs.offers[hash]; ok {
+		// Offered again, it now expires last
+		offer.expires = time.Now().Add(offeredContentTTL)
+		offer.excluded = excluded
+		cs.removeFromOrder(hash)
+		cs.offeredOrder = append(cs.offeredOrder, hash)
+		return hash
+	}
+	for cs.offeredBytes+len(data) > maxOfferedBytes && len(cs.offeredOrder) > 0 {
+		cs.dropOffer(cs.offeredOrder[0])
+	}
+	cs.offers[hash] = &offeredContent{data: data, expires: time.Now().Add(offeredContentTTL), excluded: excluded}
+	cs.offeredOrder = append(cs.offeredOrder, hash)
+	cs.offeredBytes += len(data)
+	return hash
+}
+
+// offered returns content offered to a peer by hash
+func (cs *ClipboardStream) offered(hash string, to peer.ID) ([]byte, bool) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	cs.pruneOffers()
+	offer, ok := cs.offers[hash]
+	if !ok || !time.Now().Before(offer.expires) || offer.excluded[to] {
+		return nil, false
+	}
+	return offer.data, true
+}
+
+// pruneOffers drops expired offers. The caller holds the lock.
+func (cs *ClipboardStream) pruneOffers() {
+	now := time.Now()
+	for len(cs.offeredOrder) > 0 {
+		offer, ok := cs.offers[cs.offeredOrder[0]]
+		if ok && now.Before(offer.expires) {
+			return
+		}
+		cs.dropOffer(cs.offeredOrder[0])
+	}
+}
+
+// dropOffer forgets an offer. The caller holds the lock.
+func (cs *ClipboardStream) dropOffer(hash string) {
+	if offer, ok := cs.offers[hash]; ok {
+		cs.offeredBytes -= len(offer.data)
+		delete(cs.offers, hash)
+	}
+	cs.removeFromOrder(hash)
+}
+
+// removeFromOrder removes a hash from the offer order. The caller holds the lock.
+func (cs *ClipboardStream) removeFromOrder(hash string) {
+	for i, offered := range cs.offeredOrder {
+		if offered == hash {
+			cs.offeredOrder = append(cs.offeredOrder[:i], cs.offeredOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+// Handle serves announced content to a paired device
+func (cs *ClipboardStream) Handle(stream network.Stream) {
+	defer stream.Close()
+	remotePeer := stream.Conn().RemotePeer()
+
+	if !cs.pairing.IsPaired(remotePeer.String()) {
+		cs.logger.Warn("Rejected content request from unpaired peer", zap.String("peer_id", remotePeer.String()))
+		stream.Reset()
+		return
+	}
+
+	stream.SetDeadline(time.Now().Add(contentFetchTimeout))
+	var request contentRequest
+	if _, err := readHeader(stream, maxContentRequestSize, &request); err != nil {
+		cs.logger.Debug("Failed to read content request", zap.String("peer_id", remotePeer.String()), zap.Error(err))
+		stream.Reset()
+		return
+	}
+
+	data, ok := cs.offered(request.Hash, remotePeer)
+	if !ok {
+		json.NewEncoder(stream).Encode(contentResponse{Error: ErrContentNotOffered.Error()})
+		return
+	}
+	if err := json.NewEncoder(stream).Encode(contentResponse{Found: true, Size: int64(len(data))}); err != nil {
+		stream.Reset()
+		return
+	}
+	if err := writeBytes(stream, data); err != nil {
+		cs.logger.Debug("Failed to send content", zap.String("peer_id", remotePeer.String()), zap.Error(err))
+		stream.Reset()
+	}
+}
+
+// Fetch downloads announced content from a peer and verifies it against the
+// announced hash. A fetch from the same peer that is still running is
+// cancelled, as newer content supersedes it.
+func (cs *ClipboardStream) Fetch(peerID peer.ID, announcement ContentAnnouncement, maxSize int64) ([]byte, error) {
+	if maxSize > 0 && announcement.Size > maxSize {
+		return nil, fmt.Errorf("announced content of %d bytes exceeds the limit of %d bytes", announcement.Size, maxSize)
+	}
+
+	ctx, cancel := context.WithTimeout(cs.ctx, contentFetchTimeout)
+	defer cancel()
+	fetch := &contentFetch{cancel: cancel}
+	cs.mutex.Lock()
+	if previous, ok := cs.fetches[peerID]; ok {
+		previous.cancel()
+	}
+	cs.fetches[peerID] = fetch
+	cs.mutex.Unlock()
+	defer func() {
+		cs.mutex.Lock()
+		defer cs.mutex.Unlock()
+		// A newer fetch may have replaced this one
+		if cs.fetches[peerID] == fetch {
+			delete(cs.fetches, peerID)
+		}
+	}()
+
+	stream, err := cs.host.NewStream(ctx, peerID, cs.ID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream to peer %s: %w", peerID, err)
+	}
+	defer stream.Close()
+	stop := context.AfterFunc(ctx, func() { stream.Reset() })
+	defer stop()
+
+	deadline, _ := ctx.Deadline()
+	stream.SetDeadline(deadline)
+	if err := json.NewEncoder(stream).Encode(contentRequest{Hash: announcement.Hash}); err != nil {
+		return nil, fmt.Errorf("failed to request content: %w", err)
+	}
+
+	var response contentResponse
+	body, err := readHeader(stream, maxContentRequestSize, &response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content response: %w", err)
+	}
+	if !response.Found {
+		return nil, fmt.Errorf("%w: %s", ErrContentNotOffered, response.Error)
+	}
+	if response.Size != announcement.Size {
+		return nil, fmt.Errorf("peer sent %d bytes, announced %d", response.Size, announcement.Size)
+	}
+
+	data := make([]byte, response.Size)
+	if _, err := io.ReadFull(body, data); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("fetch cancelled: %w", context.Cause(ctx))
+		}
+		return nil, fmt.Errorf("failed to read content: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != announcement.Hash {
+		return nil, errors.New("fetched content doesn't match the announced hash")
+	}
+	return data, nil
+}
@@ -0,0 +1,127 @@
+package sync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"go.uber.org/zap"
+)
+
+func TestClipboardStreamOffers(t *testing.T) {
+	cs := &ClipboardStream{
+		logger:  zap.NewNop(),
+		offers:  make(map[string]*offeredContent),
+		fetches: make(map[peer.ID]*contentFetch),
+	}
+
+	data := []byte("large clipboard content")
+	hash := cs.Offer(data)
+	sum := sha256.Sum256(data)
+	if hash != hex.EncodeToString(sum[:]) {
+		t.Errorf("hash = %s, want the SHA-256 of the content", hash)
+	}
+	if got, ok := cs.offered(hash, ""); !ok || string(got) != string(data) {
+		t.Fatal("offered content not found")
+	}
+
+	// Offering the same content again doesn't keep a second copy
+	cs.Offer(data)
+	if len(cs.offeredOrder) != 1 || cs.offeredBytes != len(data) {
+		t.Errorf("duplicate offer kept: %d offers, %d bytes", len(cs.offeredOrder), cs.offeredBytes)
+	}
+
+	other := cs.Offer([]byte("newer content"))
+	cs.offers[hash].expires = time.Now().Add(-time.Second)
+	if _, ok := cs.offered(hash, ""); ok {
+		t.Error("expired offer is still served")
+	}
+	if _, ok := cs.offered(other, ""); !ok {
+		t.Error("current offer was dropped with the expired one")
+	}
+	if cs.offeredBytes != len("newer content") {
+		t.Errorf("offered bytes = %d after expiry", cs.offeredBytes)
+	}
+
+	// Offering content again moves it behind newer offers, and offers that
+	// expired are refused even while an unexpired one is ahead of them
+	first := cs.Offer([]byte("first"))
+	second := cs.Offer([]byte("second"))
+	cs.Offer([]byte("first"))
+	if last := cs.offeredOrder[len(cs.offeredOrder)-1]; last != first {
+		t.Errorf("re-offered content isn't last in the offer order")
+	}
+	cs.offers[second].expires = time.Now().Add(-time.Second)
+	if _, ok := cs.offered(second, ""); ok {
+		t.Error("expired offer is served")
+	}
+	if _, ok := cs.offered(first, ""); !ok {
+		t.Error("re-offered content was dropped")
+	}
+
+	// Content isn't served to peers a sync policy excludes
+	excluded := newTestPeerID(t)
+	limited := cs.OfferExcluding([]byte("work only"), []peer.ID{excluded})
+	if _, ok := cs.offered(limited, excluded); ok {
+		t.Error("content served to an excluded peer")
+	}
+	if _, ok := cs.offered(limited, newTestPeerID(t)); !ok {
+		t.Error("content not served to a peer that isn't excluded")
+	}
+}
+
+func TestClipboardStreamFetch(t *testing.T) {
+	_, peers := newTestPeers(t, 3, mocknet.LinkOptions{})
+	streams := make([]*ClipboardStream, len(peers))
+	for i, p := range peers {
+		streams[i] = NewClipboardStream(p.ctx, p.host, p.protocols, p.pairing, zap.NewNop())
+		p.start(t)
+	}
+	owner, fetcher, excluded := peers[0], peers[1], peers[2]
+
+	data := bytes.Repeat([]byte("large clipboard content "), 4096)
+	announcement := ContentAnnouncement{Hash: streams[0].Offer(data), Size: int64(len(data))}
+	got, err := streams[1].Fetch(owner.id(), announcement, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("fetched content differs from the offered content")
+	}
+
+	// Content larger than the limit isn't fetched
+	if _, err := streams[1].Fetch(owner.id(), announcement, announcement.Size-1); err == nil {
+		t.Error("content over the size limit was fetched")
+	}
+
+	// Content that changed since it was announced is refused
+	streams[0].mutex.Lock()
+	streams[0].offers[announcement.Hash].data = bytes.ToUpper(data)
+	streams[0].mutex.Unlock()
+	if _, err := streams[1].Fetch(owner.id(), announcement, 0); err == nil || !strings.Contains(err.Error(), "doesn't match") {
+		t.Errorf("fetch of tampered content = %v", err)
+	}
+
+	// Peers a sync policy excludes aren't served
+	limited := []byte("work only")
+	hash := streams[0].OfferExcluding(limited, []peer.ID{excluded.id()})
+	announcement = ContentAnnouncement{Hash: hash, Size: int64(len(limited))}
+	if _, err := streams[2].Fetch(owner.id(), announcement, 0); !errors.Is(err, ErrContentNotOffered) {
+		t.Errorf("fetch by an excluded peer = %v, want ErrContentNotOffered", err)
+	}
+	if got, err := streams[1].Fetch(owner.id(), announcement, 0); err != nil || !bytes.Equal(got, limited) {
+		t.Errorf("fetch by a peer that isn't excluded = %q, %v", got, err)
+	}
+
+	// Unpaired peers are refused
+	owner.unpair(fetcher)
+	if _, err := streams[1].Fetch(owner.id(), announcement, 0); err == nil {
+		t.Error("content served to an unpaired peer")
+	}
+}
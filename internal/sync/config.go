@@ -72,6 +72,19 @@ type SyncConfig struct {
 	DeviceName     string `json:"device_name"`
 	DeviceType     string `json:"device_type"`
 	PairingTimeout int    `json:"pairing_timeout"`
+	PairedDevicesPath string `json:"paired_devices_path"` // Path to store paired devices
+	
+	// Encryption Options
+	GroupKeysPath string `json:"group_keys_path"` // Path to store group encryption keys
+	
+	// Group Options
+	GroupsPath string `json:"groups_path"` // Path to store the groups this device is in
+	
+	// Policy Options
+	PoliciesPath string `json:"policies_path"` // Path to store the sync policies of paired devices and groups
+	
+	// Revocation Options
+	RevokedPeersPath string `json:"revoked_peers_path"` // Path to store the revoked devices denied at the connection level
 }
 
 // NodeConfig contains configuration specific to the libp2p node
@@ -130,7 +143,7 @@ func LoadSyncConfig(cfg *config.Config) *SyncConfig {
 		RequireFileConfirmation: cfg.Sync.RequireFileConfirmation,
 		DefaultDownloadFolder:   cfg.Sync.DefaultDownloadFolder,
 		MaxFileSizeMB:           cfg.Sync.MaxFileSizeMB,
-		AutoAcceptFromPeers:     []string{}, // Not exposed in user config yet
+		AutoAcceptFromPeers:     cfg.Sync.AutoAcceptFromPeers,
 		
 		// Privacy & Security
 		AllowOnlyKnownPeers: cfg.Sync.AllowOnlyKnownPeers,
@@ -157,6 +170,19 @@ func LoadSyncConfig(cfg *config.Config) *SyncConfig {
 		DeviceName:     cfg.DeviceName,
 		DeviceType:     "desktop", // Hardcoded for now, should be determined based on platform
 		PairingTimeout: 300, // 5 minutes default timeout
+		PairedDevicesPath: filepath.Join(paths.DataDir, "paired_devices.json"),
+		
+		// Encryption Options
+		GroupKeysPath: filepath.Join(paths.DataDir, "group_keys.json"),
+		
+		// Group Options
+		GroupsPath: filepath.Join(paths.DataDir, "groups.json"),
+		
+		// Policy Options
+		PoliciesPath: filepath.Join(paths.DataDir, "policies.json"),
+		
+		// Revocation Options
+		RevokedPeersPath: filepath.Join(paths.DataDir, "revoked_peers.json"),
 	}
 	
 	return syncCfg
@@ -0,0 +1,102 @@
+// Package sync provides clipboard synchronization using libp2p
+package sync
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// EnvelopeVersion is the version of the encrypted envelope format
+const EnvelopeVersion = 1
+
+// Envelope carries a group message encrypted with the group key, so nodes
+// that join the topic without the key can't read it
+type Envelope struct {
+	Version    int    `json:"v"`
+	Group      string `json:"group"`
+	Epoch      uint32 `json:"epoch"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Reasons a received envelope can't be decrypted
+var (
+	ErrNotEnvelope   = errors.New("message is not an encrypted envelope")
+	ErrWrongGroup    = errors.New("envelope is for another group")
+	ErrNoGroupKey    = errors.New("no key for the group")
+	ErrStaleEpoch    = errors.New("envelope uses a retired key epoch")
+	ErrFutureEpoch   = errors.New("envelope uses a key epoch this device hasn't received")
+	ErrDecryptFailed = errors.New("envelope failed authentication")
+)
+
+// SealEnvelope encrypts a message with a group key
+func SealEnvelope(key GroupKey, plaintext []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key for group %s: %w", key.Group, err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	envelope := Envelope{
+		Version:    EnvelopeVersion,
+		Group:      key.Group,
+		Epoch:      key.Epoch,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, envelopeAD(key.Group, key.Epoch)),
+	}
+	return json.Marshal(envelope)
+}
+
+// OpenEnvelope decrypts a message received on a group's topic. The error
+// says why a message was rejected, matching one of the Err reasons above.
+func (k *GroupKeyring) OpenEnvelope(group string, data []byte) ([]byte, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil || envelope.Version == 0 {
+		return nil, ErrNotEnvelope
+	}
+	if envelope.Version != EnvelopeVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrNotEnvelope, envelope.Version)
+	}
+	if envelope.Group != group {
+		return nil, fmt.Errorf("%w: %q received on the topic of %q", ErrWrongGroup, envelope.Group, group)
+	}
+
+	key, ok := k.Current(group)
+	if !ok {
+		return nil, fmt.Errorf("%w %q, pair with a member of the group to receive it", ErrNoGroupKey, group)
+	}
+	switch {
+	case envelope.Epoch < key.Epoch:
+		return nil, fmt.Errorf("%w: epoch %d, the group is at epoch %d; the sender may have been removed or missed the rotation",
+			ErrStaleEpoch, envelope.Epoch, key.Epoch)
+	case envelope.Epoch > key.Epoch:
+		return nil, fmt.Errorf("%w: epoch %d, this device has epoch %d; it will be received from a member on the next connection",
+			ErrFutureEpoch, envelope.Epoch, key.Epoch)
+	}
+
+	aead, err := chacha20poly1305.NewX(key.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key for group %s: %w", group, err)
+	}
+	if len(envelope.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("%w: invalid nonce", ErrDecryptFailed)
+	}
+	plaintext, err := aead.Open(nil, envelope.Nonce, envelope.Ciphertext, envelopeAD(group, envelope.Epoch))
+	if err != nil {
+		return nil, fmt.Errorf("%w: it was encrypted with another key for epoch %d or was modified", ErrDecryptFailed, envelope.Epoch)
+	}
+	return plaintext, nil
+}
+
+// envelopeAD binds a ciphertext to its group and epoch
+func envelopeAD(group string, epoch uint32) []byte {
+	return []byte(fmt.Sprintf("clipman-envelope/%d/%s/%d", EnvelopeVersion, group, epoch))
+}
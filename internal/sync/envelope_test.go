@@ -0,0 +1,268 @@
+package sync
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func newTestKeyring(t *testing.T) *GroupKeyring {
+	t.Helper()
+	return NewGroupKeyring(filepath.Join(t.TempDir(), "group_keys.json"), zap.NewNop())
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	keyring := newTestKeyring(t)
+	key, err := keyring.Ensure("home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Epoch != 1 {
+		t.Errorf("first epoch = %d, want 1", key.Epoch)
+	}
+
+	sealed, err := SealEnvelope(key, []byte("secret clipboard"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(sealed), "secret") {
+		t.Fatal("payload is not encrypted")
+	}
+
+	plaintext, err := keyring.OpenEnvelope("home", sealed)
+	if err != nil {
+		t.Fatalf("OpenEnvelope: %v", err)
+	}
+	if string(plaintext) != "secret clipboard" {
+		t.Errorf("plaintext = %q", plaintext)
+	}
+}
+
+func TestEnvelopeRejections(t *testing.T) {
+	keyring := newTestKeyring(t)
+	key, _ := keyring.Ensure("home")
+	sealed, _ := SealEnvelope(key, []byte("hello"))
+
+	// Another member's keyring with a different key for the same group
+	other := newTestKeyring(t)
+	otherKey, _ := other.Ensure("home")
+	forged, _ := SealEnvelope(otherKey, []byte("hello"))
+
+	var envelope Envelope
+	json.Unmarshal(sealed, &envelope)
+	envelope.Ciphertext[0] ^= 0xff
+	tampered, _ := json.Marshal(envelope)
+
+	tests := []struct {
+		name  string
+		group string
+		data  []byte
+		want  error
+	}{
+		{"plaintext", "home", []byte("hello"), ErrNotEnvelope},
+		{"wrong topic", "work", sealed, ErrWrongGroup},
+		{"other key", "home", forged, ErrDecryptFailed},
+		{"tampered", "home", tampered, ErrDecryptFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := keyring.OpenEnvelope(tt.group, tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	empty := newTestKeyring(t)
+	if _, err := empty.OpenEnvelope("home", sealed); !errors.Is(err, ErrNoGroupKey) {
+		t.Errorf("without key: error = %v, want %v", err, ErrNoGroupKey)
+	}
+}
+
+func TestEnvelopeEpochs(t *testing.T) {
+	keyring := newTestKeyring(t)
+	old, _ := keyring.Ensure("home")
+	oldMessage, _ := SealEnvelope(old, []byte("before"))
+
+	// A member that doesn't receive the rotated key
+	removed := newTestKeyring(t)
+	if _, err := removed.Import([]GroupKey{old}, "desktop", true); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := keyring.Rotate("home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Epoch != 2 {
+		t.Fatalf("rotated epoch = %d, want 2", current.Epoch)
+	}
+
+	if _, err := keyring.OpenEnvelope("home", oldMessage); !errors.Is(err, ErrStaleEpoch) {
+		t.Errorf("old epoch: error = %v, want %v", err, ErrStaleEpoch)
+	}
+
+	newMessage, _ := SealEnvelope(current, []byte("after"))
+	if _, err := removed.OpenEnvelope("home", newMessage); !errors.Is(err, ErrFutureEpoch) {
+		t.Errorf("removed member: error = %v, want %v", err, ErrFutureEpoch)
+	}
+}
+
+// newTestMemberKeyring creates the keyring of a device, by name
+func newTestMemberKeyring(t *testing.T, self string) *GroupKeyring {
+	t.Helper()
+	keyring := newTestKeyring(t)
+	keyring.SetSelf(self)
+	return keyring
+}
+
+func TestGroupKeyringImport(t *testing.T) {
+	desktop := newTestMemberKeyring(t, "desktop")
+	first, _ := desktop.Ensure("home")
+	if _, err := desktop.Grant("home", "laptop"); err != nil {
+		t.Fatal(err)
+	}
+	first, _ = desktop.Current("home")
+
+	// Pairing hands the key over, the laptop adopts it over its own
+	laptop := newTestMemberKeyring(t, "laptop")
+	laptop.Ensure("home")
+	if result, err := laptop.Import([]GroupKey{first}, "desktop", true); err != nil || len(result.changed) != 1 {
+		t.Fatalf("pairing import = %+v, %v", result, err)
+	}
+	if key, _ := laptop.Current("home"); !bytes.Equal(key.Key, first.Key) && !key.precedes(first) {
+		t.Error("pairing kept a key that doesn't win over the handed over one")
+	}
+
+	// Keys pushed by members only move the group forward
+	second, _ := laptop.Rotate("home")
+	if result, _ := desktop.Import([]GroupKey{second}, "laptop", false); len(result.changed) != 1 {
+		t.Errorf("later epoch push was not taken")
+	}
+	if result, _ := desktop.Import([]GroupKey{first}, "laptop", false); len(result.changed) != 0 || len(result.behind) != 1 {
+		t.Errorf("earlier epoch push = %+v, want the sender behind", result)
+	}
+	if key, _ := desktop.Current("home"); key.Epoch != 2 {
+		t.Errorf("earlier epoch push rolled the key back to epoch %d", key.Epoch)
+	}
+
+	// Pairing never rolls a group back either
+	if result, _ := desktop.Import([]GroupKey{first}, "laptop", true); len(result.behind) != 1 {
+		t.Errorf("pairing with an earlier epoch = %+v, want the sender behind", result)
+	}
+	if key, _ := desktop.Current("home"); key.Epoch != 2 {
+		t.Errorf("pairing rolled the key back to epoch %d", key.Epoch)
+	}
+
+	// Devices that aren't members can't replace a group's key
+	forged, _ := newGroupKey("home", 9)
+	if result, _ := desktop.Import([]GroupKey{forged}, "stranger", false); len(result.changed) != 0 {
+		t.Error("a device that isn't a member replaced the group key")
+	}
+
+	if _, err := desktop.Import([]GroupKey{{Group: "home", Epoch: 9, Key: []byte("short")}}, "laptop", false); err == nil {
+		t.Error("invalid key was accepted")
+	}
+}
+
+func TestGroupKeyringMembers(t *testing.T) {
+	desktop := newTestMemberKeyring(t, "desktop")
+	desktop.Ensure("work")
+	desktop.Ensure("family")
+	for _, member := range []string{"laptop", "phone"} {
+		if granted, err := desktop.Grant("work", member); err != nil || !granted {
+			t.Fatalf("grant %s = %v, %v", member, granted, err)
+		}
+	}
+	if granted, _ := desktop.Grant("work", "laptop"); granted {
+		t.Error("granted a member twice")
+	}
+	if _, err := desktop.Grant("unknown", "laptop"); !errors.Is(err, ErrNoGroupKey) {
+		t.Errorf("grant without a key: %v", err)
+	}
+
+	// Only the keys of a device's groups are handed to it
+	if keys := desktop.KeysFor("laptop"); len(keys) != 1 || keys[0].Group != "work" {
+		t.Errorf("keys for the laptop = %+v, want work only", keys)
+	}
+
+	// Removing a member rotates the key without it
+	rotated, err := desktop.Rotate("work", "phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.Epoch != 2 || containsString(rotated.Members, "phone") || !containsString(rotated.Members, "laptop") {
+		t.Errorf("rotated key = epoch %d, members %v", rotated.Epoch, rotated.Members)
+	}
+	if keys := desktop.KeysFor("phone"); len(keys) != 0 {
+		t.Errorf("removed member is still handed %d keys", len(keys))
+	}
+}
+
+func TestGroupKeyringConflictingRotations(t *testing.T) {
+	// Two devices share a group with two more members
+	devices := []string{"desktop", "laptop", "phone", "tablet"}
+	base, _ := newGroupKey("home", 1)
+	base.Members = devices
+	desktop := newTestMemberKeyring(t, "desktop")
+	laptop := newTestMemberKeyring(t, "laptop")
+	for _, keyring := range []*GroupKeyring{desktop, laptop} {
+		if _, err := keyring.Import([]GroupKey{base}, "desktop", true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// At the same time, each removes a different member
+	desktop.Rotate("home", "phone")
+	laptop.Rotate("home", "tablet")
+
+	// Exchanging keys until neither changes settles on one key
+	for round := 0; ; round++ {
+		if round == 5 {
+			t.Fatal("keys didn't settle")
+		}
+		fromDesktop, _ := desktop.Current("home")
+		fromLaptop, _ := laptop.Current("home")
+		toLaptop, _ := laptop.Import([]GroupKey{fromDesktop}, "desktop", false)
+		toDesktop, _ := desktop.Import([]GroupKey{fromLaptop}, "laptop", false)
+		if len(toLaptop.changed) == 0 && len(toDesktop.changed) == 0 {
+			break
+		}
+	}
+
+	desktopKey, _ := desktop.Current("home")
+	laptopKey, _ := laptop.Current("home")
+	if !bytes.Equal(desktopKey.Key, laptopKey.Key) || desktopKey.Epoch != laptopKey.Epoch {
+		t.Fatalf("devices disagree: epochs %d and %d", desktopKey.Epoch, laptopKey.Epoch)
+	}
+	// Neither removed member is handed the key both settled on
+	for _, removed := range []string{"phone", "tablet"} {
+		if containsString(desktopKey.Members, removed) || containsString(laptopKey.Members, removed) {
+			t.Errorf("removed member %s is still a member: %v, %v", removed, desktopKey.Members, laptopKey.Members)
+		}
+	}
+}
+
+func TestGroupKeyringReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "group_keys.json")
+	daemon := NewGroupKeyring(path, zap.NewNop())
+	if _, err := daemon.Ensure("home"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process, such as the pair command, changes the key
+	pairCommand := NewGroupKeyring(path, zap.NewNop())
+	rotated, err := pairCommand.Rotate("home")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, ok := daemon.Current("home")
+	if !ok || key.Epoch != rotated.Epoch {
+		t.Errorf("daemon sees epoch %d, want %d", key.Epoch, rotated.Epoch)
+	}
+}
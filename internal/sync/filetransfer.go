@@ -0,0 +1,708 @@
+// Package sync provides clipboard synchronization using libp2p
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"go.uber.org/zap"
+)
+
+const (
+	// fileChunkSize is how much of a file is written to the stream at a time
+	fileChunkSize = 64 * 1024
+
+	// fileChunkTimeout bounds each step of a transfer, a stalled peer fails the attempt
+	fileChunkTimeout = time.Minute
+
+	// fileConfirmationTimeout is how long an offer waits for the user to accept it
+	fileConfirmationTimeout = 5 * time.Minute
+
+	// fileTransferRetries is how often a sender resumes a transfer after the stream breaks
+	fileTransferRetries = 5
+
+	// maxFileOfferSize bounds the offer a peer sends before the file data
+	maxFileOfferSize = 4096
+
+	// maxRememberedTransfers bounds the finished transfers kept for listing
+	maxRememberedTransfers = 100
+
+	// partialFileSuffix marks files still being received in the download folder
+	partialFileSuffix = ".clipman-part"
+)
+
+// fileRetryDelay is the wait before resuming a broken transfer, a variable
+// so tests don't wait
+var fileRetryDelay = 5 * time.Second
+
+// Errors returned by file transfers
+var (
+	ErrFileSharingDisabled = errors.New("file sharing is disabled")
+	ErrFileTooLarge        = errors.New("file is larger than the maximum file size")
+	ErrTransferRejected    = errors.New("transfer was rejected")
+	ErrTransferNotFound    = errors.New("no such transfer")
+)
+
+// fileOffer is sent by the sender before the file data
+type fileOffer struct {
+	TransferID string `json:"transfer_id"`
+	Name       string `json:"name"`
+	Size       int64  `json:"size"`
+	Hash       string `json:"hash"` // Hex SHA-256 of the whole file
+}
+
+// fileOfferResponse accepts or rejects an offer. Offset is how much of the
+// file the recipient already has from an earlier attempt.
+type fileOfferResponse struct {
+	Accepted bool   `json:"accepted"`
+	Offset   int64  `json:"offset"`
+	Error    string `json:"error,omitempty"`
+}
+
+// fileResult is sent by the recipient once the file is received and verified
+type fileResult struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// fileTransfer is the state of one transfer
+type fileTransfer struct {
+	info      TransferInfo
+	cancel    func()    // Stops the transfer while it runs
+	decision  chan bool // Set while an incoming offer waits for confirmation
+	cancelled bool
+}
+
+// FileTransfer sends files to paired devices over a direct stream. Files are
+// sent in chunks, verified with SHA-256, and resumed from what the recipient
+// already has when a stream breaks.
+type FileTransfer struct {
+	host            host.Host
+	ctx             context.Context
+	logger          *zap.Logger
+	protocolManager *ProtocolManager
+	pairing         *PairingManager
+	config          *SyncConfig
+	autoAccept      map[string]bool
+
+	mutex     sync.Mutex
+	transfers map[string]*fileTransfer
+	accepted  map[string]bool // Incoming transfers the user accepted, not confirmed again when resumed
+}
+
+// NewFileTransfer creates the file transfer protocol and registers its handler
+func NewFileTransfer(ctx context.Context, host host.Host, pm *ProtocolManager, pairing *PairingManager, config *SyncConfig, logger *zap.Logger) *FileTransfer {
+	ft := &FileTransfer{
+		host:            host,
+		ctx:             ctx,
+		logger:          logger.With(zap.String("component", "file-transfer")),
+		protocolManager: pm,
+		pairing:         pairing,
+		config:          config,
+		autoAccept:      make(map[string]bool),
+		transfers:       make(map[string]*fileTransfer),
+		accepted:        make(map[string]bool),
+	}
+	for _, id := range config.AutoAcceptFromPeers {
+		ft.autoAccept[id] = true
+	}
+	pm.AddHandler(ft)
+	return ft
+}
+
+// ID returns the protocol ID
+func (ft *FileTransfer) ID() protocol.ID {
+	return protocol.ID(FileProtocolPath)
+}
+
+// Start starts the protocol handler
+func (ft *FileTransfer) Start() error {
+	return nil
+}
+
+// Stop stops the transfers that are running
+func (ft *FileTransfer) Stop() error {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+	for _, transfer := range ft.transfers {
+		if transfer.cancel != nil {
+			transfer.cancel()
+		}
+	}
+	return nil
+}
+
+// SendFile starts sending a file to a paired device and returns the transfer,
+// which runs in the background
+func (ft *FileTransfer) SendFile(peerID peer.ID, path string) (TransferInfo, error) {
+	if !ft.config.EnableFileSharing {
+		return TransferInfo{}, ErrFileSharingDisabled
+	}
+	if !ft.pairing.IsPaired(peerID.String()) {
+		return TransferInfo{}, fmt.Errorf("%w: %s", ErrUnknownSender, peerID)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return TransferInfo{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	if !stat.Mode().IsRegular() {
+		return TransferInfo{}, fmt.Errorf("%s is not a regular file", path)
+	}
+	if limit := ft.maxFileSize(); limit > 0 && stat.Size() > limit {
+		return TransferInfo{}, fmt.Errorf("%w of %d MB", ErrFileTooLarge, ft.config.MaxFileSizeMB)
+	}
+	hash, err := hashFile(path)
+	if err != nil {
+		return TransferInfo{}, err
+	}
+
+	ctx, cancel := context.WithCancel(ft.ctx)
+	transfer := &fileTransfer{
+		info: TransferInfo{
+			ID:        generateNonce(),
+			FileName:  filepath.Base(path),
+			FileSize:  stat.Size(),
+			Hash:      hash,
+			Path:      path,
+			Sender:    ft.host.ID().String(),
+			Recipient: peerID.String(),
+			StartTime: time.Now(),
+			Status:    TransferPending,
+		},
+		cancel: cancel,
+	}
+	info := ft.track(transfer)
+
+	go func() {
+		defer cancel()
+		ft.send(ctx, transfer, peerID)
+	}()
+	return info, nil
+}
+
+// send runs an outgoing transfer, resuming it when the stream breaks
+func (ft *FileTransfer) send(ctx context.Context, transfer *fileTransfer, peerID peer.ID) {
+	var err error
+	for attempt := 0; attempt <= fileTransferRetries; attempt++ {
+		if attempt > 0 {
+			ft.logger.Info("Resuming interrupted file transfer",
+				zap.String("transfer_id", transfer.info.ID),
+				zap.String("peer_id", peerID.String()),
+				zap.Int("attempt", attempt),
+				zap.Error(err))
+			select {
+			case <-time.After(fileRetryDelay):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		err = ft.sendAttempt(ctx, transfer, peerID)
+		if err == nil || errors.Is(err, ErrTransferRejected) {
+			break
+		}
+	}
+	ft.finish(transfer, err)
+}
+
+// sendAttempt offers the file and sends what the recipient doesn't have yet
+func (ft *FileTransfer) sendAttempt(ctx context.Context, transfer *fileTransfer, peerID peer.ID) error {
+	stream, err := ft.protocolManager.OpenStream(peerID, ft.ID())
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	stop := context.AfterFunc(ctx, func() { stream.Reset() })
+	defer stop()
+
+	ft.mutex.Lock()
+	offer := fileOffer{
+		TransferID: transfer.info.ID,
+		Name:       transfer.info.FileName,
+		Size:       transfer.info.FileSize,
+		Hash:       transfer.info.Hash,
+	}
+	path := transfer.info.Path
+	ft.mutex.Unlock()
+
+	stream.SetWriteDeadline(time.Now().Add(fileChunkTimeout))
+	if err := json.NewEncoder(stream).Encode(offer); err != nil {
+		return fmt.Errorf("failed to send file offer: %w", err)
+	}
+
+	// The recipient may ask the user first
+	stream.SetReadDeadline(time.Now().Add(fileConfirmationTimeout + fileChunkTimeout))
+	decoder := json.NewDecoder(stream)
+	var response fileOfferResponse
+	if err := decoder.Decode(&response); err != nil {
+		return fmt.Errorf("failed to read offer response: %w", err)
+	}
+	if !response.Accepted {
+		return fmt.Errorf("%w: %s", ErrTransferRejected, response.Error)
+	}
+	if response.Offset < 0 || response.Offset > offer.Size {
+		return fmt.Errorf("%w: invalid resume offset %d", ErrTransferRejected, response.Offset)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%w: failed to open file: %v", ErrTransferRejected, err)
+	}
+	defer file.Close()
+	if _, err := file.Seek(response.Offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek to resume offset: %w", err)
+	}
+	ft.update(transfer, func(info *TransferInfo) {
+		info.Status = TransferInProgress
+		info.Transferred = response.Offset
+		info.Error = ""
+	})
+
+	buffer := make([]byte, fileChunkSize)
+	for sent := response.Offset; sent < offer.Size; {
+		n, err := io.ReadFull(file, buffer[:min(int64(len(buffer)), offer.Size-sent)])
+		if err != nil {
+			return fmt.Errorf("%w: file changed while sending: %v", ErrTransferRejected, err)
+		}
+		stream.SetWriteDeadline(time.Now().Add(fileChunkTimeout))
+		if err := writeBytes(stream, buffer[:n]); err != nil {
+			return fmt.Errorf("failed to send file data: %w", err)
+		}
+		sent += int64(n)
+		ft.update(transfer, func(info *TransferInfo) { info.Transferred = sent })
+	}
+	if err := stream.CloseWrite(); err != nil {
+		return fmt.Errorf("failed to finish sending: %w", err)
+	}
+
+	stream.SetReadDeadline(time.Now().Add(fileChunkTimeout))
+	var result fileResult
+	if err := decoder.Decode(&result); err != nil {
+		return fmt.Errorf("failed to read transfer result: %w", err)
+	}
+	if !result.OK {
+		return fmt.Errorf("recipient couldn't verify the file: %s", result.Error)
+	}
+	return nil
+}
+
+// Handle receives a file from a paired device
+func (ft *FileTransfer) Handle(stream network.Stream) {
+	defer stream.Close()
+	remotePeer := stream.Conn().RemotePeer()
+
+	if !ft.pairing.IsPaired(remotePeer.String()) {
+		ft.logger.Warn("Rejected file offer from unpaired peer", zap.String("peer_id", remotePeer.String()))
+		stream.Reset()
+		return
+	}
+
+	stream.SetDeadline(time.Now().Add(fileChunkTimeout))
+	var offer fileOffer
+	body, err := readHeader(stream, maxFileOfferSize, &offer)
+	if err != nil {
+		ft.logger.Warn("Failed to read file offer", zap.String("peer_id", remotePeer.String()), zap.Error(err))
+		stream.Reset()
+		return
+	}
+	respond := func(response fileOfferResponse) error {
+		stream.SetWriteDeadline(time.Now().Add(fileChunkTimeout))
+		return json.NewEncoder(stream).Encode(response)
+	}
+
+	transfer := &fileTransfer{
+		info: TransferInfo{
+			ID:         offer.TransferID,
+			FileName:   offer.Name,
+			FileSize:   offer.Size,
+			Hash:       offer.Hash,
+			Sender:     remotePeer.String(),
+			Recipient:  ft.host.ID().String(),
+			StartTime:  time.Now(),
+			IsIncoming: true,
+			Status:     TransferPending,
+		},
+		cancel: func() { stream.Reset() },
+	}
+	if err := ft.checkOffer(offer); err != nil {
+		ft.logger.Warn("Rejected file offer",
+			zap.String("peer_id", remotePeer.String()),
+			zap.String("file", offer.Name),
+			zap.Error(err))
+		transfer.cancel = nil
+		ft.track(transfer)
+		ft.finish(transfer, fmt.Errorf("%w: %v", ErrTransferRejected, err))
+		respond(fileOfferResponse{Error: err.Error()})
+		return
+	}
+	// A transfer cancelled here isn't resumed by the sender's retries
+	ft.mutex.Lock()
+	earlier, ok := ft.transfers[offer.TransferID]
+	cancelled := ok && earlier.cancelled
+	ft.mutex.Unlock()
+	if cancelled {
+		respond(fileOfferResponse{Error: "the transfer was cancelled"})
+		return
+	}
+	ft.track(transfer)
+
+	if !ft.confirm(transfer, remotePeer) {
+		ft.finish(transfer, fmt.Errorf("%w: declined", ErrTransferRejected))
+		respond(fileOfferResponse{Error: "the transfer was declined"})
+		return
+	}
+
+	dir, err := ft.downloadFolder()
+	if err != nil {
+		ft.finish(transfer, err)
+		respond(fileOfferResponse{Error: "the recipient can't store files"})
+		return
+	}
+	partialPath := filepath.Join(dir, "."+offer.Hash[:16]+partialFileSuffix)
+	var offset int64
+	if stat, err := os.Stat(partialPath); err == nil && stat.Size() <= offer.Size {
+		offset = stat.Size()
+	} else {
+		os.Remove(partialPath)
+	}
+
+	if err := respond(fileOfferResponse{Accepted: true, Offset: offset}); err != nil {
+		ft.finish(transfer, fmt.Errorf("failed to accept offer: %w", err))
+		return
+	}
+	ft.update(transfer, func(info *TransferInfo) {
+		info.Status = TransferInProgress
+		info.Transferred = offset
+	})
+	if offset > 0 {
+		ft.logger.Info("Resuming file transfer",
+			zap.String("transfer_id", offer.TransferID),
+			zap.String("file", offer.Name),
+			zap.Int64("offset", offset))
+	}
+
+	if err := ft.receive(stream, body, partialPath, offset, transfer); err != nil {
+		// The partial file is kept so the sender can resume
+		ft.finish(transfer, fmt.Errorf("transfer interrupted: %w", err))
+		return
+	}
+
+	hash, err := hashFile(partialPath)
+	if err != nil || hash != offer.Hash {
+		os.Remove(partialPath)
+		ft.finish(transfer, errors.New("received file doesn't match its SHA-256 hash"))
+		stream.SetWriteDeadline(time.Now().Add(fileChunkTimeout))
+		json.NewEncoder(stream).Encode(fileResult{Error: "SHA-256 mismatch"})
+		return
+	}
+
+	finalPath := uniqueFilePath(dir, offer.Name)
+	if err := os.Rename(partialPath, finalPath); err != nil {
+		ft.finish(transfer, fmt.Errorf("failed to save received file: %w", err))
+		stream.SetWriteDeadline(time.Now().Add(fileChunkTimeout))
+		json.NewEncoder(stream).Encode(fileResult{Error: "the recipient couldn't save the file"})
+		return
+	}
+
+	ft.update(transfer, func(info *TransferInfo) { info.Path = finalPath })
+	ft.finish(transfer, nil)
+	stream.SetWriteDeadline(time.Now().Add(fileChunkTimeout))
+	json.NewEncoder(stream).Encode(fileResult{OK: true})
+
+	ft.logger.Info("Received file from paired device",
+		zap.String("peer_id", remotePeer.String()),
+		zap.String("path", finalPath),
+		zap.Int64("size", offer.Size))
+}
+
+// receive appends the rest of a file to its partial file
+func (ft *FileTransfer) receive(stream network.Stream, body io.Reader, partialPath string, offset int64, transfer *fileTransfer) error {
+	file, err := os.OpenFile(partialPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open partial file: %w", err)
+	}
+	defer file.Close()
+
+	buffer := make([]byte, fileChunkSize)
+	for received := offset; received < transfer.info.FileSize; {
+		stream.SetReadDeadline(time.Now().Add(fileChunkTimeout))
+		n, readErr := io.ReadFull(body, buffer[:min(int64(len(buffer)), transfer.info.FileSize-received)])
+		if n > 0 {
+			if _, err := file.Write(buffer[:n]); err != nil {
+				return fmt.Errorf("failed to write partial file: %w", err)
+			}
+			received += int64(n)
+			ft.update(transfer, func(info *TransferInfo) { info.Transferred = received })
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+	return nil
+}
+
+// checkOffer validates an offer against the file sharing settings
+func (ft *FileTransfer) checkOffer(offer fileOffer) error {
+	if !ft.config.EnableFileSharing {
+		return ErrFileSharingDisabled
+	}
+	if offer.TransferID == "" || len(offer.TransferID) > 64 {
+		return errors.New("invalid transfer ID")
+	}
+	if offer.Name == "" || offer.Name == "." || offer.Name == ".." || filepath.Base(offer.Name) != offer.Name || strings.ContainsAny(offer.Name, `/\`) {
+		return fmt.Errorf("invalid file name %q", offer.Name)
+	}
+	if offer.Size < 0 {
+		return errors.New("invalid file size")
+	}
+	if limit := ft.maxFileSize(); limit > 0 && offer.Size > limit {
+		return fmt.Errorf("%w of %d MB", ErrFileTooLarge, ft.config.MaxFileSizeMB)
+	}
+	if decoded, err := hex.DecodeString(offer.Hash); err != nil || len(decoded) != sha256.Size {
+		return errors.New("invalid SHA-256 hash")
+	}
+	return nil
+}
+
+// confirm reports whether an incoming transfer may go ahead. Offers are
+// accepted without asking when confirmation is off, when the sender is in
+// AutoAcceptFromPeers, or when the user already accepted an interrupted transfer.
+func (ft *FileTransfer) confirm(transfer *fileTransfer, from peer.ID) bool {
+	if !ft.config.RequireFileConfirmation || ft.autoAccept[from.String()] {
+		return true
+	}
+
+	ft.mutex.Lock()
+	if ft.accepted[transfer.info.ID] {
+		ft.mutex.Unlock()
+		return true
+	}
+	decision := make(chan bool, 1)
+	transfer.decision = decision
+	ft.mutex.Unlock()
+
+	fields := []zap.Field{
+		zap.String("transfer_id", transfer.info.ID),
+		zap.String("file", transfer.info.FileName),
+		zap.Int64("size", transfer.info.FileSize),
+		zap.String("peer_id", from.String()),
+	}
+	if device, ok := ft.pairing.GetPairedDevice(from.String()); ok {
+		fields = append(fields, zap.String("device", device.DeviceName))
+	}
+	ft.logger.Info("File offer waiting for confirmation, accept it with 'file accept'", fields...)
+
+	var accepted bool
+	select {
+	case accepted = <-decision:
+	case <-time.After(fileConfirmationTimeout):
+	case <-ft.ctx.Done():
+	}
+
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+	transfer.decision = nil
+	if accepted {
+		ft.accepted[transfer.info.ID] = true
+	}
+	return accepted
+}
+
+// Respond accepts or declines an incoming offer waiting for confirmation
+func (ft *FileTransfer) Respond(id string, accept bool) error {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	transfer, ok := ft.transfers[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTransferNotFound, id)
+	}
+	if transfer.decision == nil {
+		return fmt.Errorf("transfer %s is not waiting for confirmation", id)
+	}
+	transfer.decision <- accept
+	transfer.decision = nil
+	return nil
+}
+
+// Cancel stops a transfer, or declines it if it waits for confirmation
+func (ft *FileTransfer) Cancel(id string) error {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	transfer, ok := ft.transfers[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTransferNotFound, id)
+	}
+	if transfer.info.Finished() {
+		return fmt.Errorf("transfer %s has already %s", id, transfer.info.Status)
+	}
+	transfer.cancelled = true
+	if transfer.decision != nil {
+		transfer.decision <- false
+		transfer.decision = nil
+	}
+	if transfer.cancel != nil {
+		transfer.cancel()
+	}
+	return nil
+}
+
+// Transfers returns the running and recent transfers, newest first
+func (ft *FileTransfer) Transfers() []TransferInfo {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	transfers := make([]TransferInfo, 0, len(ft.transfers))
+	for _, transfer := range ft.transfers {
+		transfers = append(transfers, transfer.info)
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].StartTime.After(transfers[j].StartTime)
+	})
+	return transfers
+}
+
+// track adds a transfer, replacing an earlier attempt with the same ID, and
+// returns a copy of its info
+func (ft *FileTransfer) track(transfer *fileTransfer) TransferInfo {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	ft.transfers[transfer.info.ID] = transfer
+	if len(ft.transfers) > maxRememberedTransfers {
+		ft.pruneTransfers()
+	}
+	return transfer.info
+}
+
+// pruneTransfers forgets the oldest finished transfers. The caller holds the lock.
+func (ft *FileTransfer) pruneTransfers() {
+	var finished []*fileTransfer
+	for _, transfer := range ft.transfers {
+		if transfer.info.Finished() {
+			finished = append(finished, transfer)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].info.StartTime.Before(finished[j].info.StartTime)
+	})
+	for _, transfer := range finished {
+		if len(ft.transfers) <= maxRememberedTransfers {
+			break
+		}
+		delete(ft.transfers, transfer.info.ID)
+	}
+}
+
+// update changes a transfer's info under the lock
+func (ft *FileTransfer) update(transfer *fileTransfer, change func(info *TransferInfo)) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+	change(&transfer.info)
+}
+
+// finish records how a transfer ended
+func (ft *FileTransfer) finish(transfer *fileTransfer, err error) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	transfer.cancel = nil
+	switch {
+	case transfer.cancelled:
+		transfer.info.Status = TransferCancelled
+	case err != nil:
+		transfer.info.Status = TransferFailed
+		transfer.info.Error = err.Error()
+	default:
+		transfer.info.Status = TransferCompleted
+		transfer.info.Error = ""
+		delete(ft.accepted, transfer.info.ID)
+	}
+
+	if err == nil || transfer.cancelled {
+		return
+	}
+	fields := []zap.Field{
+		zap.String("transfer_id", transfer.info.ID),
+		zap.String("file", transfer.info.FileName),
+		zap.Bool("incoming", transfer.info.IsIncoming),
+		zap.Error(err),
+	}
+	if errors.Is(err, ErrTransferRejected) {
+		ft.logger.Info("File transfer was rejected", fields...)
+	} else {
+		ft.logger.Warn("File transfer failed", fields...)
+	}
+}
+
+// maxFileSize returns the largest file that may be transferred in bytes, 0 for no limit
+func (ft *FileTransfer) maxFileSize() int64 {
+	return int64(ft.config.MaxFileSizeMB) * 1024 * 1024
+}
+
+// downloadFolder returns the folder received files are saved to, creating it if needed
+func (ft *FileTransfer) downloadFolder() (string, error) {
+	dir := ft.config.DefaultDownloadFolder
+	if dir == "" || strings.HasPrefix(dir, "~") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find download folder: %w", err)
+		}
+		if dir == "" {
+			dir = filepath.Join(home, "Downloads", "Clipman")
+		} else {
+			dir = filepath.Join(home, strings.TrimPrefix(dir, "~"))
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create download folder: %w", err)
+	}
+	return dir, nil
+}
+
+// hashFile returns the hex SHA-256 of a file
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to hash file: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// uniqueFilePath returns a path for a file in dir that doesn't exist yet,
+// numbering the name if needed
+func uniqueFilePath(dir, name string) string {
+	path := filepath.Join(dir, name)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", base, i, ext))
+	}
+}
@@ -0,0 +1,248 @@
+package sync
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFileOfferChecks(t *testing.T) {
+	ft := &FileTransfer{
+		config: &SyncConfig{EnableFileSharing: true, MaxFileSizeMB: 1},
+		logger: zap.NewNop(),
+	}
+	valid := fileOffer{TransferID: "t1", Name: "report.pdf", Size: 1024, Hash: strings.Repeat("ab", 32)}
+	if err := ft.checkOffer(valid); err != nil {
+		t.Fatalf("valid offer rejected: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(*fileOffer)
+	}{
+		{"path in name", func(o *fileOffer) { o.Name = "../.ssh/authorized_keys" }},
+		{"dot name", func(o *fileOffer) { o.Name = ".." }},
+		{"backslash", func(o *fileOffer) { o.Name = `..\evil` }},
+		{"no transfer ID", func(o *fileOffer) { o.TransferID = "" }},
+		{"negative size", func(o *fileOffer) { o.Size = -1 }},
+		{"too large", func(o *fileOffer) { o.Size = 2 * 1024 * 1024 }},
+		{"short hash", func(o *fileOffer) { o.Hash = "abcd" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offer := valid
+			tt.change(&offer)
+			if err := ft.checkOffer(offer); err == nil {
+				t.Errorf("offer %+v was accepted", offer)
+			}
+		})
+	}
+
+	ft.config.EnableFileSharing = false
+	if err := ft.checkOffer(valid); !errors.Is(err, ErrFileSharingDisabled) {
+		t.Errorf("sharing disabled: error = %v, want %v", err, ErrFileSharingDisabled)
+	}
+}
+
+func TestUniqueFilePath(t *testing.T) {
+	dir := t.TempDir()
+	if got := uniqueFilePath(dir, "notes.txt"); got != filepath.Join(dir, "notes.txt") {
+		t.Errorf("free name = %s", got)
+	}
+
+	os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "notes (1).txt"), nil, 0644)
+	if got := uniqueFilePath(dir, "notes.txt"); got != filepath.Join(dir, "notes (2).txt") {
+		t.Errorf("taken name = %s, want notes (2).txt", got)
+	}
+}
+
+// newTestFileTransfers creates a sender and a recipient of files on a mock network
+func newTestFileTransfers(t *testing.T, link mocknet.LinkOptions, recipientLogger *zap.Logger) (mocknet.Mocknet, *testPeer, *testPeer, *FileTransfer, *FileTransfer) {
+	t.Helper()
+	delay := fileRetryDelay
+	fileRetryDelay = 10 * time.Millisecond
+	t.Cleanup(func() { fileRetryDelay = delay })
+
+	mn, peers := newTestPeers(t, 2, link)
+	sender, recipient := peers[0], peers[1]
+	senderFiles := NewFileTransfer(sender.ctx, sender.host, sender.protocols, sender.pairing, sender.config, zap.NewNop())
+	recipientFiles := NewFileTransfer(recipient.ctx, recipient.host, recipient.protocols, recipient.pairing, recipient.config, recipientLogger)
+	sender.start(t)
+	recipient.start(t)
+	return mn, sender, recipient, senderFiles, recipientFiles
+}
+
+// writeTestFile writes size random bytes to a file and returns its path and data
+func writeTestFile(t *testing.T, name string, size int) (string, []byte) {
+	t.Helper()
+	data := make([]byte, size)
+	rand.Read(data)
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+// transferInfo returns a transfer by ID
+func transferInfo(ft *FileTransfer, id string) (TransferInfo, bool) {
+	for _, info := range ft.Transfers() {
+		if info.ID == id {
+			return info, true
+		}
+	}
+	return TransferInfo{}, false
+}
+
+// waitForTransfer waits until a transfer finishes and returns it
+func waitForTransfer(t *testing.T, ft *FileTransfer, id string) TransferInfo {
+	t.Helper()
+	var info TransferInfo
+	waitFor(t, "transfer "+id+" to finish", func() bool {
+		var ok bool
+		info, ok = transferInfo(ft, id)
+		return ok && info.Finished()
+	})
+	return info
+}
+
+// downloadedFiles lists the files in a download folder
+func downloadedFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestFileTransferConfirmation(t *testing.T) {
+	_, _, recipient, senderFiles, recipientFiles := newTestFileTransfers(t, mocknet.LinkOptions{}, zap.NewNop())
+	recipient.config.RequireFileConfirmation = true
+
+	path, data := writeTestFile(t, "report.pdf", 200*1024)
+	sent, err := senderFiles.SendFile(recipient.id(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The offer waits until the user accepts it
+	waitFor(t, "the offer to wait for confirmation", func() bool {
+		return recipientFiles.Respond(sent.ID, true) == nil
+	})
+	if info := waitForTransfer(t, senderFiles, sent.ID); info.Status != TransferCompleted {
+		t.Fatalf("accepted transfer %s: %s", info.Status, info.Error)
+	}
+	received := waitForTransfer(t, recipientFiles, sent.ID)
+	if received.Status != TransferCompleted || received.Path != filepath.Join(recipient.config.DefaultDownloadFolder, "report.pdf") {
+		t.Fatalf("recipient transfer = %+v", received)
+	}
+	if got, err := os.ReadFile(received.Path); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("received file differs from the sent one (%v)", err)
+	}
+
+	// A declined offer fails without retries and leaves nothing behind
+	path, _ = writeTestFile(t, "unwanted.bin", 1024)
+	declined, err := senderFiles.SendFile(recipient.id(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the second offer to wait for confirmation", func() bool {
+		return recipientFiles.Respond(declined.ID, false) == nil
+	})
+	info := waitForTransfer(t, senderFiles, declined.ID)
+	if info.Status != TransferFailed || !strings.Contains(info.Error, "declined") {
+		t.Errorf("declined transfer = %s: %s", info.Status, info.Error)
+	}
+	if files := downloadedFiles(t, recipient.config.DefaultDownloadFolder); len(files) != 1 {
+		t.Errorf("download folder holds %v after a declined offer", files)
+	}
+}
+
+func TestFileTransferResumesAfterDisconnect(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	// Slow enough to break the connection mid-transfer
+	mn, sender, recipient, senderFiles, recipientFiles := newTestFileTransfers(t, mocknet.LinkOptions{Bandwidth: 256 * 1024}, zap.New(core))
+
+	path, data := writeTestFile(t, "video.mp4", 512*1024)
+	sent, err := senderFiles.SendFile(recipient.id(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "part of the file to arrive", func() bool {
+		info, ok := transferInfo(recipientFiles, sent.ID)
+		return ok && info.Transferred >= fileChunkSize
+	})
+	if err := mn.DisconnectPeers(sender.id(), recipient.id()); err != nil {
+		t.Fatal(err)
+	}
+
+	if info := waitForTransfer(t, senderFiles, sent.ID); info.Status != TransferCompleted {
+		t.Fatalf("resumed transfer %s: %s", info.Status, info.Error)
+	}
+	received := waitForTransfer(t, recipientFiles, sent.ID)
+	if got, err := os.ReadFile(received.Path); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("resumed file differs from the sent one (%v)", err)
+	}
+
+	// The second attempt continued from the partial file
+	resumed := logs.FilterMessage("Resuming file transfer").All()
+	if len(resumed) == 0 {
+		t.Fatal("transfer started over instead of resuming")
+	}
+	offset := resumed[0].ContextMap()["offset"].(int64)
+	if offset < fileChunkSize || offset >= int64(len(data)) {
+		t.Errorf("resumed from offset %d", offset)
+	}
+	if files := downloadedFiles(t, recipient.config.DefaultDownloadFolder); len(files) != 1 || files[0] != "video.mp4" {
+		t.Errorf("download folder holds %v", files)
+	}
+}
+
+func TestFileTransferRejectsCorruptedData(t *testing.T) {
+	_, _, recipient, senderFiles, recipientFiles := newTestFileTransfers(t, mocknet.LinkOptions{}, zap.NewNop())
+	recipient.config.RequireFileConfirmation = true
+
+	path, data := writeTestFile(t, "notes.txt", 3*fileChunkSize)
+	sent, err := senderFiles.SendFile(recipient.id(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt a chunk after the hash was taken and offered
+	data[fileChunkSize+10] ^= 0xff
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the offer to wait for confirmation", func() bool {
+		return recipientFiles.Respond(sent.ID, true) == nil
+	})
+
+	info := waitForTransfer(t, senderFiles, sent.ID)
+	if info.Status != TransferFailed || !strings.Contains(info.Error, "couldn't verify") {
+		t.Errorf("corrupted transfer = %s: %s", info.Status, info.Error)
+	}
+	received := waitForTransfer(t, recipientFiles, sent.ID)
+	if received.Status != TransferFailed || !strings.Contains(received.Error, "SHA-256") {
+		t.Errorf("recipient transfer = %s: %s", received.Status, received.Error)
+	}
+	// Neither the file nor its partial copy is kept
+	if files := downloadedFiles(t, recipient.config.DefaultDownloadFolder); len(files) != 0 {
+		t.Errorf("download folder holds %v after a hash mismatch", files)
+	}
+}
@@ -0,0 +1,230 @@
+// Package sync provides clipboard synchronization using libp2p
+package sync
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"go.uber.org/zap"
+)
+
+// maxTrackedRejections bounds the number of rejected peers remembered for rate limiting
+const maxTrackedRejections = 1024
+
+// rejectionWarnInterval is how often a rejected peer is logged as a warning,
+// rejections in between are logged at debug level and counted
+const rejectionWarnInterval = 10 * time.Minute
+
+// PeerGater admits only paired and trusted peers when AllowOnlyKnownPeers is
+// set, and never revoked ones. It gates connections as a libp2p connection
+// gater and topic membership as a pubsub peer filter.
+type PeerGater struct {
+	enabled bool
+	trusted map[peer.ID]bool // Peers listed in TrustedPeers
+	infra   map[peer.ID]bool // Bootstrap peers, which may connect but not join topics
+	pairing *PairingManager
+	denied  *DenyList
+	logger  *zap.Logger
+
+	mutex      sync.Mutex
+	admitted   map[peer.ID]time.Time // Peers admitted until a time, e.g. while pairing with them
+	rejections map[peer.ID]*peerRejections
+}
+
+// peerRejections tracks how often a peer was rejected, to rate-limit warnings
+type peerRejections struct {
+	lastWarned time.Time
+	suppressed int
+}
+
+// NewPeerGater creates a gater from the sync configuration
+func NewPeerGater(cfg *SyncConfig, logger *zap.Logger) *PeerGater {
+	gater := &PeerGater{
+		enabled:    cfg.AllowOnlyKnownPeers,
+		trusted:    make(map[peer.ID]bool),
+		infra:      make(map[peer.ID]bool),
+		logger:     logger.With(zap.String("component", "peer-gater")),
+		admitted:   make(map[peer.ID]time.Time),
+		rejections: make(map[peer.ID]*peerRejections),
+	}
+
+	for _, id := range cfg.TrustedPeers {
+		peerID, err := peer.Decode(id)
+		if err != nil {
+			gater.logger.Warn("Ignoring invalid trusted peer ID", zap.String("peer_id", id), zap.Error(err))
+			continue
+		}
+		gater.trusted[peerID] = true
+	}
+
+	bootstrapPeers := append(append([]string{}, cfg.BootstrapPeers...), cfg.DHTBootstrapPeers...)
+	for _, addr := range bootstrapPeers {
+		if info, err := peer.AddrInfoFromString(addr); err == nil {
+			gater.infra[info.ID] = true
+		}
+	}
+	return gater
+}
+
+// SetPairing sets the pairing manager whose paired devices are admitted
+func (g *PeerGater) SetPairing(pairing *PairingManager) {
+	g.pairing = pairing
+}
+
+// SetDenyList sets the deny list whose revoked devices are rejected
+func (g *PeerGater) SetDenyList(denied *DenyList) {
+	g.denied = denied
+}
+
+// Enabled reports whether only known peers are admitted
+func (g *PeerGater) Enabled() bool {
+	return g.enabled
+}
+
+// Admit lets a peer connect for a while, e.g. the device a pairing request is sent to
+func (g *PeerGater) Admit(id peer.ID, d time.Duration) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.admitted[id] = time.Now().Add(d)
+}
+
+// IsRevoked reports whether a peer is on the deny list
+func (g *PeerGater) IsRevoked(id peer.ID) bool {
+	return g.denied != nil && g.denied.Contains(id.String())
+}
+
+// IsKnown reports whether a peer is paired, trusted or temporarily admitted,
+// and not revoked
+func (g *PeerGater) IsKnown(id peer.ID) bool {
+	if g.IsRevoked(id) {
+		return false
+	}
+	if !g.enabled || g.trusted[id] {
+		return true
+	}
+	if g.pairing != nil && g.pairing.IsPaired(id.String()) {
+		return true
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	until, ok := g.admitted[id]
+	if ok && time.Now().After(until) {
+		delete(g.admitted, id)
+		return false
+	}
+	return ok
+}
+
+// allowConnection reports whether a peer may connect. While pairing mode is
+// on any peer may connect, so that new devices can send pairing requests;
+// they still can't join topics until paired. Revoked peers may never connect.
+func (g *PeerGater) allowConnection(id peer.ID) bool {
+	if g.IsRevoked(id) {
+		return false
+	}
+	if g.IsKnown(id) || g.infra[id] {
+		return true
+	}
+	return g.pairing != nil && g.pairing.IsPairingEnabled()
+}
+
+// InterceptPeerDial implements connmgr.ConnectionGater
+func (g *PeerGater) InterceptPeerDial(id peer.ID) bool {
+	if g.allowConnection(id) {
+		return true
+	}
+	g.logRejection(id, "outbound connection")
+	return false
+}
+
+// InterceptAddrDial implements connmgr.ConnectionGater, peers are checked in InterceptPeerDial
+func (g *PeerGater) InterceptAddrDial(peer.ID, multiaddr.Multiaddr) bool {
+	return true
+}
+
+// InterceptAccept implements connmgr.ConnectionGater, the peer isn't known until the connection is secured
+func (g *PeerGater) InterceptAccept(network.ConnMultiaddrs) bool {
+	return true
+}
+
+// InterceptSecured implements connmgr.ConnectionGater
+func (g *PeerGater) InterceptSecured(direction network.Direction, id peer.ID, addrs network.ConnMultiaddrs) bool {
+	if g.allowConnection(id) {
+		return true
+	}
+	g.logRejection(id, strings.ToLower(direction.String())+" connection from "+addrs.RemoteMultiaddr().String())
+	return false
+}
+
+// InterceptUpgraded implements connmgr.ConnectionGater
+func (g *PeerGater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
+
+// AllowTopic is a pubsub peer filter admitting only known peers to group topics
+func (g *PeerGater) AllowTopic(id peer.ID, topic string) bool {
+	if g.IsKnown(id) {
+		return true
+	}
+	g.logRejection(id, "topic "+topic)
+	return false
+}
+
+// logRejection logs a rejected peer, as a warning at most once per interval per peer
+func (g *PeerGater) logRejection(id peer.ID, what string) {
+	g.mutex.Lock()
+	rejections, ok := g.rejections[id]
+	if !ok {
+		g.pruneRejections()
+		rejections = &peerRejections{}
+		g.rejections[id] = rejections
+	}
+	now := time.Now()
+	warn := now.Sub(rejections.lastWarned) >= rejectionWarnInterval
+	suppressed := rejections.suppressed
+	if warn {
+		rejections.lastWarned = now
+		rejections.suppressed = 0
+	} else {
+		rejections.suppressed++
+	}
+	g.mutex.Unlock()
+
+	revoked := g.IsRevoked(id)
+	if !warn {
+		if revoked {
+			g.logger.Debug("Rejected revoked peer", zap.String("peer_id", id.String()), zap.String("rejected", what))
+		} else {
+			g.logger.Debug("Rejected unknown peer", zap.String("peer_id", id.String()), zap.String("rejected", what))
+		}
+		return
+	}
+	fields := []zap.Field{zap.String("peer_id", id.String()), zap.String("rejected", what)}
+	if suppressed > 0 {
+		fields = append(fields, zap.Int("rejected_since_last_warning", suppressed))
+	}
+	if revoked {
+		g.logger.Warn("Rejected revoked peer", fields...)
+		return
+	}
+	g.logger.Warn("Rejected unknown peer, only paired and trusted peers are allowed", fields...)
+}
+
+// pruneRejections forgets peers not warned about recently once too many are
+// tracked. The caller holds the lock.
+func (g *PeerGater) pruneRejections() {
+	if len(g.rejections) < maxTrackedRejections {
+		return
+	}
+	for id, rejections := range g.rejections {
+		if time.Since(rejections.lastWarned) >= rejectionWarnInterval {
+			delete(g.rejections, id)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package sync
+
+import (
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+)
+
+func newTestPeerID(t *testing.T) peer.ID {
+	t.Helper()
+	_, pub, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestPeerGater(t *testing.T) {
+	trusted := newTestPeerID(t)
+	stranger := newTestPeerID(t)
+
+	open := NewPeerGater(&SyncConfig{}, zap.NewNop())
+	if !open.InterceptPeerDial(stranger) || !open.AllowTopic(stranger, "clipman-default") {
+		t.Error("gater rejected a peer while AllowOnlyKnownPeers is off")
+	}
+
+	gater := NewPeerGater(&SyncConfig{
+		AllowOnlyKnownPeers: true,
+		TrustedPeers:        []string{trusted.String(), "not-a-peer-id"},
+	}, zap.NewNop())
+
+	if !gater.InterceptPeerDial(trusted) || !gater.AllowTopic(trusted, "clipman-default") {
+		t.Error("trusted peer was rejected")
+	}
+	if gater.InterceptPeerDial(stranger) || gater.AllowTopic(stranger, "clipman-default") {
+		t.Error("unknown peer was admitted")
+	}
+
+	gater.Admit(stranger, time.Hour)
+	if !gater.InterceptPeerDial(stranger) {
+		t.Error("admitted peer was rejected")
+	}
+	gater.Admit(stranger, -time.Second)
+	if gater.InterceptPeerDial(stranger) {
+		t.Error("peer was admitted after its admission expired")
+	}
+}
+
+func TestPeerGaterRateLimitsWarnings(t *testing.T) {
+	gater := NewPeerGater(&SyncConfig{AllowOnlyKnownPeers: true}, zap.NewNop())
+	stranger := newTestPeerID(t)
+
+	for i := 0; i < 5; i++ {
+		gater.InterceptPeerDial(stranger)
+	}
+	rejections := gater.rejections[stranger]
+	if rejections == nil || rejections.suppressed != 4 {
+		t.Fatalf("rejections = %+v, want the first warned and 4 suppressed", rejections)
+	}
+}
+
+func TestPeerGaterDeniesRevokedPeers(t *testing.T) {
+	revoked := newTestPeerID(t)
+	denied := NewDenyList("", zap.NewNop())
+	if err := denied.Add(RevokedPeer{PeerID: revoked.String(), RevokedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Revoked peers are rejected whether or not only known peers are
+	// allowed, even when trusted
+	for _, cfg := range []*SyncConfig{{}, {AllowOnlyKnownPeers: true, TrustedPeers: []string{revoked.String()}}} {
+		gater := NewPeerGater(cfg, zap.NewNop())
+		gater.SetDenyList(denied)
+		gater.Admit(revoked, time.Hour)
+		if gater.InterceptPeerDial(revoked) || gater.AllowTopic(revoked, "clipman-default") {
+			t.Errorf("revoked peer was admitted with AllowOnlyKnownPeers %v", cfg.AllowOnlyKnownPeers)
+		}
+	}
+}
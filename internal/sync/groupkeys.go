@@ -0,0 +1,399 @@
+// Package sync provides clipboard synchronization using libp2p
+package sync
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// GroupKey is the symmetric key group members encrypt clipboard payloads with.
+// The epoch increases each time the key is rotated, e.g. to remove a member.
+// Members lists the devices the key is handed to; a device only hands a
+// key to the members it lists, so groups are kept apart.
+type GroupKey struct {
+	Group   string    `json:"group"`
+	Epoch   uint32    `json:"epoch"`
+	Key     []byte    `json:"key"`
+	Created time.Time `json:"created"`
+	Members []string  `json:"members,omitempty"` // Peer IDs of the devices in the group
+}
+
+// precedes reports whether the key wins over another key of the same group
+// and epoch, as when two devices rotated it at the same time. Every device
+// picks the key with the lowest hash, so they agree on one.
+func (k GroupKey) precedes(other GroupKey) bool {
+	hash, otherHash := sha256.Sum256(k.Key), sha256.Sum256(other.Key)
+	return bytes.Compare(hash[:], otherHash[:]) < 0
+}
+
+// GroupKeyring holds the current key of each group, persisted to disk so
+// the daemon sees keys received by the pair command
+type GroupKeyring struct {
+	file   *jsonFile
+	logger *zap.Logger
+	self   string // Peer ID of this device, listed as a member of its groups
+
+	mutex sync.RWMutex
+	keys  map[string]GroupKey
+}
+
+// NewGroupKeyring creates a keyring stored at path and loads any saved keys
+func NewGroupKeyring(path string, logger *zap.Logger) *GroupKeyring {
+	keyring := &GroupKeyring{
+		file:   newJSONFile(path, "group keys"),
+		logger: logger.With(zap.String("component", "group-keyring")),
+		keys:   make(map[string]GroupKey),
+	}
+	if err := keyring.refresh(); err != nil {
+		keyring.logger.Warn("Failed to load group keys", zap.Error(err))
+	}
+	return keyring
+}
+
+// SetSelf sets the peer ID of this device, a member of the groups it has keys for
+func (k *GroupKeyring) SetSelf(peerID string) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.self = peerID
+}
+
+// Current returns the current key of a group
+func (k *GroupKeyring) Current(group string) (GroupKey, bool) {
+	k.reload()
+
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	key, ok := k.keys[group]
+	return key, ok
+}
+
+// KeysFor returns the current keys of the groups a device is a member of
+func (k *GroupKeyring) KeysFor(peerID string) []GroupKey {
+	var keys []GroupKey
+	for _, key := range k.Keys() {
+		if containsString(key.Members, peerID) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Keys returns the current key of every group
+func (k *GroupKeyring) Keys() []GroupKey {
+	k.reload()
+
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	keys := make([]GroupKey, 0, len(k.keys))
+	for _, key := range k.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Group < keys[j].Group })
+	return keys
+}
+
+// Ensure returns the key of a group, creating the first epoch if the group has none
+func (k *GroupKeyring) Ensure(group string) (GroupKey, error) {
+	if key, ok := k.Current(group); ok {
+		return key, nil
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	var key GroupKey
+	created := false
+	err := k.update(func() error {
+		var ok bool
+		if key, ok = k.keys[group]; ok {
+			return errUnchanged
+		}
+		var err error
+		if key, err = newGroupKey(group, 1); err != nil {
+			return err
+		}
+		key.Members = k.withSelf(nil)
+		k.keys[group] = key
+		created = true
+		return nil
+	})
+	if err != nil {
+		return GroupKey{}, err
+	}
+	if created {
+		k.logger.Info("Created group key", zap.String("group", group))
+	}
+	return key, nil
+}
+
+// Grant makes a paired device a member of a group, it receives the group
+// key when it next connects. It reports whether the device wasn't a member yet.
+func (k *GroupKeyring) Grant(group string, peerID string) (bool, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	granted := false
+	err := k.update(func() error {
+		key, ok := k.keys[group]
+		if !ok {
+			return fmt.Errorf("%w %q", ErrNoGroupKey, group)
+		}
+		if containsString(key.Members, peerID) {
+			return errUnchanged
+		}
+		// A key created while the daemon was stopped doesn't list this device yet
+		key.Members = k.withSelf(append(key.Members, peerID))
+		k.keys[group] = key
+		granted = true
+		return nil
+	})
+	return granted, err
+}
+
+// Rotate replaces the key of a group with a new one in the next epoch,
+// handed to the same members but the removed ones. Messages encrypted with
+// earlier epochs are rejected from then on.
+func (k *GroupKeyring) Rotate(group string, removed ...string) (GroupKey, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	var key GroupKey
+	err := k.update(func() error {
+		current := k.keys[group]
+		var err error
+		if key, err = newGroupKey(group, current.Epoch+1); err != nil {
+			return err
+		}
+		for _, member := range current.Members {
+			if !containsString(removed, member) {
+				key.Members = append(key.Members, member)
+			}
+		}
+		key.Members = k.withSelf(key.Members)
+		k.keys[group] = key
+		return nil
+	})
+	if err != nil {
+		return GroupKey{}, err
+	}
+	k.logger.Info("Rotated group key",
+		zap.String("group", group),
+		zap.Uint32("epoch", key.Epoch),
+		zap.Strings("removed", removed))
+	return key, nil
+}
+
+// keyImport reports what importing group keys changed
+type keyImport struct {
+	changed []string // Groups whose key or members changed
+	behind  []string // Groups the sender has an earlier or losing key of
+	rotated []string // Groups rotated again to settle conflicting rotations
+}
+
+// Import stores keys received from a paired device. A group's key is only
+// taken from a device that is a member of it here, or for a group this
+// device has no key for yet. Keys never go back to an earlier epoch: a key
+// from a later epoch replaces the current one, and of two keys in the same
+// epoch the one that precedes wins. With adopt set, as when pairing, the
+// sending device is taken as a member of its groups.
+func (k *GroupKeyring) Import(keys []GroupKey, from string, adopt bool) (keyImport, error) {
+	for _, key := range keys {
+		if key.Group == "" || len(key.Key) != chacha20poly1305.KeySize {
+			return keyImport{}, fmt.Errorf("invalid key for group %q", key.Group)
+		}
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	var result keyImport
+	err := k.update(func() error {
+		result = keyImport{}
+		for _, key := range keys {
+			current, exists := k.keys[key.Group]
+			if exists && !adopt && !containsString(current.Members, from) {
+				k.logger.Warn("Ignored group key from a device that isn't a member",
+					zap.String("group", key.Group),
+					zap.String("peer_id", from))
+				continue
+			}
+
+			merged, taken, err := k.merge(current, exists, key, from, adopt)
+			if err != nil {
+				return err
+			}
+			if !taken {
+				result.behind = append(result.behind, key.Group)
+			}
+			if exists && merged.Epoch > current.Epoch && merged.Epoch > key.Epoch {
+				result.rotated = append(result.rotated, key.Group)
+			}
+			if exists && bytes.Equal(merged.Key, current.Key) && sameMembers(merged.Members, current.Members) {
+				continue
+			}
+			k.keys[key.Group] = merged
+			result.changed = append(result.changed, key.Group)
+		}
+		if len(result.changed) == 0 {
+			return errUnchanged
+		}
+		return nil
+	})
+	if err != nil {
+		return keyImport{}, err
+	}
+	if len(result.changed) > 0 {
+		k.logger.Info("Imported group keys", zap.Strings("groups", result.changed))
+	}
+	if len(result.rotated) > 0 {
+		k.logger.Info("Rotated group keys to settle conflicting rotations", zap.Strings("groups", result.rotated))
+	}
+	return result, nil
+}
+
+// merge returns the key a group has after receiving a key from a device,
+// and whether the received key was taken; if not, the sender is behind.
+// The members of one key add up, with this device and the sender. Two keys
+// of the same epoch come from rotations made at the same time: only the
+// members both list are kept, so a member either rotation removed stays
+// removed, and if the winning key was handed to a member that isn't kept,
+// it is rotated once more. Adopting keeps the members of both. The caller
+// holds the lock.
+func (k *GroupKeyring) merge(current GroupKey, exists bool, received GroupKey, from string, adopt bool) (GroupKey, bool, error) {
+	switch {
+	case !exists, received.Epoch > current.Epoch:
+		received.Members = k.withSelf(unionStrings(received.Members, []string{from}))
+		return received, true, nil
+	case received.Epoch < current.Epoch:
+		current.Members = k.withSelf(unionStrings(current.Members, []string{from}))
+		return current, false, nil
+	case bytes.Equal(received.Key, current.Key):
+		current.Members = k.withSelf(unionStrings(current.Members, unionStrings(received.Members, []string{from})))
+		return current, true, nil
+	}
+
+	winner, taken := received, true
+	if current.precedes(received) {
+		winner, taken = current, false
+	}
+	if adopt {
+		winner.Members = k.withSelf(unionStrings(current.Members, unionStrings(received.Members, []string{from})))
+		return winner, taken, nil
+	}
+
+	var members []string
+	for _, member := range k.withSelf(current.Members) {
+		if member == from || member == k.self || containsString(received.Members, member) {
+			members = append(members, member)
+		}
+	}
+	handed := winner.Members
+	if taken {
+		handed = unionStrings(handed, []string{from})
+	} else {
+		handed = k.withSelf(handed)
+	}
+	if sameMembers(members, handed) {
+		winner.Members = members
+		return winner, taken, nil
+	}
+	rotated, err := newGroupKey(winner.Group, winner.Epoch+1)
+	if err != nil {
+		return GroupKey{}, false, err
+	}
+	rotated.Members = members
+	return rotated, false, nil
+}
+
+// withSelf adds this device to a list of members. The caller holds the lock.
+func (k *GroupKeyring) withSelf(members []string) []string {
+	if k.self == "" {
+		return members
+	}
+	return unionStrings(members, []string{k.self})
+}
+
+// unionStrings returns the strings of both lists, each once
+func unionStrings(a, b []string) []string {
+	union := append([]string(nil), a...)
+	for _, s := range b {
+		if !containsString(union, s) {
+			union = append(union, s)
+		}
+	}
+	return union
+}
+
+// sameMembers reports whether two member lists hold the same devices
+func sameMembers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, member := range a {
+		if !containsString(b, member) {
+			return false
+		}
+	}
+	return true
+}
+
+// reload picks up keys saved by another process, such as the pair command
+func (k *GroupKeyring) reload() {
+	if err := k.refresh(); err != nil {
+		k.logger.Warn("Failed to reload group keys", zap.Error(err))
+	}
+}
+
+// refresh reads the keyring file if it changed since it was last read
+func (k *GroupKeyring) refresh() error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	return k.file.refresh(k.decode)
+}
+
+// update applies a change to the keys saved on disk and saves them,
+// readable by the owner only. The caller holds the lock.
+func (k *GroupKeyring) update(change func() error) error {
+	return k.file.update(k.decode, change, k.encode)
+}
+
+// decode replaces the keys with those read from the file. The caller holds the lock.
+func (k *GroupKeyring) decode(data []byte) error {
+	var keys []GroupKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	k.keys = make(map[string]GroupKey, len(keys))
+	for _, key := range keys {
+		k.keys[key.Group] = key
+	}
+	return nil
+}
+
+// encode returns the keys to save, sorted by group. The caller holds the lock.
+func (k *GroupKeyring) encode() any {
+	keys := make([]GroupKey, 0, len(k.keys))
+	for _, key := range k.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Group < keys[j].Group })
+	return keys
+}
+
+// newGroupKey generates a random key for a group
+func newGroupKey(group string, epoch uint32) (GroupKey, error) {
+	if group == "" {
+		return GroupKey{}, errors.New("group name is empty")
+	}
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return GroupKey{}, fmt.Errorf("failed to generate group key: %w", err)
+	}
+	return GroupKey{Group: group, Epoch: epoch, Key: key, Created: time.Now().UTC()}, nil
+}
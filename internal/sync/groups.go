@@ -0,0 +1,259 @@
+// Package sync provides clipboard synchronization using libp2p
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"go.uber.org/zap"
+)
+
+// maxGroupNameLength bounds group names, which are part of topic names
+const maxGroupNameLength = 64
+
+// Errors returned when managing groups
+var (
+	ErrGroupExists      = errors.New("group already exists")
+	ErrNotGroupMember   = errors.New("not a member of the group")
+	ErrInvalidGroupName = errors.New("invalid group name")
+)
+
+// validGroupName matches group names: letters, digits, dots, dashes and underscores
+var validGroupName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// GroupMembership is a group this device is in and the rules for the
+// content it publishes to it
+type GroupMembership struct {
+	Name   string    `json:"name"`
+	Joined time.Time `json:"joined"`
+	GroupRoute
+}
+
+// GroupRoute selects the content published to a group. Content is routed
+// to every group whose rules it matches; a group without rules gets all of it.
+type GroupRoute struct {
+	Types []string `json:"types,omitempty"` // Clipboard types ("text", "image", "files", "url"), all if empty
+	Match string   `json:"match,omitempty"` // Regular expression the text of the content must match
+}
+
+// Validate checks the route's types and pattern
+func (r GroupRoute) Validate() error {
+	for _, t := range r.Types {
+		switch t {
+		case "text", "image", "files", "url":
+		default:
+			return fmt.Errorf("invalid clipboard type: %s", t)
+		}
+	}
+	if r.Match != "" {
+		if _, err := regexp.Compile(r.Match); err != nil {
+			return fmt.Errorf("invalid match pattern: %w", err)
+		}
+	}
+	return nil
+}
+
+// Routes reports whether content is published to the group. Snippets go to
+// every group, as they keep the snippet library in sync.
+func (r GroupRoute) Routes(content *types.ClipboardContent) bool {
+	if content.Type == types.TypeSnippet {
+		return true
+	}
+	if len(r.Types) > 0 && !(historyFilter{Types: r.Types}).allows(content) {
+		return false
+	}
+	if r.Match != "" {
+		pattern, err := regexp.Compile(r.Match)
+		if err != nil || !pattern.MatchString(content.Text()) {
+			return false
+		}
+	}
+	return true
+}
+
+// Describe describes the route for listings
+func (r GroupRoute) Describe() string {
+	var rules []string
+	if len(r.Types) > 0 {
+		rules = append(rules, "types "+strings.Join(r.Types, ","))
+	}
+	if r.Match != "" {
+		rules = append(rules, fmt.Sprintf("matching %q", r.Match))
+	}
+	if len(rules) == 0 {
+		return "all content"
+	}
+	return strings.Join(rules, ", ")
+}
+
+// ValidateGroupName checks that a name can be used for a group
+func ValidateGroupName(name string) error {
+	if len(name) > maxGroupNameLength || !validGroupName.MatchString(name) {
+		return fmt.Errorf("%w %q: use up to %d letters, digits, dots, dashes and underscores",
+			ErrInvalidGroupName, name, maxGroupNameLength)
+	}
+	return nil
+}
+
+// GroupStore persists the groups this device is in. Until groups are
+// changed, the device is in the default group only.
+type GroupStore struct {
+	file   *jsonFile
+	logger *zap.Logger
+
+	mutex  sync.RWMutex
+	groups map[string]GroupMembership
+}
+
+// NewGroupStore creates a group store at path and loads the saved groups
+func NewGroupStore(path string, logger *zap.Logger) *GroupStore {
+	store := &GroupStore{
+		file:   newJSONFile(path, "groups"),
+		logger: logger.With(zap.String("component", "group-store")),
+		groups: map[string]GroupMembership{DefaultGroup: {Name: DefaultGroup}},
+	}
+	if err := store.refresh(); err != nil {
+		store.logger.Warn("Failed to load groups", zap.Error(err))
+	}
+	return store
+}
+
+// List returns the groups this device is in, sorted by name
+func (s *GroupStore) List() []GroupMembership {
+	s.reload()
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	groups := make([]GroupMembership, 0, len(s.groups))
+	for _, group := range s.groups {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
+}
+
+// Get returns the membership of a group
+func (s *GroupStore) Get(name string) (GroupMembership, bool) {
+	s.reload()
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	group, ok := s.groups[name]
+	return group, ok
+}
+
+// Add records membership of a group, replacing its route if already a member
+func (s *GroupStore) Add(name string, route GroupRoute) (GroupMembership, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var group GroupMembership
+	err := s.update(func() error {
+		var ok bool
+		group, ok = s.groups[name]
+		if !ok {
+			group = GroupMembership{Name: name, Joined: time.Now()}
+		}
+		group.GroupRoute = route
+		s.groups[name] = group
+		return nil
+	})
+	return group, err
+}
+
+// Create creates a group with a new key and records membership of it.
+// Paired devices receive the key when they next connect.
+func (s *GroupStore) Create(keyring *GroupKeyring, name string, route GroupRoute) (GroupMembership, error) {
+	if err := checkGroup(name, route); err != nil {
+		return GroupMembership{}, err
+	}
+	if _, ok := keyring.Current(name); ok {
+		return GroupMembership{}, fmt.Errorf("%w: %q, join it instead", ErrGroupExists, name)
+	}
+	if _, err := keyring.Ensure(name); err != nil {
+		return GroupMembership{}, fmt.Errorf("failed to create key for group %s: %w", name, err)
+	}
+	return s.Add(name, route)
+}
+
+// Join records membership of a group another device created. Its key must
+// have been received from a paired device in the group.
+func (s *GroupStore) Join(keyring *GroupKeyring, name string, route GroupRoute) (GroupMembership, error) {
+	if err := checkGroup(name, route); err != nil {
+		return GroupMembership{}, err
+	}
+	if _, ok := keyring.Current(name); !ok {
+		return GroupMembership{}, fmt.Errorf("%w %q: pair with a device in the group, or let one connect, first", ErrNoGroupKey, name)
+	}
+	return s.Add(name, route)
+}
+
+// checkGroup validates a group name and route
+func checkGroup(name string, route GroupRoute) error {
+	if err := ValidateGroupName(name); err != nil {
+		return err
+	}
+	return route.Validate()
+}
+
+// Remove forgets membership of a group
+func (s *GroupStore) Remove(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.update(func() error {
+		if _, ok := s.groups[name]; !ok {
+			return fmt.Errorf("%w %q", ErrNotGroupMember, name)
+		}
+		delete(s.groups, name)
+		return nil
+	})
+}
+
+// reload picks up groups saved by another process, such as the group command
+func (s *GroupStore) reload() {
+	if err := s.refresh(); err != nil {
+		s.logger.Warn("Failed to reload groups", zap.Error(err))
+	}
+}
+
+// refresh reads the group file if it changed since it was last read
+func (s *GroupStore) refresh() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.file.refresh(s.decode)
+}
+
+// update applies a change to the groups saved on disk and saves them. The
+// caller holds the lock.
+func (s *GroupStore) update(change func() error) error {
+	return s.file.update(s.decode, change, s.encode)
+}
+
+// decode replaces the groups with those read from the file. The caller holds the lock.
+func (s *GroupStore) decode(data []byte) error {
+	var groups []GroupMembership
+	if err := json.Unmarshal(data, &groups); err != nil {
+		return err
+	}
+	s.groups = make(map[string]GroupMembership, len(groups))
+	for _, group := range groups {
+		s.groups[group.Name] = group
+	}
+	return nil
+}
+
+// encode returns the groups to save, sorted by name. The caller holds the lock.
+func (s *GroupStore) encode() any {
+	groups := make([]GroupMembership, 0, len(s.groups))
+	for _, group := range s.groups {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
+}
@@ -0,0 +1,163 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"go.uber.org/zap"
+)
+
+func TestGroupStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "groups.json")
+	keyring := newTestKeyring(t)
+	groups := NewGroupStore(path, zap.NewNop())
+
+	if list := groups.List(); len(list) != 1 || list[0].Name != DefaultGroup {
+		t.Fatalf("new store lists %+v, want the default group only", list)
+	}
+
+	// Joining needs the group key, creating makes it
+	if _, err := groups.Join(keyring, "work", GroupRoute{}); !errors.Is(err, ErrNoGroupKey) {
+		t.Errorf("join without a key: %v", err)
+	}
+	if _, err := groups.Create(keyring, "work", GroupRoute{Types: []string{"text"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := keyring.Current("work"); !ok {
+		t.Error("create made no group key")
+	}
+	if _, err := groups.Create(keyring, "work", GroupRoute{}); !errors.Is(err, ErrGroupExists) {
+		t.Errorf("creating an existing group: %v", err)
+	}
+
+	// Joining again replaces the route
+	if _, err := groups.Join(keyring, "work", GroupRoute{Match: "^JIRA-"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := groups.Remove(DefaultGroup); err != nil {
+		t.Fatal(err)
+	}
+	if err := groups.Remove(DefaultGroup); !errors.Is(err, ErrNotGroupMember) {
+		t.Errorf("leaving a group twice: %v", err)
+	}
+
+	// Membership survives a restart and changes made by another process
+	// are picked up
+	reloaded := NewGroupStore(path, zap.NewNop())
+	list := reloaded.List()
+	if len(list) != 1 || list[0].Name != "work" || list[0].Match != "^JIRA-" || len(list[0].Types) != 0 {
+		t.Fatalf("reloaded groups = %+v", list)
+	}
+	if _, err := reloaded.Add("family", GroupRoute{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := groups.Get("family"); !ok {
+		t.Error("store missed a group added by another process")
+	}
+}
+
+func TestGroupValidation(t *testing.T) {
+	keyring := newTestKeyring(t)
+	groups := NewGroupStore("", zap.NewNop())
+
+	for _, name := range []string{"", "-work", "my group", "work/home", string(make([]byte, maxGroupNameLength+1))} {
+		if _, err := groups.Create(keyring, name, GroupRoute{}); !errors.Is(err, ErrInvalidGroupName) {
+			t.Errorf("group name %q: %v", name, err)
+		}
+	}
+	if _, err := groups.Create(keyring, "work", GroupRoute{Types: []string{"video"}}); err == nil {
+		t.Error("accepted an unknown clipboard type")
+	}
+	if _, err := groups.Create(keyring, "work", GroupRoute{Match: "("}); err == nil {
+		t.Error("accepted an invalid pattern")
+	}
+}
+
+func TestGroupRoute(t *testing.T) {
+	text := &types.ClipboardContent{Type: types.TypeText, Data: []byte("JIRA-42 is done")}
+	url := &types.ClipboardContent{Type: types.TypeURL, Data: []byte("https://example.com")}
+	image := &types.ClipboardContent{Type: types.TypeImage, Data: []byte{0x89, 'P', 'N', 'G'}}
+	snippet := &types.ClipboardContent{Type: types.TypeSnippet, Data: []byte("{}")}
+
+	tests := []struct {
+		name    string
+		route   GroupRoute
+		content *types.ClipboardContent
+		want    bool
+	}{
+		{"no rules", GroupRoute{}, image, true},
+		{"type matches", GroupRoute{Types: []string{"text"}}, text, true},
+		{"type differs", GroupRoute{Types: []string{"text"}}, image, false},
+		{"url by its own type", GroupRoute{Types: []string{"url"}}, url, true},
+		{"pattern matches", GroupRoute{Match: `JIRA-\d+`}, text, true},
+		{"pattern differs", GroupRoute{Match: `^https://`}, text, false},
+		{"type and pattern", GroupRoute{Types: []string{"url"}, Match: `example\.com`}, url, true},
+		{"snippets always", GroupRoute{Types: []string{"image"}, Match: "nothing"}, snippet, true},
+	}
+	for _, tt := range tests {
+		if got := tt.route.Routes(tt.content); got != tt.want {
+			t.Errorf("%s: Routes = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestJoinAndLeaveGroupsConcurrently(t *testing.T) {
+	_, peers := newTestPeers(t, 2, mocknet.LinkOptions{})
+	managers := []*Manager{peers[0].manager(t), peers[1].manager(t)}
+	for _, m := range managers {
+		m.SetGroupJoinHandler(func(string, types.PeerInfo) {})
+		for _, group := range []string{"work", "family"} {
+			if err := m.JoinGroup(group); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Group commands from the control socket run while group events are
+	// read, and while other commands and the daemon's rejoin do the same
+	var wg sync.WaitGroup
+	for i, m := range managers {
+		for j := 0; j < 3; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				group := fmt.Sprintf("group-%d", j)
+				for k := 0; k < 10; k++ {
+					if err := m.JoinGroup(group); err != nil {
+						t.Errorf("peer %d join %s: %v", i, group, err)
+					}
+					m.GroupPeerCount(group)
+					m.ListGroups()
+					if err := m.LeaveGroup(group); err != nil {
+						t.Errorf("peer %d leave %s: %v", i, group, err)
+					}
+				}
+			}()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 10; k++ {
+				if err := m.RejoinGroups(); err != nil {
+					t.Errorf("peer %d rejoin: %v", i, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, m := range managers {
+		groups, err := m.ListGroups()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(groups) != 3 {
+			t.Errorf("peer %d is in groups %v, want the default group, work and family", i, groups)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Package sync provides clipboard synchronization using libp2p
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errUnchanged is returned by a jsonFile change that left the state as it
+// was, so there is nothing to write
+var errUnchanged = errors.New("unchanged")
+
+// jsonFile persists state shared by the daemon and CLI commands, such as
+// the pair and group commands, to a JSON file. Readers pick up changes made
+// by other processes by the file's modification time. Changes are made
+// under an exclusive lock on a lock file next to it, after reading it again,
+// so concurrent changes from another process aren't lost.
+//
+// jsonFile isn't safe for concurrent use, its owner guards it with the
+// lock of the state it holds.
+type jsonFile struct {
+	path    string
+	what    string    // What the file holds, for error messages
+	modTime time.Time // Modification time of the file when it was last read
+}
+
+// newJSONFile creates a file at path holding what, no file if path is empty
+func newJSONFile(path, what string) *jsonFile {
+	return &jsonFile{path: path, what: what}
+}
+
+// refresh passes the file's contents to decode if it changed since it was
+// last read. Without a file, or a path, the state is left as it is.
+func (f *jsonFile) refresh(decode func(data []byte) error) error {
+	if f.path == "" {
+		return nil
+	}
+	info, err := os.Stat(f.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(f.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", f.what, err)
+	}
+	if err := decode(data); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", f.what, err)
+	}
+	f.modTime = info.ModTime()
+	return nil
+}
+
+// update reads the file into the state with decode, applies change and
+// writes what encode returns, holding the file lock throughout. A change
+// returning errUnchanged writes nothing.
+func (f *jsonFile) update(decode func(data []byte) error, change func() error, encode func() any) error {
+	if f.path == "" {
+		if err := change(); err != nil && !errors.Is(err, errUnchanged) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", f.what, err)
+	}
+	unlock, err := lockFile(f.path + ".lock")
+	if err != nil {
+		return fmt.Errorf("failed to lock %s: %w", f.what, err)
+	}
+	defer unlock()
+
+	// Another process may have written within the modification time's
+	// resolution, so read the file whatever its time says
+	f.modTime = time.Time{}
+	if err := f.refresh(decode); err != nil {
+		return err
+	}
+	if err := change(); err != nil {
+		if errors.Is(err, errUnchanged) {
+			return nil
+		}
+		return err
+	}
+	return f.write(encode())
+}
+
+// write encodes v to the file, readable by the owner only. The caller holds the file lock.
+func (f *jsonFile) write(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", f.what, err)
+	}
+
+	// Write to a temporary file first so a reader never sees a partial file
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.what, err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.what, err)
+	}
+
+	if info, err := os.Stat(f.path); err == nil {
+		f.modTime = info.ModTime()
+	}
+	return nil
+}
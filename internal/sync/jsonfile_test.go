@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestJSONFileKeepsConcurrentChanges(t *testing.T) {
+	// Two stores on one file stand for the daemon and a CLI command
+	path := filepath.Join(t.TempDir(), "policies.json")
+	daemon := NewPolicyStore(path, zap.NewNop())
+	command := NewPolicyStore(path, zap.NewNop())
+
+	const changes = 20
+	var wg sync.WaitGroup
+	for i, store := range []*PolicyStore{daemon, command} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < changes; j++ {
+				name := fmt.Sprintf("group-%d-%d", i, j)
+				if err := store.SetGroup(name, SyncPolicy{Direction: DirectionSend}); err != nil {
+					t.Errorf("set %s: %v", name, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, store := range []*PolicyStore{daemon, command, NewPolicyStore(path, zap.NewNop())} {
+		if groups := store.All().Groups; len(groups) != 2*changes {
+			t.Errorf("store holds %d group policies, want %d", len(groups), 2*changes)
+		}
+	}
+}
+
+func TestJSONFileUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked_peers.json")
+	denied := NewDenyList(path, zap.NewNop())
+
+	// Nothing to remove writes nothing
+	if removed, err := denied.Remove("unknown"); removed || err != nil {
+		t.Fatalf("remove of an unknown device = %v, %v", removed, err)
+	}
+	if !denied.file.modTime.IsZero() {
+		t.Error("an unchanged deny list was written")
+	}
+
+	// Without a path, changes are kept in memory
+	memory := NewDenyList("", zap.NewNop())
+	if err := memory.Add(RevokedPeer{PeerID: "peer"}); err != nil {
+		t.Fatal(err)
+	}
+	if !memory.Contains("peer") {
+		t.Error("in-memory deny list lost a revocation")
+	}
+}
@@ -0,0 +1,183 @@
+// Package sync provides clipboard synchronization using libp2p
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"go.uber.org/zap"
+)
+
+const (
+	// keyExchangeTimeout bounds a key handover to one peer
+	keyExchangeTimeout = 30 * time.Second
+
+	// maxKeyExchangeSize bounds the keys a peer can send in one handover
+	maxKeyExchangeSize = 64 * 1024
+)
+
+// KeyExchange hands group keys to the paired devices in each group over a
+// direct stream, so members that were offline when a key was rotated
+// receive it when they next connect
+type KeyExchange struct {
+	host            host.Host
+	ctx             context.Context
+	logger          *zap.Logger
+	protocolManager *ProtocolManager
+	keyring         *GroupKeyring
+	pairing         *PairingManager
+	notifee         *network.NotifyBundle
+}
+
+// NewKeyExchange creates the key exchange and registers its protocol handler
+func NewKeyExchange(ctx context.Context, host host.Host, pm *ProtocolManager, keyring *GroupKeyring, pairing *PairingManager, logger *zap.Logger) *KeyExchange {
+	exchange := &KeyExchange{
+		host:            host,
+		ctx:             ctx,
+		logger:          logger.With(zap.String("component", "key-exchange")),
+		protocolManager: pm,
+		keyring:         keyring,
+		pairing:         pairing,
+	}
+	pm.AddHandler(exchange)
+	return exchange
+}
+
+// ID returns the protocol ID
+func (ke *KeyExchange) ID() protocol.ID {
+	return protocol.ID(GroupKeyProtocolPath)
+}
+
+// Handle stores the keys a paired device sends, and sends back the current
+// keys of groups the device is behind in
+func (ke *KeyExchange) Handle(stream network.Stream) {
+	defer stream.Close()
+	remotePeer := stream.Conn().RemotePeer()
+
+	if !ke.pairing.IsPaired(remotePeer.String()) {
+		ke.logger.Warn("Rejected group keys from unpaired peer", zap.String("peer_id", remotePeer.String()))
+		stream.Reset()
+		return
+	}
+
+	stream.SetDeadline(time.Now().Add(keyExchangeTimeout))
+	var keys []GroupKey
+	if err := json.NewDecoder(io.LimitReader(stream, maxKeyExchangeSize)).Decode(&keys); err != nil {
+		ke.logger.Warn("Failed to read group keys", zap.String("peer_id", remotePeer.String()), zap.Error(err))
+		stream.Reset()
+		return
+	}
+
+	// Only members' keys are taken and a member can't roll a group back to an old key
+	result, err := ke.keyring.Import(keys, remotePeer.String(), false)
+	if err != nil {
+		ke.logger.Warn("Failed to store group keys", zap.String("peer_id", remotePeer.String()), zap.Error(err))
+		return
+	}
+	if len(result.changed) > 0 {
+		ke.logger.Info("Received group keys from paired device",
+			zap.String("peer_id", remotePeer.String()),
+			zap.Strings("groups", result.changed))
+	}
+
+	switch {
+	case len(result.rotated) > 0:
+		go ke.PushToPairedDevices()
+	case len(result.behind) > 0:
+		go func() {
+			if err := ke.PushKeys(remotePeer); err != nil {
+				ke.logger.Debug("Failed to push group keys", zap.String("peer_id", remotePeer.String()), zap.Error(err))
+			}
+		}()
+	}
+}
+
+// Start pushes keys to paired devices as they connect
+func (ke *KeyExchange) Start() error {
+	ke.notifee = &network.NotifyBundle{
+		ConnectedF: func(_ network.Network, conn network.Conn) {
+			remotePeer := conn.RemotePeer()
+			if !ke.pairing.IsPaired(remotePeer.String()) {
+				return
+			}
+			go func() {
+				if err := ke.PushKeys(remotePeer); err != nil {
+					ke.logger.Debug("Failed to push group keys to paired device",
+						zap.String("peer_id", remotePeer.String()),
+						zap.Error(err))
+				}
+			}()
+		},
+	}
+	ke.host.Network().Notify(ke.notifee)
+	return nil
+}
+
+// Stop stops pushing keys on new connections
+func (ke *KeyExchange) Stop() error {
+	if ke.notifee != nil {
+		ke.host.Network().StopNotify(ke.notifee)
+		ke.notifee = nil
+	}
+	return nil
+}
+
+// PushKeys sends a peer the current keys of the groups it is a member of
+func (ke *KeyExchange) PushKeys(peerID peer.ID) error {
+	keys := ke.keyring.KeysFor(peerID.String())
+	if len(keys) == 0 {
+		return nil
+	}
+
+	stream, err := ke.protocolManager.OpenStream(peerID, ke.ID())
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	stream.SetDeadline(time.Now().Add(keyExchangeTimeout))
+	if err := json.NewEncoder(stream).Encode(keys); err != nil {
+		stream.Reset()
+		return fmt.Errorf("failed to send group keys: %w", err)
+	}
+	return nil
+}
+
+// PushToPairedDevices sends every connected paired device the current keys
+// of its groups and returns the peers that received them. Devices that
+// aren't connected receive the keys when they next connect.
+func (ke *KeyExchange) PushToPairedDevices() []string {
+	var delivered []string
+	for _, device := range ke.pairing.GetPairedDevices() {
+		peerID, err := peer.Decode(device.PeerID)
+		if err != nil || len(ke.keyring.KeysFor(device.PeerID)) == 0 {
+			continue
+		}
+		if ke.PushIfConnected(peerID) {
+			delivered = append(delivered, device.PeerID)
+		}
+	}
+	return delivered
+}
+
+// PushIfConnected sends a peer the current keys of its groups if it is
+// connected, and reports whether it received them
+func (ke *KeyExchange) PushIfConnected(peerID peer.ID) bool {
+	if ke.host.Network().Connectedness(peerID) != network.Connected {
+		return false
+	}
+	if err := ke.PushKeys(peerID); err != nil {
+		ke.logger.Warn("Failed to push group keys to paired device",
+			zap.String("peer_id", peerID.String()),
+			zap.Error(err))
+		return false
+	}
+	return true
+}
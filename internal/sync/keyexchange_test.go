@@ -0,0 +1,69 @@
+package sync
+
+import (
+	"bytes"
+	"testing"
+
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"go.uber.org/zap"
+)
+
+func TestKeyExchangeHandsKeysToMembers(t *testing.T) {
+	_, peers := newTestPeers(t, 3, mocknet.LinkOptions{})
+	exchanges := make([]*KeyExchange, len(peers))
+	for i, p := range peers {
+		exchanges[i] = NewKeyExchange(p.ctx, p.host, p.protocols, p.keyring, p.pairing, zap.NewNop())
+		p.start(t)
+	}
+	desktop, laptop, phone := peers[0], peers[1], peers[2]
+
+	// The desktop creates a group and adds the laptop to it
+	if _, err := desktop.keyring.Ensure("work"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := desktop.keyring.Grant("work", laptop.id().String()); err != nil {
+		t.Fatal(err)
+	}
+	delivered := exchanges[0].PushToPairedDevices()
+	if len(delivered) != 1 || delivered[0] != laptop.id().String() {
+		t.Errorf("keys delivered to %v, want the laptop only", delivered)
+	}
+	waitFor(t, "the laptop to receive the group key", func() bool {
+		_, ok := laptop.keyring.Current("work")
+		return ok
+	})
+	if _, ok := phone.keyring.Current("work"); ok {
+		t.Error("a paired device that isn't a member received the group key")
+	}
+
+	// Removing the laptop rotates the key, which it doesn't receive
+	first, _ := laptop.keyring.Current("work")
+	if _, err := desktop.keyring.Grant("work", phone.id().String()); err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := desktop.keyring.Rotate("work", laptop.id().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exchanges[0].PushToPairedDevices()
+	waitFor(t, "the phone to receive the rotated key", func() bool {
+		key, ok := phone.keyring.Current("work")
+		return ok && bytes.Equal(key.Key, rotated.Key)
+	})
+	if key, _ := laptop.keyring.Current("work"); key.Epoch != first.Epoch {
+		t.Errorf("removed member received epoch %d", key.Epoch)
+	}
+
+	// A removed member pushing its old key doesn't roll the group back
+	if err := exchanges[1].PushKeys(desktop.id()); err != nil {
+		t.Fatal(err)
+	}
+	if err := exchanges[1].PushKeys(phone.id()); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []*testPeer{desktop, phone} {
+		if key, _ := p.keyring.Current("work"); key.Epoch != rotated.Epoch {
+			t.Errorf("old key rolled the group back to epoch %d", key.Epoch)
+		}
+	}
+}
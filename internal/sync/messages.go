@@ -0,0 +1,294 @@
+// Package sync provides clipboard synchronization using libp2p
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+)
+
+// Message types exchanged on group topics
+const (
+	MessageTypeContent  = "content"  // Payload is a JSON-encoded types.ClipboardContent
+	MessageTypeAnnounce = "announce" // Payload is a ContentAnnouncement of content to fetch from the sender
+)
+
+// publishMessage encrypts a message with the group key and publishes it on
+// the group's topic. Every member can decrypt it, excluded peers only drop
+// it rather than fetch content that isn't served to them.
+func (m *Manager) publishMessage(group, msgType string, payload []byte, exclude []peer.ID) error {
+	key, ok := m.node.keyring.Current(group)
+	if !ok {
+		return fmt.Errorf("%w %q", ErrNoGroupKey, group)
+	}
+
+	message := SyncMessage{
+		Type:      msgType,
+		Source:    m.node.ID(),
+		Group:     group,
+		Timestamp: time.Now().UTC(),
+		ID:        generateNonce(),
+		Sequence:  m.sequence.Add(1),
+		Payload:   payload,
+		Exclude:   exclude,
+	}
+	identity := m.node.host.Peerstore().PrivKey(m.node.ID())
+	if identity == nil {
+		return fmt.Errorf("no identity key to sign messages with")
+	}
+	data, err := SignMessage(identity, &message)
+	if err != nil {
+		return err
+	}
+
+	sealed, err := SealEnvelope(key, data)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt message: %w", err)
+	}
+	return m.node.PublishToTopic(group, sealed)
+}
+
+// readGroup handles the messages of a group until its subscription is cancelled
+func (m *Manager) readGroup(group string, sub *pubsub.Subscription) {
+	for {
+		received, err := sub.Next(m.ctx)
+		if err != nil {
+			return
+		}
+		from := received.GetFrom()
+		if from == m.node.ID() {
+			continue
+		}
+		m.handleGroupMessage(group, from, received.GetData())
+	}
+}
+
+// GroupJoinCallback is called when a peer joins a group this device is in
+type GroupJoinCallback func(group string, peer types.PeerInfo)
+
+// readGroupEvents passes peers joining a group to the join handler until the
+// group is left
+func (m *Manager) readGroupEvents(group string, events *pubsub.TopicEventHandler) {
+	for {
+		event, err := events.NextPeerEvent(m.ctx)
+		if err != nil {
+			return
+		}
+		if event.Type != pubsub.PeerJoin {
+			continue
+		}
+
+		m.handlerMutex.RLock()
+		handler := m.joinHandler
+		m.handlerMutex.RUnlock()
+		if handler != nil {
+			handler(group, m.peerInfo(event.Peer))
+		}
+	}
+}
+
+// handleGroupMessage decrypts a group message and passes it on by type
+func (m *Manager) handleGroupMessage(group string, from peer.ID, data []byte) {
+	plaintext, err := m.node.keyring.OpenEnvelope(group, data)
+	if err != nil {
+		m.logger.Warn("Rejected group message that can't be decrypted",
+			zap.String("group", group),
+			zap.String("peer_id", from.String()),
+			zap.Error(err))
+
+		// A paired device that missed a key rotation gets the current keys of
+		// the groups it is still a member of
+		if errors.Is(err, ErrStaleEpoch) && m.node.pairing.IsPaired(from.String()) {
+			go func() {
+				if err := m.node.keyExchange.PushKeys(from); err != nil {
+					m.logger.Debug("Failed to push group keys", zap.String("peer_id", from.String()), zap.Error(err))
+				}
+			}()
+		}
+		return
+	}
+
+	signed, message, err := ParseSignedMessage(plaintext)
+	if err != nil {
+		m.logger.Warn("Rejected malformed group message",
+			zap.String("group", group),
+			zap.String("peer_id", from.String()),
+			zap.Error(err))
+		return
+	}
+	if message.Source != from || message.Group != group {
+		m.logger.Warn("Rejected group message with mismatched source or group",
+			zap.String("group", group),
+			zap.String("peer_id", from.String()),
+			zap.String("claimed_source", message.Source.String()),
+			zap.String("claimed_group", message.Group))
+		return
+	}
+
+	// Only messages signed by the paired device itself are trusted, holding
+	// the group key isn't enough
+	key, err := m.node.pairing.DevicePublicKey(from.String())
+	if err == nil {
+		err = signed.Verify(key)
+	}
+	if err != nil {
+		m.logger.Warn("Rejected group message that isn't signed by a paired device",
+			zap.String("group", group),
+			zap.String("peer_id", from.String()),
+			zap.Error(err))
+		return
+	}
+	if err := m.replay.Check(message); err != nil {
+		m.logger.Warn("Rejected replayed or out of date group message",
+			zap.String("group", group),
+			zap.String("peer_id", from.String()),
+			zap.Error(err))
+		return
+	}
+
+	for _, excluded := range message.Exclude {
+		if excluded == m.node.ID() {
+			m.logger.Debug("Dropping group message the sender's sync policy excludes this device from",
+				zap.String("group", group),
+				zap.String("peer_id", from.String()))
+			return
+		}
+	}
+
+	switch message.Type {
+	case MessageTypeContent:
+		var content types.ClipboardContent
+		if err := json.Unmarshal(message.Payload, &content); err != nil {
+			m.logger.Warn("Rejected malformed clipboard content",
+				zap.String("peer_id", from.String()),
+				zap.Error(err))
+			return
+		}
+		if limit := m.maxContentSize(); limit > 0 && int64(len(content.Data)) > limit {
+			m.logger.Debug("Dropping content larger than the maximum clipboard size",
+				zap.String("peer_id", from.String()),
+				zap.Int("size", len(content.Data)))
+			return
+		}
+		if err := m.checkReceive(group, from, content.Type, int64(len(content.Data))); err != nil {
+			m.logger.Debug("Dropping content from peer", zap.String("peer_id", from.String()), zap.Error(err))
+			return
+		}
+		m.deliverContent(&content, from)
+	case MessageTypeAnnounce:
+		var announcement ContentAnnouncement
+		if err := json.Unmarshal(message.Payload, &announcement); err != nil {
+			m.logger.Warn("Rejected malformed content announcement",
+				zap.String("peer_id", from.String()),
+				zap.Error(err))
+			return
+		}
+		if err := m.checkReceive(group, from, types.ContentType(announcement.Type), announcement.ContentSize); err != nil {
+			m.logger.Debug("Not fetching content from peer", zap.String("peer_id", from.String()), zap.Error(err))
+			return
+		}
+		go m.fetchAnnounced(announcement, from)
+	default:
+		m.logger.Debug("Ignoring group message of unknown type",
+			zap.String("type", message.Type),
+			zap.String("peer_id", from.String()))
+	}
+}
+
+// fetchAnnounced fetches announced content from its sender and delivers it,
+// unless nothing would handle it or it exceeds the maximum clipboard size
+func (m *Manager) fetchAnnounced(announcement ContentAnnouncement, from peer.ID) {
+	m.handlerMutex.RLock()
+	interested := m.contentHandler != nil
+	m.handlerMutex.RUnlock()
+	if !interested {
+		return
+	}
+
+	fields := []zap.Field{
+		zap.String("peer_id", from.String()),
+		zap.String("content_type", announcement.Type),
+		zap.Int64("size", announcement.ContentSize),
+	}
+	maxFetch := int64(maxOfferedBytes)
+	if limit := m.maxContentSize(); limit > 0 {
+		if announcement.ContentSize > limit {
+			m.logger.Debug("Not fetching content larger than the maximum clipboard size", fields...)
+			return
+		}
+		// The encoded content holds the data as base64, plus metadata and a thumbnail
+		maxFetch = min(maxFetch, limit*4/3+inlineContentLimit)
+	}
+
+	data, err := m.node.clipboard.Fetch(from, announcement, maxFetch)
+	if err != nil {
+		m.logger.Warn("Failed to fetch announced content", append(fields, zap.Error(err))...)
+		return
+	}
+
+	var content types.ClipboardContent
+	if err := json.Unmarshal(data, &content); err != nil {
+		m.logger.Warn("Rejected malformed fetched content", append(fields, zap.Error(err))...)
+		return
+	}
+	if string(content.Type) != announcement.Type || int64(len(content.Data)) != announcement.ContentSize {
+		m.logger.Warn("Rejected fetched content that doesn't match its announcement", fields...)
+		return
+	}
+	m.deliverContent(&content, from)
+}
+
+// checkReceive returns why content received from a peer in a group is
+// dropped under their sync policies, nil if it is accepted
+func (m *Manager) checkReceive(group string, from peer.ID, contentType types.ContentType, size int64) error {
+	now := time.Now()
+	if err := m.node.policies.Group(group).check(contentType, size, DirectionReceive, now); err != nil {
+		return fmt.Errorf("group %s: %w", group, err)
+	}
+	return m.node.policies.Peer(from.String()).check(contentType, size, DirectionReceive, now)
+}
+
+// excludedPeers returns the paired devices whose sync policies don't allow
+// sending them content
+func (m *Manager) excludedPeers(content *types.ClipboardContent) []peer.ID {
+	now := time.Now()
+	var excluded []peer.ID
+	for peerID, policy := range m.node.policies.All().Peers {
+		if policy.Check(content, DirectionSend, now) == nil {
+			continue
+		}
+		if id, err := peer.Decode(peerID); err == nil {
+			excluded = append(excluded, id)
+		}
+	}
+	return excluded
+}
+
+// deliverContent passes content received from a peer to the content handler
+func (m *Manager) deliverContent(content *types.ClipboardContent, from peer.ID) {
+	m.handlerMutex.RLock()
+	handler := m.contentHandler
+	m.handlerMutex.RUnlock()
+
+	if handler == nil {
+		m.logger.Debug("No content handler set, dropping content from peer", zap.String("peer_id", from.String()))
+		return
+	}
+	handler(content, m.peerInfo(from))
+}
+
+// peerInfo describes a peer, by its paired device record when there is one
+func (m *Manager) peerInfo(id peer.ID) types.PeerInfo {
+	info := types.PeerInfo{ID: id.String(), LastSeen: time.Now()}
+	if device, ok := m.node.pairing.GetPairedDevice(id.String()); ok {
+		info.Name = device.DeviceName
+		info.DeviceType = device.DeviceType
+	}
+	return info
+}
@@ -9,7 +9,7 @@ import (
 	// "os"
 	// "path/filepath"
 	"sort"
-	// "sync"
+	"sync"
 	"time"
 
 	"github.com/berrythewa/clipman-daemon/internal/config"
@@ -42,10 +42,36 @@ type Node struct {
 	protocols    *ProtocolManager
 	pairing      *PairingManager
 	
-	// PubSub for group communication
+	// Group encryption keys
+	keyring      *GroupKeyring
+	keyExchange  *KeyExchange
+	
+	// Direct streams for large clipboard content and files
+	clipboard    *ClipboardStream
+	files        *FileTransfer
+	
+	// Reconciliation of history missed while devices were apart
+	history      *Reconciler
+	
+	// Sync policies of paired devices and groups
+	policies     *PolicyStore
+	
+	// Admission of peers when only known peers are allowed
+	gater        *PeerGater
+	
+	// Revoked devices, denied at the connection level, and the notices
+	// telling paired devices of revocations
+	denied       *DenyList
+	revocation   *RevocationService
+	
+	// PubSub for group communication. The maps of joined groups are guarded
+	// by topicsMutex, groups are joined and left from the control socket
+	// while their messages and events are read.
 	pubsub        *pubsub.PubSub
+	topicsMutex   sync.RWMutex
 	topics        map[string]*pubsub.Topic
 	subscriptions map[string]*pubsub.Subscription
+	topicEvents   map[string]*pubsub.TopicEventHandler // Peers joining and leaving each group
 	
 	// State
 	started       bool
@@ -109,6 +135,12 @@ func NewNode(ctx context.Context, cfg *config.Config, logger *zap.Logger, opts .
 		return nil, fmt.Errorf("failed to create libp2p options: %w", err)
 	}
 	
+	// Only admit paired and trusted peers if configured, and never revoked ones
+	denied := NewDenyList(syncCfg.RevokedPeersPath, nodeLogger)
+	gater := NewPeerGater(syncCfg, nodeLogger)
+	gater.SetDenyList(denied)
+	libp2pOpts = append(libp2pOpts, libp2p.ConnectionGater(gater))
+	
 	// Create the host
 	h, err := libp2p.New(libp2pOpts...)
 	if err != nil {
@@ -131,7 +163,10 @@ func NewNode(ctx context.Context, cfg *config.Config, logger *zap.Logger, opts .
 		logger:        nodeLogger,
 		topics:        make(map[string]*pubsub.Topic),
 		subscriptions: make(map[string]*pubsub.Subscription),
+		topicEvents:   make(map[string]*pubsub.TopicEventHandler),
 		peerStore:     make(map[peer.ID]InternalPeerInfo),
+		gater:         gater,
+		denied:        denied,
 		started:       false,
 	}
 	
@@ -142,8 +177,17 @@ func NewNode(ctx context.Context, cfg *config.Config, logger *zap.Logger, opts .
 	// Create protocol manager
 	node.protocols = NewProtocolManager(nodeCtx, h, syncCfg, nodeLogger)
 	
-	// Create pairing manager
-	node.pairing = NewPairingManager(nodeCtx, h, node.protocols, syncCfg, nodeLogger)
+	// Create the group keyring and pairing manager, which hands keys to new devices
+	node.keyring = NewGroupKeyring(syncCfg.GroupKeysPath, nodeLogger)
+	node.keyring.SetSelf(h.ID().String())
+	node.pairing = NewPairingManager(nodeCtx, h, node.protocols, node.keyring, syncCfg, nodeLogger)
+	node.keyExchange = NewKeyExchange(nodeCtx, h, node.protocols, node.keyring, node.pairing, nodeLogger)
+	node.clipboard = NewClipboardStream(nodeCtx, h, node.protocols, node.pairing, nodeLogger)
+	node.files = NewFileTransfer(nodeCtx, h, node.protocols, node.pairing, syncCfg, nodeLogger)
+	node.policies = NewPolicyStore(syncCfg.PoliciesPath, nodeLogger)
+	node.history = NewReconciler(nodeCtx, h, node.protocols, node.pairing, node.policies, syncCfg, nodeLogger)
+	node.revocation = NewRevocationService(nodeCtx, h, node.protocols, node.pairing, node.denied, node.policies, nodeLogger)
+	node.gater.SetPairing(node.pairing)
 	
 	// Add pairing discovery service if configured to use paired discovery
 	if syncCfg.DiscoveryMethod == "paired" {
@@ -337,7 +381,12 @@ func (n *Node) Stop() error {
 		n.logger.Warn("Failed to stop discovery services", zap.Error(err))
 	}
 	
-	// Close all pubsub subscriptions
+	// Close all pubsub subscriptions and event handlers
+	n.topicsMutex.Lock()
+	for group, events := range n.topicEvents {
+		events.Cancel()
+		delete(n.topicEvents, group)
+	}
 	for group, sub := range n.subscriptions {
 		sub.Cancel()
 		delete(n.subscriptions, group)
@@ -345,6 +394,7 @@ func (n *Node) Stop() error {
 	
 	// Close all topics
 	n.topics = make(map[string]*pubsub.Topic)
+	n.topicsMutex.Unlock()
 	
 	n.started = false
 	n.logger.Info("Node stopped successfully")
@@ -371,6 +421,11 @@ func (n *Node) Pairing() *PairingManager {
 	return n.pairing
 }
 
+// Keyring returns the group keyring
+func (n *Node) Keyring() *GroupKeyring {
+	return n.keyring
+}
+
 // GetManualDiscoveryService returns the manual discovery service
 func (n *Node) GetManualDiscoveryService() (*discovery.ManualDiscovery, error) {
 	return n.discovery.GetManualDiscovery()
@@ -493,6 +548,9 @@ func (n *Node) JoinTopic(group string) (*pubsub.Topic, *pubsub.Subscription, err
 		return nil, nil, fmt.Errorf("pubsub not available")
 	}
 	
+	n.topicsMutex.Lock()
+	defer n.topicsMutex.Unlock()
+	
 	// Check if already joined
 	if topic, exists := n.topics[group]; exists {
 		if sub, exists := n.subscriptions[group]; exists {
@@ -513,9 +571,17 @@ func (n *Node) JoinTopic(group string) (*pubsub.Topic, *pubsub.Subscription, err
 		return nil, nil, fmt.Errorf("failed to subscribe to topic %s: %w", topicName, err)
 	}
 	
-	// Store topic and subscription
+	// Follow peers joining the group
+	events, err := topic.EventHandler()
+	if err != nil {
+		sub.Cancel()
+		return nil, nil, fmt.Errorf("failed to follow peers of topic %s: %w", topicName, err)
+	}
+	
+	// Store topic, subscription and event handler
 	n.topics[group] = topic
 	n.subscriptions[group] = sub
+	n.topicEvents[group] = events
 	
 	n.logger.Info("Joined group topic", 
 		zap.String("group", group),
@@ -526,15 +592,22 @@ func (n *Node) JoinTopic(group string) (*pubsub.Topic, *pubsub.Subscription, err
 
 // LeaveTopic leaves a pubsub topic for a group
 func (n *Node) LeaveTopic(group string) error {
+	n.topicsMutex.Lock()
+	defer n.topicsMutex.Unlock()
+	
 	// Check if joined
 	sub, exists := n.subscriptions[group]
 	if !exists {
 		return nil
 	}
 	
-	// Cancel subscription
+	// Cancel subscription and event handler
 	sub.Cancel()
 	delete(n.subscriptions, group)
+	if events, exists := n.topicEvents[group]; exists {
+		events.Cancel()
+		delete(n.topicEvents, group)
+	}
 	
 	// Close topic
 	topic, exists := n.topics[group]
@@ -551,10 +624,50 @@ func (n *Node) LeaveTopic(group string) error {
 	return nil
 }
 
+// Joined reports whether a group's topic is joined
+func (n *Node) Joined(group string) bool {
+	n.topicsMutex.RLock()
+	defer n.topicsMutex.RUnlock()
+	_, exists := n.subscriptions[group]
+	return exists
+}
+
+// JoinedGroups returns the groups whose topics are joined
+func (n *Node) JoinedGroups() []string {
+	n.topicsMutex.RLock()
+	defer n.topicsMutex.RUnlock()
+	groups := make([]string, 0, len(n.topics))
+	for group := range n.topics {
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// TopicEvents returns the handler of peer events of a joined group
+func (n *Node) TopicEvents(group string) (*pubsub.TopicEventHandler, bool) {
+	n.topicsMutex.RLock()
+	defer n.topicsMutex.RUnlock()
+	events, exists := n.topicEvents[group]
+	return events, exists
+}
+
+// TopicPeers returns the peers known to be subscribed to a joined group
+func (n *Node) TopicPeers(group string) []peer.ID {
+	n.topicsMutex.RLock()
+	topic, exists := n.topics[group]
+	n.topicsMutex.RUnlock()
+	if !exists {
+		return nil
+	}
+	return topic.ListPeers()
+}
+
 // PublishToTopic publishes data to a topic
 func (n *Node) PublishToTopic(group string, data []byte) error {
 	// Get the topic
+	n.topicsMutex.RLock()
 	topic, exists := n.topics[group]
+	n.topicsMutex.RUnlock()
 	if !exists {
 		return fmt.Errorf("not subscribed to group %s", group)
 	}
@@ -586,8 +699,9 @@ func (n *Node) Close() error {
 
 // setupPubSub initializes the pubsub system
 func (n *Node) setupPubSub() error {
-	// Create pubsub
-	ps, err := pubsub.NewGossipSub(n.ctx, n.host)
+	// Create pubsub, keeping revoked peers, and unknown ones if configured,
+	// out of group topics
+	ps, err := pubsub.NewGossipSub(n.ctx, n.host, pubsub.WithPeerFilter(n.gater.AllowTopic))
 	if err != nil {
 		return fmt.Errorf("failed to create pubsub: %w", err)
 	}
@@ -12,11 +12,15 @@ import (
 	"errors"
 	"fmt"
 	// "io"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/berrythewa/clipman-daemon/internal/sync/discovery"
 	// "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -59,6 +63,7 @@ type PairingResponse struct {
 	PeerID        string            `json:"peer_id"`       // Responder's peer ID
 	Metadata      map[string]string `json:"metadata"`      // Additional device metadata
 	ValidUntil    time.Time         `json:"valid_until"`   // When this pairing expires (0 = never)
+	GroupKeys     []GroupKey        `json:"group_keys,omitempty"` // Keys of the responder's groups, for the requester to join them
 }
 
 // PairedDevice represents a device that has been paired
@@ -71,6 +76,8 @@ type PairedDevice struct {
 	Addresses   []string          `json:"addresses"`    // Known addresses for this device
 	Metadata    map[string]string `json:"metadata"`     // Additional device metadata
 	Capabilities []string         `json:"capabilities"` // Device capabilities
+	PublicKey   []byte            `json:"public_key,omitempty"` // Identity key the device signs messages with
+	RevocationRequestedBy []string `json:"revocation_requested_by,omitempty"` // Paired devices that revoked this one, awaiting confirmation here
 }
 
 // PairingManager implements the pairing protocol
@@ -90,11 +97,15 @@ type PairingManager struct {
 	pairingTimeout    *time.Timer
 	pairedDevices     map[string]PairedDevice
 	devicesLock       sync.RWMutex
+	devicesModTime    time.Time // Modification time of the paired devices file when it was last read
 	pairingInProgress bool
+
+	// Group keys handed to devices that pair with this one
+	keyring *GroupKeyring
 }
 
 // NewPairingManager creates a new pairing manager
-func NewPairingManager(ctx context.Context, host host.Host, pm *ProtocolManager, config *SyncConfig, logger *zap.Logger) *PairingManager {
+func NewPairingManager(ctx context.Context, host host.Host, pm *ProtocolManager, keyring *GroupKeyring, config *SyncConfig, logger *zap.Logger) *PairingManager {
 	pairingCtx, cancel := context.WithCancel(ctx)
 
 	manager := &PairingManager{
@@ -106,6 +117,7 @@ func NewPairingManager(ctx context.Context, host host.Host, pm *ProtocolManager,
 		protocolManager: pm,
 		pairingEnabled: false,
 		pairedDevices:  make(map[string]PairedDevice),
+		keyring:        keyring,
 	}
 
 	// Create and register the protocol handler
@@ -177,11 +189,8 @@ func (pm *PairingManager) Stop() error {
 		pm.pairingEnabled = false
 	}
 	
-	// Save paired devices
-	if err := pm.savePairedDevices(); err != nil {
-		pm.logger.Warn("Failed to save paired devices", zap.Error(err))
-	}
-	
+	// Paired devices are saved as they change, saving here could overwrite
+	// devices paired by another process
 	pm.cancel()
 	return nil
 }
@@ -349,6 +358,13 @@ func (pm *PairingManager) RequestPairing(address string) (*PairingResponse, erro
 		// Set the pairing code in the response
 		response.PairingCode = pairingCode
 		
+		// Join the responder's groups with the keys it handed over
+		if result, err := pm.keyring.Import(response.GroupKeys, addrInfo.ID.String(), true); err != nil {
+			pm.logger.Warn("Failed to store group keys from paired device", zap.Error(err))
+		} else if len(result.changed) > 0 {
+			pm.logger.Info("Received group keys from paired device", zap.Strings("groups", result.changed))
+		}
+		
 		// Create paired device entry
 		device := PairedDevice{
 			PeerID:     addrInfo.ID.String(),
@@ -358,6 +374,7 @@ func (pm *PairingManager) RequestPairing(address string) (*PairingResponse, erro
 			PairedAt:   time.Now(),
 			Addresses:  []string{},
 			Metadata:   response.Metadata,
+			PublicKey:  pm.marshalPublicKey(addrInfo.ID),
 		}
 		
 		// Add addresses
@@ -371,6 +388,7 @@ func (pm *PairingManager) RequestPairing(address string) (*PairingResponse, erro
 		}
 		
 		// Add to paired devices
+		pm.reloadPairedDevices()
 		pm.devicesLock.Lock()
 		pm.pairedDevices[addrInfo.ID.String()] = device
 		pm.devicesLock.Unlock()
@@ -398,6 +416,8 @@ func (pm *PairingManager) RequestPairing(address string) (*PairingResponse, erro
 
 // IsPaired checks if a device is paired
 func (pm *PairingManager) IsPaired(peerID string) bool {
+	pm.reloadPairedDevices()
+
 	pm.devicesLock.RLock()
 	defer pm.devicesLock.RUnlock()
 	
@@ -405,8 +425,45 @@ func (pm *PairingManager) IsPaired(peerID string) bool {
 	return exists
 }
 
+// GetPairedDevice returns a paired device by its peer ID
+func (pm *PairingManager) GetPairedDevice(peerID string) (PairedDevice, bool) {
+	pm.reloadPairedDevices()
+
+	pm.devicesLock.RLock()
+	defer pm.devicesLock.RUnlock()
+	
+	device, exists := pm.pairedDevices[peerID]
+	return device, exists
+}
+
+// DevicePublicKey returns the identity key of a paired device, used to verify its messages
+func (pm *PairingManager) DevicePublicKey(peerID string) (crypto.PubKey, error) {
+	device, ok := pm.GetPairedDevice(peerID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSender, peerID)
+	}
+	return devicePublicKey(device)
+}
+
+// marshalPublicKey returns the identity key of a peer as known to the host, to
+// record it with the paired device
+func (pm *PairingManager) marshalPublicKey(id peer.ID) []byte {
+	key := pm.host.Peerstore().PubKey(id)
+	if key == nil {
+		return nil
+	}
+	data, err := crypto.MarshalPublicKey(key)
+	if err != nil {
+		pm.logger.Debug("Failed to encode peer public key", zap.String("peer_id", id.String()), zap.Error(err))
+		return nil
+	}
+	return data
+}
+
 // GetPairedDevices returns all paired devices
 func (pm *PairingManager) GetPairedDevices() []PairedDevice {
+	pm.reloadPairedDevices()
+
 	pm.devicesLock.RLock()
 	defer pm.devicesLock.RUnlock()
 	
@@ -419,14 +476,16 @@ func (pm *PairingManager) GetPairedDevices() []PairedDevice {
 
 // RemovePairedDevice removes a paired device
 func (pm *PairingManager) RemovePairedDevice(peerID string) error {
+	pm.reloadPairedDevices()
+
 	pm.devicesLock.Lock()
-	defer pm.devicesLock.Unlock()
-	
 	if _, exists := pm.pairedDevices[peerID]; !exists {
+		pm.devicesLock.Unlock()
 		return fmt.Errorf("device not paired: %s", peerID)
 	}
 	
 	delete(pm.pairedDevices, peerID)
+	pm.devicesLock.Unlock()
 	
 	// Save paired devices
 	if err := pm.savePairedDevices(); err != nil {
@@ -437,16 +496,45 @@ func (pm *PairingManager) RemovePairedDevice(peerID string) error {
 	return nil
 }
 
+// RequestRevocation records that another paired device revoked this one,
+// for the user to confirm. It reports whether the request is new.
+func (pm *PairingManager) RequestRevocation(peerID string, by string) (bool, error) {
+	pm.reloadPairedDevices()
+
+	pm.devicesLock.Lock()
+	device, exists := pm.pairedDevices[peerID]
+	if !exists {
+		pm.devicesLock.Unlock()
+		return false, fmt.Errorf("device not paired: %s", peerID)
+	}
+	if containsString(device.RevocationRequestedBy, by) {
+		pm.devicesLock.Unlock()
+		return false, nil
+	}
+	
+	device.RevocationRequestedBy = append(device.RevocationRequestedBy, by)
+	pm.pairedDevices[peerID] = device
+	pm.devicesLock.Unlock()
+	
+	// Save paired devices
+	if err := pm.savePairedDevices(); err != nil {
+		return true, fmt.Errorf("failed to save paired devices: %w", err)
+	}
+	return true, nil
+}
+
 // UpdatePairedDevice updates a paired device's information
 func (pm *PairingManager) UpdatePairedDevice(device PairedDevice) error {
+	pm.reloadPairedDevices()
+
 	pm.devicesLock.Lock()
-	defer pm.devicesLock.Unlock()
-	
 	if _, exists := pm.pairedDevices[device.PeerID]; !exists {
+		pm.devicesLock.Unlock()
 		return fmt.Errorf("device not paired: %s", device.PeerID)
 	}
 	
 	pm.pairedDevices[device.PeerID] = device
+	pm.devicesLock.Unlock()
 	
 	// Save paired devices
 	if err := pm.savePairedDevices(); err != nil {
@@ -534,6 +622,7 @@ func (pm *PairingManager) handlePairingRequest(reader *bufio.Reader, writer *buf
 			PairedAt:   time.Now(),
 			Addresses:  []string{},
 			Metadata:   request.Metadata,
+			PublicKey:  pm.marshalPublicKey(remotePeer),
 		}
 		
 		// Get addresses
@@ -547,6 +636,7 @@ func (pm *PairingManager) handlePairingRequest(reader *bufio.Reader, writer *buf
 		}
 		
 		// Add to paired devices
+		pm.reloadPairedDevices()
 		pm.devicesLock.Lock()
 		pm.pairedDevices[remotePeer.String()] = device
 		pm.devicesLock.Unlock()
@@ -556,6 +646,13 @@ func (pm *PairingManager) handlePairingRequest(reader *bufio.Reader, writer *buf
 			pm.logger.Warn("Failed to save paired devices", zap.Error(err))
 		}
 		
+		// Hand over the group keys so the new device can read group traffic
+		groupKeys, err := pm.groupKeysForPairing(remotePeer)
+		if err != nil {
+			pm.logger.Error("Failed to get group keys for paired device", zap.Error(err))
+		}
+		response.GroupKeys = groupKeys
+		
 		pm.logger.Info("Accepted pairing request",
 			zap.String("peer_id", remotePeer.String()),
 			zap.String("verification_code", pairingCode))
@@ -592,6 +689,20 @@ func (pm *PairingManager) handleVerification(reader *bufio.Reader, writer *bufio
 
 // Helper functions
 
+// groupKeysForPairing makes a newly paired device a member of the default
+// group, creating its key if this device has none yet, and returns the keys
+// of the groups the device is a member of. Other groups are shared by
+// adding the device to them.
+func (pm *PairingManager) groupKeysForPairing(id peer.ID) ([]GroupKey, error) {
+	if _, err := pm.keyring.Ensure(DefaultGroup); err != nil {
+		return nil, err
+	}
+	if _, err := pm.keyring.Grant(DefaultGroup, id.String()); err != nil {
+		return nil, err
+	}
+	return pm.keyring.KeysFor(id.String()), nil
+}
+
 // sendPairingError sends an error response
 func sendPairingError(writer *bufio.Writer, message string) {
 	// Create error response
@@ -639,22 +750,89 @@ func generateVerificationCode(initiatorRandom, responderRandom string) string {
 	return fmt.Sprintf("%06d", code)
 }
 
-// savePairedDevices saves paired devices to disk
+// savePairedDevices saves paired devices to disk. The caller must not hold devicesLock.
 func (pm *PairingManager) savePairedDevices() error {
-	// In a real implementation, save to a file or database
-	// For now, just log that we would save them
-	pm.logger.Debug("Would save paired devices", zap.Int("count", len(pm.pairedDevices)))
+	if pm.config.PairedDevicesPath == "" {
+		return nil
+	}
+
+	pm.devicesLock.Lock()
+	defer pm.devicesLock.Unlock()
+
+	devices := make([]PairedDevice, 0, len(pm.pairedDevices))
+	for _, device := range pm.pairedDevices {
+		devices = append(devices, device)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].PeerID < devices[j].PeerID })
+
+	data, err := json.MarshalIndent(devices, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode paired devices: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(pm.config.PairedDevicesPath), 0700); err != nil {
+		return fmt.Errorf("failed to create paired devices directory: %w", err)
+	}
+	tmp := pm.config.PairedDevicesPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write paired devices: %w", err)
+	}
+	if err := os.Rename(tmp, pm.config.PairedDevicesPath); err != nil {
+		return fmt.Errorf("failed to write paired devices: %w", err)
+	}
+	if info, err := os.Stat(pm.config.PairedDevicesPath); err == nil {
+		pm.devicesModTime = info.ModTime()
+	}
+
+	pm.logger.Debug("Saved paired devices", zap.Int("count", len(devices)))
 	return nil
 }
 
-// loadPairedDevices loads paired devices from disk
+// loadPairedDevices loads paired devices from disk if the file changed since
+// it was last read, e.g. after the pair command paired a device
 func (pm *PairingManager) loadPairedDevices() error {
-	// In a real implementation, load from a file or database
-	// For now, just log that we would load them
-	pm.logger.Debug("Would load paired devices")
+	if pm.config.PairedDevicesPath == "" {
+		return nil
+	}
+	info, err := os.Stat(pm.config.PairedDevicesPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	pm.devicesLock.Lock()
+	defer pm.devicesLock.Unlock()
+	if info.ModTime().Equal(pm.devicesModTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(pm.config.PairedDevicesPath)
+	if err != nil {
+		return fmt.Errorf("failed to read paired devices: %w", err)
+	}
+	var devices []PairedDevice
+	if err := json.Unmarshal(data, &devices); err != nil {
+		return fmt.Errorf("failed to parse paired devices: %w", err)
+	}
+
+	pm.pairedDevices = make(map[string]PairedDevice, len(devices))
+	for _, device := range devices {
+		pm.pairedDevices[device.PeerID] = device
+	}
+	pm.devicesModTime = info.ModTime()
+
+	pm.logger.Debug("Loaded paired devices", zap.Int("count", len(devices)))
 	return nil
 }
 
+// reloadPairedDevices picks up devices paired by another process
+func (pm *PairingManager) reloadPairedDevices() {
+	if err := pm.loadPairedDevices(); err != nil {
+		pm.logger.Warn("Failed to reload paired devices", zap.Error(err))
+	}
+}
+
 // getDiscoveryService gets the manual discovery service
 func (pm *PairingManager) getDiscoveryService() (*discovery.ManualDiscovery, error) {
 	// Type check if the host implements the interface we need
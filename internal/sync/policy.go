@@ -0,0 +1,334 @@
+// Package sync provides clipboard synchronization using libp2p
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"go.uber.org/zap"
+)
+
+// Directions content is synced in under a policy
+const (
+	DirectionBoth    = "both"
+	DirectionSend    = "send"
+	DirectionReceive = "receive"
+)
+
+// ErrPolicyDenied is returned when a sync policy doesn't allow content
+var ErrPolicyDenied = errors.New("not allowed by sync policy")
+
+// SyncPolicy restricts the content synced with a paired device or through a
+// group, on top of the global clipboard types and size limit. The zero
+// policy allows everything.
+type SyncPolicy struct {
+	Types      []string `json:"types,omitempty"`       // Clipboard types synced ("text", "image", "files", "url"), all if empty
+	MaxSizeKB  int      `json:"max_size_kb,omitempty"` // Largest content synced, 0 for no limit beyond the global one
+	Direction  string   `json:"direction,omitempty"`   // "send", "receive" or "both", both if empty
+	QuietHours string   `json:"quiet_hours,omitempty"` // Local time range nothing is synced in, e.g. "22:00-07:00"
+}
+
+// IsZero reports whether the policy allows everything
+func (p SyncPolicy) IsZero() bool {
+	return len(p.Types) == 0 && p.MaxSizeKB == 0 && (p.Direction == "" || p.Direction == DirectionBoth) && p.QuietHours == ""
+}
+
+// Validate checks the policy's types, size, direction and quiet hours
+func (p SyncPolicy) Validate() error {
+	if err := (GroupRoute{Types: p.Types}).Validate(); err != nil {
+		return err
+	}
+	if p.MaxSizeKB < 0 {
+		return fmt.Errorf("max size cannot be negative")
+	}
+	switch p.Direction {
+	case "", DirectionBoth, DirectionSend, DirectionReceive:
+	default:
+		return fmt.Errorf("invalid direction %q: use send, receive or both", p.Direction)
+	}
+	if p.QuietHours != "" {
+		if _, _, err := parseQuietHours(p.QuietHours); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check returns why content can't be synced in a direction at a time, nil
+// if it can. Snippets are synced whatever the types.
+func (p SyncPolicy) Check(content *types.ClipboardContent, direction string, now time.Time) error {
+	return p.check(content.Type, int64(len(content.Data)), direction, now)
+}
+
+// check is Check for content known by its type and size, as announced
+func (p SyncPolicy) check(contentType types.ContentType, size int64, direction string, now time.Time) error {
+	if !p.allowsDirection(direction) {
+		return fmt.Errorf("%w: only %s", ErrPolicyDenied, describeDirection(p.Direction))
+	}
+	if p.inQuietHours(now) {
+		return fmt.Errorf("%w: quiet hours %s", ErrPolicyDenied, p.QuietHours)
+	}
+	if p.MaxSizeKB > 0 && size > int64(p.MaxSizeKB)*1024 {
+		return fmt.Errorf("%w: larger than %d KB", ErrPolicyDenied, p.MaxSizeKB)
+	}
+	if contentType != types.TypeSnippet && !(historyFilter{Types: p.Types}).allowsCategory(contentCategory(contentType)) {
+		return fmt.Errorf("%w: %s content", ErrPolicyDenied, contentType)
+	}
+	return nil
+}
+
+// allowsDirection reports whether content is synced in a direction
+func (p SyncPolicy) allowsDirection(direction string) bool {
+	return p.Direction == "" || p.Direction == DirectionBoth || p.Direction == direction
+}
+
+// inQuietHours reports whether a time is in the policy's quiet hours
+func (p SyncPolicy) inQuietHours(now time.Time) bool {
+	if p.QuietHours == "" {
+		return false
+	}
+	start, end, err := parseQuietHours(p.QuietHours)
+	if err != nil {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	// The range spans midnight
+	return minute >= start || minute < end
+}
+
+// restrict narrows a history filter to what the policy syncs in a direction,
+// for reconciling history with a paired device. Quiet hours are left to the
+// caller, as the filter is exchanged with the device.
+func (p SyncPolicy) restrict(filter historyFilter, direction string) historyFilter {
+	if !p.allowsDirection(direction) {
+		filter.Closed = true
+		return filter
+	}
+	if p.MaxSizeKB > 0 && (filter.MaxSizeKB == 0 || p.MaxSizeKB < filter.MaxSizeKB) {
+		filter.MaxSizeKB = p.MaxSizeKB
+	}
+	if len(p.Types) == 0 {
+		return filter
+	}
+
+	// Keep the types both allow; text implies URLs in both
+	var allowed []string
+	for _, category := range []string{"text", "url", "image", "files"} {
+		if category == "url" && containsString(allowed, "text") {
+			continue
+		}
+		if (historyFilter{Types: p.Types}).allowsCategory(category) && filter.allowsCategory(category) {
+			allowed = append(allowed, category)
+		}
+	}
+	if len(allowed) == 0 {
+		filter.Closed = true
+	}
+	filter.Types = allowed
+	return filter
+}
+
+// Describe describes the policy for listings
+func (p SyncPolicy) Describe() string {
+	if p.IsZero() {
+		return "no restrictions"
+	}
+	var rules []string
+	if len(p.Types) > 0 {
+		rules = append(rules, "types "+strings.Join(p.Types, ","))
+	}
+	if p.MaxSizeKB > 0 {
+		rules = append(rules, fmt.Sprintf("up to %d KB", p.MaxSizeKB))
+	}
+	if p.Direction != "" && p.Direction != DirectionBoth {
+		rules = append(rules, describeDirection(p.Direction))
+	}
+	if p.QuietHours != "" {
+		rules = append(rules, "quiet "+p.QuietHours)
+	}
+	return strings.Join(rules, ", ")
+}
+
+// describeDirection describes the direction content is synced in
+func describeDirection(direction string) string {
+	switch direction {
+	case DirectionSend:
+		return "send only"
+	case DirectionReceive:
+		return "receive only"
+	}
+	return "send and receive"
+}
+
+// parseQuietHours parses a "HH:MM-HH:MM" range into minutes since midnight
+func parseQuietHours(quiet string) (int, int, error) {
+	from, to, ok := strings.Cut(quiet, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid quiet hours %q: use HH:MM-HH:MM", quiet)
+	}
+	start, err := time.Parse("15:04", strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid quiet hours %q: use HH:MM-HH:MM", quiet)
+	}
+	end, err := time.Parse("15:04", strings.TrimSpace(to))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid quiet hours %q: use HH:MM-HH:MM", quiet)
+	}
+	return start.Hour()*60 + start.Minute(), end.Hour()*60 + end.Minute(), nil
+}
+
+// containsString reports whether a list holds a string
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Policies are the sync policies of paired devices and groups
+type Policies struct {
+	Peers  map[string]SyncPolicy `json:"peers,omitempty"`  // By peer ID
+	Groups map[string]SyncPolicy `json:"groups,omitempty"` // By group name
+}
+
+// PolicyStore persists the sync policies of paired devices and groups
+type PolicyStore struct {
+	file   *jsonFile
+	logger *zap.Logger
+
+	mutex    sync.RWMutex
+	policies Policies
+}
+
+// NewPolicyStore creates a policy store at path and loads the saved policies
+func NewPolicyStore(path string, logger *zap.Logger) *PolicyStore {
+	store := &PolicyStore{
+		file:   newJSONFile(path, "sync policies"),
+		logger: logger.With(zap.String("component", "policy-store")),
+	}
+	if err := store.refresh(); err != nil {
+		store.logger.Warn("Failed to load sync policies", zap.Error(err))
+	}
+	return store
+}
+
+// All returns every policy
+func (s *PolicyStore) All() Policies {
+	s.reload()
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	all := Policies{
+		Peers:  make(map[string]SyncPolicy, len(s.policies.Peers)),
+		Groups: make(map[string]SyncPolicy, len(s.policies.Groups)),
+	}
+	for id, policy := range s.policies.Peers {
+		all.Peers[id] = policy
+	}
+	for name, policy := range s.policies.Groups {
+		all.Groups[name] = policy
+	}
+	return all
+}
+
+// Peer returns the policy of a paired device
+func (s *PolicyStore) Peer(id string) SyncPolicy {
+	s.reload()
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.policies.Peers[id]
+}
+
+// Group returns the policy of a group
+func (s *PolicyStore) Group(name string) SyncPolicy {
+	s.reload()
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.policies.Groups[name]
+}
+
+// SetPeer sets the policy of a paired device, the zero policy removes it
+func (s *PolicyStore) SetPeer(id string, policy SyncPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	return s.set(func(p *Policies) *map[string]SyncPolicy { return &p.Peers }, id, policy)
+}
+
+// SetGroup sets the policy of a group, the zero policy removes it
+func (s *PolicyStore) SetGroup(name string, policy SyncPolicy) error {
+	if err := ValidateGroupName(name); err != nil {
+		return err
+	}
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	return s.set(func(p *Policies) *map[string]SyncPolicy { return &p.Groups }, name, policy)
+}
+
+// set stores a policy in the policy map selected by which and saves the
+// policies. The map is selected after reading the saved policies, which
+// replaces it.
+func (s *PolicyStore) set(which func(*Policies) *map[string]SyncPolicy, key string, policy SyncPolicy) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.update(func() error {
+		policies := which(&s.policies)
+		if policy.IsZero() {
+			delete(*policies, key)
+		} else {
+			if *policies == nil {
+				*policies = make(map[string]SyncPolicy)
+			}
+			(*policies)[key] = policy
+		}
+		return nil
+	})
+}
+
+// reload picks up policies saved by another process, such as the policy command
+func (s *PolicyStore) reload() {
+	if err := s.refresh(); err != nil {
+		s.logger.Warn("Failed to reload sync policies", zap.Error(err))
+	}
+}
+
+// refresh reads the policy file if it changed since it was last read
+func (s *PolicyStore) refresh() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.file.refresh(s.decode)
+}
+
+// update applies a change to the policies saved on disk and saves them. The
+// caller holds the lock.
+func (s *PolicyStore) update(change func() error) error {
+	return s.file.update(s.decode, change, s.encode)
+}
+
+// decode replaces the policies with those read from the file. The caller holds the lock.
+func (s *PolicyStore) decode(data []byte) error {
+	var policies Policies
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return err
+	}
+	s.policies = policies
+	return nil
+}
+
+// encode returns the policies to save. The caller holds the lock.
+func (s *PolicyStore) encode() any {
+	return s.policies
+}
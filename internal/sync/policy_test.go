@@ -0,0 +1,149 @@
+package sync
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"go.uber.org/zap"
+)
+
+// testHistory is a history source holding fixed content
+type testHistory []*types.ClipboardContent
+
+func (h testHistory) GetContentSince(since time.Time) ([]*types.ClipboardContent, error) {
+	return h, nil
+}
+
+func TestSyncPolicyCheck(t *testing.T) {
+	text := &types.ClipboardContent{Type: types.TypeText, Data: []byte("hello")}
+	url := &types.ClipboardContent{Type: types.TypeURL, Data: []byte("https://example.com")}
+	image := &types.ClipboardContent{Type: types.TypeImage, Data: bytes.Repeat([]byte{1}, 2048)}
+	snippet := &types.ClipboardContent{Type: types.TypeSnippet, Data: []byte("{}")}
+	noon := time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name      string
+		policy    SyncPolicy
+		content   *types.ClipboardContent
+		direction string
+		at        time.Time
+		allowed   bool
+	}{
+		{"no restrictions", SyncPolicy{}, image, DirectionSend, noon, true},
+		{"type allowed", SyncPolicy{Types: []string{"text"}}, text, DirectionReceive, noon, true},
+		{"URLs count as text", SyncPolicy{Types: []string{"text"}}, url, DirectionReceive, noon, true},
+		{"type not allowed", SyncPolicy{Types: []string{"text", "url"}}, image, DirectionReceive, noon, false},
+		{"snippets whatever the types", SyncPolicy{Types: []string{"image"}}, snippet, DirectionSend, noon, true},
+		{"over the size cap", SyncPolicy{MaxSizeKB: 1}, image, DirectionSend, noon, false},
+		{"within the size cap", SyncPolicy{MaxSizeKB: 2}, image, DirectionSend, noon, true},
+		{"receive only blocks sending", SyncPolicy{Direction: DirectionReceive}, text, DirectionSend, noon, false},
+		{"receive only allows receiving", SyncPolicy{Direction: DirectionReceive}, text, DirectionReceive, noon, true},
+		{"send only blocks receiving", SyncPolicy{Direction: DirectionSend}, text, DirectionReceive, noon, false},
+		{"in quiet hours", SyncPolicy{QuietHours: "11:30-13:00"}, text, DirectionSend, noon, false},
+		{"after quiet hours", SyncPolicy{QuietHours: "11:30-12:00"}, text, DirectionSend, noon, true},
+		{"quiet hours over midnight", SyncPolicy{QuietHours: "22:00-07:00"}, text, DirectionSend, noon.Add(11 * time.Hour), false},
+		{"outside quiet hours over midnight", SyncPolicy{QuietHours: "22:00-07:00"}, text, DirectionSend, noon, true},
+	}
+	for _, test := range tests {
+		err := test.policy.Check(test.content, test.direction, test.at)
+		if (err == nil) != test.allowed {
+			t.Errorf("%s: Check = %v, want allowed %v", test.name, err, test.allowed)
+		}
+		if err != nil && !errors.Is(err, ErrPolicyDenied) {
+			t.Errorf("%s: error %v is not ErrPolicyDenied", test.name, err)
+		}
+	}
+}
+
+func TestSyncPolicyValidate(t *testing.T) {
+	valid := SyncPolicy{Types: []string{"text", "url"}, MaxSizeKB: 512, Direction: DirectionReceive, QuietHours: "22:00-07:00"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid policy: %v", err)
+	}
+	for _, policy := range []SyncPolicy{
+		{Types: []string{"video"}},
+		{MaxSizeKB: -1},
+		{Direction: "sideways"},
+		{QuietHours: "22:00"},
+		{QuietHours: "25:00-07:00"},
+	} {
+		if err := policy.Validate(); err == nil {
+			t.Errorf("accepted invalid policy %+v", policy)
+		}
+	}
+}
+
+// The work laptop receives only text from the home desktop, which sends it
+// everything. Both select the same history, so nothing is sent that the
+// laptop would drop.
+func TestSyncPolicyHistoryFilters(t *testing.T) {
+	base := time.Now().Add(-time.Hour)
+	text := &types.ClipboardContent{Type: types.TypeText, Data: []byte("notes"), Created: base}
+	image := &types.ClipboardContent{Type: types.TypeImage, Data: []byte{1, 2, 3}, Created: base.Add(time.Minute)}
+	global := historyFilter{MaxSizeKB: 512, Types: []string{"text", "image"}}
+
+	laptopPolicy := SyncPolicy{Types: []string{"text"}, Direction: DirectionReceive}
+	laptopAccepts := laptopPolicy.restrict(global, DirectionReceive)
+	laptopSends := laptopPolicy.restrict(global, DirectionSend)
+	if !laptopSends.Closed || laptopAccepts.Closed || len(laptopAccepts.Types) != 1 {
+		t.Fatalf("laptop filters: accepts %+v, sends %+v", laptopAccepts, laptopSends)
+	}
+
+	desktop, err := sharedHistory(testHistory{text, image}, base.Add(-time.Hour), global, global, laptopAccepts, laptopSends)
+	if err != nil {
+		t.Fatal(err)
+	}
+	laptop, err := sharedHistory(testHistory{image}, base.Add(-time.Hour), laptopAccepts, laptopSends, global, global)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(desktop) != 1 || desktop[text.ID()] == nil || len(laptop) != 0 {
+		t.Fatalf("shared history: desktop %d items, laptop %d items", len(desktop), len(laptop))
+	}
+
+	want := laptop.missingFrom(desktop.entriesIn([]time.Time{bucketOf(text)}))
+	if sent := desktop.sendable(want, global, laptopAccepts); len(sent) != 1 || sent[0] != text.ID() {
+		t.Errorf("desktop sends %v", sent)
+	}
+	if sent := laptop.sendable([]string{image.ID()}, laptopSends, global); len(sent) != 0 {
+		t.Errorf("receive-only laptop sends %v", sent)
+	}
+}
+
+func TestPolicyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.json")
+	policies := NewPolicyStore(path, zap.NewNop())
+
+	peerPolicy := SyncPolicy{Types: []string{"text"}, Direction: DirectionReceive}
+	if err := policies.SetPeer("peer-a", peerPolicy); err != nil {
+		t.Fatal(err)
+	}
+	if err := policies.SetGroup("work", SyncPolicy{QuietHours: "19:00-08:00"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := policies.SetGroup("work", SyncPolicy{Direction: "sideways"}); err == nil {
+		t.Error("stored an invalid policy")
+	}
+	if err := policies.SetGroup("bad name", SyncPolicy{MaxSizeKB: 1}); !errors.Is(err, ErrInvalidGroupName) {
+		t.Errorf("policy for an invalid group name: %v", err)
+	}
+
+	// Policies survive a restart, the zero policy removes one
+	reloaded := NewPolicyStore(path, zap.NewNop())
+	if got := reloaded.Peer("peer-a"); got.Direction != DirectionReceive || len(got.Types) != 1 {
+		t.Errorf("reloaded peer policy = %+v", got)
+	}
+	if got := reloaded.Group("work"); got.QuietHours != "19:00-08:00" {
+		t.Errorf("reloaded group policy = %+v", got)
+	}
+	if err := reloaded.SetPeer("peer-a", SyncPolicy{}); err != nil {
+		t.Fatal(err)
+	}
+	if all := policies.All(); len(all.Peers) != 0 || len(all.Groups) != 1 {
+		t.Errorf("policies after clearing = %+v", all)
+	}
+}
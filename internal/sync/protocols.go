@@ -2,7 +2,9 @@
 package sync
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -23,9 +25,12 @@ const (
 	BaseProtocolPath = "/clipman/"
 
 	// Full protocol paths
-	PairingProtocolPath   = BaseProtocolPath + ProtocolVersion + "/pairing"
-	ClipboardProtocolPath = BaseProtocolPath + ProtocolVersion + "/clipboard"
-	FileProtocolPath      = BaseProtocolPath + ProtocolVersion + "/file"
+	PairingProtocolPath    = BaseProtocolPath + ProtocolVersion + "/pairing"
+	ClipboardProtocolPath  = BaseProtocolPath + ProtocolVersion + "/clipboard"
+	FileProtocolPath       = BaseProtocolPath + ProtocolVersion + "/file"
+	GroupKeyProtocolPath   = BaseProtocolPath + ProtocolVersion + "/groupkey"
+	HistoryProtocolPath    = BaseProtocolPath + ProtocolVersion + "/history"
+	RevocationProtocolPath = BaseProtocolPath + ProtocolVersion + "/revocation"
 )
 
 // ProtocolHandler defines the interface for protocol-specific handlers
@@ -190,4 +195,16 @@ func readBytes(stream network.Stream, data []byte) error {
 	}
 	
 	return nil
-} 
\ No newline at end of file
+}
+
+// readHeader decodes a JSON header of at most limit bytes, as written by
+// json.Encoder, and returns a reader for the raw data that follows it
+func readHeader(r io.Reader, limit int64, header interface{}) (io.Reader, error) {
+	decoder := json.NewDecoder(io.LimitReader(r, limit))
+	if err := decoder.Decode(header); err != nil {
+		return nil, err
+	}
+	// The encoder ends the header with a newline
+	buffered, _ := io.ReadAll(decoder.Buffered())
+	return io.MultiReader(bytes.NewReader(bytes.TrimPrefix(buffered, []byte("\n"))), r), nil
+}
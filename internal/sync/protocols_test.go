@@ -0,0 +1,147 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"go.uber.org/zap"
+)
+
+// testPeer is a host on a mock network, paired with the other test peers
+type testPeer struct {
+	ctx       context.Context
+	host      host.Host
+	config    *SyncConfig
+	protocols *ProtocolManager
+	keyring   *GroupKeyring
+	pairing   *PairingManager
+}
+
+// newTestPeers creates count hosts on a mock network, linked with the given
+// options, connected and paired with each other. Protocol handlers are
+// added by the caller, then started with start.
+func newTestPeers(t *testing.T, count int, link mocknet.LinkOptions) (mocknet.Mocknet, []*testPeer) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	mn := mocknet.New()
+	t.Cleanup(func() { mn.Close() })
+	mn.SetLinkDefaults(link)
+
+	peers := make([]*testPeer, count)
+	for i := range peers {
+		h, err := mn.GenPeer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		config := &SyncConfig{EnableFileSharing: true, DefaultDownloadFolder: t.TempDir()}
+		protocols := NewProtocolManager(ctx, h, config, zap.NewNop())
+		keyring := newTestMemberKeyring(t, h.ID().String())
+		peers[i] = &testPeer{
+			ctx:       ctx,
+			host:      h,
+			config:    config,
+			protocols: protocols,
+			keyring:   keyring,
+			pairing:   NewPairingManager(ctx, h, protocols, keyring, config, zap.NewNop()),
+		}
+	}
+	for _, p := range peers {
+		for _, other := range peers {
+			if p == other {
+				continue
+			}
+			p.pairing.pairedDevices[other.id().String()] = PairedDevice{PeerID: other.id().String(), PairedAt: time.Now()}
+			// Let streams dial again after a disconnect
+			p.host.Peerstore().AddAddrs(other.id(), other.host.Addrs(), peerstore.PermanentAddrTTL)
+		}
+	}
+
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mn.ConnectAllButSelf(); err != nil {
+		t.Fatal(err)
+	}
+	return mn, peers
+}
+
+// id returns the peer's ID
+func (p *testPeer) id() peer.ID {
+	return p.host.ID()
+}
+
+// start starts the peer's protocol handlers
+func (p *testPeer) start(t *testing.T) {
+	t.Helper()
+	if err := p.protocols.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.protocols.Stop() })
+}
+
+// manager creates a started sync manager on the peer, with gossipsub for
+// group topics and in-memory group keys, policies and deny list
+func (p *testPeer) manager(t *testing.T) *Manager {
+	t.Helper()
+	ps, err := pubsub.NewGossipSub(p.ctx, p.host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies := NewPolicyStore("", zap.NewNop())
+	denied := NewDenyList("", zap.NewNop())
+	node := &Node{
+		host:          p.host,
+		ctx:           p.ctx,
+		config:        p.config,
+		logger:        zap.NewNop(),
+		protocols:     p.protocols,
+		pairing:       p.pairing,
+		keyring:       p.keyring,
+		keyExchange:   NewKeyExchange(p.ctx, p.host, p.protocols, p.keyring, p.pairing, zap.NewNop()),
+		policies:      policies,
+		denied:        denied,
+		revocation:    NewRevocationService(p.ctx, p.host, p.protocols, p.pairing, denied, policies, zap.NewNop()),
+		pubsub:        ps,
+		topics:        make(map[string]*pubsub.Topic),
+		subscriptions: make(map[string]*pubsub.Subscription),
+		topicEvents:   make(map[string]*pubsub.TopicEventHandler),
+		peerStore:     make(map[peer.ID]InternalPeerInfo),
+	}
+	return &Manager{
+		node:    node,
+		ctx:     p.ctx,
+		config:  p.config,
+		logger:  zap.NewNop(),
+		groups:  NewGroupStore("", zap.NewNop()),
+		replay:  NewReplayGuard(time.Minute, 100),
+		started: true,
+	}
+}
+
+// unpair forgets the pairing with another peer
+func (p *testPeer) unpair(other *testPeer) {
+	p.pairing.devicesLock.Lock()
+	defer p.pairing.devicesLock.Unlock()
+	delete(p.pairing.pairedDevices, other.id().String())
+}
+
+// waitFor polls cond until it holds or the test times out
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
@@ -0,0 +1,664 @@
+// Package sync provides clipboard synchronization using libp2p
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"go.uber.org/zap"
+)
+
+const (
+	// reconcileWindow is how far back history is reconciled
+	reconcileWindow = 72 * time.Hour
+
+	// reconcileBucket is the span of history summarized by one digest
+	reconcileBucket = time.Hour
+
+	// reconcileInterval is how often history is reconciled with connected devices
+	reconcileInterval = 30 * time.Minute
+
+	// reconcileDelay lets a new connection settle before reconciling over it
+	reconcileDelay = 2 * time.Second
+
+	// reconcileTimeout bounds one reconciliation
+	reconcileTimeout = 2 * time.Minute
+
+	// maxReconcileItems and maxReconcileBytes bound the items sent in one
+	// direction per reconciliation, the rest follow in later rounds
+	maxReconcileItems = 200
+	maxReconcileBytes = 16 * 1024 * 1024
+
+	// maxReconcileStreamSize bounds what is read from a reconciliation stream
+	maxReconcileStreamSize = 64 * 1024 * 1024
+)
+
+// HistorySource gives access to the local clipboard history
+type HistorySource interface {
+	GetContentSince(since time.Time) ([]*types.ClipboardContent, error)
+}
+
+// historyFilter is the content a device syncs, from its clipboard types and
+// maximum clipboard size
+type historyFilter struct {
+	MaxSizeKB int      `json:"max_size_kb,omitempty"`
+	Types     []string `json:"types,omitempty"`
+	Closed    bool     `json:"closed,omitempty"` // Nothing passes, a sync policy doesn't allow the direction
+}
+
+// allows reports whether content passes the filter. An empty type list
+// allows every type, URLs count as text.
+func (f historyFilter) allows(content *types.ClipboardContent) bool {
+	if f.Closed || content.Type == types.TypeSnippet {
+		return false
+	}
+	if f.MaxSizeKB > 0 && len(content.Data) > f.MaxSizeKB*1024 {
+		return false
+	}
+	return f.allowsCategory(contentCategory(content.Type))
+}
+
+// allowsCategory reports whether the filter's types include a clipboard type
+func (f historyFilter) allowsCategory(category string) bool {
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == category || (t == "text" && category == "url") {
+			return true
+		}
+	}
+	return false
+}
+
+// contentCategory maps a content type to its clipboard type name in the config
+func contentCategory(t types.ContentType) string {
+	switch t {
+	case types.TypeText, types.TypeString, types.TypeHTML, types.TypeRTF:
+		return "text"
+	case types.TypeURL:
+		return "url"
+	case types.TypeImage:
+		return "image"
+	case types.TypeFile, types.TypeFilePath:
+		return "files"
+	}
+	return ""
+}
+
+// historyEntry identifies an item of history and its content
+type historyEntry struct {
+	ID   string `json:"id"`
+	Hash string `json:"hash"`
+}
+
+// historyBucket summarizes the history in one bucket
+type historyBucket struct {
+	Start  time.Time `json:"start"`
+	Count  int       `json:"count"`
+	Digest string    `json:"digest"`
+}
+
+// Reconciliation messages, exchanged in this order over one stream
+type (
+	// reconcileHello opens a reconciliation
+	reconcileHello struct {
+		Since  time.Time      `json:"since"`
+		Filter historyFilter  `json:"filter"`          // What the initiator accepts
+		Sends  *historyFilter `json:"sends,omitempty"` // What the initiator sends, the filter if absent
+	}
+
+	// reconcileSummary answers with the responder's summary of the shared history
+	reconcileSummary struct {
+		Filter  historyFilter   `json:"filter"`          // What the responder accepts
+		Sends   *historyFilter  `json:"sends,omitempty"` // What the responder sends, the filter if absent
+		Buckets []historyBucket `json:"buckets"`
+	}
+
+	// reconcileEntries lists the initiator's items in the buckets that differ
+	reconcileEntries struct {
+		Buckets []time.Time    `json:"buckets"`
+		Entries []historyEntry `json:"entries"`
+	}
+
+	// reconcileItems carries missing items and asks for the ones wanted back
+	reconcileItems struct {
+		Want  []string                  `json:"want,omitempty"`
+		Items []*types.ClipboardContent `json:"items,omitempty"`
+	}
+)
+
+// historyItems is the local history shared with one peer, by ID
+type historyItems map[string]*types.ClipboardContent
+
+// historyEntryOf returns the history entry of an item
+func historyEntryOf(content *types.ClipboardContent) historyEntry {
+	hash := sha256.New()
+	hash.Write([]byte(content.Type))
+	hash.Write([]byte{0})
+	hash.Write(content.Data)
+	return historyEntry{ID: content.ID(), Hash: hex.EncodeToString(hash.Sum(nil)[:16])}
+}
+
+// bucketOf returns the start of the bucket an item falls in
+func bucketOf(content *types.ClipboardContent) time.Time {
+	return content.Created.UTC().Truncate(reconcileBucket)
+}
+
+// bucketKey identifies a bucket by its start in Unix seconds
+func bucketKey(start time.Time) int64 {
+	return start.Unix()
+}
+
+// summarize computes the digest of every bucket holding items
+func (items historyItems) summarize() []historyBucket {
+	entries := make(map[int64][]historyEntry)
+	for _, content := range items {
+		key := bucketKey(bucketOf(content))
+		entries[key] = append(entries[key], historyEntryOf(content))
+	}
+
+	buckets := make([]historyBucket, 0, len(entries))
+	for key, bucket := range entries {
+		sort.Slice(bucket, func(i, j int) bool { return bucket[i].ID < bucket[j].ID })
+		digest := sha256.New()
+		for _, entry := range bucket {
+			fmt.Fprintf(digest, "%s %s\n", entry.ID, entry.Hash)
+		}
+		buckets = append(buckets, historyBucket{
+			Start:  time.Unix(key, 0).UTC(),
+			Count:  len(bucket),
+			Digest: hex.EncodeToString(digest.Sum(nil)),
+		})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Start.Before(buckets[j].Start) })
+	return buckets
+}
+
+// entriesIn lists the items in the given buckets
+func (items historyItems) entriesIn(buckets []time.Time) []historyEntry {
+	wanted := make(map[int64]bool, len(buckets))
+	for _, start := range buckets {
+		wanted[bucketKey(start)] = true
+	}
+	var entries []historyEntry
+	for _, content := range items {
+		if wanted[bucketKey(bucketOf(content))] {
+			entries = append(entries, historyEntryOf(content))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries
+}
+
+// take returns the items with the given IDs, oldest first, within the limits
+// of one reconciliation
+func (items historyItems) take(ids []string) []*types.ClipboardContent {
+	var selected []*types.ClipboardContent
+	for _, id := range ids {
+		if content, ok := items[id]; ok {
+			selected = append(selected, content)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].Created.Before(selected[j].Created) })
+
+	size := 0
+	for i, content := range selected {
+		size += len(content.Data)
+		if i == maxReconcileItems || (i > 0 && size > maxReconcileBytes) {
+			return selected[:i]
+		}
+	}
+	return selected
+}
+
+// differingBuckets returns the buckets whose digests differ between two summaries
+func differingBuckets(local, remote []historyBucket) []time.Time {
+	digests := make(map[int64]string, len(remote))
+	for _, bucket := range remote {
+		digests[bucketKey(bucket.Start)] = bucket.Digest
+	}
+
+	var differing []time.Time
+	for _, bucket := range local {
+		key := bucketKey(bucket.Start)
+		if digest, ok := digests[key]; !ok || digest != bucket.Digest {
+			differing = append(differing, time.Unix(key, 0).UTC())
+		}
+		delete(digests, key)
+	}
+	for key := range digests {
+		differing = append(differing, time.Unix(key, 0).UTC())
+	}
+	sort.Slice(differing, func(i, j int) bool { return differing[i].Before(differing[j]) })
+	return differing
+}
+
+// missingFrom returns the IDs of entries that aren't in items
+func (items historyItems) missingFrom(entries []historyEntry) []string {
+	var missing []string
+	for _, entry := range entries {
+		if _, ok := items[entry.ID]; !ok {
+			missing = append(missing, entry.ID)
+		}
+	}
+	return missing
+}
+
+// Reconciler exchanges history with paired devices, so that items copied
+// while they were apart reach both. Devices compare digests of their history
+// per time bucket, list the items of the buckets that differ and send each
+// other what is missing. Only items both devices sync, by their clipboard
+// types and maximum clipboard size, take part.
+type Reconciler struct {
+	host     host.Host
+	ctx      context.Context
+	logger   *zap.Logger
+	pairing  *PairingManager
+	policies *PolicyStore
+	config   *SyncConfig
+
+	mutex   sync.Mutex
+	source  HistorySource
+	handler func(*types.ClipboardContent, peer.ID)
+	running map[peer.ID]bool
+	notifee *network.NotifyBundle
+	cancel  context.CancelFunc
+}
+
+// NewReconciler creates the history reconciler and registers its protocol handler
+func NewReconciler(ctx context.Context, host host.Host, pm *ProtocolManager, pairing *PairingManager, policies *PolicyStore, config *SyncConfig, logger *zap.Logger) *Reconciler {
+	r := &Reconciler{
+		host:     host,
+		ctx:      ctx,
+		logger:   logger.With(zap.String("component", "history-reconciler")),
+		pairing:  pairing,
+		policies: policies,
+		config:   config,
+		running:  make(map[peer.ID]bool),
+	}
+	pm.AddHandler(r)
+	return r
+}
+
+// ID returns the protocol ID
+func (r *Reconciler) ID() protocol.ID {
+	return protocol.ID(HistoryProtocolPath)
+}
+
+// SetHistory sets the history to reconcile and the handler for items
+// received from peers. Until it is set, reconciliation is refused.
+func (r *Reconciler) SetHistory(source HistorySource, handler func(*types.ClipboardContent, peer.ID)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.source = source
+	r.handler = handler
+}
+
+// Start reconciles with paired devices as they connect and periodically after
+func (r *Reconciler) Start() error {
+	r.notifee = &network.NotifyBundle{
+		ConnectedF: func(_ network.Network, conn network.Conn) {
+			remotePeer := conn.RemotePeer()
+			if !r.initiates(remotePeer) {
+				return
+			}
+			go func() {
+				select {
+				case <-time.After(reconcileDelay):
+				case <-r.ctx.Done():
+					return
+				}
+				r.reconcileLogged(remotePeer)
+			}()
+		},
+	}
+	r.host.Network().Notify(r.notifee)
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.cancel = cancel
+	go func() {
+		ticker := time.NewTicker(reconcileInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				for _, id := range r.host.Network().Peers() {
+					if r.initiates(id) {
+						r.reconcileLogged(id)
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops reconciling
+func (r *Reconciler) Stop() error {
+	if r.notifee != nil {
+		r.host.Network().StopNotify(r.notifee)
+		r.notifee = nil
+	}
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+	return nil
+}
+
+// initiates reports whether this device starts reconciliation with a peer.
+// Each exchange goes both ways, so only the device with the lower peer ID
+// starts it.
+func (r *Reconciler) initiates(id peer.ID) bool {
+	return r.host.ID() < id && r.pairing.IsPaired(id.String())
+}
+
+// reconcileLogged reconciles with a peer and logs the outcome
+func (r *Reconciler) reconcileLogged(id peer.ID) {
+	received, sent, err := r.Reconcile(id)
+	if err != nil {
+		r.logger.Debug("Failed to reconcile history", zap.String("peer_id", id.String()), zap.Error(err))
+		return
+	}
+	if received > 0 || sent > 0 {
+		r.logger.Info("Reconciled history",
+			zap.String("peer_id", id.String()),
+			zap.Int("received", received),
+			zap.Int("sent", sent))
+	}
+}
+
+// begin marks a reconciliation with a peer as running and returns the
+// history, or false when one is already running or no history is set
+func (r *Reconciler) begin(id peer.ID) (HistorySource, func(*types.ClipboardContent, peer.ID), bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.source == nil || r.handler == nil || r.running[id] {
+		return nil, nil, false
+	}
+	r.running[id] = true
+	return r.source, r.handler, true
+}
+
+// end marks a reconciliation with a peer as done
+func (r *Reconciler) end(id peer.ID) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.running, id)
+}
+
+// localFilter is the content this device syncs
+func (r *Reconciler) localFilter() historyFilter {
+	return historyFilter{MaxSizeKB: r.config.MaxClipboardSizeKB, Types: r.config.ClipboardTypes}
+}
+
+// peerFilters returns the content this device accepts from a peer and sends
+// to it, under the peer's sync policy. Nothing is exchanged in quiet hours.
+func (r *Reconciler) peerFilters(id peer.ID) (historyFilter, historyFilter) {
+	policy := r.policies.Peer(id.String())
+	accepts := policy.restrict(r.localFilter(), DirectionReceive)
+	sends := policy.restrict(r.localFilter(), DirectionSend)
+	if policy.inQuietHours(time.Now()) {
+		accepts.Closed, sends.Closed = true, true
+	}
+	return accepts, sends
+}
+
+// sharedHistory loads the local history since a time that one device sends
+// and the other accepts. Both devices select the same items from the
+// filters, so their summaries only differ by what is missing.
+func sharedHistory(source HistorySource, since time.Time, accepts, sends, remoteAccepts, remoteSends historyFilter) (historyItems, error) {
+	contents, err := source.GetContentSince(since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history: %w", err)
+	}
+	items := make(historyItems, len(contents))
+	for _, content := range contents {
+		if content.Created.Before(since) {
+			continue
+		}
+		if (sends.allows(content) && remoteAccepts.allows(content)) || (remoteSends.allows(content) && accepts.allows(content)) {
+			items[content.ID()] = content
+		}
+	}
+	return items, nil
+}
+
+// sendable keeps the IDs of items this device sends and the peer accepts
+func (items historyItems) sendable(ids []string, sends, remoteAccepts historyFilter) []string {
+	var allowed []string
+	for _, id := range ids {
+		if content, ok := items[id]; ok && sends.allows(content) && remoteAccepts.allows(content) {
+			allowed = append(allowed, id)
+		}
+	}
+	return allowed
+}
+
+// sendsFilter returns what a device sends, for devices that only send one filter
+func sendsFilter(accepts historyFilter, sends *historyFilter) historyFilter {
+	if sends == nil {
+		return accepts
+	}
+	return *sends
+}
+
+// accept passes received items to the handler. Only items that were asked
+// for and that this device syncs are accepted.
+func (r *Reconciler) accept(received []*types.ClipboardContent, wanted []string, since time.Time, filter historyFilter, handler func(*types.ClipboardContent, peer.ID), from peer.ID) int {
+	asked := make(map[string]bool, len(wanted))
+	for _, id := range wanted {
+		asked[id] = true
+	}
+
+	accepted := 0
+	for _, content := range received {
+		if content == nil || !asked[content.ID()] || content.Created.Before(since) || !filter.allows(content) {
+			continue
+		}
+		// Only one copy of each item is accepted
+		delete(asked, content.ID())
+		content.Compressed = false
+		handler(content, from)
+		accepted++
+	}
+	return accepted
+}
+
+// Reconcile exchanges missing history with a paired peer and returns the
+// number of items received and sent
+func (r *Reconciler) Reconcile(id peer.ID) (int, int, error) {
+	if !r.pairing.IsPaired(id.String()) {
+		return 0, 0, ErrUnknownSender
+	}
+	source, handler, ok := r.begin(id)
+	if !ok {
+		return 0, 0, nil
+	}
+	defer r.end(id)
+
+	ctx, cancel := context.WithTimeout(r.ctx, reconcileTimeout)
+	defer cancel()
+	stream, err := r.host.NewStream(ctx, id, r.ID())
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open stream to peer %s: %w", id, err)
+	}
+	defer stream.Close()
+	stop := context.AfterFunc(ctx, func() { stream.Reset() })
+	defer stop()
+	stream.SetDeadline(time.Now().Add(reconcileTimeout))
+
+	encoder := json.NewEncoder(stream)
+	decoder := json.NewDecoder(io.LimitReader(stream, maxReconcileStreamSize))
+	accepts, sends := r.peerFilters(id)
+	since := time.Now().Add(-reconcileWindow)
+
+	if err := encoder.Encode(reconcileHello{Since: since, Filter: accepts, Sends: &sends}); err != nil {
+		stream.Reset()
+		return 0, 0, fmt.Errorf("failed to send history request: %w", err)
+	}
+	var summary reconcileSummary
+	if err := decoder.Decode(&summary); err != nil {
+		stream.Reset()
+		return 0, 0, fmt.Errorf("failed to read history summary: %w", err)
+	}
+
+	remoteSends := sendsFilter(summary.Filter, summary.Sends)
+	items, err := sharedHistory(source, since, accepts, sends, summary.Filter, remoteSends)
+	if err != nil {
+		stream.Reset()
+		return 0, 0, err
+	}
+	differing := differingBuckets(items.summarize(), summary.Buckets)
+	if err := encoder.Encode(reconcileEntries{Buckets: differing, Entries: items.entriesIn(differing)}); err != nil {
+		stream.Reset()
+		return 0, 0, fmt.Errorf("failed to send history entries: %w", err)
+	}
+	if len(differing) == 0 {
+		return 0, 0, nil
+	}
+
+	var offered reconcileItems
+	if err := decoder.Decode(&offered); err != nil {
+		stream.Reset()
+		return 0, 0, fmt.Errorf("failed to read history items: %w", err)
+	}
+	// The responder only sends items that were missing here
+	missing := make([]string, 0, len(offered.Items))
+	for _, content := range offered.Items {
+		if content != nil {
+			if _, ok := items[content.ID()]; !ok && containsTime(differing, bucketOf(content)) {
+				missing = append(missing, content.ID())
+			}
+		}
+	}
+	received := r.accept(offered.Items, missing, since, accepts, handler, id)
+
+	if len(offered.Want) == 0 {
+		return received, 0, nil
+	}
+	sent := items.take(items.sendable(offered.Want, sends, summary.Filter))
+	if err := encoder.Encode(reconcileItems{Items: sent}); err != nil {
+		stream.Reset()
+		return received, 0, fmt.Errorf("failed to send history items: %w", err)
+	}
+	return received, len(sent), nil
+}
+
+// Handle answers a reconciliation started by a paired device
+func (r *Reconciler) Handle(stream network.Stream) {
+	defer stream.Close()
+	remotePeer := stream.Conn().RemotePeer()
+
+	if !r.pairing.IsPaired(remotePeer.String()) {
+		r.logger.Warn("Rejected history request from unpaired peer", zap.String("peer_id", remotePeer.String()))
+		stream.Reset()
+		return
+	}
+	source, handler, ok := r.begin(remotePeer)
+	if !ok {
+		stream.Reset()
+		return
+	}
+	defer r.end(remotePeer)
+
+	stream.SetDeadline(time.Now().Add(reconcileTimeout))
+	received, sent, err := r.respond(stream, source, handler, remotePeer)
+	if err != nil {
+		r.logger.Debug("Failed to reconcile history", zap.String("peer_id", remotePeer.String()), zap.Error(err))
+		stream.Reset()
+		return
+	}
+	if received > 0 || sent > 0 {
+		r.logger.Info("Reconciled history",
+			zap.String("peer_id", remotePeer.String()),
+			zap.Int("received", received),
+			zap.Int("sent", sent))
+	}
+}
+
+// respond runs the responder's side of a reconciliation
+func (r *Reconciler) respond(stream io.ReadWriter, source HistorySource, handler func(*types.ClipboardContent, peer.ID), from peer.ID) (int, int, error) {
+	encoder := json.NewEncoder(stream)
+	decoder := json.NewDecoder(io.LimitReader(stream, maxReconcileStreamSize))
+	accepts, sends := r.peerFilters(from)
+
+	var hello reconcileHello
+	if err := decoder.Decode(&hello); err != nil {
+		return 0, 0, fmt.Errorf("failed to read history request: %w", err)
+	}
+	since := hello.Since
+	if earliest := time.Now().Add(-reconcileWindow); since.Before(earliest) {
+		since = earliest
+	}
+
+	items, err := sharedHistory(source, since, accepts, sends, hello.Filter, sendsFilter(hello.Filter, hello.Sends))
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := encoder.Encode(reconcileSummary{Filter: accepts, Sends: &sends, Buckets: items.summarize()}); err != nil {
+		return 0, 0, fmt.Errorf("failed to send history summary: %w", err)
+	}
+
+	var entries reconcileEntries
+	if err := decoder.Decode(&entries); err != nil {
+		return 0, 0, fmt.Errorf("failed to read history entries: %w", err)
+	}
+	if len(entries.Buckets) == 0 {
+		return 0, 0, nil
+	}
+
+	// Ask for what the peer has and send what it lacks
+	want := items.missingFrom(entries.Entries)
+	if len(want) > maxReconcileItems {
+		want = want[:maxReconcileItems]
+	}
+	theirs := make(map[string]bool, len(entries.Entries))
+	for _, entry := range entries.Entries {
+		theirs[entry.ID] = true
+	}
+	var lacking []string
+	for _, entry := range items.entriesIn(entries.Buckets) {
+		if !theirs[entry.ID] {
+			lacking = append(lacking, entry.ID)
+		}
+	}
+	sent := items.take(items.sendable(lacking, sends, hello.Filter))
+	if err := encoder.Encode(reconcileItems{Want: want, Items: sent}); err != nil {
+		return 0, 0, fmt.Errorf("failed to send history items: %w", err)
+	}
+
+	if len(want) == 0 {
+		return 0, len(sent), nil
+	}
+	var returned reconcileItems
+	if err := decoder.Decode(&returned); err != nil {
+		return 0, len(sent), fmt.Errorf("failed to read history items: %w", err)
+	}
+	return r.accept(returned.Items, want, since, accepts, handler, from), len(sent), nil
+}
+
+// containsTime reports whether a time is in a list
+func containsTime(times []time.Time, t time.Time) bool {
+	for _, candidate := range times {
+		if candidate.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
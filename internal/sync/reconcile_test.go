@@ -0,0 +1,202 @@
+package sync
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"go.uber.org/zap"
+)
+
+func newTestHistory(contents ...*types.ClipboardContent) historyItems {
+	items := make(historyItems)
+	for _, content := range contents {
+		items[content.ID()] = content
+	}
+	return items
+}
+
+// testHistorySource is a history that keeps the items it receives
+type testHistorySource struct {
+	mutex sync.Mutex
+	items historyItems
+}
+
+func (h *testHistorySource) GetContentSince(since time.Time) ([]*types.ClipboardContent, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	var contents []*types.ClipboardContent
+	for _, content := range h.items {
+		if !content.Created.Before(since) {
+			contents = append(contents, content)
+		}
+	}
+	return contents, nil
+}
+
+func (h *testHistorySource) add(content *types.ClipboardContent, _ peer.ID) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.items[content.ID()] = content
+}
+
+func (h *testHistorySource) has(content *types.ClipboardContent) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	_, ok := h.items[content.ID()]
+	return ok
+}
+
+func TestHistoryReconciliation(t *testing.T) {
+	base := time.Now().Truncate(reconcileBucket).Add(-5 * reconcileBucket)
+	shared := &types.ClipboardContent{Type: types.TypeText, Data: []byte("shared"), Created: base.Add(time.Minute)}
+	desktopOnly := &types.ClipboardContent{Type: types.TypeText, Data: []byte("copied on the desktop"), Created: base.Add(2 * time.Minute)}
+	laptopOnly := &types.ClipboardContent{Type: types.TypeURL, Data: []byte("https://example.com"), Created: base.Add(3 * reconcileBucket)}
+	older := &types.ClipboardContent{Type: types.TypeText, Data: []byte("older"), Created: base.Add(-reconcileBucket)}
+
+	desktop := newTestHistory(older, shared, desktopOnly)
+	laptop := newTestHistory(older, shared, laptopOnly)
+
+	differing := differingBuckets(desktop.summarize(), laptop.summarize())
+	if len(differing) != 2 || !differing[0].Equal(bucketOf(shared)) || !differing[1].Equal(bucketOf(laptopOnly)) {
+		t.Fatalf("differing buckets = %v", differing)
+	}
+
+	if missing := laptop.missingFrom(desktop.entriesIn(differing)); len(missing) != 1 || missing[0] != desktopOnly.ID() {
+		t.Errorf("missing on the laptop = %v, want %s", missing, desktopOnly.ID())
+	}
+	if missing := desktop.missingFrom(laptop.entriesIn(differing)); len(missing) != 1 || missing[0] != laptopOnly.ID() {
+		t.Errorf("missing on the desktop = %v, want %s", missing, laptopOnly.ID())
+	}
+
+	// Once both have everything the summaries match
+	laptop[desktopOnly.ID()] = desktopOnly
+	desktop[laptopOnly.ID()] = laptopOnly
+	if differing := differingBuckets(desktop.summarize(), laptop.summarize()); len(differing) != 0 {
+		t.Errorf("buckets still differ after reconciliation: %v", differing)
+	}
+}
+
+func TestHistoryFilter(t *testing.T) {
+	text := &types.ClipboardContent{Type: types.TypeHTML, Data: []byte("<b>hi</b>")}
+	url := &types.ClipboardContent{Type: types.TypeURL, Data: []byte("https://example.com")}
+	image := &types.ClipboardContent{Type: types.TypeImage, Data: bytes.Repeat([]byte{1}, 2048)}
+	snippet := &types.ClipboardContent{Type: types.TypeSnippet, Data: []byte("{}")}
+
+	tests := []struct {
+		name    string
+		filter  historyFilter
+		content *types.ClipboardContent
+		want    bool
+	}{
+		{"text allowed", historyFilter{Types: []string{"text"}}, text, true},
+		{"URLs count as text", historyFilter{Types: []string{"text"}}, url, true},
+		{"URLs on their own", historyFilter{Types: []string{"url"}}, url, true},
+		{"type not synced", historyFilter{Types: []string{"text"}}, image, false},
+		{"over the size limit", historyFilter{MaxSizeKB: 1}, image, false},
+		{"within the size limit", historyFilter{MaxSizeKB: 2, Types: []string{"image"}}, image, true},
+		{"snippets sync separately", historyFilter{}, snippet, false},
+	}
+	for _, test := range tests {
+		if got := test.filter.allows(test.content); got != test.want {
+			t.Errorf("%s: allows = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestHistoryTakeLimits(t *testing.T) {
+	base := time.Now()
+	items := make(historyItems)
+	var ids []string
+	for i := 0; i < maxReconcileItems+10; i++ {
+		content := &types.ClipboardContent{Type: types.TypeText, Data: []byte("x"), Created: base.Add(time.Duration(i) * time.Second)}
+		items[content.ID()] = content
+		ids = append(ids, content.ID())
+	}
+	taken := items.take(ids)
+	if len(taken) != maxReconcileItems {
+		t.Fatalf("took %d items, want %d", len(taken), maxReconcileItems)
+	}
+	if !taken[0].Created.Equal(base) {
+		t.Error("oldest items are not sent first")
+	}
+
+	large := newTestHistory(
+		&types.ClipboardContent{Type: types.TypeImage, Data: make([]byte, maxReconcileBytes-1), Created: base},
+		&types.ClipboardContent{Type: types.TypeImage, Data: make([]byte, 2), Created: base.Add(time.Second)},
+	)
+	var largeIDs []string
+	for id := range large {
+		largeIDs = append(largeIDs, id)
+	}
+	if taken := large.take(largeIDs); len(taken) != 1 {
+		t.Errorf("took %d items over the size limit, want 1", len(taken))
+	}
+}
+
+func TestReconcilerExchangesHistory(t *testing.T) {
+	_, peers := newTestPeers(t, 3, mocknet.LinkOptions{})
+	desktop, laptop, stranger := peers[0], peers[1], peers[2]
+	laptop.config.ClipboardTypes = []string{"text", "url"}
+
+	base := time.Now().Truncate(reconcileBucket).Add(-5 * reconcileBucket)
+	shared := &types.ClipboardContent{Type: types.TypeText, Data: []byte("shared"), Created: base.Add(time.Minute)}
+	desktopOnly := &types.ClipboardContent{Type: types.TypeText, Data: []byte("copied on the desktop"), Created: base.Add(2 * time.Minute)}
+	laptopOnly := &types.ClipboardContent{Type: types.TypeURL, Data: []byte("https://example.com"), Created: base.Add(3 * reconcileBucket)}
+	image := &types.ClipboardContent{Type: types.TypeImage, Data: []byte{0x89, 'P', 'N', 'G'}, Created: base.Add(3 * time.Minute)}
+	histories := []*testHistorySource{
+		{items: newTestHistory(shared, desktopOnly, image)},
+		{items: newTestHistory(shared, laptopOnly)},
+		{items: newTestHistory()},
+	}
+	reconcilers := make([]*Reconciler, len(peers))
+	for i, p := range peers {
+		reconcilers[i] = NewReconciler(p.ctx, p.host, p.protocols, p.pairing, NewPolicyStore("", zap.NewNop()), p.config, zap.NewNop())
+		reconcilers[i].SetHistory(histories[i], histories[i].add)
+		// The peers are already connected, so starting doesn't reconcile
+		p.start(t)
+	}
+
+	received, sent, err := reconcilers[0].Reconcile(laptop.id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received != 1 || sent != 1 {
+		t.Errorf("reconciliation received %d and sent %d items, want 1 and 1", received, sent)
+	}
+	if !histories[0].has(laptopOnly) {
+		t.Error("the desktop didn't receive the laptop's item")
+	}
+	// The responder hands over received items after the initiator returns
+	waitFor(t, "the laptop to receive the desktop's item", func() bool {
+		reconcilers[1].mutex.Lock()
+		defer reconcilers[1].mutex.Unlock()
+		return histories[1].has(desktopOnly) && len(reconcilers[1].running) == 0
+	})
+	if histories[1].has(image) {
+		t.Error("an image was sent to a device that doesn't sync images")
+	}
+
+	// Once both have everything, nothing is exchanged
+	if received, sent, err := reconcilers[1].Reconcile(desktop.id()); err != nil || received != 0 || sent != 0 {
+		t.Errorf("second reconciliation = %d, %d, %v; want nothing exchanged", received, sent, err)
+	}
+
+	// History isn't exchanged with unpaired peers, whichever side forgot the pairing
+	desktop.unpair(stranger)
+	if _, _, err := reconcilers[2].Reconcile(desktop.id()); err == nil {
+		t.Error("an unpaired peer's reconciliation was answered")
+	}
+	stranger.unpair(desktop)
+	if _, _, err := reconcilers[2].Reconcile(desktop.id()); !errors.Is(err, ErrUnknownSender) {
+		t.Errorf("reconciliation with an unpaired peer = %v, want ErrUnknownSender", err)
+	}
+	if contents, _ := histories[2].GetContentSince(time.Time{}); len(contents) != 0 {
+		t.Errorf("an unpaired peer received %d items", len(contents))
+	}
+}
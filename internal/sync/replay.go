@@ -0,0 +1,133 @@
+// Package sync provides clipboard synchronization using libp2p
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// maxMessageSkew is how far a message timestamp may differ from the local clock
+	maxMessageSkew = 5 * time.Minute
+
+	// seenMessageCacheSize bounds the number of message IDs remembered
+	seenMessageCacheSize = 4096
+
+	// sequenceWindowSize is how far behind a sender's highest sequence number
+	// a message may arrive, pubsub doesn't guarantee delivery order
+	sequenceWindowSize = 64
+)
+
+// Errors returned when a message is rejected as a replay
+var (
+	ErrReplayedMessage = errors.New("message was already received")
+	ErrMessageSkew     = errors.New("message timestamp is outside the allowed clock skew")
+)
+
+// ReplayGuard rejects group messages that were already received, are too old
+// or come from too far in the future. Each sender's sequence numbers are
+// tracked with a sliding window, and message IDs in a bounded cache.
+type ReplayGuard struct {
+	mutex   sync.Mutex
+	maxSkew time.Duration
+	now     func() time.Time
+
+	seen     map[string]struct{}
+	seenRing []string // Message IDs in the order they were seen, to evict the oldest
+	seenNext int
+
+	senders map[peer.ID]*sequenceWindow
+}
+
+// sequenceWindow holds the highest sequence number seen from a sender, and
+// which of the sequenceWindowSize numbers before it were seen as a bitmap
+type sequenceWindow struct {
+	highest uint64
+	bitmap  uint64
+}
+
+// NewReplayGuard creates a replay guard with the given clock skew and ID cache size
+func NewReplayGuard(maxSkew time.Duration, cacheSize int) *ReplayGuard {
+	return &ReplayGuard{
+		maxSkew:  maxSkew,
+		now:      time.Now,
+		seen:     make(map[string]struct{}, cacheSize),
+		seenRing: make([]string, cacheSize),
+		senders:  make(map[peer.ID]*sequenceWindow),
+	}
+}
+
+// Check accepts a message once and records it. It must only be called for
+// authenticated messages, so that forged ones can't advance a sender's window.
+func (g *ReplayGuard) Check(message *SyncMessage) error {
+	skew := g.now().Sub(message.Timestamp)
+	if skew > g.maxSkew || skew < -g.maxSkew {
+		return fmt.Errorf("%w: sent at %s, %s from local time", ErrMessageSkew,
+			message.Timestamp.Format(time.RFC3339), skew.Round(time.Second))
+	}
+	if message.Sequence == 0 || message.ID == "" {
+		return fmt.Errorf("%w: message has no sequence number or ID", ErrReplayedMessage)
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if _, ok := g.seen[message.ID]; ok {
+		return fmt.Errorf("%w: duplicate message ID %s", ErrReplayedMessage, message.ID)
+	}
+	window, ok := g.senders[message.Source]
+	if !ok {
+		window = &sequenceWindow{}
+		g.senders[message.Source] = window
+	}
+	if !window.accept(message.Sequence) {
+		return fmt.Errorf("%w: sequence %d from %s", ErrReplayedMessage, message.Sequence, message.Source)
+	}
+	g.remember(message.ID)
+	return nil
+}
+
+// remember adds a message ID to the cache, evicting the oldest when full.
+// The caller holds the lock.
+func (g *ReplayGuard) remember(id string) {
+	if len(g.seenRing) == 0 {
+		return
+	}
+	if evicted := g.seenRing[g.seenNext]; evicted != "" {
+		delete(g.seen, evicted)
+	}
+	g.seenRing[g.seenNext] = id
+	g.seen[id] = struct{}{}
+	g.seenNext = (g.seenNext + 1) % len(g.seenRing)
+}
+
+// accept marks a sequence number as seen, and reports false if it was seen
+// before or is too far behind the highest one
+func (w *sequenceWindow) accept(sequence uint64) bool {
+	if sequence > w.highest {
+		shift := sequence - w.highest
+		if shift >= sequenceWindowSize {
+			w.bitmap = 0
+		} else {
+			w.bitmap <<= shift
+		}
+		w.bitmap |= 1
+		w.highest = sequence
+		return true
+	}
+
+	behind := w.highest - sequence
+	if behind >= sequenceWindowSize {
+		return false
+	}
+	bit := uint64(1) << behind
+	if w.bitmap&bit != 0 {
+		return false
+	}
+	w.bitmap |= bit
+	return true
+}
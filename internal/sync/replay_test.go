@@ -0,0 +1,138 @@
+package sync
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func newTestMessage(source peer.ID, id string, sequence uint64) *SyncMessage {
+	return &SyncMessage{
+		Type:      MessageTypeContent,
+		Source:    source,
+		Timestamp: time.Now(),
+		ID:        id,
+		Sequence:  sequence,
+	}
+}
+
+func TestReplayGuard(t *testing.T) {
+	guard := NewReplayGuard(maxMessageSkew, 16)
+	source := newTestPeerID(t)
+
+	if err := guard.Check(newTestMessage(source, "a", 100)); err != nil {
+		t.Fatalf("first message rejected: %v", err)
+	}
+	if err := guard.Check(newTestMessage(source, "a", 100)); !errors.Is(err, ErrReplayedMessage) {
+		t.Errorf("replayed message: error = %v, want %v", err, ErrReplayedMessage)
+	}
+	if err := guard.Check(newTestMessage(source, "b", 100)); !errors.Is(err, ErrReplayedMessage) {
+		t.Errorf("reused sequence: error = %v, want %v", err, ErrReplayedMessage)
+	}
+
+	// Messages may arrive out of order within the window
+	if err := guard.Check(newTestMessage(source, "c", 105)); err != nil {
+		t.Errorf("later message rejected: %v", err)
+	}
+	if err := guard.Check(newTestMessage(source, "d", 103)); err != nil {
+		t.Errorf("reordered message rejected: %v", err)
+	}
+	if err := guard.Check(newTestMessage(source, "e", 103)); !errors.Is(err, ErrReplayedMessage) {
+		t.Errorf("reordered replay: error = %v, want %v", err, ErrReplayedMessage)
+	}
+	if err := guard.Check(newTestMessage(source, "f", 105-sequenceWindowSize)); !errors.Is(err, ErrReplayedMessage) {
+		t.Errorf("message behind the window: error = %v, want %v", err, ErrReplayedMessage)
+	}
+
+	// Senders are tracked separately
+	if err := guard.Check(newTestMessage(newTestPeerID(t), "g", 1)); err != nil {
+		t.Errorf("other sender rejected: %v", err)
+	}
+
+	if err := guard.Check(newTestMessage(source, "h", 0)); !errors.Is(err, ErrReplayedMessage) {
+		t.Errorf("missing sequence: error = %v, want %v", err, ErrReplayedMessage)
+	}
+}
+
+func TestReplayGuardSkew(t *testing.T) {
+	guard := NewReplayGuard(time.Minute, 16)
+	source := newTestPeerID(t)
+
+	old := newTestMessage(source, "old", 1)
+	old.Timestamp = time.Now().Add(-2 * time.Minute)
+	if err := guard.Check(old); !errors.Is(err, ErrMessageSkew) {
+		t.Errorf("old message: error = %v, want %v", err, ErrMessageSkew)
+	}
+
+	future := newTestMessage(source, "future", 2)
+	future.Timestamp = time.Now().Add(2 * time.Minute)
+	if err := guard.Check(future); !errors.Is(err, ErrMessageSkew) {
+		t.Errorf("future message: error = %v, want %v", err, ErrMessageSkew)
+	}
+
+	// Rejected messages don't advance the sender's window
+	if err := guard.Check(newTestMessage(source, "now", 1)); err != nil {
+		t.Errorf("current message rejected: %v", err)
+	}
+}
+
+func TestReplayGuardEvictsOldIDs(t *testing.T) {
+	guard := NewReplayGuard(maxMessageSkew, 2)
+	for i, id := range []string{"a", "b", "c"} {
+		if err := guard.Check(newTestMessage(newTestPeerID(t), id, uint64(i+1))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(guard.seen) != 2 {
+		t.Errorf("cache holds %d IDs, want 2", len(guard.seen))
+	}
+	if _, ok := guard.seen["a"]; ok {
+		t.Error("oldest ID was not evicted")
+	}
+}
+
+func TestSignedMessage(t *testing.T) {
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := peer.IDFromPrivateKey(key)
+	device := PairedDevice{PeerID: id.String()}
+
+	data, err := SignMessage(key, newTestMessage(id, "a", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, message, err := ParseSignedMessage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.ID != "a" || message.Source != id {
+		t.Errorf("decoded message = %+v", message)
+	}
+
+	publicKey, err := devicePublicKey(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signed.Verify(publicKey); err != nil {
+		t.Errorf("valid signature rejected: %v", err)
+	}
+
+	// A group member rewriting another device's message
+	signed.Message = []byte(`{"type":"content","source":"` + id.String() + `","id":"b","seq":2}`)
+	if err := signed.Verify(publicKey); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("rewritten message: error = %v, want %v", err, ErrBadSignature)
+	}
+
+	// A paired device record whose key doesn't match its peer ID
+	other, _, _ := crypto.GenerateEd25519Key(rand.Reader)
+	device.PublicKey, _ = crypto.MarshalPublicKey(other.GetPublic())
+	if _, err := devicePublicKey(device); err == nil {
+		t.Error("mismatched public key was accepted")
+	}
+}
@@ -0,0 +1,494 @@
+// Package sync provides clipboard synchronization using libp2p
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"go.uber.org/zap"
+)
+
+const (
+	// revocationTimeout bounds telling one paired device of a revocation
+	revocationTimeout = 10 * time.Second
+
+	// maxRevocationMessageSize bounds a revocation notice or acknowledgement
+	maxRevocationMessageSize = 4 * 1024
+)
+
+// RevokedPeer is a device whose pairing was revoked. It is denied at the
+// connection level until it is paired with again.
+type RevokedPeer struct {
+	PeerID     string    `json:"peer_id"`
+	DeviceName string    `json:"device_name,omitempty"`
+	RevokedAt  time.Time `json:"revoked_at"`
+	RevokedBy  string    `json:"revoked_by,omitempty"` // Paired device the revocation came from, empty if revoked on this device
+	Notify     []string  `json:"notify,omitempty"`     // Paired devices not told of the revocation yet
+}
+
+// RevocationResult reports the revocation of a paired device
+type RevocationResult struct {
+	PeerID        string            `json:"peer_id"`
+	DeviceName    string            `json:"device_name,omitempty"`
+	Acknowledged  []string          `json:"acknowledged,omitempty"`   // Paired devices that acknowledged the revocation
+	Confirming    []string          `json:"confirming,omitempty"`     // Paired devices that also paired with the device, left to confirm the revocation
+	Pending       []string          `json:"pending,omitempty"`        // Paired devices told when they next connect
+	RotatedGroups []string          `json:"rotated_groups,omitempty"` // Groups whose key was rotated
+	KeysDelivered []string          `json:"keys_delivered,omitempty"` // Paired devices that received the new keys
+	DeviceNames   map[string]string `json:"device_names,omitempty"`   // Names of the paired devices above, by peer ID
+}
+
+// DenyList persists the revoked devices, so they stay denied across
+// restarts and revocations made by the pair command reach the daemon
+type DenyList struct {
+	file   *jsonFile
+	logger *zap.Logger
+
+	mutex sync.RWMutex
+	peers map[string]RevokedPeer
+}
+
+// NewDenyList creates a deny list stored at path and loads the revoked devices
+func NewDenyList(path string, logger *zap.Logger) *DenyList {
+	denied := &DenyList{
+		file:   newJSONFile(path, "revoked devices"),
+		logger: logger.With(zap.String("component", "deny-list")),
+		peers:  make(map[string]RevokedPeer),
+	}
+	if err := denied.refresh(); err != nil {
+		denied.logger.Warn("Failed to load revoked devices", zap.Error(err))
+	}
+	return denied
+}
+
+// Contains reports whether a device is revoked
+func (d *DenyList) Contains(id string) bool {
+	_, ok := d.Get(id)
+	return ok
+}
+
+// Get returns a revoked device
+func (d *DenyList) Get(id string) (RevokedPeer, bool) {
+	d.reload()
+
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	revoked, ok := d.peers[id]
+	return revoked, ok
+}
+
+// List returns the revoked devices, most recently revoked first
+func (d *DenyList) List() []RevokedPeer {
+	d.reload()
+
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	list := make([]RevokedPeer, 0, len(d.peers))
+	for _, revoked := range d.peers {
+		list = append(list, revoked)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].RevokedAt.After(list[j].RevokedAt) })
+	return list
+}
+
+// Add denies a device, replacing an earlier revocation of it
+func (d *DenyList) Add(revoked RevokedPeer) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.update(func() error {
+		d.peers[revoked.PeerID] = revoked
+		return nil
+	})
+}
+
+// Remove lifts the revocation of a device, it reports whether it was revoked
+func (d *DenyList) Remove(id string) (bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	removed := false
+	err := d.update(func() error {
+		if _, removed = d.peers[id]; !removed {
+			return errUnchanged
+		}
+		delete(d.peers, id)
+		return nil
+	})
+	return removed, err
+}
+
+// Acknowledge records that a paired device was told of a revocation
+func (d *DenyList) Acknowledge(id string, by string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.update(func() error {
+		revoked, ok := d.peers[id]
+		if !ok || !containsString(revoked.Notify, by) {
+			return errUnchanged
+		}
+		notify := make([]string, 0, len(revoked.Notify)-1)
+		for _, device := range revoked.Notify {
+			if device != by {
+				notify = append(notify, device)
+			}
+		}
+		revoked.Notify = notify
+		d.peers[id] = revoked
+		return nil
+	})
+}
+
+// reload picks up revocations saved by another process, such as the pair command
+func (d *DenyList) reload() {
+	if err := d.refresh(); err != nil {
+		d.logger.Warn("Failed to reload revoked devices", zap.Error(err))
+	}
+}
+
+// refresh reads the deny list file if it changed since it was last read
+func (d *DenyList) refresh() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.file.refresh(d.decode)
+}
+
+// update applies a change to the deny list saved on disk and saves it. The
+// caller holds the lock.
+func (d *DenyList) update(change func() error) error {
+	return d.file.update(d.decode, change, d.encode)
+}
+
+// decode replaces the revoked devices with those read from the file. The
+// caller holds the lock.
+func (d *DenyList) decode(data []byte) error {
+	var list []RevokedPeer
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	d.peers = make(map[string]RevokedPeer, len(list))
+	for _, revoked := range list {
+		d.peers[revoked.PeerID] = revoked
+	}
+	return nil
+}
+
+// encode returns the revoked devices to save, sorted by peer ID. The caller
+// holds the lock.
+func (d *DenyList) encode() any {
+	list := make([]RevokedPeer, 0, len(d.peers))
+	for _, revoked := range d.peers {
+		list = append(list, revoked)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].PeerID < list[j].PeerID })
+	return list
+}
+
+// revocationNotice tells a paired device that another device was revoked
+type revocationNotice struct {
+	PeerID     string    `json:"peer_id"`
+	DeviceName string    `json:"device_name,omitempty"`
+	RevokedAt  time.Time `json:"revoked_at"`
+}
+
+// revocationAck answers a revocation notice
+type revocationAck struct {
+	Accepted bool   `json:"accepted"`
+	Confirm  bool   `json:"confirm,omitempty"` // Recorded for the user to confirm, the device is still paired
+	Error    string `json:"error,omitempty"`
+}
+
+// errConfirmRevocation is returned by send when the device recorded the
+// revocation for its user to confirm
+var errConfirmRevocation = errors.New("revocation left to confirm")
+
+// RevocationService revokes paired devices and tells the other paired
+// devices, which unpair and deny the revoked device too. Devices that aren't
+// connected are told when they next connect. A device that paired with the
+// revoked device itself only records the revocation, for its user to
+// confirm, so a paired device can't cut it off from the devices it trusts.
+type RevocationService struct {
+	host            host.Host
+	ctx             context.Context
+	logger          *zap.Logger
+	protocolManager *ProtocolManager
+	pairing         *PairingManager
+	denied          *DenyList
+	policies        *PolicyStore
+	notifee         *network.NotifyBundle
+}
+
+// NewRevocationService creates the revocation service and registers its protocol handler
+func NewRevocationService(ctx context.Context, host host.Host, pm *ProtocolManager, pairing *PairingManager, denied *DenyList, policies *PolicyStore, logger *zap.Logger) *RevocationService {
+	service := &RevocationService{
+		host:            host,
+		ctx:             ctx,
+		logger:          logger.With(zap.String("component", "revocation")),
+		protocolManager: pm,
+		pairing:         pairing,
+		denied:          denied,
+		policies:        policies,
+	}
+	pm.AddHandler(service)
+	return service
+}
+
+// ID returns the protocol ID
+func (rs *RevocationService) ID() protocol.ID {
+	return protocol.ID(RevocationProtocolPath)
+}
+
+// Handle applies a revocation made on a paired device and acknowledges it
+func (rs *RevocationService) Handle(stream network.Stream) {
+	defer stream.Close()
+	remotePeer := stream.Conn().RemotePeer()
+
+	if !rs.pairing.IsPaired(remotePeer.String()) {
+		rs.logger.Warn("Rejected revocation from unpaired peer", zap.String("peer_id", remotePeer.String()))
+		stream.Reset()
+		return
+	}
+
+	stream.SetDeadline(time.Now().Add(revocationTimeout))
+	var notice revocationNotice
+	if err := json.NewDecoder(io.LimitReader(stream, maxRevocationMessageSize)).Decode(&notice); err != nil {
+		rs.logger.Warn("Failed to read revocation", zap.String("peer_id", remotePeer.String()), zap.Error(err))
+		stream.Reset()
+		return
+	}
+
+	var ack revocationAck
+	confirm, err := rs.apply(notice, remotePeer)
+	if err != nil {
+		rs.logger.Warn("Rejected revocation from paired device",
+			zap.String("peer_id", remotePeer.String()),
+			zap.String("revoked_peer_id", notice.PeerID),
+			zap.Error(err))
+		ack.Error = err.Error()
+	} else {
+		ack.Accepted = true
+		ack.Confirm = confirm
+	}
+	if err := json.NewEncoder(stream).Encode(ack); err != nil {
+		rs.logger.Debug("Failed to acknowledge revocation", zap.String("peer_id", remotePeer.String()), zap.Error(err))
+	}
+}
+
+// apply denies a device revoked on a paired device. A device paired with
+// here too is only marked as revoked by the sender, for the user to confirm
+// with RevokeDevice; apply then reports that it is left to confirm.
+func (rs *RevocationService) apply(notice revocationNotice, from peer.ID) (bool, error) {
+	revokedID, err := peer.Decode(notice.PeerID)
+	if err != nil {
+		return false, fmt.Errorf("invalid peer ID: %w", err)
+	}
+	if revokedID == rs.host.ID() || revokedID == from {
+		return false, fmt.Errorf("a device can't revoke itself or this device")
+	}
+	if rs.denied.Contains(notice.PeerID) {
+		return false, nil
+	}
+
+	if device, ok := rs.pairing.GetPairedDevice(notice.PeerID); ok {
+		requested, err := rs.pairing.RequestRevocation(notice.PeerID, from.String())
+		if err != nil {
+			return false, err
+		}
+		if requested {
+			rs.logger.Warn("Paired device revoked a device paired with here, confirm with pair --remove",
+				zap.String("revoked_peer_id", notice.PeerID),
+				zap.String("device_name", device.DeviceName),
+				zap.String("peer_id", from.String()))
+		}
+		return true, nil
+	}
+
+	if err := rs.deny(revokedID, RevokedPeer{
+		PeerID:     notice.PeerID,
+		DeviceName: notice.DeviceName,
+		RevokedAt:  notice.RevokedAt,
+		RevokedBy:  from.String(),
+	}); err != nil {
+		return false, err
+	}
+
+	rs.logger.Info("Denied device on request of paired device",
+		zap.String("revoked_peer_id", notice.PeerID),
+		zap.String("device_name", notice.DeviceName),
+		zap.String("peer_id", from.String()))
+	return false, nil
+}
+
+// Start tells paired devices of pending revocations as they connect
+func (rs *RevocationService) Start() error {
+	rs.notifee = &network.NotifyBundle{
+		ConnectedF: func(_ network.Network, conn network.Conn) {
+			remotePeer := conn.RemotePeer()
+			if !rs.pairing.IsPaired(remotePeer.String()) {
+				return
+			}
+			go rs.notifyPending(remotePeer)
+		},
+	}
+	rs.host.Network().Notify(rs.notifee)
+	return nil
+}
+
+// Stop stops telling devices of revocations on new connections
+func (rs *RevocationService) Stop() error {
+	if rs.notifee != nil {
+		rs.host.Network().StopNotify(rs.notifee)
+		rs.notifee = nil
+	}
+	return nil
+}
+
+// Revoke unpairs a device, denies it at the connection level and drops
+// its sync policy. The other paired devices are left to tell with Notify.
+func (rs *RevocationService) Revoke(id peer.ID) (RevokedPeer, error) {
+	device, ok := rs.pairing.GetPairedDevice(id.String())
+	if !ok {
+		return RevokedPeer{}, fmt.Errorf("device not paired: %s", id)
+	}
+
+	revoked := RevokedPeer{
+		PeerID:     id.String(),
+		DeviceName: device.DeviceName,
+		RevokedAt:  time.Now(),
+	}
+	for _, other := range rs.pairing.GetPairedDevices() {
+		if other.PeerID != revoked.PeerID {
+			revoked.Notify = append(revoked.Notify, other.PeerID)
+		}
+	}
+	if err := rs.deny(id, revoked); err != nil {
+		return RevokedPeer{}, err
+	}
+
+	rs.logger.Info("Revoked paired device",
+		zap.String("peer_id", revoked.PeerID),
+		zap.String("device_name", revoked.DeviceName),
+		zap.Int("devices_to_notify", len(revoked.Notify)))
+	return revoked, nil
+}
+
+// deny adds a device to the deny list, unpairs it and closes its connections
+func (rs *RevocationService) deny(id peer.ID, revoked RevokedPeer) error {
+	if err := rs.denied.Add(revoked); err != nil {
+		return fmt.Errorf("failed to deny device: %w", err)
+	}
+	if rs.pairing.IsPaired(revoked.PeerID) {
+		if err := rs.pairing.RemovePairedDevice(revoked.PeerID); err != nil {
+			return fmt.Errorf("failed to unpair device: %w", err)
+		}
+	}
+	if err := rs.policies.SetPeer(revoked.PeerID, SyncPolicy{}); err != nil {
+		rs.logger.Warn("Failed to remove sync policy of revoked device", zap.String("peer_id", revoked.PeerID), zap.Error(err))
+	}
+	if err := rs.host.Network().ClosePeer(id); err != nil {
+		rs.logger.Debug("Failed to close connections to revoked device", zap.String("peer_id", revoked.PeerID), zap.Error(err))
+	}
+	return nil
+}
+
+// Notify tells the connected paired devices still to be told of a
+// revocation, and returns the ones that acknowledged it, the ones left to
+// confirm it and the ones left to tell when they next connect
+func (rs *RevocationService) Notify(revokedID string) (acknowledged []string, confirming []string, pending []string) {
+	revoked, ok := rs.denied.Get(revokedID)
+	if !ok {
+		return nil, nil, nil
+	}
+
+	for _, device := range revoked.Notify {
+		peerID, err := peer.Decode(device)
+		if err != nil || rs.host.Network().Connectedness(peerID) != network.Connected {
+			pending = append(pending, device)
+			continue
+		}
+		err = rs.send(peerID, revoked)
+		if errors.Is(err, errConfirmRevocation) {
+			confirming = append(confirming, device)
+			continue
+		}
+		if err != nil {
+			rs.logger.Warn("Failed to tell paired device of revocation",
+				zap.String("peer_id", device),
+				zap.String("revoked_peer_id", revokedID),
+				zap.Error(err))
+			pending = append(pending, device)
+			continue
+		}
+		acknowledged = append(acknowledged, device)
+	}
+	return acknowledged, confirming, pending
+}
+
+// notifyPending tells a paired device of the revocations it missed
+func (rs *RevocationService) notifyPending(peerID peer.ID) {
+	for _, revoked := range rs.denied.List() {
+		if !containsString(revoked.Notify, peerID.String()) {
+			continue
+		}
+		err := rs.send(peerID, revoked)
+		if errors.Is(err, errConfirmRevocation) {
+			rs.logger.Info("Paired device left revocation to confirm",
+				zap.String("peer_id", peerID.String()),
+				zap.String("revoked_peer_id", revoked.PeerID))
+			continue
+		}
+		if err != nil {
+			rs.logger.Debug("Failed to tell paired device of revocation",
+				zap.String("peer_id", peerID.String()),
+				zap.String("revoked_peer_id", revoked.PeerID),
+				zap.Error(err))
+			return
+		}
+		rs.logger.Info("Paired device acknowledged revocation",
+			zap.String("peer_id", peerID.String()),
+			zap.String("revoked_peer_id", revoked.PeerID))
+	}
+}
+
+// send sends a revocation notice to a paired device and records its acknowledgement
+func (rs *RevocationService) send(peerID peer.ID, revoked RevokedPeer) error {
+	stream, err := rs.protocolManager.OpenStream(peerID, rs.ID())
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	stream.SetDeadline(time.Now().Add(revocationTimeout))
+	notice := revocationNotice{
+		PeerID:     revoked.PeerID,
+		DeviceName: revoked.DeviceName,
+		RevokedAt:  revoked.RevokedAt,
+	}
+	if err := json.NewEncoder(stream).Encode(notice); err != nil {
+		stream.Reset()
+		return fmt.Errorf("failed to send revocation: %w", err)
+	}
+	var ack revocationAck
+	if err := json.NewDecoder(io.LimitReader(stream, maxRevocationMessageSize)).Decode(&ack); err != nil {
+		stream.Reset()
+		return fmt.Errorf("failed to read acknowledgement: %w", err)
+	}
+	if !ack.Accepted {
+		return fmt.Errorf("revocation rejected: %s", ack.Error)
+	}
+	if err := rs.denied.Acknowledge(revoked.PeerID, peerID.String()); err != nil {
+		return err
+	}
+	if ack.Confirm {
+		return errConfirmRevocation
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package sync
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"go.uber.org/zap"
+)
+
+func TestDenyList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked_peers.json")
+	denied := NewDenyList(path, zap.NewNop())
+
+	revokedAt := time.Now().Add(-time.Minute)
+	if err := denied.Add(RevokedPeer{PeerID: "peer-a", DeviceName: "old phone", RevokedAt: revokedAt, Notify: []string{"peer-b", "peer-c"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := denied.Acknowledge("peer-a", "peer-b"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Revocations survive a restart and are picked up by other processes
+	reloaded := NewDenyList(path, zap.NewNop())
+	revoked, ok := reloaded.Get("peer-a")
+	if !ok || revoked.DeviceName != "old phone" || len(revoked.Notify) != 1 || revoked.Notify[0] != "peer-c" {
+		t.Fatalf("reloaded revocation = %+v, %v", revoked, ok)
+	}
+	if err := reloaded.Add(RevokedPeer{PeerID: "peer-d", RevokedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if list := denied.List(); len(list) != 2 || list[0].PeerID != "peer-d" {
+		t.Errorf("deny list missed a revocation made by another process: %+v", list)
+	}
+
+	// Lifting a revocation admits the device again
+	if lifted, err := denied.Remove("peer-a"); err != nil || !lifted {
+		t.Fatalf("Remove = %v, %v", lifted, err)
+	}
+	if lifted, err := denied.Remove("peer-a"); err != nil || lifted {
+		t.Errorf("second Remove = %v, %v", lifted, err)
+	}
+	if reloaded.Contains("peer-a") {
+		t.Error("lifted revocation still denies the device")
+	}
+}
+
+func TestRevocationLeavesOwnPairingsToConfirm(t *testing.T) {
+	_, peers := newTestPeers(t, 4, mocknet.LinkOptions{})
+	managers := make([]*Manager, len(peers))
+	for i, p := range peers {
+		managers[i] = p.manager(t)
+		p.start(t)
+	}
+	desktop, phone, laptop, tablet := peers[0], peers[1], peers[2], peers[3]
+
+	// The tablet never paired with the laptop
+	tablet.unpair(laptop)
+	laptop.unpair(tablet)
+
+	result, err := managers[0].RevokeDevice(laptop.id().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Acknowledged) != 1 || result.Acknowledged[0] != tablet.id().String() {
+		t.Errorf("acknowledged by %v, want the tablet", result.Acknowledged)
+	}
+	if len(result.Confirming) != 1 || result.Confirming[0] != phone.id().String() {
+		t.Errorf("left to confirm by %v, want the phone", result.Confirming)
+	}
+	if len(result.Pending) != 0 {
+		t.Errorf("devices left to tell: %v", result.Pending)
+	}
+
+	// The phone paired with the laptop itself, so it only records the request
+	device, ok := phone.pairing.GetPairedDevice(laptop.id().String())
+	if !ok {
+		t.Fatal("a paired device's revocation unpaired a device paired with here")
+	}
+	if len(device.RevocationRequestedBy) != 1 || device.RevocationRequestedBy[0] != desktop.id().String() {
+		t.Errorf("revocation requested by %v, want the desktop", device.RevocationRequestedBy)
+	}
+	if managers[1].node.denied.Contains(laptop.id().String()) {
+		t.Error("revocation left to confirm denied the device")
+	}
+
+	// The tablet doesn't know the laptop and denies it
+	revoked, ok := managers[3].node.denied.Get(laptop.id().String())
+	if !ok || revoked.RevokedBy != desktop.id().String() {
+		t.Errorf("tablet revocation = %+v, %v", revoked, ok)
+	}
+
+	// Confirming on the phone revokes the laptop there too
+	if _, err := managers[1].RevokeDevice(laptop.id().String()); err != nil {
+		t.Fatal(err)
+	}
+	if phone.pairing.IsPaired(laptop.id().String()) || !managers[1].node.denied.Contains(laptop.id().String()) {
+		t.Error("confirmed revocation left the laptop paired")
+	}
+
+	// A device can't revoke itself or the device it tells
+	for _, target := range []*testPeer{desktop, tablet} {
+		if _, err := managers[3].node.revocation.apply(revocationNotice{PeerID: target.id().String(), RevokedAt: time.Now()}, desktop.id()); err == nil {
+			t.Errorf("revocation of %s accepted", target.id())
+		}
+	}
+	if !tablet.pairing.IsPaired(desktop.id().String()) {
+		t.Error("revocation of the sender unpaired it")
+	}
+}
+
+func TestConcurrentRevocationsConverge(t *testing.T) {
+	_, peers := newTestPeers(t, 4, mocknet.LinkOptions{})
+	managers := make([]*Manager, len(peers))
+	for i, p := range peers {
+		managers[i] = p.manager(t)
+		p.start(t)
+	}
+	desktop, laptop, phone, tablet := peers[0], peers[1], peers[2], peers[3]
+
+	// Every device is a member of the group
+	if _, err := desktop.keyring.Ensure("work"); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range peers[1:] {
+		if _, err := managers[0].AddGroupMember("work", p.id().String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, "every device to receive the group key", func() bool {
+		for _, p := range peers {
+			if _, ok := p.keyring.Current("work"); !ok {
+				return false
+			}
+		}
+		return true
+	})
+
+	// The desktop revokes the phone while the laptop revokes the tablet, both
+	// rotating to the same epoch
+	errs := make(chan error, 2)
+	go func() {
+		_, err := managers[0].RevokeDevice(phone.id().String())
+		errs <- err
+	}()
+	go func() {
+		_, err := managers[1].RevokeDevice(tablet.id().String())
+		errs <- err
+	}()
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Both settle on one key that neither revoked device holds
+	waitFor(t, "the remaining members to settle on one key", func() bool {
+		a, _ := desktop.keyring.Current("work")
+		b, _ := laptop.keyring.Current("work")
+		return a.Epoch == b.Epoch && bytes.Equal(a.Key, b.Key) &&
+			!containsString(a.Members, phone.id().String()) && !containsString(a.Members, tablet.id().String())
+	})
+	key, _ := desktop.keyring.Current("work")
+	for _, p := range []*testPeer{phone, tablet} {
+		if held, _ := p.keyring.Current("work"); bytes.Equal(held.Key, key.Key) {
+			t.Errorf("revoked device %s holds the group key", p.id())
+		}
+	}
+}
+
+func TestRevocationReachesDeviceOnReconnect(t *testing.T) {
+	mn, peers := newTestPeers(t, 3, mocknet.LinkOptions{})
+	managers := make([]*Manager, len(peers))
+	for i, p := range peers {
+		managers[i] = p.manager(t)
+		p.start(t)
+	}
+	desktop, laptop, phone := peers[0], peers[1], peers[2]
+	if err := managers[0].node.keyExchange.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := managers[0].node.revocation.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := desktop.keyring.Ensure("work"); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []*testPeer{laptop, phone} {
+		if _, err := managers[0].AddGroupMember("work", p.id().String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, "the phone to receive the group key", func() bool {
+		_, ok := phone.keyring.Current("work")
+		return ok
+	})
+
+	// The phone is offline while the laptop is revoked
+	if err := mn.UnlinkPeers(desktop.id(), phone.id()); err != nil {
+		t.Fatal(err)
+	}
+	if err := mn.DisconnectPeers(desktop.id(), phone.id()); err != nil {
+		t.Fatal(err)
+	}
+	result, err := managers[0].RevokeDevice(laptop.id().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Pending) != 1 || result.Pending[0] != phone.id().String() {
+		t.Errorf("devices left to tell: %v, want the phone", result.Pending)
+	}
+	if containsString(result.KeysDelivered, phone.id().String()) {
+		t.Error("new keys delivered to an offline device")
+	}
+
+	// Reconnecting hands it the new key and the revocation
+	rotated, _ := desktop.keyring.Current("work")
+	if _, err := mn.LinkPeers(desktop.id(), phone.id()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mn.ConnectPeers(desktop.id(), phone.id()); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the phone to receive the rotated key", func() bool {
+		key, _ := phone.keyring.Current("work")
+		return bytes.Equal(key.Key, rotated.Key)
+	})
+	waitFor(t, "the phone to record the revocation", func() bool {
+		device, _ := phone.pairing.GetPairedDevice(laptop.id().String())
+		return containsString(device.RevocationRequestedBy, desktop.id().String())
+	})
+}
@@ -0,0 +1,89 @@
+// Package sync provides clipboard synchronization using libp2p
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// messageSigningContext is prepended to a message before signing, so that a
+// message signature can't be reused for anything else signed with the identity key
+const messageSigningContext = "clipman-message/1:"
+
+// Errors returned when a message's sender can't be authenticated
+var (
+	ErrUnknownSender = errors.New("sender is not a paired device")
+	ErrBadSignature  = errors.New("message signature doesn't match the paired device's key")
+)
+
+// SignedMessage is the plaintext of a group envelope: an encoded SyncMessage
+// and its sender's signature made with their libp2p identity key
+type SignedMessage struct {
+	Message   json.RawMessage `json:"message"`
+	Signature []byte          `json:"signature"`
+}
+
+// SignMessage encodes a message and signs it with the node's identity key
+func SignMessage(key crypto.PrivKey, message *SyncMessage) ([]byte, error) {
+	encoded, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode message: %w", err)
+	}
+	signature, err := key.Sign(append([]byte(messageSigningContext), encoded...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign message: %w", err)
+	}
+	return json.Marshal(SignedMessage{Message: encoded, Signature: signature})
+}
+
+// ParseSignedMessage decodes a signed message without verifying it
+func ParseSignedMessage(data []byte) (*SignedMessage, *SyncMessage, error) {
+	var signed SignedMessage
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode signed message: %w", err)
+	}
+	var message SyncMessage
+	if err := json.Unmarshal(signed.Message, &message); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode message: %w", err)
+	}
+	return &signed, &message, nil
+}
+
+// Verify checks the signature against a sender's public key
+func (s *SignedMessage) Verify(key crypto.PubKey) error {
+	ok, err := key.Verify(append([]byte(messageSigningContext), s.Message...), s.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	if !ok {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// devicePublicKey returns the identity key recorded for a paired device, or
+// the one embedded in its peer ID for devices paired before keys were recorded
+func devicePublicKey(device PairedDevice) (crypto.PubKey, error) {
+	id, err := peer.Decode(device.PeerID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer ID in paired device record: %w", err)
+	}
+
+	var key crypto.PubKey
+	if len(device.PublicKey) > 0 {
+		key, err = crypto.UnmarshalPublicKey(device.PublicKey)
+	} else {
+		key, err = id.ExtractPublicKey()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no usable public key for paired device %s: %w", device.PeerID, err)
+	}
+	if !id.MatchesPublicKey(key) {
+		return nil, fmt.Errorf("public key of paired device %s doesn't match its peer ID", device.PeerID)
+	}
+	return key, nil
+}
@@ -3,16 +3,24 @@ package sync
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/berrythewa/clipman-daemon/internal/config"
 	// "github.com/berrythewa/clipman-daemon/internal/sync/discovery"
 	// TODO: Discovery is done elsewhere ? through node ?
 	"github.com/berrythewa/clipman-daemon/internal/types"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"go.uber.org/zap"
 )
 
+// DefaultGroup is the group devices sync through unless configured otherwise
+const DefaultGroup = "clipman-default"
+
 // Manager implements the types.SyncManager interface
 // It orchestrates the libp2p node, protocols, and discovery
 type Manager struct {
@@ -25,8 +33,17 @@ type Manager struct {
 	
 	// Content handling
 	contentHandler types.ContentCallback
+	joinHandler    GroupJoinCallback
 	handlerMutex   sync.RWMutex
 	
+	// Groups this device is in, kept across restarts
+	groups        *GroupStore
+	groupMutex    sync.Mutex // Joining and leaving one group at a time, so each is read once
+	
+	// Message authenticity and replay protection
+	sequence      atomic.Uint64
+	replay        *ReplayGuard
+	
 	// State
 	started       bool
 	mutex         sync.RWMutex
@@ -58,9 +75,14 @@ func New(ctx context.Context, cfg *config.Config, logger *zap.Logger) (*Manager,
 		cancel:        cancel,
 		config:        node.GetConfig(),
 		logger:        syncLogger,
+		groups:        NewGroupStore(node.GetConfig().GroupsPath, syncLogger),
+		replay:        NewReplayGuard(maxMessageSkew, seenMessageCacheSize),
 		started:       false,
 	}
 	
+	// Sequence numbers start at the current time so they keep increasing across restarts
+	manager.sequence.Store(uint64(time.Now().UnixNano()))
+	
 	return manager, nil
 }
 
@@ -105,18 +127,67 @@ func (m *Manager) Stop() error {
 	return nil
 }
 
-// SendContent sends content to a group
+// SendContent sends content to a group, encrypted with the group key
 func (m *Manager) SendContent(content *types.ClipboardContent, group string) error {
 	if !m.started {
 		return fmt.Errorf("sync manager not started")
 	}
 	
-	// TODO: Implement content sending via protocols
-	m.logger.Info("Sending content to group", 
+	if limit := m.maxContentSize(); limit > 0 && int64(len(content.Data)) > limit {
+		m.logger.Debug("Not sending content larger than the maximum clipboard size",
+			zap.Int("size", len(content.Data)),
+			zap.Int("max_clipboard_size_kb", m.config.MaxClipboardSizeKB))
+		return nil
+	}
+	if err := m.node.policies.Group(group).Check(content, DirectionSend, time.Now()); err != nil {
+		m.logger.Debug("Not sending content to group", zap.String("group", group), zap.Error(err))
+		return nil
+	}
+	
+	// Paired devices whose policy doesn't allow the content must not receive
+	// it, and every group member can decrypt the topic, so it is announced and
+	// only served to the others
+	exclude := m.excludedPeers(content)
+	
+	payload, err := json.Marshal(content)
+	if err != nil {
+		return fmt.Errorf("failed to encode content: %w", err)
+	}
+	
+	m.logger.Debug("Sending content to group", 
 		zap.String("group", group),
-		zap.String("content_type", string(content.Type)))
+		zap.String("content_type", string(content.Type)),
+		zap.Int("size", len(payload)))
+	
+	// Large content is announced, peers fetch it over a direct stream
+	msgType := MessageTypeContent
+	if len(payload) > inlineContentLimit || len(exclude) > 0 {
+		if len(payload) > maxOfferedBytes {
+			return fmt.Errorf("content of %d bytes is too large to sync", len(payload))
+		}
+		announcement := ContentAnnouncement{
+			Hash:        m.node.clipboard.OfferExcluding(payload, exclude),
+			Size:        int64(len(payload)),
+			ContentSize: int64(len(content.Data)),
+			Type:        string(content.Type),
+			Subtype:     string(content.Subtype),
+			Created:     content.Created,
+		}
+		if payload, err = json.Marshal(announcement); err != nil {
+			return fmt.Errorf("failed to encode announcement: %w", err)
+		}
+		msgType = MessageTypeAnnounce
+	}
 	
-	return fmt.Errorf("not implemented")
+	if err := m.publishMessage(group, msgType, payload, exclude); err != nil {
+		return fmt.Errorf("failed to send content to group %s: %w", group, err)
+	}
+	return nil
+}
+
+// maxContentSize returns the largest clipboard content synced in bytes, 0 for no limit
+func (m *Manager) maxContentSize() int64 {
+	return int64(m.config.MaxClipboardSizeKB) * 1024
 }
 
 // SetContentHandler sets the handler for incoming content
@@ -128,19 +199,63 @@ func (m *Manager) SetContentHandler(handler types.ContentCallback) {
 	m.logger.Debug("Content handler set")
 }
 
+// SetGroupJoinHandler sets the handler called when a peer joins a group
+// this device is in, such as a device coming back online
+func (m *Manager) SetGroupJoinHandler(handler GroupJoinCallback) {
+	m.handlerMutex.Lock()
+	defer m.handlerMutex.Unlock()
+	
+	m.joinHandler = handler
+}
+
+// GroupPeerCount returns the number of peers known to be in a group,
+// who receive what is published to it
+func (m *Manager) GroupPeerCount(group string) int {
+	if !m.started {
+		return 0
+	}
+	return len(m.node.TopicPeers(group))
+}
+
+// SetHistory sets the history reconciled with paired devices and the handler
+// for items received by reconciliation. Those items were missed rather than
+// just copied, so the handler should store them without touching the clipboard.
+func (m *Manager) SetHistory(source HistorySource, handler types.ContentCallback) {
+	m.node.history.SetHistory(source, func(content *types.ClipboardContent, from peer.ID) {
+		handler(content, m.peerInfo(from))
+	})
+	m.logger.Debug("History set for reconciliation")
+}
+
 // JoinGroup joins a group
 func (m *Manager) JoinGroup(group string) error {
 	if !m.started {
 		return fmt.Errorf("sync manager not started")
 	}
 	
+	m.groupMutex.Lock()
+	defer m.groupMutex.Unlock()
+	
+	// Already joined and reading
+	if m.node.Joined(group) {
+		return nil
+	}
+	
+	// Payloads are encrypted with the group key, create it if this is the first member
+	if _, err := m.node.keyring.Ensure(group); err != nil {
+		return fmt.Errorf("failed to get key for group %s: %w", group, err)
+	}
+	
 	// Join the topic
-	_, _, err := m.node.JoinTopic(group)
+	_, sub, err := m.node.JoinTopic(group)
 	if err != nil {
 		return fmt.Errorf("failed to join group %s: %w", group, err)
 	}
 	
-	// TODO: Set up subscription handling
+	go m.readGroup(group, sub)
+	if events, ok := m.node.TopicEvents(group); ok {
+		go m.readGroupEvents(group, events)
+	}
 	
 	return nil
 }
@@ -151,6 +266,9 @@ func (m *Manager) LeaveGroup(group string) error {
 		return fmt.Errorf("sync manager not started")
 	}
 	
+	m.groupMutex.Lock()
+	defer m.groupMutex.Unlock()
+	
 	// Leave the topic
 	err := m.node.LeaveTopic(group)
 	if err != nil {
@@ -160,19 +278,175 @@ func (m *Manager) LeaveGroup(group string) error {
 	return nil
 }
 
-// ListGroups lists the joined groups
-func (m *Manager) ListGroups() ([]string, error) {
+// Groups returns the groups this device is in and their routes
+func (m *Manager) Groups() []GroupMembership {
+	return m.groups.List()
+}
+
+// RejoinGroups joins every group this device is in, as when the daemon starts
+func (m *Manager) RejoinGroups() error {
+	var errs []error
+	for _, group := range m.groups.List() {
+		if err := m.JoinGroup(group.Name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CreateGroup creates a group with a new key, joins it and remembers it.
+// Connected paired devices receive the key so they can join it too.
+func (m *Manager) CreateGroup(name string, route GroupRoute) (GroupMembership, error) {
+	if !m.started {
+		return GroupMembership{}, fmt.Errorf("sync manager not started")
+	}
+	
+	group, err := m.groups.Create(m.node.keyring, name, route)
+	if err != nil {
+		return GroupMembership{}, err
+	}
+	if err := m.JoinGroup(name); err != nil {
+		return group, err
+	}
+	m.node.keyExchange.PushToPairedDevices()
+	return group, nil
+}
+
+// AddGroup joins a group another device created and remembers it, with the
+// key received from a paired device. For a group already joined, it
+// replaces the route.
+func (m *Manager) AddGroup(name string, route GroupRoute) (GroupMembership, error) {
+	if !m.started {
+		return GroupMembership{}, fmt.Errorf("sync manager not started")
+	}
+	
+	group, err := m.groups.Join(m.node.keyring, name, route)
+	if err != nil {
+		return GroupMembership{}, err
+	}
+	return group, m.JoinGroup(name)
+}
+
+// AddGroupMember makes a paired device a member of a group, which hands it
+// the group key so it can join the group. It reports whether the device
+// received the key now; if not, it does when it next connects.
+func (m *Manager) AddGroupMember(name string, peerID string) (bool, error) {
+	if !m.started {
+		return false, fmt.Errorf("sync manager not started")
+	}
+	id, err := m.pairedPeer(peerID)
+	if err != nil {
+		return false, err
+	}
+	
+	if _, err := m.node.keyring.Grant(name, peerID); err != nil {
+		return false, err
+	}
+	return m.node.keyExchange.PushIfConnected(id), nil
+}
+
+// RemoveGroupMember removes a device from a group. The group key is rotated
+// and handed to the remaining members, the device can't read the group's
+// content from then on. It returns the paired devices that received the
+// new key, the others do when they next connect.
+func (m *Manager) RemoveGroupMember(name string, peerID string) ([]string, error) {
 	if !m.started {
 		return nil, fmt.Errorf("sync manager not started")
 	}
+	key, ok := m.node.keyring.Current(name)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrNoGroupKey, name)
+	}
+	if !containsString(key.Members, peerID) {
+		return nil, fmt.Errorf("device %s is not a member of group %s", peerID, name)
+	}
+	
+	if _, err := m.node.keyring.Rotate(name, peerID); err != nil {
+		return nil, fmt.Errorf("failed to rotate key for group %s: %w", name, err)
+	}
+	return m.node.keyExchange.PushToPairedDevices(), nil
+}
+
+// pairedPeer decodes the peer ID of a paired device
+func (m *Manager) pairedPeer(peerID string) (peer.ID, error) {
+	id, err := peer.Decode(peerID)
+	if err != nil {
+		return "", fmt.Errorf("invalid peer ID: %w", err)
+	}
+	if !m.node.pairing.IsPaired(peerID) {
+		return "", fmt.Errorf("device not paired: %s", peerID)
+	}
+	return id, nil
+}
+
+// RemoveGroup leaves a group and forgets it. The group key is kept, so the
+// group can be joined again.
+func (m *Manager) RemoveGroup(name string) error {
+	if err := m.groups.Remove(name); err != nil {
+		return err
+	}
+	if !m.started {
+		return nil
+	}
+	return m.LeaveGroup(name)
+}
+
+// GroupMembers returns the peers online in a group
+func (m *Manager) GroupMembers(name string) ([]types.PeerInfo, error) {
+	if _, ok := m.groups.Get(name); !ok {
+		return nil, fmt.Errorf("%w %q", ErrNotGroupMember, name)
+	}
+	if !m.started {
+		return nil, nil
+	}
 	
-	// Get the list of groups from topics
-	groups := make([]string, 0, len(m.node.topics))
-	for group := range m.node.topics {
-		groups = append(groups, group)
+	ids := m.node.TopicPeers(name)
+	members := make([]types.PeerInfo, 0, len(ids))
+	for _, id := range ids {
+		members = append(members, m.peerInfo(id))
+	}
+	return members, nil
+}
+
+// RouteContent returns the groups content is published to, by their routes
+// and sync policies
+func (m *Manager) RouteContent(content *types.ClipboardContent) []string {
+	now := time.Now()
+	var groups []string
+	for _, group := range m.groups.List() {
+		if group.Routes(content) && m.node.policies.Group(group.Name).Check(content, DirectionSend, now) == nil {
+			groups = append(groups, group.Name)
+		}
+	}
+	return groups
+}
+
+// Policies returns the sync policies of paired devices and groups
+func (m *Manager) Policies() Policies {
+	return m.node.policies.All()
+}
+
+// SetPeerPolicy sets the sync policy of a paired device, the zero policy
+// removes it
+func (m *Manager) SetPeerPolicy(peerID string, policy SyncPolicy) error {
+	if !m.node.pairing.IsPaired(peerID) {
+		return fmt.Errorf("device not paired: %s", peerID)
+	}
+	return m.node.policies.SetPeer(peerID, policy)
+}
+
+// SetGroupPolicy sets the sync policy of a group, the zero policy removes it
+func (m *Manager) SetGroupPolicy(name string, policy SyncPolicy) error {
+	return m.node.policies.SetGroup(name, policy)
+}
+
+// ListGroups lists the joined groups
+func (m *Manager) ListGroups() ([]string, error) {
+	if !m.started {
+		return nil, fmt.Errorf("sync manager not started")
 	}
 	
-	return groups, nil
+	return m.node.JoinedGroups(), nil
 }
 
 // GetDiscoveredPeers returns the list of discovered peers
@@ -281,6 +555,17 @@ func (m *Manager) RequestPairing(address string) (*types.PairingResponse, error)
 		return nil, fmt.Errorf("sync manager not started")
 	}
 	
+	// Let the device through the peer gater while pairing with it. Pairing
+	// with a revoked device again lifts its revocation.
+	if addrInfo, err := peer.AddrInfoFromString(address); err == nil {
+		if lifted, err := m.node.denied.Remove(addrInfo.ID.String()); err != nil {
+			return nil, fmt.Errorf("failed to lift revocation: %w", err)
+		} else if lifted {
+			m.logger.Info("Lifted revocation of device to pair with", zap.String("peer_id", addrInfo.ID.String()))
+		}
+		m.node.gater.Admit(addrInfo.ID, PairingRequestTimeout)
+	}
+	
 	// First, try to establish a connection with the peer
 	if err := m.node.AddPeerByAddress(address); err != nil {
 		return nil, fmt.Errorf("failed to connect to peer: %w", err)
@@ -292,6 +577,14 @@ func (m *Manager) RequestPairing(address string) (*types.PairingResponse, error)
 		return nil, err
 	}
 	
+	// The device may have kept its own default group key, which then wins
+	// over the one handed over, so send it back
+	if addrInfo, err := peer.AddrInfoFromString(address); err == nil && internalResponse.Accepted {
+		if err := m.node.keyExchange.PushKeys(addrInfo.ID); err != nil {
+			m.logger.Debug("Failed to push group keys to paired device", zap.String("peer_id", addrInfo.ID.String()), zap.Error(err))
+		}
+	}
+	
 	// Convert to external type
 	externalResponse := &types.PairingResponse{
 		Accepted:     internalResponse.Accepted,
@@ -339,19 +632,94 @@ func (m *Manager) GetPairedDevices() []types.PairedDevice {
 			DeviceType: device.DeviceType,
 			LastSeen:   device.LastSeen,
 			PairedAt:   device.PairedAt,
+			RevocationRequestedBy: device.RevocationRequestedBy,
 		})
 	}
 	
 	return devices
 }
 
-// RemovePairedDevice removes a paired device
+// RemovePairedDevice revokes a paired device, see RevokeDevice
 func (m *Manager) RemovePairedDevice(peerID string) error {
+	_, err := m.RevokeDevice(peerID)
+	return err
+}
+
+// RevokeDevice revokes a paired device. It is unpaired and denied at the
+// connection level, the key of every group is rotated and handed to the
+// remaining paired devices, and those are told to unpair and deny it too.
+// Paired devices that aren't connected are told, and receive the new keys,
+// when they next connect.
+func (m *Manager) RevokeDevice(peerID string) (RevocationResult, error) {
 	if !m.started {
-		return fmt.Errorf("sync manager not started")
+		return RevocationResult{}, fmt.Errorf("sync manager not started")
+	}
+	
+	id, err := peer.Decode(peerID)
+	if err != nil {
+		return RevocationResult{}, fmt.Errorf("invalid peer ID: %w", err)
+	}
+	revoked, err := m.node.revocation.Revoke(id)
+	if err != nil {
+		return RevocationResult{}, err
+	}
+	result := RevocationResult{
+		PeerID:      revoked.PeerID,
+		DeviceName:  revoked.DeviceName,
+		DeviceNames: make(map[string]string),
+	}
+	
+	// The device may have been added to any group by another member, so
+	// every group is rotated without it
+	for _, key := range m.node.keyring.Keys() {
+		if _, err := m.node.keyring.Rotate(key.Group, revoked.PeerID); err != nil {
+			return result, fmt.Errorf("failed to rotate key for group %s: %w", key.Group, err)
+		}
+		result.RotatedGroups = append(result.RotatedGroups, key.Group)
+	}
+	if len(result.RotatedGroups) > 0 {
+		result.KeysDelivered = m.node.keyExchange.PushToPairedDevices()
+	}
+	
+	result.Acknowledged, result.Confirming, result.Pending = m.node.revocation.Notify(revoked.PeerID)
+	for _, device := range m.node.pairing.GetPairedDevices() {
+		result.DeviceNames[device.PeerID] = device.DeviceName
+	}
+	return result, nil
+}
+
+// RevokedDevices returns the revoked devices, most recently revoked first
+func (m *Manager) RevokedDevices() []RevokedPeer {
+	return m.node.denied.List()
+}
+
+// SendFile starts sending a file to a paired device. The transfer runs in
+// the background, its progress is reported by GetTransfers.
+func (m *Manager) SendFile(peerID string, path string) (TransferInfo, error) {
+	if !m.started {
+		return TransferInfo{}, fmt.Errorf("sync manager not started")
 	}
 	
-	return m.node.pairing.RemovePairedDevice(peerID)
+	id, err := peer.Decode(peerID)
+	if err != nil {
+		return TransferInfo{}, fmt.Errorf("invalid peer ID: %w", err)
+	}
+	return m.node.files.SendFile(id, path)
+}
+
+// GetTransfers returns the running and recent file transfers, newest first
+func (m *Manager) GetTransfers() []TransferInfo {
+	return m.node.files.Transfers()
+}
+
+// RespondToFileOffer accepts or declines an incoming file waiting for confirmation
+func (m *Manager) RespondToFileOffer(transferID string, accept bool) error {
+	return m.node.files.Respond(transferID, accept)
+}
+
+// CancelTransfer stops a running file transfer
+func (m *Manager) CancelTransfer(transferID string) error {
+	return m.node.files.Cancel(transferID)
 }
 
 // GetConfig returns the sync configuration for application use
@@ -379,6 +747,7 @@ func (m *Manager) GetConfig() *types.SyncConfig {
 		RequireFileConfirmation: m.config.RequireFileConfirmation,
 		DefaultDownloadFolder:   m.config.DefaultDownloadFolder,
 		MaxFileSizeMB:           m.config.MaxFileSizeMB,
+		AutoAcceptFromPeers:     m.config.AutoAcceptFromPeers,
 		
 		AllowOnlyKnownPeers: m.config.AllowOnlyKnownPeers,
 		TrustedPeers:        m.config.TrustedPeers,
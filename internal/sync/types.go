@@ -45,14 +45,16 @@ func FromExternalPeerInfo(p types.PeerInfo) InternalPeerInfo {
 
 // SyncMessage represents a message exchanged between peers
 type SyncMessage struct {
-	Type        string            // Message type (content, control, etc.)
-	Source      peer.ID           // Source peer ID
-	Destination peer.ID           // Destination peer ID (empty for broadcast)
-	Group       string            // Target group (if applicable)
-	Timestamp   time.Time         // Message timestamp
-	ID          string            // Unique message ID
-	Payload     []byte            // Message payload
-	Headers     map[string]string // Message headers/metadata
+	Type        string            `json:"type"`                  // Message type (content, control, etc.)
+	Source      peer.ID           `json:"source"`                // Source peer ID
+	Destination peer.ID           `json:"destination,omitempty"` // Destination peer ID (empty for broadcast)
+	Group       string            `json:"group,omitempty"`       // Target group (if applicable)
+	Timestamp   time.Time         `json:"timestamp"`             // Message timestamp
+	ID          string            `json:"id"`                    // Unique message ID
+	Sequence    uint64            `json:"seq"`                   // Per-sender sequence number, increasing across restarts
+	Payload     []byte            `json:"payload,omitempty"`     // Message payload
+	Headers     map[string]string `json:"headers,omitempty"`     // Message headers/metadata
+	Exclude     []peer.ID         `json:"exclude,omitempty"`     // Peers the sender's sync policies don't serve the content to, advisory
 }
 
 // FileInfo provides information about a file being transferred
@@ -66,15 +68,33 @@ type FileInfo struct {
 
 // TransferInfo provides information about a file transfer
 type TransferInfo struct {
-	ID          string    // Unique transfer ID
-	FileName    string    // Name of the file
-	FileSize    int64     // Size of the file in bytes
-	Sender      string    // Sender peer ID
-	Recipient   string    // Recipient peer ID
-	Group       string    // Group context (if applicable)
-	StartTime   time.Time // When the transfer started
-	IsIncoming  bool      // Whether this is an incoming or outgoing transfer
-	Status      string    // "pending", "in_progress", "completed", "failed", "cancelled"
+	ID          string    `json:"id"`              // Unique transfer ID
+	FileName    string    `json:"file_name"`       // Name of the file
+	FileSize    int64     `json:"file_size"`       // Size of the file in bytes
+	Hash        string    `json:"hash"`            // Hex SHA-256 of the file
+	Path        string    `json:"path,omitempty"`  // Local path, the source or the received file
+	Sender      string    `json:"sender"`          // Sender peer ID
+	Recipient   string    `json:"recipient"`       // Recipient peer ID
+	Group       string    `json:"group,omitempty"` // Group context (if applicable)
+	StartTime   time.Time `json:"start_time"`      // When the transfer started
+	IsIncoming  bool      `json:"is_incoming"`     // Whether this is an incoming or outgoing transfer
+	Status      string    `json:"status"`          // "pending", "in_progress", "completed", "failed", "cancelled"
+	Transferred int64     `json:"transferred"`     // Bytes received or sent so far, including resumed ones
+	Error       string    `json:"error,omitempty"` // Why the transfer failed or was rejected
+}
+
+// Transfer statuses
+const (
+	TransferPending    = "pending"
+	TransferInProgress = "in_progress"
+	TransferCompleted  = "completed"
+	TransferFailed     = "failed"
+	TransferCancelled  = "cancelled"
+)
+
+// Finished reports whether a transfer has ended
+func (t TransferInfo) Finished() bool {
+	return t.Status == TransferCompleted || t.Status == TransferFailed || t.Status == TransferCancelled
 }
 
 // SyncError wraps errors from the sync package
@@ -0,0 +1,101 @@
+package tui
+
+import (
+	"unicode/utf8"
+)
+
+// keyKind identifies a key read from the terminal
+type keyKind int
+
+const (
+	keyRune keyKind = iota
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyEnter
+	keyEscape
+	keyBackspace
+	keyDelete
+	keyCtrlC
+	keyCtrlU
+	keyUnknown
+)
+
+// key is a key press; r is set for keyRune
+type key struct {
+	kind keyKind
+	r    rune
+}
+
+// escapeSequences maps the escape sequences of common terminals to keys
+var escapeSequences = map[string]keyKind{
+	"\x1b[A": keyUp, "\x1bOA": keyUp,
+	"\x1b[B": keyDown, "\x1bOB": keyDown,
+	"\x1b[5~": keyPageUp, "\x1b[6~": keyPageDown,
+	"\x1b[H": keyHome, "\x1bOH": keyHome, "\x1b[1~": keyHome, "\x1b[7~": keyHome,
+	"\x1b[F": keyEnd, "\x1bOF": keyEnd, "\x1b[4~": keyEnd, "\x1b[8~": keyEnd,
+	"\x1b[3~": keyDelete,
+}
+
+// parseKeys decodes the key presses in a chunk of raw terminal input
+func parseKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b:
+			n := escapeLength(b)
+			if n == 1 {
+				keys = append(keys, key{kind: keyEscape})
+			} else if kind, ok := escapeSequences[string(b[:n])]; ok {
+				keys = append(keys, key{kind: kind})
+			} else {
+				keys = append(keys, key{kind: keyUnknown})
+			}
+			b = b[n:]
+			continue
+		case c == '\r' || c == '\n':
+			keys = append(keys, key{kind: keyEnter})
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, key{kind: keyBackspace})
+		case c == 0x03:
+			keys = append(keys, key{kind: keyCtrlC})
+		case c == 0x15:
+			keys = append(keys, key{kind: keyCtrlU})
+		case c == 0x0e:
+			keys = append(keys, key{kind: keyDown}) // Ctrl-N
+		case c == 0x10:
+			keys = append(keys, key{kind: keyUp}) // Ctrl-P
+		case c < 0x20:
+			keys = append(keys, key{kind: keyUnknown})
+		default:
+			r, size := utf8.DecodeRune(b)
+			if r == utf8.RuneError {
+				keys = append(keys, key{kind: keyUnknown})
+			} else {
+				keys = append(keys, key{kind: keyRune, r: r})
+			}
+			b = b[size:]
+			continue
+		}
+		b = b[1:]
+	}
+	return keys
+}
+
+// escapeLength returns the length of the escape sequence at the start of b,
+// 1 for a lone escape key
+func escapeLength(b []byte) int {
+	if len(b) < 2 || (b[1] != '[' && b[1] != 'O') {
+		return 1
+	}
+	// A CSI or SS3 sequence ends with a byte in the range @ to ~
+	for i := 2; i < len(b); i++ {
+		if b[i] >= 0x40 && b[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(b)
+}
@@ -0,0 +1,276 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+// Item is a history item shown in the browser
+type Item struct {
+	Content *types.ClipboardContent
+	Size    int // Length of the data, also for images whose data isn't loaded
+}
+
+// mode is what key presses currently do
+type mode int
+
+const (
+	modeBrowse mode = iota
+	modeSearch
+	modeConfirmDelete
+)
+
+// action is work for the browser to do against its source after a key press
+type action int
+
+const (
+	actionNone action = iota
+	actionQuit
+	actionRestore
+	actionRestorePlain
+	actionTogglePin
+	actionDelete
+	actionReload
+)
+
+// model is the state of the browser
+type model struct {
+	items    []Item
+	search   []string // Lower-cased searchable text of each item
+	filtered []int    // Indexes of the items matching the query
+	cursor   int      // Position of the selected item in filtered
+	offset   int      // Position of the first visible row in filtered
+	rows     int      // Number of visible list rows
+	query    string
+	mode     mode
+	status   string
+}
+
+// setItems replaces the items, keeping the selection on the same item if it is still there
+func (m *model) setItems(items []Item) {
+	var selectedID string
+	if item, ok := m.selected(); ok {
+		selectedID = item.Content.ID()
+	}
+
+	m.items = items
+	m.search = make([]string, len(items))
+	for i, item := range items {
+		m.search[i] = searchText(item.Content)
+	}
+	m.filtered = nil
+	m.filter()
+
+	for i, index := range m.filtered {
+		if m.items[index].Content.ID() == selectedID {
+			m.cursor = i
+			break
+		}
+	}
+	m.scroll()
+}
+
+// filter selects the items that contain every word of the query. The
+// selection stays on the same item if it still matches, and moves to the
+// first match otherwise.
+func (m *model) filter() {
+	selected := -1
+	if m.cursor < len(m.filtered) {
+		selected = m.filtered[m.cursor]
+	}
+
+	words := strings.Fields(strings.ToLower(m.query))
+	m.filtered = m.filtered[:0]
+	m.cursor = 0
+	for i, text := range m.search {
+		if matchesAll(text, words) {
+			if i == selected {
+				m.cursor = len(m.filtered)
+			}
+			m.filtered = append(m.filtered, i)
+		}
+	}
+	m.scroll()
+}
+
+// selected returns the item under the cursor
+func (m *model) selected() (Item, bool) {
+	if m.cursor >= len(m.filtered) {
+		return Item{}, false
+	}
+	return m.items[m.filtered[m.cursor]], true
+}
+
+// remove drops the selected item after it was deleted
+func (m *model) remove() {
+	if m.cursor >= len(m.filtered) {
+		return
+	}
+	index := m.filtered[m.cursor]
+	m.items = append(m.items[:index], m.items[index+1:]...)
+	m.search = append(m.search[:index], m.search[index+1:]...)
+
+	// Select the item that took its place
+	cursor := m.cursor
+	m.filtered = nil
+	m.filter()
+	m.cursor = min(cursor, max(len(m.filtered)-1, 0))
+	m.scroll()
+}
+
+// move moves the cursor by delta items, staying within the list
+func (m *model) move(delta int) {
+	m.cursor = max(0, min(m.cursor+delta, len(m.filtered)-1))
+	m.scroll()
+}
+
+// scroll keeps the cursor within the visible rows
+func (m *model) scroll() {
+	if m.rows <= 0 {
+		return
+	}
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	} else if m.cursor >= m.offset+m.rows {
+		m.offset = m.cursor - m.rows + 1
+	}
+	m.offset = max(0, min(m.offset, len(m.filtered)-m.rows))
+}
+
+// handleKey updates the state for a key press and returns what to do next
+func (m *model) handleKey(k key) action {
+	if k.kind == keyCtrlC {
+		return actionQuit
+	}
+
+	switch m.mode {
+	case modeConfirmDelete:
+		m.mode = modeBrowse
+		if k.kind == keyRune && (k.r == 'y' || k.r == 'Y') {
+			return actionDelete
+		}
+		m.status = "Delete cancelled."
+		return actionNone
+	case modeSearch:
+		if m.handleSearchKey(k) {
+			return actionNone
+		}
+	}
+
+	m.status = ""
+	switch k.kind {
+	case keyUp:
+		m.move(-1)
+	case keyDown:
+		m.move(1)
+	case keyPageUp:
+		m.move(-max(m.rows-1, 1))
+	case keyPageDown:
+		m.move(max(m.rows-1, 1))
+	case keyHome:
+		m.move(-len(m.filtered))
+	case keyEnd:
+		m.move(len(m.filtered))
+	case keyEnter:
+		return m.itemAction(actionRestore)
+	case keyDelete:
+		return m.itemAction(actionDelete)
+	case keyEscape:
+		if m.query == "" {
+			return actionQuit
+		}
+		m.query = ""
+		m.filter()
+	case keyRune:
+		return m.handleBrowseRune(k.r)
+	}
+	return actionNone
+}
+
+// handleSearchKey edits the query, and reports whether the key was used
+func (m *model) handleSearchKey(k key) bool {
+	switch k.kind {
+	case keyRune:
+		m.query += string(k.r)
+	case keyBackspace:
+		if runes := []rune(m.query); len(runes) > 0 {
+			m.query = string(runes[:len(runes)-1])
+		}
+	case keyCtrlU:
+		m.query = ""
+	case keyEnter:
+		m.mode = modeBrowse
+		return true
+	case keyEscape:
+		m.query = ""
+		m.mode = modeBrowse
+	default:
+		// Keep navigation keys working while typing
+		return false
+	}
+	m.filter()
+	return true
+}
+
+// handleBrowseRune handles the single-letter commands
+func (m *model) handleBrowseRune(r rune) action {
+	switch r {
+	case 'q':
+		return actionQuit
+	case '/':
+		m.mode = modeSearch
+	case 'j':
+		m.move(1)
+	case 'k':
+		m.move(-1)
+	case 'g':
+		m.move(-len(m.filtered))
+	case 'G':
+		m.move(len(m.filtered))
+	case 't':
+		return m.itemAction(actionRestorePlain)
+	case 'p':
+		return m.itemAction(actionTogglePin)
+	case 'd':
+		return m.itemAction(actionDelete)
+	case 'r':
+		return actionReload
+	}
+	return actionNone
+}
+
+// itemAction returns an action on the selected item, asking first before deleting
+func (m *model) itemAction(a action) action {
+	if _, ok := m.selected(); !ok {
+		return actionNone
+	}
+	if a == actionDelete {
+		m.mode = modeConfirmDelete
+		m.status = "Delete this item? (y/n)"
+		return actionNone
+	}
+	return a
+}
+
+// searchText returns the lower-cased text an item is searched by
+func searchText(content *types.ClipboardContent) string {
+	text := string(content.Type) + " " + string(content.Subtype)
+	if content.Type != types.TypeImage {
+		text += " " + content.Text()
+	}
+	for _, value := range content.Metadata {
+		text += " " + value
+	}
+	return strings.ToLower(text)
+}
+
+// matchesAll reports whether text contains every word
+func matchesAll(text string, words []string) bool {
+	for _, word := range words {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
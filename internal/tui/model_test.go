@@ -0,0 +1,126 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+func testItems() []Item {
+	now := time.Now()
+	newItem := func(contentType types.ContentType, data string, age time.Duration) Item {
+		return Item{
+			Content: &types.ClipboardContent{Type: contentType, Data: []byte(data), Created: now.Add(-age)},
+			Size:    len(data),
+		}
+	}
+	return []Item{
+		newItem(types.TypeText, "git push origin main", time.Minute),
+		newItem(types.TypeURL, "https://example.com/docs", time.Hour),
+		newItem(types.TypeText, "Meeting notes\nwith \x1b[31mcolor\x1b[0m", 2*time.Hour),
+		newItem(types.TypeText, "git status", 3*time.Hour),
+	}
+}
+
+func typeKeys(m *model, input string) action {
+	var last action
+	for _, k := range parseKeys([]byte(input)) {
+		last = m.handleKey(k)
+	}
+	return last
+}
+
+func TestModelSearchAndNavigation(t *testing.T) {
+	m := &model{rows: 2}
+	m.setItems(testItems())
+
+	typeKeys(m, "jj")
+	if item, _ := m.selected(); !strings.HasPrefix(string(item.Content.Data), "Meeting") {
+		t.Fatalf("expected the third item selected, got %q", item.Content.Data)
+	}
+	if m.offset != 1 {
+		t.Errorf("expected the list to scroll, offset %d", m.offset)
+	}
+
+	// Live search narrows the list with every key, words match in any order
+	typeKeys(m, "/git")
+	if len(m.filtered) != 2 {
+		t.Fatalf("expected 2 matches for git, got %d", len(m.filtered))
+	}
+	typeKeys(m, " main")
+	if len(m.filtered) != 1 {
+		t.Fatalf("expected 1 match for 'git main', got %d", len(m.filtered))
+	}
+	typeKeys(m, "\x7f\x7f\x7f\x7fstat\r")
+	if item, _ := m.selected(); string(item.Content.Data) != "git status" {
+		t.Errorf("expected git status selected, got %q", item.Content.Data)
+	}
+	if m.mode != modeBrowse || m.query != "git stat" {
+		t.Errorf("enter should end search keeping the query, mode %d query %q", m.mode, m.query)
+	}
+
+	if a := typeKeys(m, "\r"); a != actionRestore {
+		t.Errorf("expected enter to restore, got %d", a)
+	}
+	if a := typeKeys(m, "t"); a != actionRestorePlain {
+		t.Errorf("expected t to restore as plain text, got %d", a)
+	}
+
+	// Escape clears the filter first, then quits
+	if a := typeKeys(m, "\x1b"); a != actionNone || len(m.filtered) != 4 {
+		t.Errorf("expected escape to clear the filter, got action %d with %d items", a, len(m.filtered))
+	}
+	if item, _ := m.selected(); string(item.Content.Data) != "git status" {
+		t.Errorf("selection should stay on git status after clearing, got %q", item.Content.Data)
+	}
+	if a := typeKeys(m, "\x1b"); a != actionQuit {
+		t.Errorf("expected escape to quit, got %d", a)
+	}
+}
+
+func TestModelDelete(t *testing.T) {
+	m := &model{rows: 10}
+	m.setItems(testItems())
+
+	if a := typeKeys(m, "dn"); a != actionNone || len(m.items) != 4 {
+		t.Fatalf("delete should need confirmation")
+	}
+	if a := typeKeys(m, "dy"); a != actionDelete {
+		t.Fatalf("expected delete after confirmation, got %d", a)
+	}
+	m.remove()
+	if len(m.items) != 3 || len(m.filtered) != 3 {
+		t.Errorf("expected 3 items after delete, got %d", len(m.items))
+	}
+}
+
+func TestRenderSanitizesContent(t *testing.T) {
+	m := &model{}
+	m.setItems(testItems())
+	typeKeys(m, "G")
+
+	for _, width := range []int{40, 120} {
+		screen := m.render(width, 12)
+		if strings.Contains(screen, "\x1b[31m") {
+			t.Errorf("escape sequences from content reached the terminal at width %d", width)
+		}
+		if lines := strings.Count(screen, "\r\n"); lines != 11 {
+			t.Errorf("expected 11 full lines at width %d, got %d", width, lines)
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys := parseKeys([]byte("a\x1b[A\x1b[6~\x1bé\r"))
+	want := []key{{kind: keyRune, r: 'a'}, {kind: keyUp}, {kind: keyPageDown}, {kind: keyEscape}, {kind: keyRune, r: 'é'}, {kind: keyEnter}}
+	if len(keys) != len(want) {
+		t.Fatalf("got %v, want %v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("key %d: got %v, want %v", i, keys[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+// Terminal control sequences
+const (
+	seqHome      = "\x1b[H"
+	seqClearLine = "\x1b[K"
+	seqReverse   = "\x1b[7m"
+	seqBold      = "\x1b[1m"
+	seqDim       = "\x1b[2m"
+	seqReset     = "\x1b[0m"
+)
+
+// Screen layout
+const (
+	headerLines      = 2   // Title and search line
+	footerLines      = 1   // Status or key help
+	minPreviewWidth  = 30  // Narrower terminals only show the list
+	maxSummaryLength = 500 // Characters of an item considered for its list row
+	helpText         = "enter restore  t plain text  p pin  d delete  / search  r reload  q quit"
+)
+
+// render draws the whole screen for a terminal of the given size
+func (m *model) render(width, height int) string {
+	m.rows = max(height-headerLines-footerLines, 1)
+	m.scroll()
+
+	listWidth := width
+	previewWidth := 0
+	if width >= 2*minPreviewWidth+1 {
+		listWidth = width * 2 / 5
+		previewWidth = width - listWidth - 1
+	}
+
+	var preview []string
+	if item, ok := m.selected(); ok && previewWidth > 0 {
+		preview = previewLines(item, previewWidth, m.rows)
+	}
+
+	var b strings.Builder
+	b.WriteString(seqHome)
+
+	title := fmt.Sprintf(" Clipboard history: %d items", len(m.items))
+	if m.query != "" {
+		title += fmt.Sprintf(", %d matching", len(m.filtered))
+	}
+	writeLine(&b, seqReverse+pad(title, width)+seqReset)
+
+	switch {
+	case m.mode == modeSearch:
+		writeLine(&b, seqBold+"/"+seqReset+sanitize(m.query)+"█")
+	case m.query != "":
+		writeLine(&b, seqDim+"filter: "+seqReset+sanitize(m.query)+seqDim+"  (esc clears)"+seqReset)
+	default:
+		writeLine(&b, seqDim+"type / to search"+seqReset)
+	}
+
+	for row := 0; row < m.rows; row++ {
+		line := ""
+		if i := m.offset + row; i < len(m.filtered) {
+			line = listRow(m.items[m.filtered[i]], listWidth)
+			if i == m.cursor {
+				line = seqReverse + line + seqReset
+			}
+		} else {
+			line = strings.Repeat(" ", listWidth)
+		}
+		if previewWidth > 0 {
+			line += seqDim + "│" + seqReset
+			if row < len(preview) {
+				line += preview[row]
+			}
+		}
+		writeLine(&b, line)
+	}
+
+	footer := m.status
+	if footer == "" {
+		footer = helpText
+	}
+	b.WriteString(seqDim + truncate(footer, width) + seqReset + seqClearLine)
+	return b.String()
+}
+
+// writeLine writes one screen line, clearing what was left from the previous frame
+func writeLine(b *strings.Builder, line string) {
+	b.WriteString(line + seqClearLine + "\r\n")
+}
+
+// listRow formats an item as one line of the list
+func listRow(item Item, width int) string {
+	pin := " "
+	if item.Content.Pinned {
+		pin = "*"
+	}
+	prefix := fmt.Sprintf("%s%4s %-8s ", pin, shortAge(time.Since(item.Content.Created)), truncate(typeLabel(item.Content), 8))
+	return pad(prefix+summary(item), width)
+}
+
+// summary returns a one-line description of an item
+func summary(item Item) string {
+	content := item.Content
+	switch content.Type {
+	case types.TypeImage:
+		return fmt.Sprintf("%sx%s %s image, %s", content.Metadata[types.MetaWidth], content.Metadata[types.MetaHeight],
+			content.Metadata[types.MetaImageFormat], formatSize(item.Size))
+	case types.TypeFile:
+		paths := contentPaths(content)
+		if len(paths) == 1 {
+			return paths[0]
+		}
+		return fmt.Sprintf("%d files", len(paths))
+	}
+	return strings.Join(strings.Fields(sanitize(head(content.Text(), maxSummaryLength))), " ")
+}
+
+// previewLines renders the details and contents of an item for the preview
+// pane, at most height lines
+func previewLines(item Item, width, height int) []string {
+	content := item.Content
+	var lines []string
+	field := func(name, value string) {
+		lines = append(lines, seqBold+pad(name, 8)+seqReset+" "+truncate(sanitize(value), width-9))
+	}
+
+	field("ID", content.ID())
+	field("Type", typeLabel(content))
+	copied := content.Created.Local().Format("2006-01-02 15:04:05")
+	if age := shortAge(time.Since(content.Created)); age == "now" {
+		field("Copied", copied+" (just now)")
+	} else {
+		field("Copied", copied+" ("+age+" ago)")
+	}
+	field("Size", formatSize(item.Size))
+	if content.Pinned {
+		field("Pinned", "yes")
+	}
+
+	keys := make([]string, 0, len(content.Metadata))
+	for k := range content.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		field(k, content.Metadata[k])
+	}
+	lines = append(lines, "")
+
+	var body []string
+	switch content.Type {
+	case types.TypeImage:
+		body = []string{seqDim + "Images can't be shown in the terminal." + seqReset}
+	case types.TypeFile, types.TypeFilePath:
+		snapshots := make(map[string]bool)
+		for _, file := range content.Files {
+			snapshots[file.Path] = true
+		}
+		for _, path := range contentPaths(content) {
+			note := ""
+			if _, err := os.Stat(path); err != nil {
+				note = " (missing"
+				if snapshots[path] {
+					note += ", restorable from snapshot"
+				}
+				note += ")"
+			}
+			body = append(body, wrap(path+note, width, height)...)
+		}
+	case types.TypeHTML, types.TypeRTF:
+		body = append([]string{seqDim + "Plain text:" + seqReset}, wrap(content.Text(), width, height)...)
+	default:
+		body = wrap(content.Text(), width, height)
+	}
+
+	lines = append(lines, body...)
+	return lines[:min(len(lines), height)]
+}
+
+// typeLabel names the type of an item, with its subtype if it has one
+func typeLabel(content *types.ClipboardContent) string {
+	if content.Subtype != types.SubtypeNone {
+		return string(content.Subtype)
+	}
+	return string(content.Type)
+}
+
+// contentPaths returns the paths of a file item
+func contentPaths(content *types.ClipboardContent) []string {
+	if content.Type == types.TypeFilePath {
+		return []string{strings.TrimSpace(string(content.Data))}
+	}
+
+	var paths []string
+	json.Unmarshal(content.Data, &paths)
+	return paths
+}
+
+// wrap splits text into at most maxLines lines of at most width characters
+func wrap(text string, width, maxLines int) []string {
+	// Only what can be shown is processed, items can be large
+	text = head(text, width*maxLines)
+
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		runes := []rune(sanitize(line))
+		for len(runes) > width && len(lines) < maxLines {
+			lines = append(lines, string(runes[:width]))
+			runes = runes[width:]
+		}
+		if len(lines) >= maxLines {
+			break
+		}
+		lines = append(lines, string(runes))
+	}
+	return lines
+}
+
+// head returns at most the first n characters of text
+func head(text string, n int) string {
+	for i := range text {
+		if n == 0 {
+			return text[:i]
+		}
+		n--
+	}
+	return text
+}
+
+// sanitize replaces control characters, so clipboard content can't send
+// escape sequences to the terminal
+func sanitize(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return ' '
+		}
+		if unicode.IsControl(r) {
+			return '·'
+		}
+		return r
+	}, text)
+}
+
+// truncate shortens text to width characters
+func truncate(text string, width int) string {
+	if runes := []rune(text); len(runes) > width {
+		return string(runes[:max(width, 0)])
+	}
+	return text
+}
+
+// pad truncates or pads text with spaces to exactly width characters
+func pad(text string, width int) string {
+	text = truncate(text, width)
+	if n := len([]rune(text)); n < width {
+		text += strings.Repeat(" ", width-n)
+	}
+	return text
+}
+
+// shortAge formats a duration as a compact age such as 5m or 3d
+func shortAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	case d < 365*24*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+	return fmt.Sprintf("%dy", int(d.Hours()/24/365))
+}
+
+// formatSize formats a size in bytes for display
+func formatSize(size int) string {
+	switch {
+	case size < 1024:
+		return fmt.Sprintf("%d B", size)
+	case size < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%.1f MB", float64(size)/1024/1024)
+}
@@ -0,0 +1,8 @@
+package tui
+
+import (
+	"os"
+)
+
+// notifyResize does nothing on Windows, the size is checked on each key press
+func notifyResize(ch chan<- os.Signal) {}
@@ -0,0 +1,148 @@
+// Package tui implements the interactive history browser
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"golang.org/x/term"
+)
+
+// Source provides the history items and carries out the browser's actions
+type Source interface {
+	Items() ([]Item, error) // Most recent first
+	Restore(item Item, plainText bool) error
+	SetPinned(item Item, pinned bool) error
+	Delete(item Item) error
+}
+
+// Terminal modes used while the browser runs
+const (
+	seqEnterScreen = "\x1b[?1049h\x1b[?25l" // Alternate screen, hidden cursor
+	seqLeaveScreen = "\x1b[?25h\x1b[?1049l"
+)
+
+// Run shows the history browser on the terminal until the user quits or
+// restores an item. It returns a message describing what was restored, if anything.
+func Run(source Source, in, out *os.File) (string, error) {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(out.Fd())) {
+		return "", errors.New("the history browser needs an interactive terminal")
+	}
+
+	items, err := source.Items()
+	if err != nil {
+		return "", err
+	}
+	m := &model{}
+	m.setItems(items)
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", fmt.Errorf("failed to set up terminal: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	fmt.Fprint(out, seqEnterScreen)
+	defer fmt.Fprint(out, seqLeaveScreen)
+
+	input := make(chan []byte)
+	go readInput(in, input)
+
+	resized := make(chan os.Signal, 1)
+	notifyResize(resized)
+	defer signal.Stop(resized)
+
+	for {
+		width, height, err := term.GetSize(int(out.Fd()))
+		if err != nil {
+			width, height = 80, 24
+		}
+		fmt.Fprint(out, m.render(width, height))
+
+		var keys []key
+		select {
+		case b, ok := <-input:
+			if !ok {
+				return "", nil
+			}
+			keys = parseKeys(b)
+		case <-resized:
+		}
+
+		for _, k := range keys {
+			message, done := perform(source, m, m.handleKey(k))
+			if done {
+				return message, nil
+			}
+		}
+	}
+}
+
+// perform carries out an action, reporting whether the browser should exit
+func perform(source Source, m *model, a action) (string, bool) {
+	item, _ := m.selected()
+	switch a {
+	case actionQuit:
+		return "", true
+
+	case actionRestore, actionRestorePlain:
+		plain := a == actionRestorePlain
+		if err := source.Restore(item, plain); err != nil {
+			m.status = "Restore failed: " + err.Error()
+			return "", false
+		}
+		message := fmt.Sprintf("Restored %s item %s to the clipboard.", item.Content.Type, item.Content.ID())
+		if plain {
+			message = fmt.Sprintf("Restored item %s to the clipboard as plain text.", item.Content.ID())
+		}
+		return message, true
+
+	case actionTogglePin:
+		pinned := !item.Content.Pinned
+		if err := source.SetPinned(item, pinned); err != nil {
+			m.status = "Pin failed: " + err.Error()
+			return "", false
+		}
+		item.Content.Pinned = pinned
+		m.status = "Unpinned."
+		if pinned {
+			m.status = "Pinned, the item is kept when history is flushed."
+		}
+
+	case actionDelete:
+		if err := source.Delete(item); err != nil {
+			m.status = "Delete failed: " + err.Error()
+			return "", false
+		}
+		m.remove()
+		m.status = "Deleted."
+
+	case actionReload:
+		items, err := source.Items()
+		if err != nil {
+			m.status = "Reload failed: " + err.Error()
+			return "", false
+		}
+		m.setItems(items)
+		m.status = "Reloaded."
+	}
+	return "", false
+}
+
+// readInput sends chunks of terminal input until it can't be read
+func readInput(in *os.File, input chan<- []byte) {
+	defer close(input)
+	buf := make([]byte, 256)
+	for {
+		n, err := in.Read(buf)
+		if err != nil {
+			return
+		}
+		chunk := make([]byte, n)
+		copy(chunk, buf[:n])
+		input <- chunk
+	}
+}
@@ -3,6 +3,8 @@ package types
 import ( 
 	"time"
 	"bytes"
+	"fmt"
+	"os"
 )
 
 type ContentType string
@@ -19,12 +21,77 @@ const (
 	TypeRTF      ContentType = "rtf"
 )
 
+// ContentSubtype refines a content type, e.g. text that is JSON or source code
+type ContentSubtype string
+
+const (
+	SubtypeNone   ContentSubtype = ""
+	SubtypeCode   ContentSubtype = "code"
+	SubtypeJSON   ContentSubtype = "json"
+	SubtypeYAML   ContentSubtype = "yaml"
+	SubtypeXML    ContentSubtype = "xml"
+	SubtypeEmail  ContentSubtype = "email"
+	SubtypePhone  ContentSubtype = "phone"
+	SubtypeColor  ContentSubtype = "color"
+	SubtypeUUID   ContentSubtype = "uuid"
+	SubtypeShell  ContentSubtype = "shell"
+	SubtypeBase64 ContentSubtype = "base64"
+)
+
+// Metadata keys set by content classification
+const (
+	MetaLanguage    = "language"     // Source code language guess
+	MetaColorFormat = "color_format" // "hex", "rgb" or "hsl"
+	MetaDecodedSize = "decoded_size" // Size of decoded base64 data in bytes
+	MetaDecodedType = "decoded_type" // MIME type of decoded base64 data
+)
+
+// Metadata keys set by image processing
+const (
+	MetaWidth       = "width"        // Image width in pixels
+	MetaHeight      = "height"       // Image height in pixels
+	MetaImageFormat = "image_format" // Format the image was captured in, e.g. "jpeg"
+	MetaImageHash   = "image_hash"   // Perceptual hash as 16 hex digits
+	MetaImageGroup  = "image_group"  // Hash of the first of a group of similar images
+	MetaOriginalURL = "original_url" // URL as copied, before tracking parameters were removed
+)
+
 
 type ClipboardContent struct {
 	Type		ContentType
 	Data		[]byte
 	Created		time.Time
 	Compressed	bool
+	Subtype		ContentSubtype		`json:",omitempty"`
+	Metadata	map[string]string	`json:",omitempty"`
+	Thumbnail	[]byte			`json:",omitempty"` // PNG preview of images
+	PlainText	string			`json:",omitempty"` // Plain-text rendition of HTML and RTF
+	Markdown	string			`json:",omitempty"` // Markdown rendition of HTML and RTF
+	Files		[]FileSnapshot		`json:",omitempty"` // Snapshots of the files of file items
+	Pinned		bool			`json:",omitempty"` // Kept when history is flushed
+}
+
+// FileSnapshot records a copied file whose contents are kept in the blob area
+type FileSnapshot struct {
+	Path    string // Path the file was copied from
+	Size    int64
+	Mode    os.FileMode
+	ModTime time.Time
+	Hash    string // SHA-256 of the contents, naming the blob
+}
+
+// ID identifies the item in history. It is derived from the creation time,
+// which is also the storage key.
+func (c *ClipboardContent) ID() string {
+	return fmt.Sprintf("%016x", c.Created.UnixNano())
+}
+
+// Text returns the plain-text rendition of rich content, or the data itself
+func (c *ClipboardContent) Text() string {
+	if c.PlainText != "" {
+		return c.PlainText
+	}
+	return string(c.Data)
 }
 
 func (c1 *ClipboardContent) Equal(c2 *ClipboardContent) bool {
@@ -0,0 +1,40 @@
+package types
+
+import (
+	"time"
+)
+
+// TypeSnippet marks content that carries a snippet to paired devices rather
+// than clipboard data. It is never written to the clipboard or history.
+const TypeSnippet ContentType = "snippet"
+
+// Snippet is a named, reusable piece of text that may contain template variables
+type Snippet struct {
+	Name        string    `json:"name"`
+	Content     string    `json:"content"`
+	Description string    `json:"description,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Created     time.Time `json:"created"`
+	Updated     time.Time `json:"updated"`
+	Origin      string    `json:"origin,omitempty"`  // Device that made the last change
+	Deleted     bool      `json:"deleted,omitempty"` // Kept as a tombstone so deletions sync
+}
+
+// HasTag reports whether the snippet is tagged with tag
+func (s *Snippet) HasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Supersedes reports whether s is a newer version of other. Ties between
+// devices are broken by device ID so every device settles on the same version.
+func (s *Snippet) Supersedes(other *Snippet) bool {
+	if !s.Updated.Equal(other.Updated) {
+		return s.Updated.After(other.Updated)
+	}
+	return s.Origin > other.Origin
+}
@@ -47,6 +47,10 @@ type PairedDevice struct {
 	DeviceType string    // Type of device (desktop, mobile, etc.)
 	LastSeen   time.Time // When this device was last seen
 	PairedAt   time.Time // When the pairing was established
+
+	// RevocationRequestedBy lists the paired devices that revoked this one,
+	// awaiting confirmation on this device
+	RevocationRequestedBy []string
 }
 
 // PairingRequestCallback is called when a pairing request is received
@@ -101,12 +105,15 @@ type SyncConfig struct {
 	MaxClipboardSizeKB     int      
 	ClipboardHistorySize   int      
 	ClipboardBlacklistApps []string 
+	OutboxTTLMinutes       int      // How long content waits for a peer to come online before it is dropped
+	OutboxMaxSizeKB        int      // Space for content waiting for peers
 	
 	// File Transfer Options
 	EnableFileSharing       bool   
 	RequireFileConfirmation bool   
 	DefaultDownloadFolder   string 
 	MaxFileSizeMB           int   
+	AutoAcceptFromPeers     []string // Peer IDs whose files are accepted without confirmation
 	
 	// Privacy & Security
 	AllowOnlyKnownPeers bool    
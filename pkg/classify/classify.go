@@ -0,0 +1,166 @@
+// Package classify guesses what clipboard data is: its content type, and for
+// text a subtype such as JSON, a color or code in some language.
+//
+// Clipboard backends that read data without a type use Type. The monitor
+// classifies content once with Content, after transformers have run, and
+// the result is carried with the content from then on.
+package classify
+
+import (
+	"bytes"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+// metadataKeys are the metadata keys owned by classification
+var metadataKeys = []string{
+	types.MetaLanguage, types.MetaColorFormat, types.MetaDecodedSize, types.MetaDecodedType,
+}
+
+// Result is what classification found out about data
+type Result struct {
+	Type     types.ContentType
+	Subtype  types.ContentSubtype // Only for text
+	Metadata map[string]string    // Classification metadata, such as the language of code
+}
+
+// Data determines the most likely content type of data and, for text, its
+// subtype
+func Data(data []byte) Result {
+	if len(data) == 0 {
+		return Result{Type: types.TypeText}
+	}
+
+	// Look for image magic bytes first, binary data can look like anything else
+	if IsImage(data) {
+		return Result{Type: types.TypeImage}
+	}
+
+	text := strings.TrimSpace(string(data))
+	if isValidURL(text) {
+		return Result{Type: types.TypeURL}
+	}
+
+	// Recognizable text like JSON, code or email addresses stays text,
+	// even if it contains path separators or a dotted suffix
+	subtype, metadata := Text(data)
+	if subtype == types.SubtypeNone && !strings.Contains(text, "\n") && isLikelyFilePath(text) {
+		return Result{Type: types.TypeFilePath}
+	}
+	return Result{Type: types.TypeText, Subtype: subtype, Metadata: metadata}
+}
+
+// Type determines the most likely content type of data, for clipboard
+// backends that read data without one. It agrees with Data, but only looks
+// for a subtype when the text could be a file path.
+func Type(data []byte) types.ContentType {
+	if len(data) == 0 {
+		return types.TypeText
+	}
+	if IsImage(data) {
+		return types.TypeImage
+	}
+
+	text := strings.TrimSpace(string(data))
+	if isValidURL(text) {
+		return types.TypeURL
+	}
+	if !strings.Contains(text, "\n") && isLikelyFilePath(text) {
+		if subtype, _ := Text(data); subtype == types.SubtypeNone {
+			return types.TypeFilePath
+		}
+	}
+	return types.TypeText
+}
+
+// Content classifies text-like content: its type is set from its data, as
+// a URL, a file path or text, and text gets its subtype and classification
+// metadata. Metadata from other processing steps is left alone. Other
+// content only loses the classification of earlier data.
+func Content(content *types.ClipboardContent) {
+	if content == nil {
+		return
+	}
+
+	content.Subtype = types.SubtypeNone
+	for _, key := range metadataKeys {
+		delete(content.Metadata, key)
+	}
+
+	switch content.Type {
+	case types.TypeText, types.TypeString, types.TypeURL, types.TypeFilePath:
+	default:
+		return
+	}
+
+	result := Data(content.Data)
+	content.Type = result.Type
+	content.Subtype = result.Subtype
+	for key, value := range result.Metadata {
+		if content.Metadata == nil {
+			content.Metadata = make(map[string]string, len(result.Metadata))
+		}
+		content.Metadata[key] = value
+	}
+}
+
+// IsImage checks if the data has image magic bytes
+func IsImage(data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+
+	// Check for common image formats by their magic bytes
+	// PNG: 89 50 4E 47
+	if bytes.HasPrefix(data, []byte{0x89, 0x50, 0x4E, 0x47}) {
+		return true
+	}
+
+	// JPEG: FF D8 FF
+	if bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}) {
+		return true
+	}
+
+	// GIF: GIF87a or GIF89a
+	if bytes.HasPrefix(data, []byte("GIF87a")) || bytes.HasPrefix(data, []byte("GIF89a")) {
+		return true
+	}
+
+	// BMP: BM, then the file size and 4 reserved zero bytes
+	if bytes.HasPrefix(data, []byte{0x42, 0x4D}) && len(data) >= 14 &&
+		bytes.Equal(data[6:10], []byte{0, 0, 0, 0}) {
+		return true
+	}
+
+	return false
+}
+
+// isValidURL checks if the given string is a valid URL
+func isValidURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	// A valid URL should have a scheme and host
+	return u.Scheme != "" && u.Host != ""
+}
+
+// isLikelyFilePath checks if the string looks like a file path
+func isLikelyFilePath(s string) bool {
+	// Check if it has a file extension
+	if ext := filepath.Ext(s); ext != "" {
+		return true
+	}
+
+	// Check if it starts with a path separator or drive letter (for Windows)
+	if strings.HasPrefix(s, "/") || strings.HasPrefix(s, "\\") ||
+		(len(s) > 1 && s[1] == ':' && (s[0] >= 'A' && s[0] <= 'Z' || s[0] >= 'a' && s[0] <= 'z')) {
+		return true
+	}
+
+	// Check if it has path separators
+	return strings.Contains(s, "/") || strings.Contains(s, "\\")
+}
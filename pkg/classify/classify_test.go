@@ -0,0 +1,37 @@
+package classify
+
+import (
+	"testing"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+func TestDataAndType(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantType types.ContentType
+		subtype  types.ContentSubtype
+	}{
+		{"empty", "", types.TypeText, types.SubtypeNone},
+		{"url", " https://example.com/a?b=1 ", types.TypeURL, types.SubtypeNone},
+		{"absolute path", "/etc/hosts", types.TypeFilePath, types.SubtypeNone},
+		{"file name", "report.pdf", types.TypeFilePath, types.SubtypeNone},
+		{"json with slashes stays text", `{"path": "/etc/hosts"}`, types.TypeText, types.SubtypeJSON},
+		{"email stays text", "jane.doe@example.com", types.TypeText, types.SubtypeEmail},
+		{"multi-line text with a slash", "either/or\nboth", types.TypeText, types.SubtypeNone},
+		{"png", "\x89PNG\r\n\x1a\n", types.TypeImage, types.SubtypeNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Data([]byte(tt.data))
+			if result.Type != tt.wantType || result.Subtype != tt.subtype {
+				t.Errorf("Data(%q) = %s/%s, want %s/%s", tt.data, result.Type, result.Subtype, tt.wantType, tt.subtype)
+			}
+			if got := Type([]byte(tt.data)); got != tt.wantType {
+				t.Errorf("Type(%q) = %s, want %s", tt.data, got, tt.wantType)
+			}
+		})
+	}
+}
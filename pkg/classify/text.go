@@ -0,0 +1,328 @@
+package classify
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+// maxClassifySize is the largest text that is classified; larger content gets no subtype
+const maxClassifySize = 1024 * 1024
+
+var (
+	uuidPattern     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	emailPattern    = regexp.MustCompile(`^(?i:mailto:)?[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}$`)
+	hexColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+	rgbColorPattern = regexp.MustCompile(`^(?i)rgba?\(\s*\d{1,3}%?\s*[, ]\s*\d{1,3}%?\s*[, ]\s*\d{1,3}%?\s*([,/]\s*[\d.]+%?\s*)?\)$`)
+	hslColorPattern = regexp.MustCompile(`^(?i)hsla?\(\s*\d{1,3}(deg)?\s*[, ]\s*\d{1,3}%\s*[, ]\s*\d{1,3}%\s*([,/]\s*[\d.]+%?\s*)?\)$`)
+	phonePattern    = regexp.MustCompile(`^\+?[0-9 ().\-]{7,20}$`)
+	datePattern     = regexp.MustCompile(`^\d{4}[-/.]\d{1,2}[-/.]\d{1,2}$`)
+	dottedPattern   = regexp.MustCompile(`^[\d.]+$`) // IP addresses and version numbers
+	base64Pattern   = regexp.MustCompile(`^[A-Za-z0-9+/_\-]+={0,2}$`)
+	yamlLinePattern = regexp.MustCompile(`^\s*(- )?[A-Za-z0-9_."'\-]+:(\s|$)|^\s*- \S`)
+)
+
+// shellCommands are common command names that start a shell command line
+var shellCommands = map[string]bool{
+	"apt": true, "apt-get": true, "awk": true, "brew": true, "cargo": true, "cat": true,
+	"cd": true, "chmod": true, "chown": true, "cp": true, "curl": true, "dnf": true,
+	"docker": true, "echo": true, "export": true, "find": true, "git": true, "go": true,
+	"grep": true, "journalctl": true, "kill": true, "kubectl": true, "ls": true,
+	"make": true, "mkdir": true, "mv": true, "npm": true, "npx": true, "pacman": true,
+	"pip": true, "pip3": true, "ps": true, "python": true, "python3": true, "rm": true,
+	"rsync": true, "scp": true, "sed": true, "ssh": true, "sudo": true, "systemctl": true,
+	"tail": true, "tar": true, "touch": true, "wget": true, "yarn": true,
+}
+
+// proseCommands are command names that are also common English words. They
+// need shell-like arguments before text starting with them counts as a command.
+var proseCommands = map[string]bool{
+	"cat": true, "cd": true, "echo": true, "export": true, "find": true, "go": true,
+	"kill": true, "make": true, "tail": true, "touch": true,
+}
+
+// languageRule scores how much text looks like a programming language
+type languageRule struct {
+	language string
+	patterns []*regexp.Regexp
+}
+
+var languageRules = []languageRule{
+	{"go", compileAll(`(?m)^package \w+$`, `(?m)^func (\(\w+ \*?\w+\) )?\w+\(`, `:= `, `(?m)^import \(`, `\bfmt\.\w+\(`, `\berr != nil\b`)},
+	{"python", compileAll(`(?m)^\s*def \w+\(.*\):\s*$`, `(?m)^\s*(from \w+(\.\w+)* )?import \w+`, `\bself\.\w+`, `(?m)^\s*(elif|except)\b.*:\s*$`, `(?m)^if __name__ == `, `\bprint\(`)},
+	{"javascript", compileAll(`\bfunction\s+\w*\s*\(`, `(?m)^\s*(const|let|var) \w+ = `, `=> \{?`, `\bconsole\.log\(`, `\brequire\(['"]`, `(?m)^\s*export (default |const |function )`)},
+	{"typescript", compileAll(`(?m)^\s*(export )?interface \w+ \{`, `:\s*(string|number|boolean)(\[\])?\s*[;,)=]`, `(?m)^\s*(export )?type \w+ = `, `(?m)^import .* from ['"]`)},
+	{"java", compileAll(`\bpublic (static )?(class|void|final) `, `\bSystem\.out\.print`, `(?m)^import java\.`, `@Override\b`, `\bprivate (static )?(final )?\w+(<.*>)? \w+;`)},
+	{"c", compileAll(`(?m)^#include\s*[<"]`, `\bint main\s*\(`, `\bprintf\s*\(`, `\bmalloc\s*\(`, `(?m)^#define \w+`)},
+	{"cpp", compileAll(`\bstd::\w+`, `(?m)^#include <(iostream|vector|string|memory)>`, `\bcout\s*<<`, `(?m)^\s*(class|namespace) \w+\s*\{`, `\btemplate\s*<`)},
+	{"rust", compileAll(`(?m)^\s*(pub )?fn \w+(<.*>)?\(`, `\blet mut \w+`, `(?m)^\s*impl\b`, `(?m)^use \w+(::\w+)+`, `\bprintln!\(`, `(?m)^\s*#\[derive\(`)},
+	{"ruby", compileAll(`(?m)^\s*def \w+[?!]?(\(.*\))?\s*$`, `(?m)^\s*end\s*$`, `\bputs\b`, `(?m)^\s*require ['"]`, `\.each do \|`)},
+	{"php", compileAll(`<\?php`, `\$\w+\s*=`, `\bfunction \w+\(.*\$\w+`, `\becho\s+\$`)},
+	{"sql", compileAll(`(?i)\bselect\b.+\bfrom\b`, `(?i)\binsert\s+into\b`, `(?i)\bcreate\s+table\b`, `(?i)\bupdate\b.+\bset\b`, `(?i)\bwhere\b`, `(?i)\b(inner|left|right)\s+join\b`)},
+	{"shell", compileAll(`(?m)^#!/(usr/)?bin/(env )?(ba|z|da)?sh`, `(?m)^\s*(fi|done|esac)\s*$`, `(?m)^\s*if \[\[? .* \]\]?; then`, `\$\{\w+[:}]`, `(?m)^\s*for \w+ in .*; do`)},
+}
+
+// minLanguageScore is the number of matching patterns needed to call text code
+const minLanguageScore = 2
+
+func compileAll(patterns ...string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		compiled[i] = regexp.MustCompile(p)
+	}
+	return compiled
+}
+
+// Text guesses what kind of text data holds, and returns metadata such as
+// the language of code
+func Text(data []byte) (types.ContentSubtype, map[string]string) {
+	if len(data) == 0 || len(data) > maxClassifySize || !utf8.Valid(data) {
+		return types.SubtypeNone, nil
+	}
+
+	text := strings.TrimSpace(string(data))
+	if text == "" {
+		return types.SubtypeNone, nil
+	}
+	singleLine := !strings.Contains(text, "\n")
+
+	// Short single values first, they are cheap and unambiguous
+	if singleLine {
+		switch {
+		case uuidPattern.MatchString(text):
+			return types.SubtypeUUID, nil
+		case emailPattern.MatchString(text):
+			return types.SubtypeEmail, nil
+		case hexColorPattern.MatchString(text):
+			return types.SubtypeColor, map[string]string{types.MetaColorFormat: "hex"}
+		case rgbColorPattern.MatchString(text):
+			return types.SubtypeColor, map[string]string{types.MetaColorFormat: "rgb"}
+		case hslColorPattern.MatchString(text):
+			return types.SubtypeColor, map[string]string{types.MetaColorFormat: "hsl"}
+		case isPhoneNumber(text):
+			return types.SubtypePhone, nil
+		}
+	}
+
+	// Structured data formats
+	if isJSON(text) {
+		return types.SubtypeJSON, nil
+	}
+	if isXML(text) {
+		return types.SubtypeXML, nil
+	}
+
+	if metadata, ok := decodeBase64Metadata(text); ok {
+		return types.SubtypeBase64, metadata
+	}
+
+	if isShellCommand(text) {
+		return types.SubtypeShell, nil
+	}
+
+	if language := guessLanguage(text); language != "" {
+		return types.SubtypeCode, map[string]string{types.MetaLanguage: language}
+	}
+
+	if isYAML(text) {
+		return types.SubtypeYAML, nil
+	}
+
+	return types.SubtypeNone, nil
+}
+
+// isPhoneNumber reports whether text looks like a phone number
+func isPhoneNumber(text string) bool {
+	if !phonePattern.MatchString(text) || datePattern.MatchString(text) || dottedPattern.MatchString(text) {
+		return false
+	}
+
+	digits := 0
+	for _, r := range text {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	if digits < 7 || digits > 15 {
+		return false
+	}
+
+	// Bare digit runs are more often IDs or amounts than phone numbers
+	return strings.HasPrefix(text, "+") || strings.ContainsAny(text, " ().-")
+}
+
+// isJSON reports whether text is a JSON object or array
+func isJSON(text string) bool {
+	if !strings.HasPrefix(text, "{") && !strings.HasPrefix(text, "[") {
+		return false
+	}
+	return json.Valid([]byte(text))
+}
+
+// isXML reports whether text is a well-formed XML document (HTML is not XML here)
+func isXML(text string) bool {
+	if !strings.HasPrefix(text, "<") {
+		return false
+	}
+
+	decoder := xml.NewDecoder(strings.NewReader(text))
+	elements := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return elements > 0
+		}
+		if err != nil {
+			return false
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			if elements == 0 && strings.EqualFold(start.Name.Local, "html") {
+				return false
+			}
+			elements++
+		}
+	}
+}
+
+// decodeBase64Metadata reports whether text is a base64 blob and describes its decoded data
+func decodeBase64Metadata(text string) (map[string]string, bool) {
+	compact := strings.Join(strings.Fields(text), "")
+	if len(compact) < 16 || !base64Pattern.MatchString(compact) {
+		return nil, false
+	}
+
+	// Require a mix of character classes so plain words aren't mistaken for base64
+	var upper, lower, digit bool
+	for _, r := range compact {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= '0' && r <= '9':
+			digit = true
+		}
+	}
+	if !(upper && lower && (digit || strings.ContainsAny(compact, "+/="))) {
+		return nil, false
+	}
+
+	var decoded []byte
+	var err error
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if decoded, err = encoding.DecodeString(compact); err == nil {
+			break
+		}
+	}
+	if err != nil || len(decoded) == 0 {
+		return nil, false
+	}
+
+	return map[string]string{
+		types.MetaDecodedSize: strconv.Itoa(len(decoded)),
+		types.MetaDecodedType: http.DetectContentType(decoded),
+	}, true
+}
+
+// isShellCommand reports whether text is a short shell command line
+func isShellCommand(text string) bool {
+	lines := strings.Split(text, "\n")
+	if len(lines) > 5 {
+		return false
+	}
+
+	first := strings.TrimSpace(lines[0])
+	prompt := strings.HasPrefix(first, "$ ")
+	first = strings.TrimPrefix(first, "$ ")
+
+	fields := strings.Fields(first)
+	if len(fields) < 2 {
+		return false
+	}
+
+	// Skip leading environment assignments like FOO=bar cmd
+	for len(fields) > 1 && strings.Contains(fields[0], "=") && !strings.HasPrefix(fields[0], "=") {
+		fields = fields[1:]
+	}
+	if !shellCommands[fields[0]] || len(fields) < 2 {
+		return false
+	}
+	if prompt || !proseCommands[fields[0]] {
+		return true
+	}
+
+	for _, arg := range fields[1:] {
+		if strings.HasPrefix(arg, "-") || strings.ContainsAny(arg, "/|=~*$") || arg == "&&" {
+			return true
+		}
+	}
+	return false
+}
+
+// guessLanguage returns the most likely programming language of text, or ""
+func guessLanguage(text string) string {
+	// Obvious markers settle it immediately
+	switch {
+	case strings.HasPrefix(text, "<?php"):
+		return "php"
+	case strings.HasPrefix(text, "#!"):
+		firstLine, _, _ := strings.Cut(text, "\n")
+		for _, shell := range []string{"python", "ruby", "node", "php"} {
+			if strings.Contains(firstLine, shell) {
+				if shell == "node" {
+					return "javascript"
+				}
+				return shell
+			}
+		}
+		return "shell"
+	}
+
+	best, bestScore := "", 0
+	for _, rule := range languageRules {
+		score := 0
+		for _, pattern := range rule.patterns {
+			if pattern.MatchString(text) {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = rule.language, score
+		}
+	}
+
+	if bestScore < minLanguageScore {
+		return ""
+	}
+	return best
+}
+
+// isYAML reports whether text looks like a YAML document
+func isYAML(text string) bool {
+	if strings.HasPrefix(text, "---\n") {
+		return true
+	}
+
+	var lines, matching int
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		lines++
+		if yamlLinePattern.MatchString(line) || (matching > 0 && strings.HasPrefix(line, "  ")) {
+			matching++
+		}
+	}
+
+	// Most lines must be keys, list items or nested values
+	return lines >= 2 && matching*10 >= lines*8
+}
@@ -0,0 +1,71 @@
+package classify
+
+import (
+	"testing"
+
+	"github.com/berrythewa/clipman-daemon/internal/types"
+)
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		subtype  types.ContentSubtype
+		metaKey  string
+		metaWant string
+	}{
+		{"plain text", "Meeting notes for tomorrow", types.SubtypeNone, "", ""},
+		{"prose starting with a command name", "make sure to bring the slides", types.SubtypeNone, "", ""},
+		{"uuid", "123e4567-e89b-12d3-a456-426614174000", types.SubtypeUUID, "", ""},
+		{"email", "jane.doe@example.co.uk", types.SubtypeEmail, "", ""},
+		{"mailto", "mailto:jane@example.com", types.SubtypeEmail, "", ""},
+		{"hex color", "#ff8800", types.SubtypeColor, types.MetaColorFormat, "hex"},
+		{"rgb color", "rgb(255, 136, 0)", types.SubtypeColor, types.MetaColorFormat, "rgb"},
+		{"hsl color", "hsl(32, 100%, 50%)", types.SubtypeColor, types.MetaColorFormat, "hsl"},
+		{"phone", "+1 (555) 123-4567", types.SubtypePhone, "", ""},
+		{"date is not a phone", "2024-01-15", types.SubtypeNone, "", ""},
+		{"ip is not a phone", "192.168.100.200", types.SubtypeNone, "", ""},
+		{"json", `{"name": "clipman", "tags": [1, 2]}`, types.SubtypeJSON, "", ""},
+		{"xml", `<?xml version="1.0"?><note><to>Tove</to></note>`, types.SubtypeXML, "", ""},
+		{"yaml", "name: clipman\nversion: 1\ntags:\n  - sync\n  - history", types.SubtypeYAML, "", ""},
+		{"base64", "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk+M9QDwADhgGAWjR9awAAAABJRU5ErkJggg==", types.SubtypeBase64, types.MetaDecodedType, "image/png"},
+		{"shell", "git commit -m 'fix tests'", types.SubtypeShell, "", ""},
+		{"shell with prompt", "$ make build", types.SubtypeShell, "", ""},
+		{"go", "package main\n\nfunc main() {\n\tx := 1\n\tfmt.Println(x)\n}", types.SubtypeCode, types.MetaLanguage, "go"},
+		{"python", "def greet(name):\n    print(f\"hi {name}\")\n\nif __name__ == \"__main__\":\n    greet(\"x\")", types.SubtypeCode, types.MetaLanguage, "python"},
+		{"sql", "SELECT id, name FROM users WHERE active = 1", types.SubtypeCode, types.MetaLanguage, "sql"},
+		{"shebang", "#!/bin/bash\necho hi", types.SubtypeCode, types.MetaLanguage, "shell"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subtype, metadata := Text([]byte(tt.text))
+			if subtype != tt.subtype {
+				t.Fatalf("Text(%q) subtype = %q, want %q", tt.text, subtype, tt.subtype)
+			}
+			if tt.metaKey != "" && metadata[tt.metaKey] != tt.metaWant {
+				t.Errorf("metadata[%s] = %q, want %q", tt.metaKey, metadata[tt.metaKey], tt.metaWant)
+			}
+		})
+	}
+}
+
+func TestContentKeepsOtherMetadata(t *testing.T) {
+	content := &types.ClipboardContent{
+		Type:     types.TypeText,
+		Data:     []byte("#abc"),
+		Metadata: map[string]string{"original_url": "https://example.com", types.MetaLanguage: "go"},
+	}
+
+	Content(content)
+
+	if content.Subtype != types.SubtypeColor {
+		t.Errorf("expected color subtype, got %q", content.Subtype)
+	}
+	if content.Metadata["original_url"] != "https://example.com" {
+		t.Error("classification removed unrelated metadata")
+	}
+	if _, ok := content.Metadata[types.MetaLanguage]; ok {
+		t.Error("stale classification metadata was kept")
+	}
+}
@@ -33,6 +33,13 @@ func CompressContent(content *types.ClipboardContent) (*types.ClipboardContent,
 		Data:       []byte(compressedData), // Store the base64-encoded data as []byte
 		Created:    content.Created,
 		Compressed: true,
+		Subtype:    content.Subtype,
+		Metadata:   content.Metadata,
+		Thumbnail:  content.Thumbnail,
+		PlainText:  content.PlainText,
+		Markdown:   content.Markdown,
+		Files:      content.Files,
+		Pinned:     content.Pinned,
 	}, nil
 }
 
@@ -62,5 +69,12 @@ func DecompressContent(content *types.ClipboardContent) (*types.ClipboardContent
 		Data:       uncompressed, // Use uncompressed []byte directly
 		Created:    content.Created,
 		Compressed: false,
+		Subtype:    content.Subtype,
+		Metadata:   content.Metadata,
+		Thumbnail:  content.Thumbnail,
+		PlainText:  content.PlainText,
+		Markdown:   content.Markdown,
+		Files:      content.Files,
+		Pinned:     content.Pinned,
 	}, nil
 }
@@ -0,0 +1,363 @@
+package richtext
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// skippedElements are dropped with their content. Scripts, styles and
+// embedded objects are never rendered.
+var skippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Iframe: true, atom.Object: true, atom.Embed: true, atom.Svg: true,
+	atom.Math: true, atom.Canvas: true, atom.Head: true, atom.Title: true,
+	atom.Meta: true, atom.Link: true, atom.Input: true, atom.Select: true,
+	atom.Textarea: true, atom.Button: true,
+}
+
+// paragraphElements are separated from their surroundings by a blank line
+var paragraphElements = map[atom.Atom]bool{
+	atom.P: true, atom.Figure: true, atom.Dl: true, atom.Details: true,
+	atom.Address: true, atom.Fieldset: true,
+}
+
+// blockElements start on a new line
+var blockElements = map[atom.Atom]bool{
+	atom.Div: true, atom.Section: true, atom.Article: true, atom.Header: true,
+	atom.Footer: true, atom.Main: true, atom.Aside: true, atom.Nav: true,
+	atom.Figcaption: true, atom.Dt: true, atom.Dd: true, atom.Summary: true,
+	atom.Form: true, atom.Caption: true, atom.Center: true,
+}
+
+// FromHTML renders HTML as plain text and Markdown, dropping scripts,
+// styles and unsafe links
+func FromHTML(data []byte) (Renditions, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return Renditions{}, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	text := &htmlConverter{}
+	markdown := &htmlConverter{markdown: true}
+	return Renditions{
+		PlainText: finish(text.children(doc), false),
+		Markdown:  finish(markdown.children(doc), true),
+	}, nil
+}
+
+// htmlConverter renders an HTML tree as plain text or Markdown
+type htmlConverter struct {
+	markdown bool
+}
+
+func (c *htmlConverter) children(n *html.Node) string {
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(c.node(child))
+	}
+	return b.String()
+}
+
+func (c *htmlConverter) node(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		text := collapseSpace(n.Data)
+		if c.markdown {
+			text = escapeMarkdown(text)
+		}
+		return text
+	case html.DocumentNode:
+		return c.children(n)
+	case html.ElementNode:
+		// Handled below
+	default:
+		return ""
+	}
+
+	if skippedElements[n.DataAtom] {
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		return "\n"
+
+	case atom.Hr:
+		if c.markdown {
+			return "\n\n---\n\n"
+		}
+		return "\n\n"
+
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		heading := strings.TrimSpace(strings.ReplaceAll(normalize(c.children(n)), "\n", " "))
+		if c.markdown && heading != "" {
+			level := int(n.Data[1] - '0')
+			heading = strings.Repeat("#", level) + " " + heading
+		}
+		return "\n\n" + heading + "\n\n"
+
+	case atom.Blockquote:
+		quote := normalize(c.children(n))
+		if c.markdown {
+			quote = prefixLines(quote, "> ")
+		}
+		return "\n\n" + quote + "\n\n"
+
+	case atom.Ul, atom.Ol:
+		// Nested lists continue their item without a blank line
+		if n.Parent != nil && n.Parent.DataAtom == atom.Li {
+			return "\n" + c.list(n) + "\n"
+		}
+		return "\n\n" + c.list(n) + "\n\n"
+
+	case atom.Li:
+		// A list item outside a list
+		return "\n- " + normalize(c.children(n)) + "\n"
+
+	case atom.Pre:
+		return "\n\n" + preOpen + codeLanguage(n) + "\n" + strings.Trim(textContent(n), "\n") + "\n" + preClose + "\n\n"
+
+	case atom.Code, atom.Kbd, atom.Samp:
+		code := collapseSpace(textContent(n))
+		if c.markdown {
+			return wrapInline(code, "`")
+		}
+		return code
+
+	case atom.Strong, atom.B:
+		return c.inline(n, "**")
+
+	case atom.Em, atom.I:
+		return c.inline(n, "_")
+
+	case atom.Del, atom.S, atom.Strike:
+		return c.inline(n, "~~")
+
+	case atom.A:
+		return c.link(n)
+
+	case atom.Img:
+		return c.image(n)
+
+	case atom.Table:
+		return "\n\n" + c.table(n) + "\n\n"
+	}
+
+	switch {
+	case paragraphElements[n.DataAtom]:
+		return "\n\n" + c.children(n) + "\n\n"
+	case blockElements[n.DataAtom]:
+		return "\n" + c.children(n) + "\n"
+	}
+	return c.children(n)
+}
+
+// inline renders inline formatting, adding Markdown markers around it
+func (c *htmlConverter) inline(n *html.Node, marker string) string {
+	content := c.children(n)
+	if c.markdown {
+		return wrapInline(content, marker)
+	}
+	return content
+}
+
+// link renders a link, keeping the target only if it is safe
+func (c *htmlConverter) link(n *html.Node) string {
+	content := c.children(n)
+	href := safeURL(attr(n, "href"))
+	if !c.markdown || href == "" || strings.TrimSpace(content) == "" {
+		return content
+	}
+	return linkMarkdown(content, href)
+}
+
+// linkMarkdown formats a Markdown link, keeping surrounding spaces outside
+func linkMarkdown(content, href string) string {
+	trimmed := strings.TrimSpace(content)
+	start := strings.Index(content, trimmed)
+	return content[:start] + "[" + trimmed + "](" + href + ")" + content[start+len(trimmed):]
+}
+
+// image renders an image as its alt text, or a Markdown image
+func (c *htmlConverter) image(n *html.Node) string {
+	alt := collapseSpace(attr(n, "alt"))
+	if !c.markdown {
+		return alt
+	}
+	src := safeURL(attr(n, "src"))
+	if src == "" || strings.HasPrefix(src, "#") {
+		return escapeMarkdown(alt)
+	}
+	return "![" + escapeMarkdown(alt) + "](" + src + ")"
+}
+
+// list renders the items of a ul or ol, indenting nested content
+func (c *htmlConverter) list(n *html.Node) string {
+	ordered := n.DataAtom == atom.Ol
+	number := 1
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil && ordered {
+		number = start
+	}
+
+	var items []string
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || child.DataAtom != atom.Li {
+			continue
+		}
+
+		marker := "- "
+		if ordered {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+		item := normalize(c.children(child))
+		items = append(items, marker+indentLines(item, len(marker)))
+	}
+	return strings.Join(items, "\n")
+}
+
+// table renders a table as a Markdown table, or tab-separated rows in plain text
+func (c *htmlConverter) table(n *html.Node) string {
+	var rows [][]string
+	headerRow := false
+
+	var collect func(*html.Node)
+	collect = func(node *html.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				collect(child)
+			case atom.Tr:
+				var cells []string
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type != html.ElementNode || (cell.DataAtom != atom.Td && cell.DataAtom != atom.Th) {
+						continue
+					}
+					if cell.DataAtom == atom.Th && len(rows) == 0 {
+						headerRow = true
+					}
+					text := strings.ReplaceAll(normalize(c.children(cell)), "\n", " ")
+					if c.markdown {
+						text = strings.ReplaceAll(text, "|", `\|`)
+					}
+					cells = append(cells, text)
+				}
+				if len(cells) > 0 {
+					rows = append(rows, cells)
+				}
+			}
+		}
+	}
+	collect(n)
+
+	if len(rows) == 0 {
+		return ""
+	}
+
+	if !c.markdown {
+		lines := make([]string, len(rows))
+		for i, row := range rows {
+			lines[i] = strings.Join(row, "\t")
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+
+	var lines []string
+	writeRow := func(row []string) {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+	}
+
+	// Markdown tables need a header, use an empty one if the table has none
+	if headerRow {
+		writeRow(rows[0])
+		rows = rows[1:]
+	} else {
+		writeRow(nil)
+	}
+	lines = append(lines, "|"+strings.Repeat(" --- |", columns))
+	for _, row := range rows {
+		writeRow(row)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// codeLanguage returns the language of a pre block from a language-* or
+// lang-* class on it or its code element
+func codeLanguage(n *html.Node) string {
+	for _, node := range []*html.Node{n, n.FirstChild} {
+		if node == nil || node.Type != html.ElementNode {
+			continue
+		}
+		for _, class := range strings.Fields(attr(node, "class")) {
+			for _, prefix := range []string{"language-", "lang-"} {
+				if language, ok := strings.CutPrefix(class, prefix); ok {
+					return language
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// textContent returns the text of n and its descendants without collapsing whitespace
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	if n.Type == html.ElementNode && skippedElements[n.DataAtom] {
+		return ""
+	}
+	if n.Type == html.ElementNode && n.DataAtom == atom.Br {
+		return "\n"
+	}
+
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(textContent(child))
+	}
+	return b.String()
+}
+
+// attr returns the value of an attribute, or ""
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+// safeURL returns u if it is a web, mail or relative link, and "" for
+// anything that could run code such as javascript: or data: URLs
+func safeURL(u string) string {
+	if u == "" {
+		return ""
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	switch strings.ToLower(parsed.Scheme) {
+	case "", "http", "https", "mailto", "ftp":
+		return strings.NewReplacer("(", "%28", ")", "%29", " ", "%20").Replace(u)
+	}
+	return ""
+}
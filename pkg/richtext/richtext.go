@@ -0,0 +1,147 @@
+// Package richtext converts rich clipboard formats (HTML, RTF) into clean
+// plain-text and Markdown renditions.
+package richtext
+
+import (
+	"strings"
+)
+
+// Renditions holds the plain-text and Markdown versions of rich content
+type Renditions struct {
+	PlainText string
+	Markdown  string
+}
+
+// Markers used while rendering, resolved by finish. Indentation uses a
+// non-space rune so line trimming doesn't remove it, and preformatted
+// blocks are fenced so their whitespace is kept.
+const (
+	indentRune = "\x01"
+	preOpen    = "\x00pre"
+	preClose   = "\x00/pre"
+)
+
+// normalize trims lines and collapses blank lines, leaving preformatted blocks alone
+func normalize(s string) string {
+	lines := strings.Split(s, "\n")
+	out := make([]string, 0, len(lines))
+	inPre := false
+	blank := false
+
+	for _, line := range lines {
+		rest := strings.TrimLeft(line, indentRune+" \t")
+		indent := strings.Count(line[:len(line)-len(rest)], indentRune)
+
+		if strings.HasPrefix(rest, preOpen) || rest == preClose {
+			inPre = rest != preClose
+			out = append(out, strings.Repeat(indentRune, indent)+strings.TrimSpace(rest))
+			blank = false
+			continue
+		}
+		if inPre {
+			out = append(out, line)
+			continue
+		}
+
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		out = append(out, strings.Repeat(indentRune, indent)+rest)
+	}
+
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	return strings.Join(out, "\n")
+}
+
+// finish resolves the rendering markers. Preformatted blocks become code
+// fences in Markdown and plain lines otherwise.
+func finish(s string, markdown bool) string {
+	lines := strings.Split(normalize(s), "\n")
+	out := make([]string, 0, len(lines))
+
+	for _, line := range lines {
+		rest := strings.TrimLeft(line, indentRune)
+		indent := strings.Repeat(" ", len(line)-len(rest))
+
+		switch {
+		case strings.HasPrefix(rest, preOpen):
+			if markdown {
+				out = append(out, indent+"```"+strings.TrimPrefix(rest, preOpen))
+			}
+		case rest == preClose:
+			if markdown {
+				out = append(out, indent+"```")
+			}
+		default:
+			out = append(out, indent+strings.ReplaceAll(rest, indentRune, " "))
+		}
+	}
+
+	result := strings.Join(out, "\n")
+	if !markdown {
+		// Dropping fences can leave runs of blank lines
+		for strings.Contains(result, "\n\n\n") {
+			result = strings.ReplaceAll(result, "\n\n\n", "\n\n")
+		}
+	}
+	return strings.TrimSpace(result)
+}
+
+// indentLines indents every line after the first by n indentation units
+func indentLines(s string, n int) string {
+	return strings.ReplaceAll(s, "\n", "\n"+strings.Repeat(indentRune, n))
+}
+
+// prefixLines prefixes every line with prefix
+func prefixLines(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
+
+// collapseSpace replaces runs of whitespace with single spaces
+func collapseSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		switch r {
+		case ' ', '\t', '\n', '\r', '\f', '\u00a0':
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// escapeMarkdown escapes characters with a meaning in Markdown inline text
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`,
+)
+
+// wrapInline wraps the non-space part of s in marker, keeping surrounding spaces outside
+func wrapInline(s, marker string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return s
+	}
+	start := strings.Index(s, trimmed)
+	return s[:start] + marker + trimmed + marker + s[start+len(trimmed):]
+}
@@ -0,0 +1,91 @@
+package richtext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFromHTML(t *testing.T) {
+	input := `<html><head><title>Page</title><style>p { color: red }</style></head>
+<body>
+<script>alert("x")</script>
+<h2>Release  notes</h2>
+<p>Fixed <b>two</b> bugs in <a href="https://example.com/a">the parser</a>.
+<a href="javascript:alert(1)">Click</a></p>
+<ul><li>First</li><li>Second<ul><li>Nested</li></ul></li></ul>
+<pre><code class="language-go">if x {
+	return
+}</code></pre>
+<table><tr><th>Name</th><th>Size</th></tr><tr><td>a|b</td><td>1</td></tr></table>
+</body></html>`
+
+	renditions, err := FromHTML([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantText := "Release notes\n\n" +
+		"Fixed two bugs in the parser. Click\n\n" +
+		"- First\n- Second\n  - Nested\n\n" +
+		"if x {\n\treturn\n}\n\n" +
+		"Name\tSize\na|b\t1"
+	if renditions.PlainText != wantText {
+		t.Errorf("unexpected plain text:\n%q\nwant:\n%q", renditions.PlainText, wantText)
+	}
+
+	wantMarkdown := "## Release notes\n\n" +
+		"Fixed **two** bugs in [the parser](https://example.com/a). Click\n\n" +
+		"- First\n- Second\n  - Nested\n\n" +
+		"```go\nif x {\n\treturn\n}\n```\n\n" +
+		"| Name | Size |\n| --- | --- |\n| a\\|b | 1 |"
+	if renditions.Markdown != wantMarkdown {
+		t.Errorf("unexpected markdown:\n%q\nwant:\n%q", renditions.Markdown, wantMarkdown)
+	}
+
+	for _, unsafe := range []string{"alert", "color: red", "javascript", "Page"} {
+		if strings.Contains(renditions.PlainText, unsafe) || strings.Contains(renditions.Markdown, unsafe) {
+			t.Errorf("renditions contain %q", unsafe)
+		}
+	}
+}
+
+func TestFromHTMLEscapesMarkdown(t *testing.T) {
+	renditions, err := FromHTML([]byte(`<p>2 * 3 = [six]</p><img src="data:image/png;base64,AAAA" alt="chart">`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2 \\* 3 = \\[six\\]\n\nchart"; renditions.Markdown != want {
+		t.Errorf("got %q, want %q", renditions.Markdown, want)
+	}
+	if want := "2 * 3 = [six]\n\nchart"; renditions.PlainText != want {
+		t.Errorf("got %q, want %q", renditions.PlainText, want)
+	}
+}
+
+func TestFromRTF(t *testing.T) {
+	input := `{\rtf1\ansi\ansicpg1252\deff0{\fonttbl{\f0 Helvetica;}}{\colortbl;\red0\green0\blue0;}
+{\*\generator Writer;}\f0\fs24 Caf\'e9 {\b bold} and {\i italic}\par
+Smart \ldblquote quotes\rdblquote\tab tab\par
+Unicode \u8364? sign\par
+{\field{\*\fldinst HYPERLINK "https://example.com"}{\fldrslt link text}}\par
+}`
+
+	renditions, err := FromRTF([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantText := "Café bold and italic\nSmart “quotes”\ttab\nUnicode € sign\nlink text"
+	if renditions.PlainText != wantText {
+		t.Errorf("unexpected plain text:\n%q\nwant:\n%q", renditions.PlainText, wantText)
+	}
+
+	wantMarkdown := "Café **bold** and _italic_\nSmart “quotes”\ttab\nUnicode € sign\nlink text"
+	if renditions.Markdown != wantMarkdown {
+		t.Errorf("unexpected markdown:\n%q\nwant:\n%q", renditions.Markdown, wantMarkdown)
+	}
+
+	if _, err := FromRTF([]byte("not rtf")); err == nil {
+		t.Error("expected an error for data that isn't RTF")
+	}
+}
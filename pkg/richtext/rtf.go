@@ -0,0 +1,269 @@
+package richtext
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// rtfSkippedDestinations are groups whose content is not document text
+var rtfSkippedDestinations = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "info": true,
+	"pict": true, "header": true, "headerl": true, "headerr": true, "headerf": true,
+	"footer": true, "footerl": true, "footerr": true, "footerf": true,
+	"object": true, "objdata": true, "themedata": true, "colorschememapping": true,
+	"latentstyles": true, "datastore": true, "xmlnstbl": true, "listtable": true,
+	"listoverridetable": true, "rsidtbl": true, "generator": true, "fldinst": true,
+	"filetbl": true, "revtbl": true, "pgdsctbl": true, "operator": true,
+	"mmathPr": true, "listtext": true, "pntext": true,
+}
+
+// rtfSymbols maps control words to the text they stand for
+var rtfSymbols = map[string]string{
+	"par": "\n", "line": "\n", "sect": "\n\n", "page": "\n\n", "row": "\n",
+	"tab": "\t", "cell": "\t", "emdash": "—", "endash": "–", "bullet": "•",
+	"lquote": "‘", "rquote": "’", "ldblquote": "“", "rdblquote": "”",
+	"emspace": " ", "enspace": " ", "qmspace": " ",
+}
+
+// rtfState is the formatting state of an RTF group
+type rtfState struct {
+	skip     bool
+	bold     bool
+	italic   bool
+	ucSkip   int // Fallback characters after \uN
+	codepage *charmap.Charmap
+}
+
+// errNotRTF is returned for data that doesn't start like an RTF document
+var errNotRTF = errors.New("data is not RTF")
+
+// FromRTF renders an RTF document as plain text and Markdown. Bold and
+// italic runs are kept as emphasis in the Markdown rendition.
+func FromRTF(data []byte) (Renditions, error) {
+	if !strings.HasPrefix(strings.TrimSpace(string(data)), `{\rtf`) {
+		return Renditions{}, errNotRTF
+	}
+
+	return Renditions{
+		PlainText: finish(convertRTF(data, false), false),
+		Markdown:  finish(convertRTF(data, true), true),
+	}, nil
+}
+
+// convertRTF walks the RTF tokens, writing document text and, for
+// Markdown, emphasis markers where bold or italic changes
+func convertRTF(data []byte, markdown bool) string {
+	var out strings.Builder
+	state := rtfState{ucSkip: 1, codepage: charmap.Windows1252}
+	var stack []rtfState
+	var emitted rtfState // Emphasis currently open in the output
+	pendingSkip := 0     // Fallback characters still to skip after \uN
+	groupStart := false  // Just after {, where \* and destinations appear
+
+	// syncEmphasis closes Markdown emphasis that has ended and, unless only
+	// closing, opens emphasis that has started. Opening waits for visible
+	// text so markers don't enclose spaces.
+	syncEmphasis := func(closeOnly bool) {
+		if !markdown {
+			return
+		}
+		if emitted.italic && !state.italic {
+			out.WriteString("_")
+			emitted.italic = false
+		}
+		if emitted.bold && !state.bold {
+			out.WriteString("**")
+			emitted.bold = false
+		}
+		if closeOnly {
+			return
+		}
+		if state.bold && !emitted.bold {
+			out.WriteString("**")
+			emitted.bold = true
+		}
+		if state.italic && !emitted.italic {
+			out.WriteString("_")
+			emitted.italic = true
+		}
+	}
+
+	write := func(s string) {
+		if state.skip || s == "" {
+			return
+		}
+		if pendingSkip > 0 {
+			pendingSkip--
+			return
+		}
+		// Emphasis doesn't span line breaks in Markdown
+		if strings.HasPrefix(s, "\n") && (emitted.bold || emitted.italic) {
+			saved := state
+			state.bold, state.italic = false, false
+			syncEmphasis(true)
+			state = saved
+		} else {
+			syncEmphasis(strings.TrimSpace(s) == "")
+		}
+		if markdown && !strings.HasPrefix(s, "\n") && s != "\t" {
+			s = escapeMarkdown(s)
+		}
+		out.WriteString(s)
+	}
+
+	for i := 0; i < len(data); i++ {
+		ch := data[i]
+		switch ch {
+		case '{':
+			stack = append(stack, state)
+			groupStart = true
+			continue
+
+		case '}':
+			if len(stack) > 0 {
+				state = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+			groupStart = false
+			continue
+
+		case '\r', '\n':
+			continue
+
+		case '\\':
+			i++
+			if i >= len(data) {
+				continue
+			}
+			next := data[i]
+
+			switch {
+			case next == '*':
+				// Ignorable destination
+				if groupStart {
+					state.skip = true
+				}
+
+			case next == '\'':
+				// Hex-encoded byte in the document codepage
+				if i+2 < len(data) {
+					if b, err := strconv.ParseUint(string(data[i+1:i+3]), 16, 8); err == nil {
+						write(string(state.codepage.DecodeByte(byte(b))))
+					}
+					i += 2
+				}
+
+			case next == '\\' || next == '{' || next == '}':
+				write(string(next))
+
+			case next == '~':
+				write(" ")
+
+			case next == '_':
+				write("-")
+
+			case next == '-':
+				// Optional hyphen
+
+			case isASCIILetter(next):
+				// Control word with an optional numeric parameter
+				start := i
+				for i < len(data) && isASCIILetter(data[i]) {
+					i++
+				}
+				word := string(data[start:i])
+
+				paramStart := i
+				if i < len(data) && data[i] == '-' {
+					i++
+				}
+				for i < len(data) && data[i] >= '0' && data[i] <= '9' {
+					i++
+				}
+				param, hasParam := 0, i > paramStart
+				if hasParam {
+					param, _ = strconv.Atoi(string(data[paramStart:i]))
+				}
+
+				// A single space delimits the control word
+				if i >= len(data) || data[i] != ' ' {
+					i--
+				}
+
+				if groupStart && rtfSkippedDestinations[word] {
+					state.skip = true
+				}
+				groupStart = false
+
+				switch word {
+				case "b":
+					state.bold = !hasParam || param != 0
+				case "i":
+					state.italic = !hasParam || param != 0
+				case "plain":
+					state.bold, state.italic = false, false
+				case "uc":
+					state.ucSkip = param
+				case "u":
+					if param < 0 {
+						param += 65536
+					}
+					write(string(rune(param)))
+					if !state.skip {
+						pendingSkip = state.ucSkip
+					}
+				case "ansicpg":
+					if cp := windowsCodepage(param); cp != nil {
+						state.codepage = cp
+					}
+				default:
+					write(rtfSymbols[word])
+				}
+				continue
+			}
+			groupStart = false
+			continue
+		}
+
+		groupStart = false
+		write(string(state.codepage.DecodeByte(ch)))
+	}
+
+	state = rtfState{}
+	syncEmphasis(true)
+	return out.String()
+}
+
+// windowsCodepage returns the charmap for an \ansicpg number, or nil if unsupported
+func windowsCodepage(cp int) *charmap.Charmap {
+	switch cp {
+	case 1250:
+		return charmap.Windows1250
+	case 1251:
+		return charmap.Windows1251
+	case 1252:
+		return charmap.Windows1252
+	case 1253:
+		return charmap.Windows1253
+	case 1254:
+		return charmap.Windows1254
+	case 1255:
+		return charmap.Windows1255
+	case 1256:
+		return charmap.Windows1256
+	case 1257:
+		return charmap.Windows1257
+	case 1258:
+		return charmap.Windows1258
+	case 10000:
+		return charmap.Macintosh
+	}
+	return nil
+}
+
+func isASCIILetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
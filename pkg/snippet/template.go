@@ -0,0 +1,172 @@
+// Package snippet expands the template variables of text snippets.
+//
+// A variable is written {{name}} or {{name:argument}}:
+//
+//	{{date}}            current date, {{date:02/01/2006}} for a Go time layout
+//	{{time}}            current time, {{time:15:04}} for a Go time layout
+//	{{uuid}}            a new random UUID
+//	{{env:NAME}}        the environment variable NAME, as Context.Env returns it
+//	{{clipboard}}       the current clipboard text
+//	{{prompt:Label}}    a value asked from the user
+package snippet
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Context supplies the values of template variables
+type Context struct {
+	Now       time.Time              // Time for date and time, the current time if zero
+	Env       func(string) string    // Environment lookup, os.Getenv if nil
+	Clipboard func() (string, error) // Reads the current clipboard text
+	Values    map[string]string      // Answers to prompts, by label
+}
+
+// variable is one {{...}} reference in a template
+type variable struct {
+	name string
+	arg  string
+}
+
+// Expand replaces the variables in template with their values
+func Expand(template string, ctx Context) (string, error) {
+	if ctx.Now.IsZero() {
+		ctx.Now = time.Now()
+	}
+	if ctx.Env == nil {
+		ctx.Env = os.Getenv
+	}
+
+	var b strings.Builder
+	err := walk(template, func(text string) {
+		b.WriteString(text)
+	}, func(v variable) error {
+		value, err := ctx.value(v)
+		if err != nil {
+			return err
+		}
+		b.WriteString(value)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Prompts returns the labels of the prompt variables in template, in order
+// of first use, so the values can be asked before expanding
+func Prompts(template string) ([]string, error) {
+	var labels []string
+	seen := make(map[string]bool)
+	err := walk(template, func(string) {}, func(v variable) error {
+		if v.name == "prompt" && !seen[v.arg] {
+			seen[v.arg] = true
+			labels = append(labels, v.arg)
+		}
+		return nil
+	})
+	return labels, err
+}
+
+// Validate checks that template only uses known variables
+func Validate(template string) error {
+	return walk(template, func(string) {}, func(variable) error { return nil })
+}
+
+// walk splits template into literal text and variables
+func walk(template string, text func(string), visit func(variable) error) error {
+	for {
+		start := strings.Index(template, "{{")
+		if start < 0 {
+			text(template)
+			return nil
+		}
+		end := strings.Index(template[start+2:], "}}")
+		if end < 0 {
+			return fmt.Errorf("unclosed variable at %q", truncate(template[start:]))
+		}
+
+		text(template[:start])
+		v, err := parseVariable(template[start+2 : start+2+end])
+		if err != nil {
+			return err
+		}
+		if err := visit(v); err != nil {
+			return err
+		}
+		template = template[start+2+end+2:]
+	}
+}
+
+// parseVariable parses the inside of a {{...}} reference
+func parseVariable(ref string) (variable, error) {
+	name, arg, hasArg := strings.Cut(strings.TrimSpace(ref), ":")
+	v := variable{name: strings.ToLower(strings.TrimSpace(name)), arg: strings.TrimSpace(arg)}
+
+	switch v.name {
+	case "date", "time":
+	case "uuid", "clipboard":
+		if hasArg {
+			return v, fmt.Errorf("{{%s}} takes no argument", v.name)
+		}
+	case "env", "prompt":
+		if v.arg == "" {
+			return v, fmt.Errorf("{{%s}} needs an argument, e.g. {{%s:NAME}}", v.name, v.name)
+		}
+	default:
+		return v, fmt.Errorf("unknown variable {{%s}}", ref)
+	}
+	return v, nil
+}
+
+// value returns the value of a variable
+func (ctx Context) value(v variable) (string, error) {
+	switch v.name {
+	case "date":
+		return ctx.Now.Format(layoutOr(v.arg, "2006-01-02")), nil
+	case "time":
+		return ctx.Now.Format(layoutOr(v.arg, "15:04:05")), nil
+	case "uuid":
+		return uuid.NewString(), nil
+	case "env":
+		return ctx.Env(v.arg), nil
+	case "clipboard":
+		if ctx.Clipboard == nil {
+			return "", nil
+		}
+		text, err := ctx.Clipboard()
+		if err != nil {
+			return "", fmt.Errorf("failed to read clipboard: %w", err)
+		}
+		return text, nil
+	case "prompt":
+		value, ok := ctx.Values[v.arg]
+		if !ok {
+			return "", fmt.Errorf("no value given for prompt %q", v.arg)
+		}
+		return value, nil
+	}
+	return "", fmt.Errorf("unknown variable {{%s}}", v.name)
+}
+
+// layoutOr returns layout, or def if it is empty
+func layoutOr(layout, def string) string {
+	if layout == "" {
+		return def
+	}
+	return layout
+}
+
+// truncate shortens text for error messages
+func truncate(text string) string {
+	if len(text) > 20 {
+		return text[:20] + "..."
+	}
+	return text
+}
@@ -0,0 +1,57 @@
+package snippet
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestExpand(t *testing.T) {
+	ctx := Context{
+		Now:       time.Date(2024, 3, 9, 14, 5, 7, 0, time.UTC),
+		Env:       func(name string) string { return map[string]string{"USER": "ada"}[name] },
+		Clipboard: func() (string, error) { return "PR-1234", nil },
+		Values:    map[string]string{"Name": "Grace"},
+	}
+
+	got, err := Expand("Hi {{prompt:Name}}, re {{clipboard}} on {{date}} at {{ time:15:04 }} ({{date:02/01/2006}}).\n-- {{env:USER}}", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Hi Grace, re PR-1234 on 2024-03-09 at 14:05 (09/03/2024).\n-- ada"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	id, err := Expand("{{uuid}}", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		t.Errorf("expected a UUID, got %q", id)
+	}
+
+	if got, _ := Expand("no variables { here }", ctx); got != "no variables { here }" {
+		t.Errorf("plain text changed to %q", got)
+	}
+
+	ctx.Clipboard = func() (string, error) { return "", errors.New("no clipboard") }
+	for _, template := range []string{"{{nope}}", "{{prompt:Other}}", "{{env}}", "{{uuid:4}}", "unclosed {{date", "{{clipboard}}"} {
+		if _, err := Expand(template, ctx); err == nil {
+			t.Errorf("expected an error expanding %q", template)
+		}
+	}
+}
+
+func TestPrompts(t *testing.T) {
+	labels, err := Prompts("{{prompt:To}} {{date}} {{prompt:Subject}} {{prompt:To}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"To", "Subject"}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("got %v, want %v", labels, want)
+	}
+}